@@ -0,0 +1,107 @@
+// Package admission decides whether task requests may be scheduled.
+//
+// Requests pass through a chain of controllers that can modify them or deny
+// them with a reason, before they are handed to the scheduler.
+package admission
+
+import (
+	"fmt"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/config"
+	"github.com/eremetic-framework/eremetic/validation"
+)
+
+// DeniedError is returned when a request is rejected by an admission
+// controller.
+type DeniedError struct {
+	Controller string
+	Reason     string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("denied by %s: %s", e.Controller, e.Reason)
+}
+
+func deny(controller, format string, args ...interface{}) error {
+	return &DeniedError{
+		Controller: controller,
+		Reason:     fmt.Sprintf(format, args...),
+	}
+}
+
+// Controller admits a task request, possibly modifying it, or returns an
+// error explaining why it was rejected.
+type Controller interface {
+	Admit(req *eremetic.Request) error
+}
+
+// ControllerFunc adapts a function to the Controller interface.
+type ControllerFunc func(req *eremetic.Request) error
+
+// Admit calls f(req).
+func (f ControllerFunc) Admit(req *eremetic.Request) error {
+	return f(req)
+}
+
+// Chain runs controllers in order and stops at the first rejection. As
+// controllers may modify the request, it is validated again at the end.
+type Chain []Controller
+
+// Admit runs the request through all controllers of the chain.
+func (c Chain) Admit(req *eremetic.Request) error {
+	if len(c) == 0 {
+		return nil
+	}
+	for _, ctrl := range c {
+		if err := ctrl.Admit(req); err != nil {
+			return err
+		}
+	}
+	return validation.ValidateRequest(*req)
+}
+
+// NewChain builds the admission chain described by the configuration.
+// Mutations run first so that the policy rules see the final request.
+func NewChain(conf *config.AdmissionConfig) Chain {
+	var chain Chain
+
+	if m := conf.Defaults; !emptyMutation(m) {
+		chain = append(chain, Defaults(m))
+	}
+	if m := conf.Overrides; !emptyMutation(m) {
+		chain = append(chain, Overrides(m))
+	}
+	if conf.WebhookURL != "" {
+		chain = append(chain, NewWebhook(conf.WebhookURL, conf.WebhookTimeout, conf.WebhookFailOpen))
+	}
+	if p := NewPolicy(conf); !p.empty() {
+		chain = append(chain, p)
+	}
+
+	return chain
+}
+
+// Scheduler admits task requests before passing them on to the wrapped
+// scheduler.
+type Scheduler struct {
+	eremetic.Scheduler
+	controller Controller
+}
+
+// NewScheduler returns a scheduler that admits requests using the given
+// controller.
+func NewScheduler(s eremetic.Scheduler, c Controller) *Scheduler {
+	return &Scheduler{
+		Scheduler:  s,
+		controller: c,
+	}
+}
+
+// ScheduleTask admits the request and schedules it.
+func (s *Scheduler) ScheduleTask(req eremetic.Request) (string, error) {
+	if err := s.controller.Admit(&req); err != nil {
+		return "", err
+	}
+	return s.Scheduler.ScheduleTask(req)
+}
@@ -0,0 +1,170 @@
+package admission
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/config"
+	"github.com/eremetic-framework/eremetic/mock"
+	"github.com/eremetic-framework/eremetic/validation"
+)
+
+func request() eremetic.Request {
+	return eremetic.Request{
+		TaskCPUs:    0.5,
+		TaskMem:     128,
+		DockerImage: "registry.internal/team/app:1.0",
+		Command:     "echo hello",
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	Convey("Policy", t, func() {
+		Convey("Images", func() {
+			p := &Policy{
+				AllowedImages: []string{"registry.internal/*"},
+				DeniedImages:  []string{"*:latest"},
+			}
+			req := request()
+			So(p.Admit(&req), ShouldBeNil)
+
+			req.DockerImage = "registry.internal/team/app:latest"
+			So(p.Admit(&req), ShouldResemble, &DeniedError{
+				Controller: "policy",
+				Reason:     `image "registry.internal/team/app:latest" is not allowed`,
+			})
+
+			req.DockerImage = "busybox"
+			So(p.Admit(&req), ShouldNotBeNil)
+		})
+
+		Convey("Privileged", func() {
+			p := &Policy{DenyPrivileged: true}
+			req := request()
+			So(p.Admit(&req), ShouldBeNil)
+
+			req.Privileged = true
+			So(p.Admit(&req).Error(), ShouldEqual, "denied by policy: privileged containers are not allowed")
+		})
+
+		Convey("Host volumes", func() {
+			p := &Policy{DenyHostVolumes: true, AllowedHostPaths: []string{"/data/*"}}
+			req := request()
+			req.Volumes = []eremetic.Volume{{ContainerPath: "/data", HostPath: "/data/app"}}
+			So(p.Admit(&req), ShouldBeNil)
+
+			req.Volumes = append(req.Volumes, eremetic.Volume{ContainerPath: "/sock", HostPath: "/var/run/docker.sock"})
+			So(p.Admit(&req), ShouldNotBeNil)
+
+			p = &Policy{DenyHostVolumes: true}
+			req.Volumes = []eremetic.Volume{{ContainerPath: "/data", HostPath: "/data/app"}}
+			So(p.Admit(&req), ShouldNotBeNil)
+		})
+
+		Convey("Networks", func() {
+			p := &Policy{AllowedNetworks: []string{"BRIDGE"}}
+			req := request()
+			So(p.Admit(&req), ShouldBeNil)
+
+			req.Network = "HOST"
+			So(p.Admit(&req), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMutations(t *testing.T) {
+	Convey("Mutations", t, func() {
+		m := config.AdmissionMutation{
+			Network: "BRIDGE",
+			Labels:  map[string]string{"team": "unknown", "managed-by": "eremetic"},
+		}
+
+		Convey("Defaults only fill in empty fields", func() {
+			req := request()
+			req.Network = "HOST"
+			req.Labels = map[string]string{"team": "data"}
+
+			So(Defaults(m).Admit(&req), ShouldBeNil)
+			So(req.Network, ShouldEqual, "HOST")
+			So(req.Labels, ShouldResemble, map[string]string{"team": "data", "managed-by": "eremetic"})
+		})
+
+		Convey("Overrides replace values", func() {
+			req := request()
+			req.Network = "HOST"
+			req.Labels = map[string]string{"team": "data"}
+
+			So(Overrides(m).Admit(&req), ShouldBeNil)
+			So(req.Network, ShouldEqual, "BRIDGE")
+			So(req.Labels, ShouldResemble, map[string]string{"team": "unknown", "managed-by": "eremetic"})
+		})
+	})
+}
+
+func TestChain(t *testing.T) {
+	Convey("NewChain", t, func() {
+		Convey("An empty configuration admits everything", func() {
+			chain := NewChain(&config.AdmissionConfig{})
+			So(chain, ShouldBeEmpty)
+
+			req := eremetic.Request{}
+			So(chain.Admit(&req), ShouldBeNil)
+		})
+
+		Convey("Mutations run before the policy", func() {
+			chain := NewChain(&config.AdmissionConfig{
+				AllowedNetworks: []string{"USER"},
+				Overrides:       config.AdmissionMutation{Network: "USER"},
+			})
+			So(chain, ShouldHaveLength, 2)
+
+			req := request()
+			req.Network = "HOST"
+			So(chain.Admit(&req), ShouldBeNil)
+			So(req.Network, ShouldEqual, "USER")
+		})
+
+		Convey("Mutated requests are validated", func() {
+			chain := Chain{ControllerFunc(func(req *eremetic.Request) error {
+				req.TaskCPUs = 0
+				return nil
+			})}
+
+			req := request()
+			err := chain.Admit(&req)
+			So(err, ShouldHaveSameTypeAs, validation.Errors{})
+		})
+	})
+
+	Convey("Scheduler", t, func() {
+		var scheduled eremetic.Request
+		sched := &mock.Scheduler{
+			ScheduleTaskFn: func(req eremetic.Request) (string, error) {
+				scheduled = req
+				return "eremetic-task.1234", nil
+			},
+		}
+
+		Convey("Schedules admitted requests", func() {
+			s := NewScheduler(sched, Defaults(config.AdmissionMutation{Labels: map[string]string{"a": "b"}}))
+
+			id, err := s.ScheduleTask(request())
+			So(err, ShouldBeNil)
+			So(id, ShouldEqual, "eremetic-task.1234")
+			So(scheduled.Labels, ShouldResemble, map[string]string{"a": "b"})
+		})
+
+		Convey("Does not schedule denied requests", func() {
+			s := NewScheduler(sched, ControllerFunc(func(req *eremetic.Request) error {
+				return errors.New("no")
+			}))
+
+			_, err := s.ScheduleTask(request())
+			So(err, ShouldNotBeNil)
+			So(sched.ScheduleTaskInvoked, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,160 @@
+package admission
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/config"
+)
+
+// Policy rejects requests that break the configured rules.
+type Policy struct {
+	AllowedImages    []string
+	DeniedImages     []string
+	DenyPrivileged   bool
+	DenyHostVolumes  bool
+	AllowedHostPaths []string
+	AllowedNetworks  []string
+}
+
+// NewPolicy returns the policy described by the configuration.
+func NewPolicy(conf *config.AdmissionConfig) *Policy {
+	return &Policy{
+		AllowedImages:    conf.AllowedImages,
+		DeniedImages:     conf.DeniedImages,
+		DenyPrivileged:   conf.DenyPrivileged,
+		DenyHostVolumes:  conf.DenyHostVolumes,
+		AllowedHostPaths: conf.AllowedHostPaths,
+		AllowedNetworks:  conf.AllowedNetworks,
+	}
+}
+
+func (p *Policy) empty() bool {
+	return len(p.AllowedImages) == 0 && len(p.DeniedImages) == 0 &&
+		!p.DenyPrivileged && !p.DenyHostVolumes &&
+		len(p.AllowedHostPaths) == 0 && len(p.AllowedNetworks) == 0
+}
+
+// Admit checks the request against every rule of the policy.
+func (p *Policy) Admit(req *eremetic.Request) error {
+	if matchAny(p.DeniedImages, req.DockerImage) {
+		return deny("policy", "image %q is not allowed", req.DockerImage)
+	}
+	if len(p.AllowedImages) > 0 && !matchAny(p.AllowedImages, req.DockerImage) {
+		return deny("policy", "image %q does not match any of %s", req.DockerImage, strings.Join(p.AllowedImages, ", "))
+	}
+
+	if p.DenyPrivileged && req.Privileged {
+		return deny("policy", "privileged containers are not allowed")
+	}
+
+	for _, v := range req.Volumes {
+		if v.HostPath == "" {
+			continue
+		}
+		restricted := p.DenyHostVolumes || len(p.AllowedHostPaths) > 0
+		if restricted && !matchAny(p.AllowedHostPaths, v.HostPath) {
+			return deny("policy", "host volume %q is not allowed", v.HostPath)
+		}
+	}
+
+	if len(p.AllowedNetworks) > 0 {
+		network := req.Network
+		if network == "" {
+			network = "BRIDGE"
+		}
+		if !contains(p.AllowedNetworks, network) {
+			return deny("policy", "network %s is not allowed", network)
+		}
+	}
+
+	return nil
+}
+
+// Defaults returns a controller that fills in fields the request leaves
+// empty.
+func Defaults(m config.AdmissionMutation) Controller {
+	return ControllerFunc(func(req *eremetic.Request) error {
+		if req.Network == "" {
+			req.Network = m.Network
+		}
+		if req.DNS == "" {
+			req.DNS = m.DNS
+		}
+		if m.ForcePullImage {
+			req.ForcePullImage = true
+		}
+		req.Environment = merge(req.Environment, m.Environment, false)
+		req.Labels = merge(req.Labels, m.Labels, false)
+		return nil
+	})
+}
+
+// Overrides returns a controller that replaces the values of the request.
+func Overrides(m config.AdmissionMutation) Controller {
+	return ControllerFunc(func(req *eremetic.Request) error {
+		if m.Network != "" {
+			req.Network = m.Network
+		}
+		if m.DNS != "" {
+			req.DNS = m.DNS
+		}
+		if m.ForcePullImage {
+			req.ForcePullImage = true
+		}
+		req.Environment = merge(req.Environment, m.Environment, true)
+		req.Labels = merge(req.Labels, m.Labels, true)
+		return nil
+	})
+}
+
+func merge(dst, src map[string]string, replace bool) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string)
+	}
+	for k, v := range src {
+		if _, ok := dst[k]; ok && !replace {
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+func emptyMutation(m config.AdmissionMutation) bool {
+	return m.Network == "" && m.DNS == "" && !m.ForcePullImage &&
+		len(m.Environment) == 0 && len(m.Labels) == 0
+}
+
+// match reports whether s matches the pattern, where `*` matches any
+// sequence of characters.
+func match(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	re := regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+	return re.MatchString(s)
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if match(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/api"
+)
+
+const defaultWebhookTimeout = 5 * time.Second
+
+// WebhookReview is the document exchanged with an admission webhook. The
+// webhook receives the request and answers whether it is allowed. It may
+// return a patched request that replaces the original.
+type WebhookReview struct {
+	Request *api.RequestV1 `json:"request,omitempty"`
+	// Owner is the owner of the reviewed request. It can't be changed.
+	Owner   string `json:"owner,omitempty"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Webhook delegates admission to an external HTTP service.
+type Webhook struct {
+	URL      string
+	FailOpen bool
+	client   *http.Client
+}
+
+// NewWebhook returns a controller posting requests to the given url. The
+// timeout is given in seconds. When failOpen is set, requests are admitted
+// if the webhook can't be reached.
+func NewWebhook(url string, timeout int, failOpen bool) *Webhook {
+	t := defaultWebhookTimeout
+	if timeout > 0 {
+		t = time.Duration(timeout) * time.Second
+	}
+	return &Webhook{
+		URL:      url,
+		FailOpen: failOpen,
+		client:   &http.Client{Timeout: t},
+	}
+}
+
+// Admit asks the webhook to review the request.
+func (w *Webhook) Admit(req *eremetic.Request) error {
+	review, err := w.review(req)
+	if err != nil {
+		logrus.WithError(err).WithField("url", w.URL).Error("Admission webhook failed")
+		if w.FailOpen {
+			return nil
+		}
+		return deny("webhook", "unable to review request")
+	}
+
+	if !review.Allowed {
+		reason := review.Reason
+		if reason == "" {
+			reason = "request not allowed"
+		}
+		return deny("webhook", "%s", reason)
+	}
+
+	if review.Request != nil {
+		patched := api.RequestFromV1(*review.Request)
+		patched.Owner = req.Owner
+		patched.Template = req.Template
+		patched.TemplateVersion = req.TemplateVersion
+		*req = patched
+	}
+	return nil
+}
+
+func (w *Webhook) review(req *eremetic.Request) (*WebhookReview, error) {
+	r := api.RequestV1FromRequest(*req)
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(WebhookReview{Request: &r, Owner: req.Owner}); err != nil {
+		return nil, err
+	}
+
+	resp, err := w.client.Post(w.URL, "application/json", &buf)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code `%s`", resp.Status)
+	}
+
+	var review WebhookReview
+	if err := json.NewDecoder(resp.Body).Decode(&review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
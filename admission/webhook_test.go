@@ -0,0 +1,77 @@
+package admission
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWebhook(t *testing.T) {
+	Convey("Webhook", t, func() {
+		var received WebhookReview
+		var response interface{}
+		status := http.StatusOK
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&received)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(response)
+		}))
+		defer ts.Close()
+
+		Convey("Allowed requests are admitted unchanged", func() {
+			response = WebhookReview{Allowed: true}
+
+			req := request()
+			So(NewWebhook(ts.URL, 0, false).Admit(&req), ShouldBeNil)
+			So(received.Request.DockerImage, ShouldEqual, req.DockerImage)
+			So(req, ShouldResemble, request())
+		})
+
+		Convey("Rejections carry the reason of the webhook", func() {
+			response = WebhookReview{Allowed: false, Reason: "outside business hours"}
+
+			req := request()
+			err := NewWebhook(ts.URL, 0, false).Admit(&req)
+			So(err, ShouldResemble, &DeniedError{Controller: "webhook", Reason: "outside business hours"})
+		})
+
+		Convey("The webhook can patch the request", func() {
+			response = map[string]interface{}{
+				"allowed": true,
+				"request": map[string]interface{}{
+					"cpu":    1.0,
+					"mem":    256.0,
+					"image":  "registry.internal/team/app:1.0",
+					"labels": map[string]string{"cost-center": "42"},
+					"owner":  "mallory",
+				},
+			}
+
+			req := request()
+			req.Owner = "alice"
+			So(NewWebhook(ts.URL, 0, false).Admit(&req), ShouldBeNil)
+			So(req.TaskCPUs, ShouldEqual, 1.0)
+			So(req.Labels, ShouldResemble, map[string]string{"cost-center": "42"})
+			So(received.Owner, ShouldEqual, "alice")
+			So(req.Owner, ShouldEqual, "alice")
+		})
+
+		Convey("Failures", func() {
+			status = http.StatusInternalServerError
+
+			Convey("deny requests by default", func() {
+				req := request()
+				So(NewWebhook(ts.URL, 0, false).Admit(&req), ShouldNotBeNil)
+			})
+
+			Convey("admit requests when failing open", func() {
+				req := request()
+				So(NewWebhook(ts.URL, 0, true).Admit(&req), ShouldBeNil)
+			})
+		})
+	})
+}
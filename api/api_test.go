@@ -53,3 +53,40 @@ func TestAPI_V1_TaskV1FromTask_TaskFromV1(t *testing.T) {
 		t.Fatalf("Invalid conversion.\nExpected:\t%+v\nActual:\t%+v", ta, task)
 	}
 }
+
+func TestAPI_MergeRequestV1(t *testing.T) {
+	base := RequestV1{
+		TaskCPUs:    0.5,
+		TaskMem:     128,
+		DockerImage: "etl:1.0",
+		Command:     "run",
+		Environment: map[string]string{"MODE": "nightly", "DEBUG": "1"},
+		Labels:      map[string]string{"team": "data"},
+		Volumes:     []eremetic.Volume{{ContainerPath: "/data", HostPath: "/mnt/data"}},
+	}
+
+	merged, err := MergeRequestV1(base, []byte(`{
+		"cpu": 2,
+		"env": {"MODE": "backfill", "DEBUG": null},
+		"volumes": [{"container_path": "/tmp", "host_path": "/tmp"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := base
+	expected.TaskCPUs = 2
+	expected.Environment = map[string]string{"MODE": "backfill"}
+	expected.Volumes = []eremetic.Volume{{ContainerPath: "/tmp", HostPath: "/tmp"}}
+
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatalf("Invalid merge.\nExpected:\t%+v\nActual:\t%+v", expected, merged)
+	}
+	if base.Environment["MODE"] != "nightly" {
+		t.Fatal("Base request was modified")
+	}
+
+	if _, err := MergeRequestV1(base, []byte(`{"cpu": "many"}`)); err == nil {
+		t.Fatal("Expected an error for invalid overrides")
+	}
+}
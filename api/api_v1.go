@@ -1,6 +1,8 @@
 package api
 
 import (
+	"encoding/json"
+
 	"github.com/eremetic-framework/eremetic"
 )
 
@@ -35,6 +37,11 @@ type TaskV1 struct {
 	ForcePullImage    bool                       `json:"force_pull_image"`
 	Privileged        bool                       `json:"privileged"`
 	FetchURIs         []eremetic.URI             `json:"fetch"`
+	Owner             string                     `json:"owner"`
+	Template          string                     `json:"template,omitempty"`
+	TemplateVersion   int                        `json:"template_version,omitempty"`
+	Restartable       bool                       `json:"restartable,omitempty"`
+	Maintenance       *eremetic.Maintenance      `json:"maintenance,omitempty"`
 }
 
 // TaskV1FromTask is needed for Go versions < 1.8
@@ -70,6 +77,11 @@ func TaskV1FromTask(task *eremetic.Task) TaskV1 {
 		ForcePullImage:    task.ForcePullImage,
 		Privileged:        task.Privileged,
 		FetchURIs:         task.FetchURIs,
+		Owner:             task.Owner,
+		Template:          task.Template,
+		TemplateVersion:   task.TemplateVersion,
+		Restartable:       task.Restartable,
+		Maintenance:       task.Maintenance,
 	}
 }
 
@@ -106,6 +118,11 @@ func TaskFromV1(task *TaskV1) eremetic.Task {
 		ForcePullImage:    task.ForcePullImage,
 		Privileged:        task.Privileged,
 		FetchURIs:         task.FetchURIs,
+		Owner:             task.Owner,
+		Template:          task.Template,
+		TemplateVersion:   task.TemplateVersion,
+		Restartable:       task.Restartable,
+		Maintenance:       task.Maintenance,
 	}
 }
 
@@ -130,6 +147,7 @@ type RequestV1 struct {
 	Fetch             []eremetic.URI             `json:"fetch"`
 	ForcePullImage    bool                       `json:"force_pull_image"`
 	Privileged        bool                       `json:"privileged"`
+	Restartable       bool                       `json:"restartable,omitempty"`
 }
 
 // RequestFromV1 is needed for Go versions < 1.8
@@ -156,5 +174,141 @@ func RequestFromV1(req RequestV1) eremetic.Request {
 		Fetch:             req.Fetch,
 		ForcePullImage:    req.ForcePullImage,
 		Privileged:        req.Privileged,
+		Restartable:       req.Restartable,
+	}
+}
+
+// RequestV1FromRequest converts a request into its V1 representation. Plain
+// URIs of V0 requests are moved into the fetch list.
+func RequestV1FromRequest(req eremetic.Request) RequestV1 {
+	var fetch []eremetic.URI
+	for _, u := range req.URIs {
+		fetch = append(fetch, eremetic.URI{
+			URI:     u,
+			Extract: eremetic.IsArchive(u),
+		})
+	}
+	fetch = append(fetch, req.Fetch...)
+
+	return RequestV1{
+		TaskCPUs:          req.TaskCPUs,
+		TaskMem:           req.TaskMem,
+		DockerImage:       req.DockerImage,
+		Command:           req.Command,
+		Args:              req.Args,
+		Volumes:           req.Volumes,
+		VolumesFrom:       req.VolumesFrom,
+		Ports:             req.Ports,
+		Name:              req.Name,
+		Network:           req.Network,
+		DNS:               req.DNS,
+		Environment:       req.Environment,
+		MaskedEnvironment: req.MaskedEnvironment,
+		Labels:            req.Labels,
+		AgentConstraints:  req.AgentConstraints,
+		CallbackURI:       req.CallbackURI,
+		Fetch:             fetch,
+		ForcePullImage:    req.ForcePullImage,
+		Privileged:        req.Privileged,
+		Restartable:       req.Restartable,
 	}
 }
+
+// QuotaV1 defines the API V1 json-structure of a quota.
+type QuotaV1 struct {
+	Name       string               `json:"name"`
+	Owner      string               `json:"owner,omitempty"`
+	Labels     map[string]string    `json:"labels,omitempty"`
+	MaxRunning int                  `json:"max_running"`
+	MaxQueued  int                  `json:"max_queued"`
+	MaxCPUs    float64              `json:"max_cpu"`
+	MaxMem     float64              `json:"max_mem"`
+	Usage      *eremetic.QuotaUsage `json:"usage,omitempty"`
+}
+
+// QuotaV1FromQuota converts a quota into its V1 representation.
+func QuotaV1FromQuota(quota *eremetic.Quota) QuotaV1 {
+	return QuotaV1{
+		Name:       quota.Name,
+		Owner:      quota.Owner,
+		Labels:     quota.Labels,
+		MaxRunning: quota.MaxRunning,
+		MaxQueued:  quota.MaxQueued,
+		MaxCPUs:    quota.MaxCPUs,
+		MaxMem:     quota.MaxMem,
+	}
+}
+
+// QuotaFromV1 converts a V1 quota into the internal representation.
+func QuotaFromV1(quota *QuotaV1) eremetic.Quota {
+	return eremetic.Quota{
+		Name:       quota.Name,
+		Owner:      quota.Owner,
+		Labels:     quota.Labels,
+		MaxRunning: quota.MaxRunning,
+		MaxQueued:  quota.MaxQueued,
+		MaxCPUs:    quota.MaxCPUs,
+		MaxMem:     quota.MaxMem,
+	}
+}
+
+// TemplateV1 defines the API V1 json-structure of a task template.
+type TemplateV1 struct {
+	Name    string    `json:"name"`
+	Version int       `json:"version"`
+	Request RequestV1 `json:"request"`
+	// Versions lists the versions of the template that are kept, the
+	// current one included.
+	Versions []int `json:"versions,omitempty"`
+}
+
+// TemplateV1FromTemplate converts a template into its V1 representation.
+func TemplateV1FromTemplate(template *eremetic.Template) TemplateV1 {
+	var versions []int
+	for _, v := range template.History {
+		versions = append(versions, v.Version)
+	}
+	return TemplateV1{
+		Name:     template.Name,
+		Version:  template.Version,
+		Request:  RequestV1FromRequest(template.Request),
+		Versions: append(versions, template.Version),
+	}
+}
+
+// TemplateFromV1 converts a V1 template into the internal representation.
+func TemplateFromV1(template *TemplateV1) eremetic.Template {
+	return eremetic.Template{
+		Name:    template.Name,
+		Version: template.Version,
+		Request: RequestFromV1(template.Request),
+	}
+}
+
+// TemplateRequestV1 is a request for a task created from a template. The
+// overrides are merged into the request of the template. The latest version
+// of the template is used unless a version is given.
+type TemplateRequestV1 struct {
+	Template  string          `json:"template"`
+	Version   int             `json:"version,omitempty"`
+	Overrides json.RawMessage `json:"overrides,omitempty"`
+}
+
+// LeaderV1 defines the API V1 json-structure reporting the leader of the
+// Eremetic instances.
+type LeaderV1 struct {
+	// Leader is the address of the leader, empty if there is none.
+	Leader string `json:"leader"`
+	// IsLeader is true if the instance answering leads.
+	IsLeader bool `json:"is_leader"`
+	// Election is false when a single instance runs without election.
+	Election bool `json:"election"`
+}
+
+// FrameworkV1 defines the API V1 json-structure reporting the Mesos
+// framework Eremetic is registered as.
+type FrameworkV1 struct {
+	// ID is the framework ID granted by the master, empty before the
+	// framework registered.
+	ID string `json:"id"`
+}
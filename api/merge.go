@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+)
+
+// MergeRequestV1 deep-merges the overrides into a request. The overrides
+// follow JSON merge patch semantics (RFC 7386): objects are merged
+// recursively, null removes a value and everything else replaces it.
+func MergeRequestV1(req RequestV1, overrides json.RawMessage) (RequestV1, error) {
+	if len(overrides) == 0 {
+		return req, nil
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return req, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return req, err
+	}
+
+	var patch interface{}
+	if err := json.Unmarshal(overrides, &patch); err != nil {
+		return req, err
+	}
+
+	b, err = json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return req, err
+	}
+
+	var merged RequestV1
+	err = json.Unmarshal(b, &merged)
+	return merged, err
+}
+
+func mergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = mergePatch(d[k], v)
+	}
+	return d
+}
@@ -54,8 +54,12 @@ func newCustomTaskDB(c connector, file string) (*TaskDB, error) {
 	}
 
 	err = conn.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("tasks"))
-		return err
+		for _, name := range []string{"tasks", "quotas", "templates"} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return migrateIndexes(tx)
 	})
 	if err != nil {
 		return nil, err
@@ -71,14 +75,18 @@ func (db *TaskDB) Close() {
 	}
 }
 
-// Clean is used to delete the tasks bucket
+// Clean is used to delete the tasks bucket and its indexes
 func (db *TaskDB) Clean() error {
 	return db.conn.Update(func(tx *bolt.Tx) error {
+		if err := dropIndexes(tx); err != nil {
+			return err
+		}
 		return tx.DeleteBucket([]byte("tasks"))
 	})
 }
 
-// PutTask stores a requested task in the database
+// PutTask stores a requested task in the database and updates its index
+// entries in the same transaction.
 func (db *TaskDB) PutTask(task *eremetic.Task) error {
 	return db.conn.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte("tasks"))
@@ -92,7 +100,49 @@ func (db *TaskDB) PutTask(task *eremetic.Task) error {
 			return err
 		}
 
-		return b.Put([]byte(task.ID), encoded)
+		if err := unindexTask(tx, b, task.ID); err != nil {
+			return err
+		}
+		if err := b.Put([]byte(task.ID), encoded); err != nil {
+			return err
+		}
+		return indexTask(tx, task)
+	})
+}
+
+// UpdateTask applies fn to a task and stores it within a single
+// transaction, so that no other update can happen in between.
+func (db *TaskDB) UpdateTask(id string, fn func(*eremetic.Task) error) error {
+	return db.conn.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("tasks"))
+		if err != nil {
+			return err
+		}
+
+		task := eremetic.Task{ID: id}
+		if v := b.Get([]byte(id)); v != nil {
+			if err := json.Unmarshal(v, &task); err != nil {
+				return err
+			}
+		}
+		if err := fn(&task); err != nil {
+			return err
+		}
+		task.Revision++
+
+		encoded, err := eremetic.Encode(&task)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to encode task to byte-array.")
+			return err
+		}
+
+		if err := unindexTask(tx, b, id); err != nil {
+			return err
+		}
+		if err := b.Put([]byte(id), encoded); err != nil {
+			return err
+		}
+		return indexTask(tx, &task)
 	})
 }
 
@@ -134,30 +184,280 @@ func (db *TaskDB) DeleteTask(id string) error {
 		if err != nil {
 			return err
 		}
+		if err := unindexTask(tx, b, id); err != nil {
+			return err
+		}
 		return b.Delete([]byte(id))
 	})
 }
 
-// ListTasks returns all tasks.
+// ListTasks returns the page of tasks matching the filter. The indexes are
+// used to only decode the tasks that may match the filter.
 func (db *TaskDB) ListTasks(filter *eremetic.TaskFilter) ([]*eremetic.Task, error) {
-	tasks := []*eremetic.Task{}
+	c, err := eremetic.NewTaskCollector(filter)
+	if err != nil {
+		return nil, err
+	}
 
-	err := db.conn.View(func(tx *bolt.Tx) error {
+	err = db.conn.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("tasks"))
 		if b == nil {
 			return bolt.ErrBucketNotFound
 		}
-		b.ForEach(func(_, v []byte) error {
+		add := func(v []byte) {
 			var task eremetic.Task
 			json.Unmarshal(v, &task)
 			eremetic.ApplyMask(&task)
-			if filter.Match(&task) {
-				tasks = append(tasks, &task)
+			c.Add(&task)
+		}
+
+		ids := lookup(tx, filter)
+		ordered := filter.SortField() == eremetic.SortCreated
+		if ids != nil && (!ordered || filter.Limit <= 0 || len(ids) <= filter.Limit) {
+			for id := range ids {
+				if v := b.Get([]byte(id)); v != nil {
+					add(v)
+				}
+			}
+			return nil
+		}
+
+		if ordered {
+			// Tasks come in the order of the page, so the scan starts
+			// at the cursor and stops as soon as the page is full.
+			var from []byte
+			if filter.Cursor != "" {
+				key, id, _ := filter.CursorPosition()
+				from = timeKey(key, id)
 			}
+			walkCreated(tx.Bucket(createdIndex), filter.Order == eremetic.OrderDesc, from, func(id string) bool {
+				if _, ok := ids[id]; ids != nil && !ok {
+					return true
+				}
+				if v := b.Get([]byte(id)); v != nil {
+					add(v)
+				}
+				return !c.Full()
+			})
+			return nil
+		}
+
+		b.ForEach(func(_, v []byte) error {
+			add(v)
+			return nil
+		})
+		return nil
+	})
+
+	return c.Tasks(), err
+}
+
+// ScanTasks calls fn with the stored document of every task.
+func (db *TaskDB) ScanTasks(fn func(id string, data []byte) error) error {
+	return db.conn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("tasks"))
+		if b == nil {
 			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), append([]byte(nil), v...))
 		})
+	})
+}
+
+// PutQuota stores a quota in the database
+func (db *TaskDB) PutQuota(quota *eremetic.Quota) error {
+	return db.conn.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("quotas"))
+		if err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(quota)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to encode quota to byte-array.")
+			return err
+		}
+
+		return b.Put([]byte(quota.Name), encoded)
+	})
+}
+
+// ReadQuota fetches a quota from the database
+func (db *TaskDB) ReadQuota(name string) (eremetic.Quota, error) {
+	var quota eremetic.Quota
+
+	err := db.conn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("quotas"))
+		if b == nil {
+			return bolt.ErrBucketNotFound
+		}
+		v := b.Get([]byte(name))
+		if v == nil {
+			return errors.New("unknown quota")
+		}
+		return json.Unmarshal(v, &quota)
+	})
+
+	return quota, err
+}
+
+// DeleteQuota deletes a quota matching the given name.
+func (db *TaskDB) DeleteQuota(name string) error {
+	return db.conn.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("quotas"))
+		if err != nil {
+			return err
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
+// ListQuotas returns all quotas.
+func (db *TaskDB) ListQuotas() ([]*eremetic.Quota, error) {
+	quotas := []*eremetic.Quota{}
+
+	err := db.conn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("quotas"))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var quota eremetic.Quota
+			if err := json.Unmarshal(v, &quota); err != nil {
+				return err
+			}
+			quotas = append(quotas, &quota)
+			return nil
+		})
+	})
+
+	return quotas, err
+}
+
+// PutTemplate stores a template in the database
+func (db *TaskDB) PutTemplate(template *eremetic.Template) error {
+	return db.conn.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("templates"))
+		if err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(template)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to encode template to byte-array.")
+			return err
+		}
+
+		return b.Put([]byte(template.Name), encoded)
+	})
+}
+
+// UpdateTemplate applies fn to a template and stores it within a single
+// transaction, so that no other update can happen in between.
+func (db *TaskDB) UpdateTemplate(name string, fn func(*eremetic.Template) error) error {
+	return db.conn.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("templates"))
+		if err != nil {
+			return err
+		}
+
+		template := eremetic.Template{Name: name}
+		if v := b.Get([]byte(name)); v != nil {
+			if err := json.Unmarshal(v, &template); err != nil {
+				return err
+			}
+		}
+		if err := fn(&template); err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(template)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to encode template to byte-array.")
+			return err
+		}
+
+		return b.Put([]byte(name), encoded)
+	})
+}
+
+// ReadTemplate fetches a template from the database
+func (db *TaskDB) ReadTemplate(name string) (eremetic.Template, error) {
+	var template eremetic.Template
+
+	err := db.conn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("templates"))
+		if b == nil {
+			return bolt.ErrBucketNotFound
+		}
+		v := b.Get([]byte(name))
+		if v == nil {
+			return errors.New("unknown template")
+		}
+		return json.Unmarshal(v, &template)
+	})
+
+	return template, err
+}
+
+// DeleteTemplate deletes a template matching the given name.
+func (db *TaskDB) DeleteTemplate(name string) error {
+	return db.conn.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("templates"))
+		if err != nil {
+			return err
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
+// ListTemplates returns all templates.
+func (db *TaskDB) ListTemplates() ([]*eremetic.Template, error) {
+	templates := []*eremetic.Template{}
+
+	err := db.conn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("templates"))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var template eremetic.Template
+			if err := json.Unmarshal(v, &template); err != nil {
+				return err
+			}
+			templates = append(templates, &template)
+			return nil
+		})
+	})
+
+	return templates, err
+}
+
+// PutFrameworkID stores the framework ID in the meta bucket.
+func (db *TaskDB) PutFrameworkID(id string) error {
+	return db.conn.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			return b.Delete(frameworkIDKey)
+		}
+		return b.Put(frameworkIDKey, []byte(id))
+	})
+}
+
+// ReadFrameworkID returns the framework ID stored in the meta bucket.
+func (db *TaskDB) ReadFrameworkID() (string, error) {
+	var id string
+
+	err := db.conn.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(metaBucket); b != nil {
+			id = string(b.Get(frameworkIDKey))
+		}
 		return nil
 	})
 
-	return tasks, err
+	return id, err
 }
@@ -1,6 +1,7 @@
 package boltdb
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -265,6 +266,50 @@ func TestBoltDatabase(t *testing.T) {
 		})
 	})
 
+	Convey("ListTasks with paging", t, func() {
+		setup()
+		defer teardown()
+		defer db.Close()
+
+		db.Clean()
+
+		for i := 0; i < 5; i++ {
+			db.PutTask(&eremetic.Task{
+				ID:     fmt.Sprintf("eremetic-task.%d", i),
+				Labels: map[string]string{"even": fmt.Sprint(i%2 == 0)},
+				Status: []eremetic.Status{
+					eremetic.Status{Status: eremetic.TaskQueued, Time: int64(100 + i)},
+				},
+			})
+		}
+
+		Convey("Returns pages in order", func() {
+			filter := &eremetic.TaskFilter{State: eremetic.QueuedState, Order: eremetic.OrderDesc, Limit: 2}
+			tasks, err := db.ListTasks(filter)
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 2)
+			So(tasks[0].ID, ShouldEqual, "eremetic-task.4")
+			So(tasks[1].ID, ShouldEqual, "eremetic-task.3")
+
+			filter.Cursor = filter.CursorAfter(tasks[1])
+			tasks, err = db.ListTasks(filter)
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 2)
+			So(tasks[0].ID, ShouldEqual, "eremetic-task.2")
+		})
+
+		Convey("Filters by label", func() {
+			tasks, err := db.ListTasks(&eremetic.TaskFilter{State: eremetic.QueuedState, Labels: "even=true"})
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 3)
+		})
+
+		Convey("Rejects invalid cursors", func() {
+			_, err := db.ListTasks(&eremetic.TaskFilter{Cursor: "nope"})
+			So(err, ShouldEqual, eremetic.ErrInvalidCursor)
+		})
+	})
+
 	Convey("DeleteTask", t, func() {
 		Convey("Success", func() {
 			setup()
@@ -286,6 +331,145 @@ func TestBoltDatabase(t *testing.T) {
 		})
 	})
 
+	Convey("UpdateTask", t, func() {
+		setup()
+		defer teardown()
+		defer db.Close()
+
+		db.PutTask(&eremetic.Task{ID: "1234", Name: "etl", Status: status})
+
+		Convey("Updates the task and its indexes", func() {
+			err := db.UpdateTask("1234", func(t *eremetic.Task) error {
+				t.UpdateStatus(eremetic.Status{Status: eremetic.TaskFinished, Time: time.Now().Unix()})
+				return nil
+			})
+			So(err, ShouldBeNil)
+
+			task, _ := db.ReadTask("1234")
+			So(task.CurrentStatus(), ShouldEqual, eremetic.TaskFinished)
+			So(task.Revision, ShouldEqual, 1)
+
+			tasks, _ := db.ListTasks(&eremetic.TaskFilter{State: eremetic.TerminatedState})
+			So(tasks, ShouldHaveLength, 1)
+		})
+
+		Convey("Creates missing tasks", func() {
+			err := db.UpdateTask("5678", func(t *eremetic.Task) error {
+				t.Name = "new"
+				return nil
+			})
+			So(err, ShouldBeNil)
+
+			task, _ := db.ReadTask("5678")
+			So(task.ID, ShouldEqual, "5678")
+			So(task.Name, ShouldEqual, "new")
+		})
+
+		Convey("Doesn't write when fn fails", func() {
+			err := db.UpdateTask("1234", func(t *eremetic.Task) error {
+				t.Name = "changed"
+				return errors.New("nope")
+			})
+			So(err, ShouldNotBeNil)
+
+			task, _ := db.ReadTask("1234")
+			So(task.Name, ShouldEqual, "etl")
+			So(task.Revision, ShouldEqual, 0)
+		})
+	})
+
+	Convey("Quotas", t, func() {
+		setup()
+		defer teardown()
+		defer db.Close()
+
+		quota := eremetic.Quota{
+			Name:       "team",
+			Labels:     map[string]string{"team": "data"},
+			MaxRunning: 5,
+			MaxCPUs:    2.5,
+		}
+
+		So(db.PutQuota(&quota), ShouldBeNil)
+
+		q, err := db.ReadQuota("team")
+		So(err, ShouldBeNil)
+		So(q, ShouldResemble, quota)
+
+		quotas, err := db.ListQuotas()
+		So(err, ShouldBeNil)
+		So(quotas, ShouldHaveLength, 1)
+
+		tasks, err := db.ListTasks(&eremetic.TaskFilter{})
+		So(err, ShouldBeNil)
+		So(tasks, ShouldBeEmpty)
+
+		So(db.DeleteQuota("team"), ShouldBeNil)
+		_, err = db.ReadQuota("team")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Templates", t, func() {
+		setup()
+		defer teardown()
+		defer db.Close()
+
+		template := eremetic.Template{
+			Name:    "nightly-etl",
+			Version: 3,
+			Request: eremetic.Request{DockerImage: "etl:1.0", TaskCPUs: 1},
+		}
+
+		So(db.PutTemplate(&template), ShouldBeNil)
+
+		tpl, err := db.ReadTemplate("nightly-etl")
+		So(err, ShouldBeNil)
+		So(tpl, ShouldResemble, template)
+
+		templates, err := db.ListTemplates()
+		So(err, ShouldBeNil)
+		So(templates, ShouldHaveLength, 1)
+
+		So(db.DeleteTemplate("nightly-etl"), ShouldBeNil)
+		_, err = db.ReadTemplate("nightly-etl")
+		So(err, ShouldNotBeNil)
+
+		Convey("UpdateTemplate", func() {
+			revise := func(t *eremetic.Template) error {
+				t.Revise(eremetic.Request{DockerImage: "etl:2.0"})
+				return nil
+			}
+			So(db.UpdateTemplate("nightly-etl", revise), ShouldBeNil)
+			So(db.UpdateTemplate("nightly-etl", revise), ShouldBeNil)
+
+			tpl, err := db.ReadTemplate("nightly-etl")
+			So(err, ShouldBeNil)
+			So(tpl.Version, ShouldEqual, 2)
+			So(tpl.History, ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("Framework ID", t, func() {
+		setup()
+		defer teardown()
+		defer db.Close()
+
+		id, err := db.ReadFrameworkID()
+		So(err, ShouldBeNil)
+		So(id, ShouldBeEmpty)
+
+		So(db.PutFrameworkID("1234-0001"), ShouldBeNil)
+		So(db.Clean(), ShouldBeNil)
+		id, err = db.ReadFrameworkID()
+		So(err, ShouldBeNil)
+		So(id, ShouldEqual, "1234-0001")
+
+		So(db.PutFrameworkID(""), ShouldBeNil)
+		id, err = db.ReadFrameworkID()
+		So(err, ShouldBeNil)
+		So(id, ShouldBeEmpty)
+	})
+
 	Convey("List non-terminal tasks no running task", t, func() {
 		setup()
 		defer teardown()
@@ -0,0 +1,307 @@
+package boltdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// The secondary indexes of the tasks bucket. Every key ends with the id of
+// the task it points to, values are empty. State, name and label keys are
+// `<value>\x00<id>`, creation time keys are an 8 byte sortable time
+// followed by the id.
+var (
+	stateIndex   = []byte("idx_state")
+	nameIndex    = []byte("idx_name")
+	labelIndex   = []byte("idx_label")
+	createdIndex = []byte("idx_created")
+
+	indexBuckets = [][]byte{stateIndex, nameIndex, labelIndex, createdIndex}
+)
+
+// indexVersion is increased whenever the layout of the indexes changes, so
+// that they are rebuilt when an older database is opened.
+const indexVersion = 1
+
+var (
+	metaBucket      = []byte("meta")
+	indexVersionKey = []byte("index_version")
+	frameworkIDKey  = []byte("framework_id")
+)
+
+type indexEntry struct {
+	bucket []byte
+	key    []byte
+}
+
+func valueKey(value, id string) []byte {
+	return []byte(value + "\x00" + id)
+}
+
+func timeKey(t int64, id string) []byte {
+	k := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(k, uint64(t)^(1<<63))
+	return append(k, id...)
+}
+
+func indexEntries(task *eremetic.Task) []indexEntry {
+	entries := []indexEntry{
+		{stateIndex, valueKey(string(task.CurrentStatus()), task.ID)},
+		{nameIndex, valueKey(task.Name, task.ID)},
+		{createdIndex, timeKey(task.CreatedAt().Unix(), task.ID)},
+	}
+	for k, v := range task.Labels {
+		entries = append(entries, indexEntry{labelIndex, valueKey(k+"="+v, task.ID)})
+	}
+	return entries
+}
+
+// indexTask adds the index entries of a task.
+func indexTask(tx *bolt.Tx, task *eremetic.Task) error {
+	for _, e := range indexEntries(task) {
+		b, err := tx.CreateBucketIfNotExists(e.bucket)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(e.key, []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexTask removes the index entries of the task stored under id, if any.
+func unindexTask(tx *bolt.Tx, tasks *bolt.Bucket, id string) error {
+	v := tasks.Get([]byte(id))
+	if v == nil {
+		return nil
+	}
+	var task eremetic.Task
+	if err := json.Unmarshal(v, &task); err != nil {
+		logrus.WithError(err).WithField("task_id", id).Warn("Unable to decode task, searching its index entries")
+		return unindexID(tx, id)
+	}
+	for _, e := range indexEntries(&task) {
+		if b := tx.Bucket(e.bucket); b != nil {
+			if err := b.Delete(e.key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unindexID removes the index entries pointing to id by walking all of the
+// indexes, for tasks whose entries can't be computed.
+func unindexID(tx *bolt.Tx, id string) error {
+	valueSuffix := []byte("\x00" + id)
+	for _, name := range indexBuckets {
+		b := tx.Bucket(name)
+		if b == nil {
+			continue
+		}
+		var keys [][]byte
+		b.ForEach(func(k, _ []byte) error {
+			if bytes.Equal(name, createdIndex) && len(k) == 8+len(id) && bytes.HasSuffix(k, []byte(id)) ||
+				bytes.HasSuffix(k, valueSuffix) {
+				keys = append(keys, k)
+			}
+			return nil
+		})
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migrateIndexes builds the indexes of databases written by older versions.
+func migrateIndexes(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+	version := strconv.Itoa(indexVersion)
+	if string(meta.Get(indexVersionKey)) == version {
+		return nil
+	}
+
+	logrus.WithField("version", indexVersion).Info("Building task indexes")
+	if err := rebuildIndexes(tx); err != nil {
+		return err
+	}
+	return meta.Put(indexVersionKey, []byte(version))
+}
+
+func rebuildIndexes(tx *bolt.Tx) error {
+	if err := dropIndexes(tx); err != nil {
+		return err
+	}
+	tasks := tx.Bucket([]byte("tasks"))
+	if tasks == nil {
+		return nil
+	}
+	return tasks.ForEach(func(k, v []byte) error {
+		var task eremetic.Task
+		if err := json.Unmarshal(v, &task); err != nil {
+			logrus.WithError(err).WithField("task_id", string(k)).Warn("Unable to decode task, leaving it out of the indexes")
+			return nil
+		}
+		return indexTask(tx, &task)
+	})
+}
+
+func dropIndexes(tx *bolt.Tx) error {
+	for _, name := range indexBuckets {
+		if tx.Bucket(name) == nil {
+			continue
+		}
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type idSet map[string]struct{}
+
+func (s idSet) intersect(o idSet) idSet {
+	if s == nil {
+		return o
+	}
+	res := idSet{}
+	for id := range s {
+		if _, ok := o[id]; ok {
+			res[id] = struct{}{}
+		}
+	}
+	return res
+}
+
+func idFromValueKey(k []byte) string {
+	return string(k[bytes.LastIndexByte(k, 0)+1:])
+}
+
+// scanPrefix returns the ids of all keys starting with prefix.
+func scanPrefix(b *bolt.Bucket, prefix []byte) idSet {
+	ids := idSet{}
+	if b == nil {
+		return ids
+	}
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		ids[idFromValueKey(k)] = struct{}{}
+	}
+	return ids
+}
+
+// scanStates returns the ids of the tasks in any of the states of the
+// filter. The state index is walked one distinct state at a time, skipping
+// the states that don't match.
+func scanStates(b *bolt.Bucket, states string) idSet {
+	ids := idSet{}
+	if b == nil {
+		return ids
+	}
+	probe := eremetic.TaskFilter{State: states}
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil; {
+		state := string(k[:bytes.IndexByte(k, 0)])
+		prefix := []byte(state + "\x00")
+		if !probe.Match(&eremetic.Task{Status: []eremetic.Status{{Status: eremetic.TaskState(state)}}}) {
+			k, _ = c.Seek([]byte(state + "\x01"))
+			continue
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			ids[idFromValueKey(k)] = struct{}{}
+		}
+	}
+	return ids
+}
+
+// scanCreated returns the ids of the tasks created within the range of the
+// filter.
+func scanCreated(b *bolt.Bucket, after, before int64) idSet {
+	ids := idSet{}
+	if b == nil {
+		return ids
+	}
+	c := b.Cursor()
+	k, _ := c.First()
+	if after > 0 {
+		k, _ = c.Seek(timeKey(after, ""))
+	}
+	end := timeKey(before, "")
+	for ; k != nil && (before <= 0 || bytes.Compare(k, end) < 0); k, _ = c.Next() {
+		ids[string(k[8:])] = struct{}{}
+	}
+	return ids
+}
+
+// lookup returns the ids of the tasks that may match the filter according to
+// the indexes. It returns nil when the filter can't be narrowed down by any
+// index. The tasks still have to be matched against the whole filter.
+//
+// States are few and usually match many tasks, so the state index is only
+// used when no other index applies.
+func lookup(tx *bolt.Tx, filter *eremetic.TaskFilter) idSet {
+	var ids idSet
+	if filter.Name != "" {
+		ids = ids.intersect(scanPrefix(tx.Bucket(nameIndex), valueKey(filter.Name, "")))
+	}
+	if filter.Labels != "" {
+		for _, sel := range strings.Split(filter.Labels, ",") {
+			prefix := []byte(sel + "=")
+			if strings.Contains(sel, "=") {
+				prefix = valueKey(sel, "")
+			}
+			ids = ids.intersect(scanPrefix(tx.Bucket(labelIndex), prefix))
+		}
+	}
+	if filter.CreatedAfter > 0 || filter.CreatedBefore > 0 {
+		ids = ids.intersect(scanCreated(tx.Bucket(createdIndex), filter.CreatedAfter, filter.CreatedBefore))
+	}
+	if ids == nil && filter.State != "" {
+		ids = scanStates(tx.Bucket(stateIndex), filter.State)
+	}
+	return ids
+}
+
+// walkCreated calls fn with the id of every task in order of creation,
+// starting after the key from when it is set, until fn returns false.
+func walkCreated(b *bolt.Bucket, desc bool, from []byte, fn func(id string) bool) {
+	if b == nil {
+		return
+	}
+	c := b.Cursor()
+	first, next := c.First, c.Next
+	if desc {
+		first, next = c.Last, c.Prev
+	}
+	k, _ := first()
+	if from != nil {
+		k, _ = c.Seek(from)
+		switch {
+		case desc && k == nil:
+			k, _ = c.Last()
+		case desc:
+			k, _ = c.Prev()
+		case bytes.Equal(k, from):
+			k, _ = c.Next()
+		}
+	}
+	for ; k != nil; k, _ = next() {
+		if !fn(string(k[8:])) {
+			return
+		}
+	}
+}
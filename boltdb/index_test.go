@@ -0,0 +1,310 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+func openTestDB(dir string) *TaskDB {
+	db, err := NewTaskDB(filepath.Join(dir, "test.db"))
+	So(err, ShouldBeNil)
+	return db
+}
+
+func indexKeys(db *TaskDB, bucket []byte) []string {
+	keys := []string{}
+	db.conn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys
+}
+
+func TestIndexes(t *testing.T) {
+	Convey("Indexes", t, func() {
+		dir, _ := ioutil.TempDir("", "eremetic")
+		defer os.RemoveAll(dir)
+		db := openTestDB(dir)
+		defer db.Close()
+
+		task := &eremetic.Task{
+			ID:     "eremetic-task.1",
+			Name:   "etl",
+			Labels: map[string]string{"team": "data"},
+			Status: []eremetic.Status{{Time: 10, Status: eremetic.TaskQueued}},
+		}
+		So(db.PutTask(task), ShouldBeNil)
+
+		Convey("Are written with the task", func() {
+			So(indexKeys(db, stateIndex), ShouldResemble, []string{"TASK_QUEUED\x00eremetic-task.1"})
+			So(indexKeys(db, nameIndex), ShouldResemble, []string{"etl\x00eremetic-task.1"})
+			So(indexKeys(db, labelIndex), ShouldResemble, []string{"team=data\x00eremetic-task.1"})
+			So(indexKeys(db, createdIndex), ShouldHaveLength, 1)
+		})
+
+		Convey("Follow updates of the task", func() {
+			task.Labels = map[string]string{"team": "web"}
+			task.UpdateStatus(eremetic.Status{Time: 15, Status: eremetic.TaskStaging})
+			task.UpdateStatus(eremetic.Status{Time: 20, Status: eremetic.TaskRunning})
+			So(db.PutTask(task), ShouldBeNil)
+
+			So(indexKeys(db, stateIndex), ShouldResemble, []string{"TASK_RUNNING\x00eremetic-task.1"})
+			So(indexKeys(db, labelIndex), ShouldResemble, []string{"team=web\x00eremetic-task.1"})
+			So(indexKeys(db, createdIndex), ShouldHaveLength, 1)
+		})
+
+		Convey("Are removed with the task", func() {
+			So(db.DeleteTask(task.ID), ShouldBeNil)
+			for _, b := range indexBuckets {
+				So(indexKeys(db, b), ShouldBeEmpty)
+			}
+		})
+
+		Convey("Are removed with undecodable tasks", func() {
+			db.conn.Update(func(tx *bolt.Tx) error {
+				return tx.Bucket([]byte("tasks")).Put([]byte(task.ID), []byte(`{"ID": `))
+			})
+
+			scanned := map[string]string{}
+			So(db.ScanTasks(func(id string, data []byte) error {
+				scanned[id] = string(data)
+				return nil
+			}), ShouldBeNil)
+			So(scanned, ShouldResemble, map[string]string{task.ID: `{"ID": `})
+
+			So(db.DeleteTask(task.ID), ShouldBeNil)
+			for _, b := range indexBuckets {
+				So(indexKeys(db, b), ShouldBeEmpty)
+			}
+		})
+
+		Convey("Are built for databases without indexes", func() {
+			db.conn.Update(func(tx *bolt.Tx) error {
+				tx.DeleteBucket(metaBucket)
+				return dropIndexes(tx)
+			})
+			db.Close()
+
+			db = openTestDB(dir)
+			defer db.Close()
+			So(indexKeys(db, nameIndex), ShouldResemble, []string{"etl\x00eremetic-task.1"})
+
+			tasks, err := db.ListTasks(&eremetic.TaskFilter{State: eremetic.QueuedState, Name: "etl"})
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 1)
+		})
+
+		Convey("Are built for databases with undecodable tasks", func() {
+			db.conn.Update(func(tx *bolt.Tx) error {
+				tx.DeleteBucket(metaBucket)
+				if err := dropIndexes(tx); err != nil {
+					return err
+				}
+				return tx.Bucket([]byte("tasks")).Put([]byte("eremetic-task.2"), []byte(`{"ID": `))
+			})
+			db.Close()
+
+			db = openTestDB(dir)
+			defer db.Close()
+			So(indexKeys(db, nameIndex), ShouldResemble, []string{"etl\x00eremetic-task.1"})
+
+			tasks, err := db.ListTasks(&eremetic.TaskFilter{State: eremetic.QueuedState})
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 1)
+			So(db.DeleteTask("eremetic-task.2"), ShouldBeNil)
+		})
+
+		Convey("Narrow down the listing", func() {
+			db.PutTask(&eremetic.Task{
+				ID:     "eremetic-task.2",
+				Name:   "etl",
+				Status: []eremetic.Status{{Time: 30, Status: eremetic.TaskQueued}, {Time: 40, Status: eremetic.TaskFinished}},
+			})
+
+			tasks, _ := db.ListTasks(&eremetic.TaskFilter{Name: "etl", State: eremetic.TerminatedState})
+			So(tasks, ShouldHaveLength, 1)
+			So(tasks[0].ID, ShouldEqual, "eremetic-task.2")
+
+			tasks, _ = db.ListTasks(&eremetic.TaskFilter{Labels: "team"})
+			So(tasks, ShouldHaveLength, 1)
+			So(tasks[0].ID, ShouldEqual, "eremetic-task.1")
+
+			tasks, _ = db.ListTasks(&eremetic.TaskFilter{CreatedAfter: 20, CreatedBefore: 31})
+			So(tasks, ShouldHaveLength, 1)
+			So(tasks[0].ID, ShouldEqual, "eremetic-task.2")
+
+			tasks, _ = db.ListTasks(&eremetic.TaskFilter{Order: eremetic.OrderDesc, Limit: 1})
+			So(tasks, ShouldHaveLength, 1)
+			So(tasks[0].ID, ShouldEqual, "eremetic-task.2")
+		})
+	})
+}
+
+func TestWalkCreated(t *testing.T) {
+	Convey("walkCreated", t, func() {
+		dir, _ := ioutil.TempDir("", "eremetic")
+		defer os.RemoveAll(dir)
+		db := openTestDB(dir)
+		defer db.Close()
+
+		for i, created := range []int64{10, 20, 20, 30} {
+			db.PutTask(&eremetic.Task{
+				ID:     fmt.Sprintf("eremetic-task.%d", i),
+				Status: []eremetic.Status{{Time: created, Status: eremetic.TaskQueued}},
+			})
+		}
+		walk := func(desc bool, from []byte) []string {
+			ids := []string{}
+			db.conn.View(func(tx *bolt.Tx) error {
+				walkCreated(tx.Bucket(createdIndex), desc, from, func(id string) bool {
+					ids = append(ids, id)
+					return true
+				})
+				return nil
+			})
+			return ids
+		}
+
+		Convey("Walks every task without a starting key", func() {
+			So(walk(false, nil), ShouldResemble, []string{"eremetic-task.0", "eremetic-task.1", "eremetic-task.2", "eremetic-task.3"})
+			So(walk(true, nil), ShouldResemble, []string{"eremetic-task.3", "eremetic-task.2", "eremetic-task.1", "eremetic-task.0"})
+		})
+
+		Convey("Starts after the starting key", func() {
+			So(walk(false, timeKey(20, "eremetic-task.1")), ShouldResemble, []string{"eremetic-task.2", "eremetic-task.3"})
+			So(walk(true, timeKey(20, "eremetic-task.2")), ShouldResemble, []string{"eremetic-task.1", "eremetic-task.0"})
+		})
+
+		Convey("Starts after keys of deleted tasks", func() {
+			So(walk(false, timeKey(15, "eremetic-task.9")), ShouldResemble, []string{"eremetic-task.1", "eremetic-task.2", "eremetic-task.3"})
+			So(walk(true, timeKey(15, "eremetic-task.9")), ShouldResemble, []string{"eremetic-task.0"})
+			So(walk(true, timeKey(40, "")), ShouldResemble, []string{"eremetic-task.3", "eremetic-task.2", "eremetic-task.1", "eremetic-task.0"})
+			So(walk(false, timeKey(40, "")), ShouldBeEmpty)
+		})
+
+		Convey("Is used to list the pages after a cursor", func() {
+			filter := &eremetic.TaskFilter{State: eremetic.QueuedState, Order: eremetic.OrderDesc, Limit: 2}
+			tasks, err := db.ListTasks(filter)
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 2)
+
+			filter.Cursor = filter.CursorAfter(tasks[1])
+			tasks, err = db.ListTasks(filter)
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 2)
+			So(tasks[0].ID, ShouldEqual, "eremetic-task.1")
+			So(tasks[1].ID, ShouldEqual, "eremetic-task.0")
+		})
+	})
+}
+
+// benchmarkDB returns a database of 100k tasks, 1% of which are running and
+// the rest finished.
+func benchmarkDB(b *testing.B) (*TaskDB, func()) {
+	dir, _ := ioutil.TempDir("", "eremetic")
+	db, err := NewTaskDB(filepath.Join(dir, "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	err = db.conn.Update(func(tx *bolt.Tx) error {
+		tasks := tx.Bucket([]byte("tasks"))
+		for i := 0; i < 100000; i++ {
+			task := &eremetic.Task{
+				ID:     fmt.Sprintf("eremetic-task.%06d", i),
+				Name:   fmt.Sprintf("job-%d", i%1000),
+				Image:  "busybox",
+				Labels: map[string]string{"team": fmt.Sprintf("team-%d", i%10)},
+				Status: []eremetic.Status{{Time: int64(i), Status: eremetic.TaskQueued}},
+			}
+			task.UpdateStatus(eremetic.Status{Time: int64(i + 1), Status: eremetic.TaskStaging})
+			if i%100 == 0 {
+				task.UpdateStatus(eremetic.Status{Time: int64(i + 1), Status: eremetic.TaskRunning})
+			} else {
+				task.UpdateStatus(eremetic.Status{Time: int64(i + 1), Status: eremetic.TaskFinished})
+			}
+			encoded, _ := eremetic.Encode(task)
+			if err := tasks.Put([]byte(task.ID), encoded); err != nil {
+				return err
+			}
+			if err := indexTask(tx, task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// scanTasks lists tasks the way it was done before the indexes, by decoding
+// the whole tasks bucket.
+func scanTasks(db *TaskDB, filter *eremetic.TaskFilter) ([]*eremetic.Task, error) {
+	c, err := eremetic.NewTaskCollector(filter)
+	if err != nil {
+		return nil, err
+	}
+	err = db.conn.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("tasks")).ForEach(func(_, v []byte) error {
+			var task eremetic.Task
+			json.Unmarshal(v, &task)
+			c.Add(&task)
+			return nil
+		})
+	})
+	return c.Tasks(), err
+}
+
+func BenchmarkListTasks(b *testing.B) {
+	db, cleanup := benchmarkDB(b)
+	defer cleanup()
+
+	filters := []struct {
+		name   string
+		filter eremetic.TaskFilter
+	}{
+		{"Active", eremetic.TaskFilter{State: eremetic.DefaultTaskFilterState}},
+		{"Name", eremetic.TaskFilter{State: eremetic.TerminatedState, Name: "job-42"}},
+		{"Label", eremetic.TaskFilter{State: eremetic.TerminatedState, Labels: "team=team-3", Limit: 50}},
+		{"LatestPage", eremetic.TaskFilter{State: eremetic.TerminatedState, Order: eremetic.OrderDesc, Limit: 50}},
+	}
+
+	for _, f := range filters {
+		filter := f.filter
+		b.Run(f.name+"/Indexed", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := db.ListTasks(&filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(f.name+"/Scan", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := scanTasks(db, &filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -15,6 +15,18 @@ type CallbackData struct {
 	TaskID string `json:"task_id"`
 }
 
+func init() {
+	OnTransition(notifyTerminated)
+}
+
+// notifyTerminated notifies the callback of a task reaching a terminal state
+// it stays in, unlike failed tasks queued for a retry.
+func notifyTerminated(task *Task, t Transition) {
+	if IsTerminal(t.To) && task.CurrentStatus() == t.To {
+		NotifyCallback(task)
+	}
+}
+
 // NotifyCallback handles posting a JSON back to the URI given with the task.
 func NotifyCallback(task *Task) {
 	if len(task.CallbackURI) == 0 {
@@ -2,6 +2,8 @@ package client
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -25,6 +27,35 @@ func New(endpoint string, client *http.Client) (*Client, error) {
 	}, nil
 }
 
+// NewTLSConfig returns a TLS configuration that verifies the server against
+// the CA in caFile and presents the client certificate in certFile and keyFile.
+// Any of the files may be left empty.
+func NewTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	conf := &tls.Config{}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
 // AddTask sends a request for a new task to be scheduled.
 func (c *Client) AddTask(r api.RequestV1) error {
 	var buf bytes.Buffer
@@ -47,6 +78,41 @@ func (c *Client) AddTask(r api.RequestV1) error {
 	return nil
 }
 
+// AddTaskFromTemplate sends a request for a new task created from a template.
+// The overrides are merged into the request of the template.
+func (c *Client) AddTaskFromTemplate(template string, overrides map[string]interface{}) error {
+	r := api.TemplateRequestV1{Template: template}
+	if len(overrides) > 0 {
+		b, err := json.Marshal(overrides)
+		if err != nil {
+			return err
+		}
+		r.Overrides = b
+	}
+
+	var buf bytes.Buffer
+
+	err := json.NewEncoder(&buf).Encode(r)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.endpoint+"/api/v1/task", &buf)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("Unexpected status code `%s`", resp.Status)
+	}
+
+	return nil
+}
+
 // Task returns a task with a given ID.
 func (c *Client) Task(id string) (*eremetic.Task, error) {
 	req, err := http.NewRequest("GET", c.endpoint+"/api/v1/task/"+id, nil)
@@ -71,6 +137,31 @@ func (c *Client) Task(id string) (*eremetic.Task, error) {
 	return &t, nil
 }
 
+// TaskDiagnostics explains why a task hasn't been launched yet.
+func (c *Client) TaskDiagnostics(id string) (*eremetic.TaskDiagnostics, error) {
+	req, err := http.NewRequest("GET", c.endpoint+"/api/v1/task/"+id+"/diagnostics", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status code `%s`", resp.Status)
+	}
+
+	var diagnostics eremetic.TaskDiagnostics
+
+	err = json.NewDecoder(resp.Body).Decode(&diagnostics)
+	if err != nil {
+		return nil, err
+	}
+
+	return &diagnostics, nil
+}
+
 // Tasks returns all current tasks.
 func (c *Client) Tasks() ([]eremetic.Task, error) {
 	req, err := http.NewRequest("GET", c.endpoint+"/api/v1/task", nil)
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -30,6 +31,33 @@ func TestClient_AddTask(t *testing.T) {
 	}
 }
 
+func TestClient_AddTaskFromTemplate(t *testing.T) {
+	var received api.TemplateRequestV1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	var httpClient http.Client
+
+	c, err := New(ts.URL, &httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.AddTaskFromTemplate("nightly-etl", map[string]interface{}{"cpu": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if received.Template != "nightly-etl" {
+		t.Fatalf("Unexpected template %q", received.Template)
+	}
+	if string(received.Overrides) != `{"cpu":2}` {
+		t.Fatalf("Unexpected overrides %s", received.Overrides)
+	}
+}
+
 func TestClient_Tasks(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`[{
@@ -93,6 +121,34 @@ func TestClient_ReadTask(t *testing.T) {
 		t.Fatal(errors.New("Unexpected task"))
 	}
 }
+func TestClient_TaskDiagnostics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/task/1234/diagnostics" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"task_id":"1234","queued":true,"offers":[{"offer_id":"5678","rejection":"cpu"}],"rejections":{"cpu":1}}`))
+	}))
+	defer ts.Close()
+
+	var httpClient http.Client
+	c, err := New(ts.URL, &httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnostics, err := c.TaskDiagnostics("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !diagnostics.Queued || len(diagnostics.Offers) != 1 || diagnostics.Rejections["cpu"] != 1 {
+		t.Fatal(errors.New("Unexpected diagnostics"))
+	}
+
+	if _, err := c.TaskDiagnostics("unknown"); err == nil {
+		t.Fatal(errors.New("Expected an error for an unknown task"))
+	}
+}
 func TestClient_Version(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(version.Version))
@@ -134,3 +190,21 @@ func TestClient_Sandbox(t *testing.T) {
 		t.Fatal("Failed to get file")
 	}
 }
+
+func TestClient_NewTLSConfig(t *testing.T) {
+	conf, err := NewTLSConfig("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.RootCAs != nil || len(conf.Certificates) != 0 {
+		t.Fail()
+	}
+
+	if _, err := NewTLSConfig("/does/not/exist.pem", "", ""); err == nil {
+		t.Fatal("expected error for missing CA file")
+	}
+
+	if _, err := NewTLSConfig("", "/does/not/exist.crt", "/does/not/exist.key"); err == nil {
+		t.Fatal("expected error for missing client certificate")
+	}
+}
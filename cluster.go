@@ -0,0 +1,25 @@
+package eremetic
+
+import "time"
+
+// Agent is the state of an agent as of the last offer received from it.
+type Agent struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname"`
+	// CPUs, Mem and Ports are the resources offered.
+	CPUs  float64  `json:"cpu"`
+	Mem   float64  `json:"mem"`
+	Ports []string `json:"ports,omitempty"`
+	// Attributes holds the text and scalar attributes of the agent.
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Maintenance *Maintenance      `json:"maintenance,omitempty"`
+	OfferedAt   time.Time         `json:"offered_at"`
+}
+
+// TaskFit tells which agents could launch a task with their last offer.
+type TaskFit struct {
+	// Agents are the ids of the agents the task fits on.
+	Agents []string `json:"agents"`
+	// Evaluations are the evaluations of the last offer of every agent.
+	Evaluations []OfferEvaluation `json:"evaluations"`
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/config"
+	"github.com/eremetic-framework/eremetic/dump"
+	"github.com/eremetic-framework/eremetic/fsck"
+)
+
+const dbUsage = `Usage:
+  eremetic db export [--db <database>] [--unmasked] [--output <file>]
+  eremetic db import [--db <database>] [--force] [--input <file>]
+  eremetic db migrate --from <database> --to <database>
+  eremetic db fsck [--db <database>] [--repair] [--stuck-after <duration>]
+
+A database is either a URL (zk://, etcd://, etcds://, postgres://,
+sqlite3://) or <driver>:<location>, like boltdb:/var/lib/eremetic.db.
+Without --db, the database of the configuration is used.
+`
+
+// runDB runs the `eremetic db` subcommands and returns the exit code.
+func runDB(conf *config.Config, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, dbUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "export":
+		err = dbExport(conf, args[1:], stdout, stderr)
+	case "import":
+		err = dbImport(conf, args[1:], stdin, stderr)
+	case "migrate":
+		err = dbMigrate(args[1:], stderr)
+	case "fsck":
+		err = dbFsck(conf, args[1:], stdout, stderr)
+	default:
+		fmt.Fprint(stderr, dbUsage)
+		return 2
+	}
+
+	if err == flag.ErrHelp {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "eremetic db %s: %s\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func dbFlags(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("eremetic db "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, dbUsage) }
+	return flags
+}
+
+func dbExport(conf *config.Config, args []string, stdout, stderr io.Writer) error {
+	flags := dbFlags("export", stderr)
+	location := flags.String("db", "", "database to export")
+	unmasked := flags.Bool("unmasked", false, "export the values of masked environment variables")
+	output := flags.String("output", "", "file to write to instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(conf, *location)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	w := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	counts, err := dump.Export(db, w, *unmasked)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "Exported %s\n", counts)
+	if counts.Masked > 0 {
+		fmt.Fprintf(stderr, "%d tasks and templates have masked environment variables, use --unmasked to export their values\n", counts.Masked)
+	}
+	return nil
+}
+
+func dbImport(conf *config.Config, args []string, stdin io.Reader, stderr io.Writer) error {
+	flags := dbFlags("import", stderr)
+	location := flags.String("db", "", "database to import into")
+	input := flags.String("input", "", "file to read from instead of stdin")
+	force := flags.Bool("force", false, "write the masking string of masked environment variables without a stored value")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(conf, *location)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	r := stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	counts, err := dump.Import(db, r, *force)
+	fmt.Fprintf(stderr, "Imported %s\n", counts)
+	if counts.Masked > 0 {
+		fmt.Fprintf(stderr, "%d tasks and templates were exported without their masked environment variables\n", counts.Masked)
+	}
+	return err
+}
+
+func dbMigrate(args []string, stderr io.Writer) error {
+	flags := dbFlags("migrate", stderr)
+	fromLocation := flags.String("from", "", "database to copy from")
+	toLocation := flags.String("to", "", "database to copy to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *fromLocation == "" || *toLocation == "" {
+		return errors.New("--from and --to are required")
+	}
+
+	from, err := openDatabase(nil, *fromLocation)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+
+	to, err := openDatabase(nil, *toLocation)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	report, err := dump.Migrate(from, to)
+	fmt.Fprintf(stderr, "Copied %s\nVerified %s\n", report.Copied, report.Verified)
+	return err
+}
+
+func dbFsck(conf *config.Config, args []string, stdout, stderr io.Writer) error {
+	flags := dbFlags("fsck", stderr)
+	location := flags.String("db", "", "database to check")
+	repair := flags.Bool("repair", false, "repair the problems found")
+	stuckAfter := flags.Duration("stuck-after", fsck.DefaultStuckAfter, "how long a task may stay staging or terminating")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(conf, *location)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := fsck.Check(db, fsck.Options{Repair: *repair, StuckAfter: *stuckAfter})
+	if err != nil {
+		return err
+	}
+	for _, p := range report.Problems {
+		line := fmt.Sprintf("%s: %s: %s", p.TaskID, p.Kind, p.Detail)
+		switch {
+		case p.Repaired:
+			line += fmt.Sprintf(" (repaired: %s)", p.Repair)
+		case p.Error != "":
+			line += fmt.Sprintf(" (%s failed: %s)", p.Repair, p.Error)
+		case p.Repair != "":
+			line += fmt.Sprintf(" (repair: %s)", p.Repair)
+		}
+		fmt.Fprintln(stdout, line)
+	}
+	fmt.Fprintf(stderr, "Checked %d tasks, found %d problems, repaired %d\n",
+		report.Scanned, len(report.Problems), report.Repaired)
+
+	if unrepaired := len(report.Problems) - report.Repaired; unrepaired > 0 {
+		return fmt.Errorf("%d problems left", unrepaired)
+	}
+	return nil
+}
+
+// openDatabase opens the database given on the command line, or the one of
+// the configuration if there is none.
+func openDatabase(conf *config.Config, location string) (eremetic.TaskDB, error) {
+	if location == "" {
+		return NewDB(conf.DatabaseDriver, conf.DatabasePath)
+	}
+	driver, path, err := parseDatabase(location)
+	if err != nil {
+		return nil, err
+	}
+	return NewDB(driver, path)
+}
+
+// parseDatabase returns the driver and location of a database given either
+// as a URL, or as `<driver>:<location>`.
+func parseDatabase(location string) (string, string, error) {
+	schemes := map[string]string{
+		"zk":         "zk",
+		"etcd":       "etcd",
+		"etcds":      "etcd",
+		"postgres":   "sql",
+		"postgresql": "sql",
+		"sqlite3":    "sql",
+	}
+	if i := strings.Index(location, "://"); i > 0 {
+		if driver, ok := schemes[location[:i]]; ok {
+			return driver, location, nil
+		}
+	}
+
+	parts := strings.SplitN(location, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid database %q", location)
+	}
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/config"
+)
+
+func TestDB(t *testing.T) {
+	Convey("parseDatabase", t, func() {
+		for _, c := range []struct{ location, driver, path string }{
+			{"boltdb:/var/lib/eremetic.db", "boltdb", "/var/lib/eremetic.db"},
+			{"zk://zk1:2181,zk2:2181/eremetic", "zk", "zk://zk1:2181,zk2:2181/eremetic"},
+			{"etcds://etcd:2379/eremetic", "etcd", "etcds://etcd:2379/eremetic"},
+			{"sqlite3:///tmp/eremetic.db", "sql", "sqlite3:///tmp/eremetic.db"},
+			{"sql:postgres://db/eremetic", "sql", "postgres://db/eremetic"},
+		} {
+			driver, path, err := parseDatabase(c.location)
+			So(err, ShouldBeNil)
+			So(driver, ShouldEqual, c.driver)
+			So(path, ShouldEqual, c.path)
+		}
+
+		_, _, err := parseDatabase("/var/lib/eremetic.db")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("runDB", t, func() {
+		dir, _ := ioutil.TempDir("", "eremetic-db")
+		defer os.RemoveAll(dir)
+
+		conf := config.DefaultConfig()
+		conf.DatabasePath = filepath.Join(dir, "eremetic.db")
+
+		db, err := NewDB(conf.DatabaseDriver, conf.DatabasePath)
+		So(err, ShouldBeNil)
+		db.PutFrameworkID("1234-0001")
+		db.PutTask(&eremetic.Task{
+			ID:                "eremetic-task.1",
+			MaskedEnvironment: map[string]string{"SECRET": "hunter2"},
+		})
+		db.Close()
+
+		run := func(stdin string, args ...string) (int, string, string) {
+			var stdout, stderr bytes.Buffer
+			code := runDB(conf, args, strings.NewReader(stdin), &stdout, &stderr)
+			return code, stdout.String(), stderr.String()
+		}
+
+		Convey("export masks the environment by default", func() {
+			code, out, errOut := run("", "export")
+
+			So(code, ShouldEqual, 0)
+			So(out, ShouldContainSubstring, `"SECRET":"*******"`)
+			So(errOut, ShouldContainSubstring, "Exported 1 tasks")
+			So(errOut, ShouldContainSubstring, "use --unmasked")
+		})
+
+		Convey("export and import into another database", func() {
+			code, out, _ := run("", "export", "--unmasked")
+			So(code, ShouldEqual, 0)
+			So(out, ShouldContainSubstring, `"SECRET":"hunter2"`)
+
+			target := "sqlite3://" + filepath.Join(dir, "import.db")
+			code, _, errOut := run(out, "import", "--db", target)
+			So(code, ShouldEqual, 0)
+			So(errOut, ShouldContainSubstring, "Imported 1 tasks")
+
+			db, err := openDatabase(conf, target)
+			So(err, ShouldBeNil)
+			defer db.Close()
+			task, err := db.ReadUnmaskedTask("eremetic-task.1")
+			So(err, ShouldBeNil)
+			So(task.MaskedEnvironment["SECRET"], ShouldEqual, "hunter2")
+		})
+
+		Convey("import of a masked export keeps the stored values", func() {
+			code, out, _ := run("", "export")
+			So(code, ShouldEqual, 0)
+
+			code, _, errOut := run(out, "import")
+			So(code, ShouldEqual, 0)
+			So(errOut, ShouldContainSubstring, "1 tasks and templates were exported without")
+
+			db, err := openDatabase(conf, "")
+			So(err, ShouldBeNil)
+			defer db.Close()
+			task, err := db.ReadUnmaskedTask("eremetic-task.1")
+			So(err, ShouldBeNil)
+			So(task.MaskedEnvironment["SECRET"], ShouldEqual, "hunter2")
+		})
+
+		Convey("import of a masked export into another database needs --force", func() {
+			_, out, _ := run("", "export")
+			target := "sqlite3://" + filepath.Join(dir, "import.db")
+
+			code, _, errOut := run(out, "import", "--db", target)
+			So(code, ShouldEqual, 1)
+			So(errOut, ShouldContainSubstring, "SECRET is masked and has no stored value")
+
+			code, _, _ = run(out, "import", "--db", target, "--force")
+			So(code, ShouldEqual, 0)
+		})
+
+		Convey("migrate", func() {
+			target := "sqlite3://" + filepath.Join(dir, "migrate.db")
+			code, _, errOut := run("", "migrate", "--from", "boltdb:"+conf.DatabasePath, "--to", target)
+
+			So(code, ShouldEqual, 0)
+			So(errOut, ShouldContainSubstring, "Verified 1 tasks, 0 quotas, 0 templates, 1 framework ID")
+
+			db, err := openDatabase(conf, target)
+			So(err, ShouldBeNil)
+			defer db.Close()
+			id, _ := db.ReadFrameworkID()
+			So(id, ShouldEqual, "1234-0001")
+		})
+
+		Convey("migrate requires both databases", func() {
+			code, _, errOut := run("", "migrate", "--from", "boltdb:"+conf.DatabasePath)
+
+			So(code, ShouldEqual, 1)
+			So(errOut, ShouldContainSubstring, "--from and --to are required")
+		})
+
+		Convey("fsck reports and repairs problems", func() {
+			code, out, errOut := run("", "fsck")
+
+			So(code, ShouldEqual, 1)
+			So(out, ShouldEqual, "eremetic-task.1: missing_status: the task has no status (repair: delete)\n")
+			So(errOut, ShouldContainSubstring, "Checked 1 tasks, found 1 problems, repaired 0")
+
+			code, out, _ = run("", "fsck", "--repair")
+			So(code, ShouldEqual, 0)
+			So(out, ShouldContainSubstring, "(repaired: delete)")
+
+			code, _, errOut = run("", "fsck")
+			So(code, ShouldEqual, 0)
+			So(errOut, ShouldContainSubstring, "Checked 0 tasks, found 0 problems")
+		})
+
+		Convey("unknown subcommands print the usage", func() {
+			code, _, errOut := run("", "vacuum")
+
+			So(code, ShouldEqual, 2)
+			So(errOut, ShouldStartWith, "Usage:")
+		})
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"time"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/mesostest"
+)
+
+// newDevCluster simulates the cluster eremetic runs against with --dev: a
+// large agent, a small one, and one going into maintenance in two hours.
+func newDevCluster() (*mesostest.Cluster, error) {
+	start := time.Now().Add(2 * time.Hour)
+	end := start.Add(time.Hour)
+	return mesostest.NewCluster(
+		mesostest.Agent{
+			ID:         "agent-1",
+			Hostname:   "agent-1.dev",
+			CPUs:       8.0,
+			Mem:        16384.0,
+			Ports:      []string{"31000-32000"},
+			Attributes: map[string]string{"rack": "r1", "zone": "a"},
+		},
+		mesostest.Agent{
+			ID:         "agent-2",
+			Hostname:   "agent-2.dev",
+			CPUs:       2.0,
+			Mem:        2048.0,
+			Ports:      []string{"31000-32000"},
+			Attributes: map[string]string{"rack": "r2", "zone": "a"},
+		},
+		mesostest.Agent{
+			ID:          "agent-3",
+			Hostname:    "agent-3.dev",
+			CPUs:        4.0,
+			Mem:         8192.0,
+			Ports:       []string{"31000-32000"},
+			Attributes:  map[string]string{"rack": "r3", "zone": "b"},
+			Maintenance: &eremetic.Maintenance{Start: start, End: &end},
+		},
+	)
+}
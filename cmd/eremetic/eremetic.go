@@ -1,21 +1,29 @@
 package main
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/sirupsen/logrus"
 	"github.com/braintree/manners"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/admission"
 	"github.com/eremetic-framework/eremetic/boltdb"
 	"github.com/eremetic-framework/eremetic/config"
+	"github.com/eremetic-framework/eremetic/etcd"
 	"github.com/eremetic-framework/eremetic/mesos"
 	"github.com/eremetic-framework/eremetic/metrics"
+	"github.com/eremetic-framework/eremetic/retention"
 	"github.com/eremetic-framework/eremetic/server"
+	"github.com/eremetic-framework/eremetic/sqldb"
 	"github.com/eremetic-framework/eremetic/version"
 	"github.com/eremetic-framework/eremetic/zk"
 )
@@ -51,6 +59,16 @@ func getSchedulerSettings(config *config.Config) *mesos.Settings {
 		MessengerPort:    uint16(config.MessengerPort),
 		Checkpoint:       config.Checkpoint,
 		FailoverTimeout:  config.FailoverTimeout,
+		OfferHold:        config.OfferHold,
+
+		UnreachablePolicy:  config.Unreachable.Policy,
+		UnreachableTimeout: config.Unreachable.Timeout,
+
+		ReconcileInterval: config.Reconciliation.Interval,
+		ReconcileTimeout:  config.Reconciliation.Timeout,
+
+		MaintenancePolicy: config.Maintenance.Policy,
+		MaintenanceLead:   config.Maintenance.Lead,
 	}
 }
 
@@ -59,25 +77,93 @@ func main() {
 		fmt.Println(version.Version)
 		os.Exit(0)
 	}
+	dev := len(os.Args) == 2 && os.Args[1] == "--dev"
 	config := setup()
 
 	setupLogging(config.LogFormat, config.LogLevel)
 
+	if len(os.Args) > 1 && os.Args[1] == "db" {
+		os.Exit(runDB(config, os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	metrics.RegisterMetrics(prometheus.DefaultRegisterer)
 
-	db, err := NewDB(config.DatabaseDriver, config.DatabasePath)
-	if err != nil {
-		logrus.WithError(err).Fatal("Unable to set up database.")
+	var db eremetic.TaskDB
+	var err error
+	if dev {
+		// The tasks of the simulated cluster don't outlive the process.
+		db = eremetic.NewDefaultTaskDB()
+		config.Election.Driver = ""
+	} else {
+		db, err = NewDB(config.DatabaseDriver, config.DatabasePath)
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to set up database.")
+		}
 	}
 	defer db.Close()
 
 	settings := getSchedulerSettings(config)
+	if dev {
+		cluster, err := newDevCluster()
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to set up the simulated cluster.")
+		}
+		defer cluster.Close()
+		settings.NewDriver = cluster.NewDriver
+		logrus.Warn("Running against a simulated cluster, tasks aren't actually launched")
+	}
+	if err := mesos.ValidateSettings(settings); err != nil {
+		logrus.WithError(err).Fatal("Invalid scheduler settings.")
+	}
 	sched := mesos.NewScheduler(settings, db)
 
-	go func() {
+	var janitor *retention.Janitor
+	if config.Retention.Enabled() {
+		janitor = retention.NewJanitor(db, &config.Retention)
+	}
+
+	run := func() {
+		if janitor != nil {
+			go janitor.Run()
+		}
 		sched.Run()
 		manners.Close()
-	}()
+	}
+
+	var elector eremetic.Elector
+	shutdown := make(chan struct{})
+	if config.Election.Enabled() {
+		elector, err = NewElector(config)
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to set up leader election.")
+		}
+		defer elector.Close()
+
+		go func() {
+			lost, err := elector.Campaign(shutdown)
+			if err == eremetic.ErrNotElected {
+				manners.Close()
+				return
+			}
+			if err != nil {
+				logrus.WithError(err).Fatal("Unable to campaign for the leadership.")
+			}
+
+			logrus.Info("Elected leader, starting the scheduler")
+			go func() {
+				<-lost
+				select {
+				case <-shutdown:
+				default:
+					// Another instance may already be scheduling.
+					logrus.Fatal("Lost the leadership, exiting.")
+				}
+			}()
+			run()
+		}()
+	} else {
+		go run()
+	}
 
 	// Catch interrupt
 	go func() {
@@ -89,10 +175,15 @@ func main() {
 		}
 
 		logrus.Info("Eremetic is shutting down")
+		close(shutdown)
+		if janitor != nil {
+			janitor.Stop()
+		}
 		sched.Stop()
 	}()
 
-	router := server.NewRouter(sched, config, db)
+	admitted := admission.NewScheduler(sched, admission.NewChain(&config.Admission))
+	router := server.NewRouter(admitted, config, db, elector)
 
 	bind := fmt.Sprintf("%s:%d", config.Address, config.Port)
 
@@ -100,14 +191,77 @@ func main() {
 		"version": version.Version,
 		"address": config.Address,
 		"port":    config.Port,
+		"tls":     config.TLSCert != "",
 	}).Infof("Launching Eremetic version %s!\nListening to %s", version.Version, bind)
 
-	err = manners.ListenAndServe(bind, router)
+	err = listenAndServe(bind, config, router)
 	if err != nil {
 		logrus.WithError(err).Fatal("Unrecoverable error")
 	}
 }
 
+// listenAndServe serves the router over plain HTTP, or over HTTPS when a TLS
+// certificate has been configured. The certificate is reloaded on SIGHUP.
+func listenAndServe(bind string, config *config.Config, handler http.Handler) error {
+	if config.TLSCert == "" && config.TLSKey == "" {
+		return manners.ListenAndServe(bind, handler)
+	}
+
+	tlsConfig, reloader, err := server.NewTLSConfig(config)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+		for range c {
+			if err := reloader.Reload(); err != nil {
+				logrus.WithError(err).Error("Unable to reload TLS certificate")
+			}
+		}
+	}()
+
+	ln, err := net.Listen("tcp", bind)
+	if err != nil {
+		return err
+	}
+
+	return manners.Serve(tls.NewListener(ln, tlsConfig), handler)
+}
+
+// NewElector is used to create the leader election based on settings. The
+// location defaults to the one of the database when both use the same
+// driver, and the advertised address to the hostname and port of this
+// instance.
+func NewElector(config *config.Config) (eremetic.Elector, error) {
+	location := config.Election.Location
+	if location == "" && config.Election.Driver == config.DatabaseDriver {
+		location = config.DatabasePath
+	}
+
+	address := config.Election.Advertise
+	if address == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		scheme := "http"
+		if config.TLSCert != "" {
+			scheme = "https"
+		}
+		address = fmt.Sprintf("%s://%s:%d", scheme, hostname, config.Port)
+	}
+
+	switch config.Election.Driver {
+	case "zk":
+		return zk.NewElector(location, address)
+	case "etcd":
+		return etcd.NewElector(location, address, int64(config.Election.LeaseTTL))
+	}
+	return nil, errors.New("invalid election driver")
+}
+
 // NewDB Is used to create a new database driver based on settings.
 func NewDB(driver string, location string) (eremetic.TaskDB, error) {
 	switch driver {
@@ -115,6 +269,10 @@ func NewDB(driver string, location string) (eremetic.TaskDB, error) {
 		return boltdb.NewTaskDB(location)
 	case "zk":
 		return zk.NewTaskDB(location)
+	case "sql":
+		return sqldb.NewTaskDB(location)
+	case "etcd":
+		return etcd.NewTaskDB(location)
 	}
 	return nil, errors.New("invalid driver")
 }
@@ -28,6 +28,13 @@ func TestMain(t *testing.T) {
 		})
 	})
 
+	Convey("newDevCluster", t, func() {
+		cluster, err := newDevCluster()
+
+		So(err, ShouldBeNil)
+		So(cluster.Close(), ShouldBeNil)
+	})
+
 	Convey("setupLogging", t, func() {
 		setupLogging(conf.LogFormat, conf.LogLevel)
 		So(logrus.GetLevel(), ShouldEqual, logrus.DebugLevel)
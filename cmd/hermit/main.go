@@ -1,7 +1,7 @@
 package main
 
 import (
-	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -18,6 +18,7 @@ import (
 	"github.com/eremetic-framework/eremetic"
 	"github.com/eremetic-framework/eremetic/api"
 	"github.com/eremetic-framework/eremetic/client"
+	"github.com/eremetic-framework/eremetic/validation"
 )
 
 var defaultEremeticServer = "http://localhost:8000"
@@ -28,14 +29,12 @@ func main() {
 		srv = defaultEremeticServer
 	}
 
-	httpClient := &http.Client{}
+	var caFile, certFile, keyFile string
+	flag.StringVar(&caFile, "cacert", os.Getenv("HERMIT_CACERT"), "CA certificate used to verify the Eremetic server")
+	flag.StringVar(&certFile, "cert", os.Getenv("HERMIT_CERT"), "Client certificate to present to the Eremetic server")
+	flag.StringVar(&keyFile, "key", os.Getenv("HERMIT_KEY"), "Private key of the client certificate")
 
-	if os.Getenv("HERMIT_INSECURE") != "" {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		httpClient.Transport = tr
-	}
+	httpClient := &http.Client{}
 
 	ec, err := client.New(srv, httpClient)
 	if err != nil {
@@ -51,18 +50,32 @@ func main() {
 		"kill":    newKillCommand(ec),
 	}
 
-	if len(os.Args) < 2 || os.Args[1] == "-help" || os.Args[1] == "-h" {
+	flag.Usage = func() { usage(cmds) }
+	flag.Parse()
+
+	if flag.NArg() < 1 {
 		usage(cmds)
 		os.Exit(0)
 	}
 
-	cmd, ok := cmds[os.Args[1]]
+	tlsConfig, err := client.NewTLSConfig(caFile, certFile, keyFile)
+	if err != nil {
+		exitWithError(err)
+	}
+	if os.Getenv("HERMIT_INSECURE") != "" {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	httpClient.Transport = &http.Transport{
+		TLSClientConfig: tlsConfig,
+	}
+
+	cmd, ok := cmds[flag.Arg(0)]
 	if !ok {
 		usage(cmds)
 		exitWithError(errors.New("Unknown command"))
 	}
 
-	cmd.Parse(os.Args[2:])
+	cmd.Parse(flag.Args()[1:])
 	cmd.Run()
 }
 
@@ -89,15 +102,16 @@ func newFlagSet(name string, synopsis string, description string) *flag.FlagSet
 }
 
 type runCommand struct {
-	CPU     float64
-	Memory  float64
-	Image   string
-	Port    uint
-	Network string
-	DNS     string
-	EnvVars varMap
-	URIs    stringSlice
-	Args    stringSlice
+	CPU      float64
+	Memory   float64
+	Image    string
+	Port     uint
+	Network  string
+	DNS      string
+	EnvVars  varMap
+	URIs     stringSlice
+	Args     stringSlice
+	Template string
 
 	flags  *flag.FlagSet
 	client *client.Client
@@ -148,6 +162,7 @@ func (cmd *runCommand) Parse(args []string) {
 	cmd.flags.Var(&cmd.EnvVars, "e", "Environment variables. e.g. -e MYVAR1=myvalue1 -e MYVAR2=myvalue2")
 	cmd.flags.Var(&cmd.URIs, "uri", "URIs of resource to download")
 	cmd.flags.Var(&cmd.Args, "arg", "Arguments to pass to the docker container entrypoint")
+	cmd.flags.StringVar(&cmd.Template, "template", "", "Template to create the task from. Only options given explicitly override the template")
 	cmd.flags.Parse(args)
 }
 
@@ -183,12 +198,58 @@ func (cmd *runCommand) Run() {
 		Args:        cmd.Args,
 	}
 
+	if cmd.Template != "" {
+		if err := cmd.client.AddTaskFromTemplate(cmd.Template, cmd.overrides(r)); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+
+	if err := validation.ValidateRequest(api.RequestFromV1(r)); err != nil {
+		exitWithError(err)
+	}
+
 	if err := cmd.client.AddTask(r); err != nil {
 		exitWithError(err)
 	}
 }
 
+// runFlagFields maps the options of the run command to the request fields
+// they set.
+var runFlagFields = map[string]string{
+	"cpu":     "cpu",
+	"mem":     "mem",
+	"image":   "image",
+	"port":    "ports",
+	"network": "network",
+	"dns":     "dns",
+	"e":       "env",
+	"uri":     "fetch",
+	"arg":     "args",
+}
+
+// overrides returns the fields of the request that were given explicitly on
+// the command-line.
+func (cmd *runCommand) overrides(r api.RequestV1) map[string]interface{} {
+	var fields map[string]interface{}
+	b, _ := json.Marshal(r)
+	json.Unmarshal(b, &fields)
+
+	res := make(map[string]interface{})
+	cmd.flags.Visit(func(f *flag.Flag) {
+		if field, ok := runFlagFields[f.Name]; ok {
+			res[field] = fields[field]
+		}
+	})
+	if len(cmd.flags.Args()) > 0 {
+		res["command"] = fields["command"]
+	}
+	return res
+}
+
 type taskCommand struct {
+	Explain bool
+
 	flags  *flag.FlagSet
 	client *client.Client
 }
@@ -201,6 +262,7 @@ func newTaskCommand(c *client.Client) *taskCommand {
 }
 
 func (cmd *taskCommand) Parse(args []string) {
+	cmd.flags.BoolVar(&cmd.Explain, "explain", false, "Explain why a queued task hasn't been launched yet")
 	cmd.flags.Parse(args)
 }
 
@@ -225,6 +287,46 @@ func (cmd *taskCommand) Run() {
 	fmt.Println("Environment Variables:", task.Environment)
 	fmt.Println("State:", currentStatus(task.Status))
 	fmt.Println("Last updated:", lastUpdated(task.LastUpdated()))
+
+	if cmd.Explain {
+		diagnostics, err := cmd.client.TaskDiagnostics(taskID)
+		if err != nil {
+			exitWithError(err)
+		}
+		printDiagnostics(diagnostics)
+	}
+}
+
+func printDiagnostics(d *eremetic.TaskDiagnostics) {
+	fmt.Println()
+	if !d.Queued {
+		fmt.Println("The task is not queued.")
+		return
+	}
+	if len(d.Offers) == 0 {
+		fmt.Println("No offers evaluated yet.")
+		return
+	}
+
+	reasons := []string{}
+	for reason, count := range d.Rejections {
+		reasons = append(reasons, fmt.Sprintf("%s: %d", reason, count))
+	}
+	sort.Strings(reasons)
+	fmt.Printf("Last %d offers rejected by %s\n\n", len(d.Offers), strings.Join(reasons, ", "))
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, strings.Join([]string{"AGENT", "REJECTED BY", "DETAIL", "TIME"}, "\t"))
+	for i := len(d.Offers) - 1; i >= 0; i-- {
+		o := d.Offers[i]
+		rejection := o.Rejection
+		if rejection == "" {
+			rejection = "accepted"
+		}
+		fmt.Fprintln(w, strings.Join([]string{o.Hostname, rejection, o.Detail, lastUpdated(o.Time)}, "\t"))
+	}
+	w.Flush()
 }
 
 type listCommand struct {
@@ -482,6 +584,12 @@ func currentStatus(statuses []eremetic.Status) string {
 		return "starting"
 	case eremetic.TaskRunning:
 		return "running"
+	case eremetic.TaskKilling:
+		return "killing"
+	case eremetic.TaskTerminating:
+		return "terminating"
+	case eremetic.TaskUnreachable:
+		return "unreachable"
 	case eremetic.TaskFinished:
 		return "finished"
 	case eremetic.TaskFailed:
@@ -492,6 +600,10 @@ func currentStatus(statuses []eremetic.Status) string {
 		return "lost"
 	case eremetic.TaskError:
 		return "error"
+	case eremetic.TaskDropped:
+		return "dropped"
+	case eremetic.TaskGone, eremetic.TaskGoneByOperator:
+		return "gone"
 	case eremetic.TaskQueued:
 		return "queued"
 	}
@@ -531,5 +643,9 @@ func usage(cmds map[string]subCommand) {
 	}
 	strings.Join(commands, ", ")
 	fmt.Printf("Available sub-commands: %s\n\n", commands)
-	fmt.Println("Use hermit <sub-command> -help for more information.")
+	fmt.Println("Global options:")
+	fmt.Println()
+	flag.PrintDefaults()
+	fmt.Println()
+	fmt.Println("Use hermit [OPTION]... <sub-command> -help for more information.")
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/kardianos/osext"
 	"github.com/kelseyhightower/envconfig"
@@ -22,10 +23,24 @@ type Config struct {
 	HTTPCredentials string `yaml:"http_credentials" envconfig:"http_credentials"`
 	URLPrefix       string `yaml:"url_prefix" envconfig:"url_prefix"`
 
+	// TLS
+	TLSCert  string `yaml:"tls_cert" envconfig:"tls_cert"`
+	TLSKey   string `yaml:"tls_key" envconfig:"tls_key"`
+	ClientCA string `yaml:"client_ca" envconfig:"client_ca"`
+
+	// Admission
+	Admission AdmissionConfig `yaml:"admission" envconfig:"admission"`
+
 	// Database
 	DatabaseDriver string `yaml:"database_driver" envconfig:"database_driver"`
 	DatabasePath   string `yaml:"database" envconfig:"database"`
 
+	// Retention
+	Retention RetentionConfig `yaml:"retention" envconfig:"retention"`
+
+	// High availability
+	Election ElectionConfig `yaml:"election" envconfig:"election"`
+
 	// Mesos
 	Name             string  `yaml:"name"`
 	User             string  `yaml:"user"`
@@ -37,6 +52,137 @@ type Config struct {
 	CredentialsFile  string  `yaml:"credential_file" envconfig:"credential_file"`
 	MessengerAddress string  `yaml:"messenger_address" envconfig:"messenger_address"`
 	MessengerPort    int     `yaml:"messenger_port" envconfig:"messenger_port"`
+
+	// OfferHold is how long offers no task could use are held for tasks
+	// queued in the meantime.
+	OfferHold time.Duration `yaml:"offer_hold" envconfig:"offer_hold"`
+
+	// Partition awareness
+	Unreachable UnreachableConfig `yaml:"unreachable" envconfig:"unreachable"`
+
+	// Reconciliation
+	Reconciliation ReconciliationConfig `yaml:"reconciliation" envconfig:"reconciliation"`
+
+	// Maintenance
+	Maintenance MaintenanceConfig `yaml:"maintenance" envconfig:"maintenance"`
+}
+
+// MaintenanceConfig describes what happens to the tasks of agents going into
+// maintenance.
+type MaintenanceConfig struct {
+	// Policy is either `decline`, to keep the tasks running until the
+	// maintenance, or `accept`, to kill the restartable tasks Lead before
+	// the maintenance and launch them again elsewhere.
+	Policy string        `yaml:"policy" envconfig:"policy"`
+	Lead   time.Duration `yaml:"lead" envconfig:"lead"`
+}
+
+// ReconciliationConfig describes how the tasks are reconciled with the
+// master. Tasks are reconciled every Interval, and marked lost when the
+// master didn't report on them within Timeout. Zero values disable both.
+type ReconciliationConfig struct {
+	Interval time.Duration `yaml:"interval" envconfig:"interval"`
+	Timeout  time.Duration `yaml:"timeout" envconfig:"timeout"`
+}
+
+// UnreachableConfig describes what happens to the tasks of agents the master
+// lost contact with.
+type UnreachableConfig struct {
+	// Policy is either `wait`, to wait for the agent to come back, or
+	// `relaunch`, to kill the task and launch it again elsewhere once it
+	// has been unreachable for Timeout.
+	Policy  string        `yaml:"policy" envconfig:"policy"`
+	Timeout time.Duration `yaml:"timeout" envconfig:"timeout"`
+}
+
+// AdmissionConfig holds the rules every task request has to pass before it
+// is scheduled. Image and host path patterns may contain `*` wildcards.
+type AdmissionConfig struct {
+	AllowedImages    []string `yaml:"allowed_images" envconfig:"allowed_images"`
+	DeniedImages     []string `yaml:"denied_images" envconfig:"denied_images"`
+	DenyPrivileged   bool     `yaml:"deny_privileged" envconfig:"deny_privileged"`
+	DenyHostVolumes  bool     `yaml:"deny_host_volumes" envconfig:"deny_host_volumes"`
+	AllowedHostPaths []string `yaml:"allowed_host_paths" envconfig:"allowed_host_paths"`
+	AllowedNetworks  []string `yaml:"allowed_networks" envconfig:"allowed_networks"`
+
+	// Defaults are applied to fields the request leaves empty, overrides
+	// replace the values of the request.
+	Defaults  AdmissionMutation `yaml:"defaults" envconfig:"defaults"`
+	Overrides AdmissionMutation `yaml:"overrides" envconfig:"overrides"`
+
+	WebhookURL      string `yaml:"webhook_url" envconfig:"webhook_url"`
+	WebhookTimeout  int    `yaml:"webhook_timeout" envconfig:"webhook_timeout"`
+	WebhookFailOpen bool   `yaml:"webhook_fail_open" envconfig:"webhook_fail_open"`
+}
+
+// AdmissionMutation describes changes made to a task request on admission.
+type AdmissionMutation struct {
+	Network        string            `yaml:"network" envconfig:"network"`
+	DNS            string            `yaml:"dns" envconfig:"dns"`
+	ForcePullImage bool              `yaml:"force_pull_image" envconfig:"force_pull_image"`
+	Environment    map[string]string `yaml:"env" envconfig:"env"`
+	Labels         map[string]string `yaml:"labels" envconfig:"labels"`
+}
+
+// RetentionConfig describes how long terminated tasks are kept. Ages are
+// counted from the last status update of a task. Zero values keep tasks
+// forever.
+type RetentionConfig struct {
+	MaxAge   time.Duration `yaml:"max_age" envconfig:"max_age"`
+	MaxCount int           `yaml:"max_count" envconfig:"max_count"`
+
+	// Rules override the limits for tasks with the given labels. The first
+	// matching rule applies, and max_count is counted per rule.
+	Rules []RetentionRule `yaml:"rules" envconfig:"rules"`
+
+	Interval  time.Duration `yaml:"interval" envconfig:"interval"`
+	BatchSize int           `yaml:"batch_size" envconfig:"batch_size"`
+
+	// Archive is a file every removed task is appended to as a JSON line.
+	Archive string `yaml:"archive" envconfig:"archive"`
+}
+
+// RetentionRule overrides the retention limits for tasks with the given
+// labels.
+type RetentionRule struct {
+	Labels   map[string]string `yaml:"labels" envconfig:"labels"`
+	MaxAge   time.Duration     `yaml:"max_age" envconfig:"max_age"`
+	MaxCount int               `yaml:"max_count" envconfig:"max_count"`
+}
+
+// Enabled reports whether any retention limit is configured.
+func (r *RetentionConfig) Enabled() bool {
+	if r.MaxAge > 0 || r.MaxCount > 0 {
+		return true
+	}
+	for _, rule := range r.Rules {
+		if rule.MaxAge > 0 || rule.MaxCount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ElectionConfig configures the election of a leader among Eremetic
+// instances sharing a framework and a database.
+type ElectionConfig struct {
+	// Driver is either `zk` or `etcd`. Without driver a single instance is
+	// run.
+	Driver   string `yaml:"driver" envconfig:"driver"`
+	Location string `yaml:"location" envconfig:"location"`
+
+	// Advertise is the URL the other instances redirect scheduling
+	// requests to while this instance leads.
+	Advertise string `yaml:"advertise" envconfig:"advertise"`
+
+	// LeaseTTL is the number of seconds after which an etcd leader that
+	// stopped renewing its lease is replaced.
+	LeaseTTL int `yaml:"lease_ttl" envconfig:"lease_ttl"`
+}
+
+// Enabled reports whether leader election is configured.
+func (e *ElectionConfig) Enabled() bool {
+	return e.Driver != ""
 }
 
 // DefaultConfig returns a Config struct with the default settings
@@ -53,7 +199,19 @@ func DefaultConfig() *Config {
 		Checkpoint:      true,
 		FailoverTimeout: 2592000.0,
 		QueueSize:       100,
-		FrameworkID:     "1234",
+
+		Unreachable: UnreachableConfig{
+			Policy:  "wait",
+			Timeout: 15 * time.Minute,
+		},
+		Reconciliation: ReconciliationConfig{
+			Interval: 15 * time.Minute,
+			Timeout:  10 * time.Minute,
+		},
+		Maintenance: MaintenanceConfig{
+			Policy: "decline",
+			Lead:   15 * time.Minute,
+		},
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -20,6 +21,25 @@ func TestConfig(t *testing.T) {
 			So(conf.Address, ShouldEqual, "0.0.0.0")
 			So(conf.HTTPCredentials, ShouldEqual, "admin:admin")
 			So(conf.CredentialsFile, ShouldEqual, "/tmp/secret_file")
+			So(conf.TLSCert, ShouldEqual, "/etc/eremetic/server.crt")
+			So(conf.TLSKey, ShouldEqual, "/etc/eremetic/server.key")
+			So(conf.ClientCA, ShouldEqual, "/etc/eremetic/clients.crt")
+			So(conf.Admission.AllowedImages, ShouldResemble, []string{"registry.internal/*"})
+			So(conf.Admission.DenyPrivileged, ShouldBeTrue)
+			So(conf.Admission.Defaults.Labels, ShouldResemble, map[string]string{"team": "unknown"})
+			So(conf.Admission.WebhookURL, ShouldEqual, "https://admission.internal/review")
+			So(conf.Retention.MaxAge, ShouldEqual, 720*time.Hour)
+			So(conf.Retention.Rules, ShouldResemble, []RetentionRule{
+				{Labels: map[string]string{"team": "data"}, MaxCount: 50},
+			})
+			So(conf.Retention.Enabled(), ShouldBeTrue)
+			So(conf.Election.Enabled(), ShouldBeTrue)
+			So(conf.Election.Location, ShouldEqual, "zk://zk1:2181/eremetic")
+			So(conf.Election.Advertise, ShouldEqual, "http://eremetic-1.internal:8080")
+			So(conf.Unreachable, ShouldResemble, UnreachableConfig{Policy: "relaunch", Timeout: 15 * time.Minute})
+			So(conf.OfferHold, ShouldEqual, 2*time.Second)
+			So(conf.Reconciliation, ShouldResemble, ReconciliationConfig{Interval: 5 * time.Minute, Timeout: 10 * time.Minute})
+			So(conf.Maintenance, ShouldResemble, MaintenanceConfig{Policy: "accept", Lead: 30 * time.Minute})
 		})
 
 		Convey("ReadEnvironment", func() {
@@ -34,6 +54,7 @@ func TestConfig(t *testing.T) {
 			os.Setenv("FRAMEWORK_ID", frameworkID)
 			os.Setenv("HTTP_CREDENTIALS", httpCredentials)
 			os.Setenv("URL_PREFIX", urlPrefix)
+			os.Setenv("ADMISSION_DENIED_IMAGES", "*:latest,busybox")
 
 			ReadEnvironment(conf)
 
@@ -42,6 +63,7 @@ func TestConfig(t *testing.T) {
 			So(conf.FrameworkID, ShouldEqual, frameworkID)
 			So(conf.HTTPCredentials, ShouldEqual, httpCredentials)
 			So(conf.URLPrefix, ShouldEqual, urlPrefix)
+			So(conf.Admission.DeniedImages, ShouldResemble, []string{"*:latest", "busybox"})
 		})
 	})
 }
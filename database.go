@@ -22,27 +22,96 @@ func Encode(task *Task) ([]byte, error) {
 	return []byte(encoded), err
 }
 
+// ErrConflict is returned when a task was modified concurrently.
+var ErrConflict = errors.New("task was modified concurrently")
+
+// UpdateAttempts is the number of times UpdateTask applies an update before
+// giving up on a task that keeps being modified concurrently.
+const UpdateAttempts = 10
+
+// RetryOnConflict calls update until it doesn't fail with ErrConflict, at
+// most UpdateAttempts times.
+func RetryOnConflict(update func() error) error {
+	var err error
+	for i := 0; i < UpdateAttempts; i++ {
+		if err = update(); err != ErrConflict {
+			return err
+		}
+	}
+	return err
+}
+
 // TaskDB defines the functions needed by the database abstraction layer
 type TaskDB interface {
 	Clean() error
 	Close()
 	PutTask(task *Task) error
+	// UpdateTask reads the unmasked task, applies fn to it and writes it
+	// back if it wasn't modified in the meantime, retrying otherwise. fn is
+	// given a task with only its ID set if there is none with the id, and
+	// nothing is written if it returns an error.
+	UpdateTask(id string, fn func(*Task) error) error
 	ReadTask(id string) (Task, error)
 	DeleteTask(id string) error
 	ReadUnmaskedTask(id string) (Task, error)
 	ListTasks(filter *TaskFilter) ([]*Task, error)
+	PutQuota(quota *Quota) error
+	ReadQuota(name string) (Quota, error)
+	DeleteQuota(name string) error
+	ListQuotas() ([]*Quota, error)
+	PutTemplate(template *Template) error
+	// UpdateTemplate reads the template, applies fn to it and writes it
+	// back if it wasn't modified in the meantime, retrying otherwise. fn is
+	// given a template with only its name set if there is none with the
+	// name, and nothing is written if it returns an error.
+	UpdateTemplate(name string, fn func(*Template) error) error
+	ReadTemplate(name string) (Template, error)
+	DeleteTemplate(name string) error
+	ListTemplates() ([]*Template, error)
+	// PutFrameworkID stores the framework ID granted by the Mesos master,
+	// an empty id removes it.
+	PutFrameworkID(id string) error
+	// ReadFrameworkID returns the stored framework ID, or an empty string
+	// if there is none.
+	ReadFrameworkID() (string, error)
+}
+
+// TaskEvent describes a change made to a task in the database.
+type TaskEvent struct {
+	ID      string
+	Deleted bool
+	// Task is the new state of the task, nil if it was deleted.
+	Task *Task
+}
+
+// TaskWatcher is implemented by databases able to report changes made to
+// tasks, including changes made by other Eremetic instances. Events are
+// sent until stop is closed.
+type TaskWatcher interface {
+	WatchTasks(stop <-chan struct{}) (<-chan TaskEvent, error)
+}
+
+// TaskScanner is implemented by databases able to list the stored documents
+// of all tasks, including those that can't be decoded anymore.
+type TaskScanner interface {
+	ScanTasks(fn func(id string, data []byte) error) error
 }
 
 // DefaultTaskDB is a in-memory implementation of TaskDB.
 type DefaultTaskDB struct {
-	mtx   sync.RWMutex
-	tasks map[string]*Task
+	mtx       sync.RWMutex
+	tasks     map[string]*Task
+	quotas    map[string]*Quota
+	templates map[string]*Template
+	framework string
 }
 
 // NewDefaultTaskDB returns a new instance of TaskDB.
 func NewDefaultTaskDB() *DefaultTaskDB {
 	return &DefaultTaskDB{
-		tasks: make(map[string]*Task),
+		tasks:     make(map[string]*Task),
+		quotas:    make(map[string]*Quota),
+		templates: make(map[string]*Template),
 	}
 }
 
@@ -65,6 +134,22 @@ func (db *DefaultTaskDB) PutTask(task *Task) error {
 	return nil
 }
 
+// UpdateTask applies fn to the task with a given id while holding the lock.
+func (db *DefaultTaskDB) UpdateTask(id string, fn func(*Task) error) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	task := Task{ID: id}
+	if t, ok := db.tasks[id]; ok {
+		task = *t
+	}
+	if err := fn(&task); err != nil {
+		return err
+	}
+	task.Revision++
+	db.tasks[id] = &task
+	return nil
+}
+
 // ReadTask returns a task with a given id, or an error if not found.
 func (db *DefaultTaskDB) ReadTask(id string) (Task, error) {
 	db.mtx.RLock()
@@ -97,15 +182,128 @@ func (db *DefaultTaskDB) ReadUnmaskedTask(id string) (Task, error) {
 	return Task{}, errors.New("unknown task")
 }
 
-// ListTasks returns all tasks based on the filter.
+// ListTasks returns the page of tasks matching the filter.
 func (db *DefaultTaskDB) ListTasks(filter *TaskFilter) ([]*Task, error) {
+	c, err := NewTaskCollector(filter)
+	if err != nil {
+		return nil, err
+	}
 	db.mtx.RLock()
 	defer db.mtx.RUnlock()
-	res := []*Task{}
 	for _, t := range db.tasks {
-		if filter.Match(t) {
-			res = append(res, t)
-		}
+		c.Add(t)
+	}
+	return c.Tasks(), nil
+}
+
+// PutQuota adds or replaces a quota in the database.
+func (db *DefaultTaskDB) PutQuota(quota *Quota) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	db.quotas[quota.Name] = quota
+	return nil
+}
+
+// ReadQuota returns the quota with a given name, or an error if not found.
+func (db *DefaultTaskDB) ReadQuota(name string) (Quota, error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+	if quota, ok := db.quotas[name]; ok {
+		return *quota, nil
+	}
+	return Quota{}, errors.New("unknown quota")
+}
+
+// DeleteQuota removes the quota with a given name, or an error if not found.
+func (db *DefaultTaskDB) DeleteQuota(name string) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	if _, ok := db.quotas[name]; ok {
+		delete(db.quotas, name)
+		return nil
+	}
+	return errors.New("unknown quota")
+}
+
+// ListQuotas returns all quotas.
+func (db *DefaultTaskDB) ListQuotas() ([]*Quota, error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+	res := []*Quota{}
+	for _, q := range db.quotas {
+		res = append(res, q)
+	}
+	return res, nil
+}
+
+// PutTemplate adds or replaces a template in the database.
+func (db *DefaultTaskDB) PutTemplate(template *Template) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	db.templates[template.Name] = template
+	return nil
+}
+
+// UpdateTemplate applies fn to a template while holding the lock of the
+// database.
+func (db *DefaultTaskDB) UpdateTemplate(name string, fn func(*Template) error) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	template := Template{Name: name}
+	if t, ok := db.templates[name]; ok {
+		template = *t
+		template.History = append([]TemplateVersion(nil), t.History...)
+	}
+	if err := fn(&template); err != nil {
+		return err
+	}
+	db.templates[name] = &template
+	return nil
+}
+
+// ReadTemplate returns the template with a given name, or an error if not found.
+func (db *DefaultTaskDB) ReadTemplate(name string) (Template, error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+	if template, ok := db.templates[name]; ok {
+		return *template, nil
+	}
+	return Template{}, errors.New("unknown template")
+}
+
+// DeleteTemplate removes the template with a given name, or an error if not found.
+func (db *DefaultTaskDB) DeleteTemplate(name string) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	if _, ok := db.templates[name]; ok {
+		delete(db.templates, name)
+		return nil
+	}
+	return errors.New("unknown template")
+}
+
+// ListTemplates returns all templates.
+func (db *DefaultTaskDB) ListTemplates() ([]*Template, error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+	res := []*Template{}
+	for _, t := range db.templates {
+		res = append(res, t)
 	}
 	return res, nil
 }
+
+// PutFrameworkID stores the framework ID.
+func (db *DefaultTaskDB) PutFrameworkID(id string) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	db.framework = id
+	return nil
+}
+
+// ReadFrameworkID returns the stored framework ID.
+func (db *DefaultTaskDB) ReadFrameworkID() (string, error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+	return db.framework, nil
+}
@@ -0,0 +1,88 @@
+package eremetic
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDatabase(t *testing.T) {
+	Convey("RetryOnConflict", t, func() {
+		Convey("Retries conflicting updates", func() {
+			calls := 0
+			err := RetryOnConflict(func() error {
+				calls++
+				if calls < 3 {
+					return ErrConflict
+				}
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 3)
+		})
+
+		Convey("Gives up after UpdateAttempts", func() {
+			calls := 0
+			err := RetryOnConflict(func() error {
+				calls++
+				return ErrConflict
+			})
+			So(err, ShouldEqual, ErrConflict)
+			So(calls, ShouldEqual, UpdateAttempts)
+		})
+
+		Convey("Returns other errors", func() {
+			calls := 0
+			err := RetryOnConflict(func() error {
+				calls++
+				return errors.New("nope")
+			})
+			So(err.Error(), ShouldEqual, "nope")
+			So(calls, ShouldEqual, 1)
+		})
+	})
+
+	Convey("DefaultTaskDB.UpdateTask", t, func() {
+		db := NewDefaultTaskDB()
+		db.PutTask(&Task{ID: "1234", Name: "etl"})
+
+		So(db.UpdateTask("1234", func(t *Task) error {
+			t.Hostname = "agent1"
+			return nil
+		}), ShouldBeNil)
+		task, _ := db.ReadUnmaskedTask("1234")
+		So(task.Hostname, ShouldEqual, "agent1")
+		So(task.Revision, ShouldEqual, 1)
+
+		So(db.UpdateTask("1234", func(t *Task) error {
+			t.Hostname = "agent2"
+			return errors.New("nope")
+		}), ShouldNotBeNil)
+		task, _ = db.ReadUnmaskedTask("1234")
+		So(task.Hostname, ShouldEqual, "agent1")
+	})
+	Convey("DefaultTaskDB.UpdateTemplate", t, func() {
+		db := NewDefaultTaskDB()
+
+		So(db.UpdateTemplate("etl", func(t *Template) error {
+			t.Revise(Request{DockerImage: "etl:1.0"})
+			return nil
+		}), ShouldBeNil)
+		So(db.UpdateTemplate("etl", func(t *Template) error {
+			t.Revise(Request{DockerImage: "etl:1.1"})
+			return nil
+		}), ShouldBeNil)
+		template, _ := db.ReadTemplate("etl")
+		So(template.Version, ShouldEqual, 2)
+		So(template.History, ShouldHaveLength, 1)
+
+		So(db.UpdateTemplate("etl", func(t *Template) error {
+			t.Revise(Request{DockerImage: "etl:1.2"})
+			return errors.New("nope")
+		}), ShouldNotBeNil)
+		template, _ = db.ReadTemplate("etl")
+		So(template.Version, ShouldEqual, 2)
+		So(template.History, ShouldHaveLength, 1)
+	})
+}
@@ -0,0 +1,34 @@
+package eremetic
+
+import "time"
+
+// Reasons an offer is rejected for a task.
+const (
+	RejectedCPU         = "cpu"
+	RejectedMem         = "mem"
+	RejectedAttribute   = "attribute"
+	RejectedMaintenance = "maintenance"
+	RejectedPorts       = "ports"
+)
+
+// OfferEvaluation is the outcome of matching an offer against a task.
+type OfferEvaluation struct {
+	OfferID  string    `json:"offer_id"`
+	AgentID  string    `json:"agent_id"`
+	Hostname string    `json:"hostname"`
+	Time     time.Time `json:"time"`
+	// Rejection is the reason the offer was rejected for, empty if the
+	// task could be launched with it.
+	Rejection string `json:"rejection,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// TaskDiagnostics explains why a queued task hasn't been launched yet.
+type TaskDiagnostics struct {
+	TaskID string `json:"task_id"`
+	Queued bool   `json:"queued"`
+	// Offers are the last offers evaluated for the task, oldest first.
+	Offers []OfferEvaluation `json:"offers"`
+	// Rejections counts the rejected offers by reason.
+	Rejections map[string]int `json:"rejections"`
+}
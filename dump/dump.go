@@ -0,0 +1,361 @@
+// Package dump exports the content of a task database as JSON lines, and
+// imports it into any other task database.
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// Kinds of records.
+const (
+	KindFramework = "framework"
+	KindQuota     = "quota"
+	KindTemplate  = "template"
+	KindTask      = "task"
+)
+
+// pageSize is the number of tasks read from the database at once.
+const pageSize = 100
+
+// Record is a single line of a dump, holding one object of the database.
+type Record struct {
+	Kind        string             `json:"kind"`
+	FrameworkID string             `json:"framework_id,omitempty"`
+	Quota       *eremetic.Quota    `json:"quota,omitempty"`
+	Template    *eremetic.Template `json:"template,omitempty"`
+	Task        *eremetic.Task     `json:"task,omitempty"`
+}
+
+// Counts holds the number of records of each kind.
+type Counts struct {
+	Framework int
+	Quotas    int
+	Templates int
+	Tasks     int
+	// Masked is the number of tasks and templates whose masked environment
+	// was replaced by the masking string, when exporting or importing.
+	Masked int
+}
+
+func (c *Counts) add(rec *Record) {
+	switch rec.Kind {
+	case KindFramework:
+		c.Framework++
+	case KindQuota:
+		c.Quotas++
+	case KindTemplate:
+		c.Templates++
+	case KindTask:
+		c.Tasks++
+	}
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%d tasks, %d quotas, %d templates, %d framework ID", c.Tasks, c.Quotas, c.Templates, c.Framework)
+}
+
+// isRecordMasked returns whether the masked environment of a task or a
+// template record holds the masking string.
+func isRecordMasked(rec *Record) bool {
+	switch {
+	case rec.Kind == KindTask && rec.Task != nil:
+		return isMasked(rec.Task.MaskedEnvironment)
+	case rec.Kind == KindTemplate && rec.Template != nil:
+		return isTemplateMasked(rec.Template)
+	}
+	return false
+}
+
+func isMasked(env map[string]string) bool {
+	for _, v := range env {
+		if v == eremetic.Masking {
+			return true
+		}
+	}
+	return false
+}
+
+func isTemplateMasked(template *eremetic.Template) bool {
+	if isMasked(template.Request.MaskedEnvironment) {
+		return true
+	}
+	for _, v := range template.History {
+		if isMasked(v.Request.MaskedEnvironment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Each calls fn with every record of the database: the framework ID, the
+// quotas, the templates and then the tasks, a page at a time. The masked
+// environment of the templates and tasks is only read when unmasked is set.
+func Each(db eremetic.TaskDB, unmasked bool, fn func(*Record) error) error {
+	id, err := db.ReadFrameworkID()
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if err := fn(&Record{Kind: KindFramework, FrameworkID: id}); err != nil {
+			return err
+		}
+	}
+
+	quotas, err := db.ListQuotas()
+	if err != nil {
+		return err
+	}
+	for _, q := range quotas {
+		if err := fn(&Record{Kind: KindQuota, Quota: q}); err != nil {
+			return err
+		}
+	}
+
+	templates, err := db.ListTemplates()
+	if err != nil {
+		return err
+	}
+	for _, t := range templates {
+		template := t
+		if !unmasked {
+			masked := *t
+			eremetic.ApplyTemplateMask(&masked)
+			template = &masked
+		}
+		if err := fn(&Record{Kind: KindTemplate, Template: template}); err != nil {
+			return err
+		}
+	}
+
+	filter := &eremetic.TaskFilter{Limit: pageSize}
+	for {
+		tasks, err := db.ListTasks(filter)
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			task := *t
+			if unmasked {
+				u, err := db.ReadUnmaskedTask(t.ID)
+				if err != nil {
+					return err
+				}
+				task = u
+			} else {
+				// Not every database masks the tasks it lists.
+				task.MaskedEnvironment = mask(t.MaskedEnvironment)
+			}
+			if err := fn(&Record{Kind: KindTask, Task: &task}); err != nil {
+				return err
+			}
+		}
+		if len(tasks) < pageSize {
+			return nil
+		}
+		filter.Cursor = filter.CursorAfter(tasks[len(tasks)-1])
+	}
+}
+
+// mask returns a copy of a masked environment holding the masking string in
+// place of the values.
+func mask(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(env))
+	for k := range env {
+		masked[k] = eremetic.Masking
+	}
+	return masked
+}
+
+// Put writes a record to the database, replacing the object with the same
+// name or id.
+func Put(db eremetic.TaskDB, rec *Record) error {
+	switch {
+	case rec.Kind == KindFramework && rec.FrameworkID != "":
+		return db.PutFrameworkID(rec.FrameworkID)
+	case rec.Kind == KindQuota && rec.Quota != nil:
+		return db.PutQuota(rec.Quota)
+	case rec.Kind == KindTemplate && rec.Template != nil:
+		return db.PutTemplate(rec.Template)
+	case rec.Kind == KindTask && rec.Task != nil && rec.Task.ID != "":
+		return db.PutTask(rec.Task)
+	}
+	return fmt.Errorf("invalid %q record", rec.Kind)
+}
+
+// Export writes every record of the database to w, one JSON document per
+// line.
+func Export(db eremetic.TaskDB, w io.Writer, unmasked bool) (Counts, error) {
+	var counts Counts
+	enc := json.NewEncoder(w)
+	err := Each(db, unmasked, func(rec *Record) error {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+		counts.add(rec)
+		if isRecordMasked(rec) {
+			counts.Masked++
+		}
+		return nil
+	})
+	return counts, err
+}
+
+// Import writes the records read from r to the database. Masked environment
+// variables holding the masking string, as exported without unmasked, keep
+// the value stored in the database. Import fails for those without a stored
+// value, unless force is set and the masking string is written as is.
+func Import(db eremetic.TaskDB, r io.Reader, force bool) (Counts, error) {
+	var counts Counts
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return counts, nil
+		}
+		if err != nil {
+			return counts, fmt.Errorf("record %d: %s", line, err)
+		}
+		masked := isRecordMasked(&rec)
+		if err := keepStored(db, &rec, force); err != nil {
+			return counts, fmt.Errorf("record %d: %s", line, err)
+		}
+		if err := Put(db, &rec); err != nil {
+			return counts, fmt.Errorf("record %d: %s", line, err)
+		}
+		counts.add(&rec)
+		if masked {
+			counts.Masked++
+		}
+	}
+}
+
+// keepStored replaces the masking string in the masked environment of a
+// task or template record with the values stored in the database. A record
+// missing from the database has no stored values.
+func keepStored(db eremetic.TaskDB, rec *Record, force bool) error {
+	if !isRecordMasked(rec) {
+		return nil
+	}
+	switch rec.Kind {
+	case KindTask:
+		stored, _ := db.ReadUnmaskedTask(rec.Task.ID)
+		return unmask(rec.Task.MaskedEnvironment, stored.MaskedEnvironment, force)
+	case KindTemplate:
+		stored, _ := db.ReadTemplate(rec.Template.Name)
+		request, _ := stored.At(rec.Template.Version)
+		if err := unmask(rec.Template.Request.MaskedEnvironment, request.MaskedEnvironment, force); err != nil {
+			return err
+		}
+		for _, v := range rec.Template.History {
+			request, _ := stored.At(v.Version)
+			if err := unmask(v.Request.MaskedEnvironment, request.MaskedEnvironment, force); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unmask sets the variables of env holding the masking string to their
+// value in stored.
+func unmask(env, stored map[string]string, force bool) error {
+	for k, v := range env {
+		if v != eremetic.Masking {
+			continue
+		}
+		value, ok := stored[k]
+		if !ok && !force {
+			return fmt.Errorf("%s is masked and has no stored value, import with --force to write the masking string", k)
+		}
+		if ok {
+			env[k] = value
+		}
+	}
+	return nil
+}
+
+// Report describes the outcome of a migration.
+type Report struct {
+	// Copied counts the records written to the destination.
+	Copied Counts
+	// Verified counts the copied records read back from the destination.
+	Verified Counts
+}
+
+// Migrate copies every record, including the masked environment of the
+// tasks, from one database to another, and reads them back from the
+// destination. It fails if any copied record can't be found there.
+func Migrate(from, to eremetic.TaskDB) (Report, error) {
+	var (
+		report  Report
+		written []Record
+	)
+	err := Each(from, true, func(rec *Record) error {
+		if err := Put(to, rec); err != nil {
+			return err
+		}
+		report.Copied.add(rec)
+		written = append(written, key(rec))
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for i := range written {
+		rec := &written[i]
+		found, err := exists(to, rec)
+		if err != nil {
+			return report, err
+		}
+		if found {
+			report.Verified.add(rec)
+		}
+	}
+	if report.Verified != report.Copied {
+		return report, fmt.Errorf("copied %s, but only found %s", report.Copied, report.Verified)
+	}
+	return report, nil
+}
+
+// key returns a record only holding what identifies the object, so that the
+// copied tasks don't need to be kept around until they are verified.
+func key(rec *Record) Record {
+	k := Record{Kind: rec.Kind, FrameworkID: rec.FrameworkID}
+	switch rec.Kind {
+	case KindQuota:
+		k.Quota = &eremetic.Quota{Name: rec.Quota.Name}
+	case KindTemplate:
+		k.Template = &eremetic.Template{Name: rec.Template.Name}
+	case KindTask:
+		k.Task = &eremetic.Task{ID: rec.Task.ID}
+	}
+	return k
+}
+
+// exists returns whether the object of a record is in the database.
+func exists(db eremetic.TaskDB, rec *Record) (bool, error) {
+	switch rec.Kind {
+	case KindFramework:
+		id, err := db.ReadFrameworkID()
+		return id == rec.FrameworkID, err
+	case KindQuota:
+		_, err := db.ReadQuota(rec.Quota.Name)
+		return err == nil, nil
+	case KindTemplate:
+		_, err := db.ReadTemplate(rec.Template.Name)
+		return err == nil, nil
+	case KindTask:
+		task, err := db.ReadUnmaskedTask(rec.Task.ID)
+		return err == nil && task.ID == rec.Task.ID, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,184 @@
+package dump
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// forgetfulTaskDB drops the tasks written to it.
+type forgetfulTaskDB struct {
+	*eremetic.DefaultTaskDB
+}
+
+func (db forgetfulTaskDB) PutTask(*eremetic.Task) error {
+	return nil
+}
+
+func populate(db eremetic.TaskDB, tasks int) {
+	db.PutFrameworkID("1234-0001")
+	db.PutQuota(&eremetic.Quota{Name: "team", Owner: "alice", MaxRunning: 2})
+	db.PutTemplate(&eremetic.Template{
+		Name:    "etl",
+		Version: 1,
+		Request: eremetic.Request{MaskedEnvironment: map[string]string{"TOKEN": "s3cr3t"}},
+	})
+	for i := 0; i < tasks; i++ {
+		db.PutTask(&eremetic.Task{
+			ID:                fmt.Sprintf("eremetic-task.%03d", i),
+			MaskedEnvironment: map[string]string{"SECRET": "hunter2"},
+			Status:            []eremetic.Status{{Status: eremetic.TaskRunning, Time: int64(i)}},
+		})
+	}
+}
+
+func TestDump(t *testing.T) {
+	Convey("Export", t, func() {
+		db := eremetic.NewDefaultTaskDB()
+		populate(db, pageSize+10)
+
+		var buf bytes.Buffer
+		counts, err := Export(db, &buf, true)
+
+		So(err, ShouldBeNil)
+		So(counts, ShouldResemble, Counts{Framework: 1, Quotas: 1, Templates: 1, Tasks: pageSize + 10})
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		So(lines, ShouldHaveLength, pageSize+13)
+		So(lines[0], ShouldEqual, `{"kind":"framework","framework_id":"1234-0001"}`)
+		So(lines[3], ShouldContainSubstring, `"kind":"task"`)
+		So(lines[2], ShouldContainSubstring, `s3cr3t`)
+		So(lines[3], ShouldContainSubstring, `hunter2`)
+
+		Convey("masks templates and tasks unless unmasked", func() {
+			var buf bytes.Buffer
+			counts, err := Export(db, &buf, false)
+
+			So(err, ShouldBeNil)
+			So(counts.Masked, ShouldEqual, pageSize+11)
+			So(buf.String(), ShouldNotContainSubstring, `s3cr3t`)
+			So(buf.String(), ShouldNotContainSubstring, `hunter2`)
+
+			template, _ := db.ReadTemplate("etl")
+			So(template.Request.MaskedEnvironment["TOKEN"], ShouldEqual, "s3cr3t")
+		})
+	})
+
+	Convey("Import", t, func() {
+		Convey("Writes every record", func() {
+			src := eremetic.NewDefaultTaskDB()
+			populate(src, 3)
+			var buf bytes.Buffer
+			Export(src, &buf, true)
+
+			db := eremetic.NewDefaultTaskDB()
+			counts, err := Import(db, &buf, false)
+
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, Counts{Framework: 1, Quotas: 1, Templates: 1, Tasks: 3})
+
+			id, _ := db.ReadFrameworkID()
+			So(id, ShouldEqual, "1234-0001")
+			task, err := db.ReadUnmaskedTask("eremetic-task.002")
+			So(err, ShouldBeNil)
+			So(task.MaskedEnvironment["SECRET"], ShouldEqual, "hunter2")
+			_, err = db.ReadQuota("team")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Keeps the stored values of masked variables", func() {
+			db := eremetic.NewDefaultTaskDB()
+			populate(db, 3)
+			db.UpdateTemplate("etl", func(t *eremetic.Template) error {
+				t.Revise(eremetic.Request{MaskedEnvironment: map[string]string{"TOKEN": "n3w"}})
+				return nil
+			})
+			var buf bytes.Buffer
+			Export(db, &buf, false)
+
+			counts, err := Import(db, &buf, false)
+
+			So(err, ShouldBeNil)
+			So(counts.Tasks, ShouldEqual, 3)
+			So(counts.Masked, ShouldEqual, 4)
+
+			task, _ := db.ReadUnmaskedTask("eremetic-task.002")
+			So(task.MaskedEnvironment["SECRET"], ShouldEqual, "hunter2")
+			template, _ := db.ReadTemplate("etl")
+			So(template.Request.MaskedEnvironment["TOKEN"], ShouldEqual, "n3w")
+			So(template.History[0].Request.MaskedEnvironment["TOKEN"], ShouldEqual, "s3cr3t")
+		})
+
+		Convey("Refuses masked variables without a stored value", func() {
+			in := `{"kind":"task","task":{"ID":"eremetic-task.1","MaskedEnvironment":{"SECRET":"*******"}}}`
+			db := eremetic.NewDefaultTaskDB()
+
+			_, err := Import(db, strings.NewReader(in), false)
+
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "SECRET is masked")
+			_, err = db.ReadTask("eremetic-task.1")
+			So(err, ShouldNotBeNil)
+
+			Convey("unless forced", func() {
+				counts, err := Import(db, strings.NewReader(in), true)
+
+				So(err, ShouldBeNil)
+				So(counts.Tasks, ShouldEqual, 1)
+				So(counts.Masked, ShouldEqual, 1)
+				task, _ := db.ReadUnmaskedTask("eremetic-task.1")
+				So(task.MaskedEnvironment["SECRET"], ShouldEqual, eremetic.Masking)
+			})
+		})
+
+		Convey("Reports the invalid record", func() {
+			in := `{"kind":"quota","quota":{"Name":"team"}}` + "\n" + `{"kind":"task","task":{}}`
+
+			counts, err := Import(eremetic.NewDefaultTaskDB(), strings.NewReader(in), false)
+
+			So(err, ShouldResemble, errors.New(`record 2: invalid "task" record`))
+			So(counts.Quotas, ShouldEqual, 1)
+		})
+
+		Convey("Reports malformed JSON", func() {
+			_, err := Import(eremetic.NewDefaultTaskDB(), strings.NewReader(`{"kind":`), false)
+
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "record 1:")
+		})
+	})
+
+	Convey("Migrate", t, func() {
+		from := eremetic.NewDefaultTaskDB()
+		populate(from, pageSize+1)
+
+		Convey("Copies and verifies every record", func() {
+			to := eremetic.NewDefaultTaskDB()
+
+			report, err := Migrate(from, to)
+
+			So(err, ShouldBeNil)
+			So(report.Copied.Tasks, ShouldEqual, pageSize+1)
+			So(report.Verified, ShouldResemble, report.Copied)
+
+			task, err := to.ReadUnmaskedTask("eremetic-task.100")
+			So(err, ShouldBeNil)
+			So(task.MaskedEnvironment["SECRET"], ShouldEqual, "hunter2")
+		})
+
+		Convey("Fails when records are missing from the destination", func() {
+			report, err := Migrate(from, forgetfulTaskDB{eremetic.NewDefaultTaskDB()})
+
+			So(err, ShouldNotBeNil)
+			So(report.Copied.Tasks, ShouldEqual, pageSize+1)
+			So(report.Verified.Tasks, ShouldEqual, 0)
+			So(report.Verified.Quotas, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,30 @@
+package eremetic
+
+import "errors"
+
+// ErrNotElected is returned by Campaign when it is stopped before this
+// instance is elected.
+var ErrNotElected = errors.New("campaign stopped before being elected")
+
+// Leadership reports which of the Eremetic instances sharing a framework
+// is the leader.
+type Leadership interface {
+	// IsLeader returns whether this instance is currently the leader.
+	IsLeader() bool
+	// Leader returns the address of the current leader, empty if there is
+	// none.
+	Leader() (string, error)
+}
+
+// Elector elects the leader of the Eremetic instances sharing a framework.
+// Only the leader runs the scheduler, the other instances serve reads from
+// the shared database and redirect scheduling requests to the leader.
+type Elector interface {
+	Leadership
+	// Campaign blocks until this instance is elected or stop is closed, in
+	// which case it returns ErrNotElected. The returned channel is closed
+	// when the leadership is lost.
+	Campaign(stop <-chan struct{}) (<-chan struct{}, error)
+	// Close gives up the leadership or candidacy of this instance.
+	Close()
+}
@@ -0,0 +1,189 @@
+package etcd
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// DefaultLeaseTTL is the number of seconds after which the leadership of an
+// instance that stopped renewing its lease expires.
+const DefaultLeaseTTL = 10
+
+// Elector elects the leader of the Eremetic instances through etcd. The
+// leader holds the `<prefix>/leader` key, attached to a lease it keeps
+// alive, the other instances watch the key until it is removed.
+type Elector struct {
+	conn    connection
+	key     string
+	address string
+	ttl     int64
+
+	mtx    sync.Mutex
+	lease  int64
+	leader bool
+	resign chan struct{}
+}
+
+// NewElector returns a new instance of an etcd Elector. The location has the
+// same form as the one of the database, address is the address other
+// instances reach this one at. ttl is the lease TTL in seconds,
+// DefaultLeaseTTL if zero.
+func NewElector(location, address string, ttl int64) (*Elector, error) {
+	return newCustomElector(defaultConnector{}, location, address, ttl)
+}
+
+func newCustomElector(c connector, location, address string, ttl int64) (*Elector, error) {
+	if location == "" {
+		return nil, errors.New("missing etcd location")
+	}
+
+	endpoints, prefix, err := parseLocation(location)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := c.Connect(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	return &Elector{
+		conn:    conn,
+		key:     prefix + "/leader",
+		address: address,
+		ttl:     ttl,
+	}, nil
+}
+
+// Campaign tries to create the leader key, and waits for it to be removed
+// while another instance holds it.
+func (e *Elector) Campaign(stop <-chan struct{}) (<-chan struct{}, error) {
+	for {
+		lease, err := e.conn.Grant(e.ttl)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := e.conn.Create(e.key, []byte(e.address), lease)
+		if err != nil {
+			e.conn.Revoke(lease)
+			return nil, err
+		}
+		if ok {
+			return e.elected(lease), nil
+		}
+		e.conn.Revoke(lease)
+
+		if err := e.waitForVacancy(stop); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitForVacancy returns once the leader key has been removed.
+func (e *Elector) waitForVacancy(stop <-chan struct{}) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	changes, err := e.conn.Watch(e.key, done)
+	if err != nil {
+		return err
+	}
+
+	// The key may have been removed before the watch started.
+	if kv, err := e.conn.Get(e.key); err != nil || kv == nil {
+		return err
+	}
+
+	logrus.Debug("Waiting for the leadership")
+	for {
+		select {
+		case kv, ok := <-changes:
+			if !ok {
+				return errors.New("etcd watch ended")
+			}
+			if kv.Key == e.key && kv.Value == nil {
+				return nil
+			}
+		case <-stop:
+			return eremetic.ErrNotElected
+		}
+	}
+}
+
+// elected keeps the lease alive until it can't be renewed or the leader
+// resigns.
+func (e *Elector) elected(lease int64) <-chan struct{} {
+	resign := make(chan struct{})
+
+	e.mtx.Lock()
+	e.lease = lease
+	e.leader = true
+	e.resign = resign
+	e.mtx.Unlock()
+
+	lost := make(chan struct{})
+	go func() {
+		defer close(lost)
+
+		interval := time.Duration(e.ttl) * time.Second / 3
+		for {
+			select {
+			case <-resign:
+				return
+			case <-time.After(interval):
+			}
+			if err := e.conn.KeepAlive(lease); err != nil {
+				logrus.WithError(err).Warn("Lost the leadership")
+
+				e.mtx.Lock()
+				e.leader = false
+				e.mtx.Unlock()
+				return
+			}
+		}
+	}()
+	return lost
+}
+
+// IsLeader returns whether this instance is currently the leader.
+func (e *Elector) IsLeader() bool {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.leader
+}
+
+// Leader returns the address held by the leader key.
+func (e *Elector) Leader() (string, error) {
+	kv, err := e.conn.Get(e.key)
+	if err != nil || kv == nil {
+		return "", err
+	}
+	return string(kv.Value), nil
+}
+
+// Close revokes the lease of the leader, which removes the leader key.
+func (e *Elector) Close() {
+	e.mtx.Lock()
+	lease, resign := e.lease, e.resign
+	e.lease, e.resign = 0, nil
+	e.leader = false
+	e.mtx.Unlock()
+
+	if resign != nil {
+		close(resign)
+	}
+	if lease != 0 {
+		if err := e.conn.Revoke(lease); err != nil {
+			logrus.WithError(err).Warn("Unable to revoke the leader lease")
+		}
+	}
+	e.conn.Close()
+}
@@ -0,0 +1,113 @@
+package etcd
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+func TestEtcdElector(t *testing.T) {
+	cluster, newLocation := testCluster()
+
+	Convey("Elector", t, func() {
+		location := newLocation()
+
+		first, err := newCustomElector(cluster, location, "http://eremetic-1:8080", 1)
+		So(err, ShouldBeNil)
+		second, err := newCustomElector(cluster, location, "http://eremetic-2:8080", 1)
+		So(err, ShouldBeNil)
+		defer second.Close()
+
+		stop := make(chan struct{})
+		defer close(stop)
+
+		lost, err := first.Campaign(stop)
+		So(err, ShouldBeNil)
+		So(first.IsLeader(), ShouldBeTrue)
+
+		leader, err := second.Leader()
+		So(err, ShouldBeNil)
+		So(leader, ShouldEqual, "http://eremetic-1:8080")
+
+		Convey("Standbys take over when the leader resigns", func() {
+			elected := make(chan error)
+			go func() {
+				_, err := second.Campaign(stop)
+				elected <- err
+			}()
+
+			select {
+			case <-elected:
+				So("second was elected while first leads", ShouldBeEmpty)
+			case <-time.After(100 * time.Millisecond):
+			}
+			So(second.IsLeader(), ShouldBeFalse)
+
+			first.Close()
+			<-lost
+			So(<-elected, ShouldBeNil)
+			So(second.IsLeader(), ShouldBeTrue)
+
+			leader, _ := second.Leader()
+			So(leader, ShouldEqual, "http://eremetic-2:8080")
+		})
+
+		Convey("The leadership is lost when the lease expires", func() {
+			defer first.Close()
+
+			// Revoking the lease from another connection stands for its
+			// expiry.
+			first.mtx.Lock()
+			lease := first.lease
+			first.mtx.Unlock()
+			So(second.conn.Revoke(lease), ShouldBeNil)
+
+			select {
+			case <-lost:
+			case <-time.After(5 * time.Second):
+			}
+			So(first.IsLeader(), ShouldBeFalse)
+
+			leader, err := second.Leader()
+			So(err, ShouldBeNil)
+			So(leader, ShouldBeEmpty)
+		})
+
+		Convey("Keys expire with leases that aren't kept alive", func() {
+			defer first.Close()
+
+			lease, err := second.conn.Grant(1)
+			So(err, ShouldBeNil)
+			ok, err := second.conn.Create(second.key+"-test", []byte("expiring"), lease)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			// etcd may round the TTL up to its minimum.
+			deadline := time.Now().Add(5 * time.Second)
+			kv, _ := second.conn.Get(second.key + "-test")
+			for kv != nil && time.Now().Before(deadline) {
+				time.Sleep(100 * time.Millisecond)
+				kv, _ = second.conn.Get(second.key + "-test")
+			}
+			So(kv, ShouldBeNil)
+			So(second.conn.KeepAlive(lease), ShouldNotBeNil)
+
+			// The leader keeps its own lease alive meanwhile.
+			So(first.IsLeader(), ShouldBeTrue)
+			leader, _ := second.Leader()
+			So(leader, ShouldEqual, "http://eremetic-1:8080")
+		})
+
+		Convey("Campaigns can be stopped", func() {
+			defer first.Close()
+
+			stopped := make(chan struct{})
+			close(stopped)
+			_, err := second.Campaign(stopped)
+			So(err, ShouldEqual, eremetic.ErrNotElected)
+		})
+	})
+}
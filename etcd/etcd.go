@@ -0,0 +1,446 @@
+// Package etcd implements the task database on top of an etcd v3 cluster.
+package etcd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// keyValue is a key stored in etcd with the revision it was last modified
+// at.
+type keyValue struct {
+	Key         string
+	Value       []byte
+	ModRevision int64
+}
+
+// connection defines the etcd operations needed by the database
+type connection interface {
+	Close()
+	Get(key string) (*keyValue, error)
+	Range(key string, prefix bool) ([]*keyValue, error)
+	Put(key string, value []byte) error
+	// PutIf writes the key if its revision is still the given one, zero
+	// meaning the key doesn't exist.
+	PutIf(key string, value []byte, revision int64) (bool, error)
+	// Create writes the key, attached to the lease, if it doesn't exist.
+	Create(key string, value []byte, lease int64) (bool, error)
+	Delete(key string, prefix bool) error
+	// Watch sends the changes made to the keys starting with prefix until
+	// stop is closed. Deleted keys have no value.
+	Watch(prefix string, stop <-chan struct{}) (<-chan *keyValue, error)
+	// Grant creates a lease expiring after ttl seconds without keep alive.
+	Grant(ttl int64) (int64, error)
+	KeepAlive(lease int64) error
+	// Revoke removes a lease and the keys attached to it.
+	Revoke(lease int64) error
+}
+
+// connector helps create an etcd connection
+type connector interface {
+	Connect(endpoints []string) (connection, error)
+}
+
+// TaskDB is an etcd implementation of the task database. Tasks, quotas and
+// templates are stored as JSON below `<prefix>/tasks/`, `<prefix>/quotas/`
+// and `<prefix>/templates/`, the framework ID at `<prefix>/framework_id`.
+type TaskDB struct {
+	conn   connection
+	prefix string
+}
+
+// NewTaskDB returns a new instance of an etcd TaskDB. The location has the
+// form `etcd://host1:2379,host2:2379/prefix`, or `etcds://...` to connect
+// over TLS.
+func NewTaskDB(location string) (*TaskDB, error) {
+	return newCustomTaskDB(defaultConnector{}, location)
+}
+
+func parseLocation(location string) ([]string, string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, "", err
+	}
+
+	scheme := "http"
+	switch u.Scheme {
+	case "etcd":
+	case "etcds":
+		scheme = "https"
+	default:
+		return nil, "", fmt.Errorf("invalid etcd location scheme %q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, "", errors.New("missing etcd endpoints")
+	}
+	endpoints := []string{}
+	for _, host := range strings.Split(u.Host, ",") {
+		endpoints = append(endpoints, fmt.Sprintf("%s://%s", scheme, host))
+	}
+	return endpoints, strings.TrimRight(u.Path, "/"), nil
+}
+
+func newCustomTaskDB(c connector, location string) (*TaskDB, error) {
+	if location == "" {
+		return nil, errors.New("missing etcd location")
+	}
+
+	endpoints, prefix, err := parseLocation(location)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := c.Connect(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TaskDB{
+		conn:   conn,
+		prefix: prefix,
+	}, nil
+}
+
+func (db *TaskDB) key(kind, name string) string {
+	return fmt.Sprintf("%s/%s/%s", db.prefix, kind, name)
+}
+
+// Close closes the connection to the database.
+func (db *TaskDB) Close() {
+	db.conn.Close()
+}
+
+// Clean removes all tasks from the database.
+func (db *TaskDB) Clean() error {
+	return db.conn.Delete(db.key("tasks", ""), true)
+}
+
+// PutTask stores a task in the database, replacing any stored version of
+// it. Updates that must not overwrite concurrent changes use UpdateTask.
+func (db *TaskDB) PutTask(task *eremetic.Task) error {
+	encoded, err := eremetic.Encode(task)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to encode task to byte-array.")
+		return err
+	}
+	return db.conn.Put(db.key("tasks", task.ID), encoded)
+}
+
+// UpdateTask applies fn to a task and writes it if its revision is still the
+// one that was read, retrying otherwise.
+func (db *TaskDB) UpdateTask(id string, fn func(*eremetic.Task) error) error {
+	key := db.key("tasks", id)
+
+	return eremetic.RetryOnConflict(func() error {
+		task := eremetic.Task{ID: id}
+
+		kv, err := db.conn.Get(key)
+		if err != nil {
+			return err
+		}
+		var revision int64
+		if kv != nil {
+			if err := json.Unmarshal(kv.Value, &task); err != nil {
+				return err
+			}
+			revision = kv.ModRevision
+		}
+
+		if err := fn(&task); err != nil {
+			return err
+		}
+		task.Revision++
+
+		encoded, err := eremetic.Encode(&task)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to encode task to byte-array.")
+			return err
+		}
+
+		ok, err := db.conn.PutIf(key, encoded, revision)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return eremetic.ErrConflict
+		}
+		return nil
+	})
+}
+
+// ReadTask fetches a task from the database and applies a mask to the
+// MaskedEnvironment field
+func (db *TaskDB) ReadTask(id string) (eremetic.Task, error) {
+	task, err := db.ReadUnmaskedTask(id)
+
+	eremetic.ApplyMask(&task)
+
+	return task, err
+}
+
+// ReadUnmaskedTask fetches a task from the database and does not mask the
+// MaskedEnvironment field.
+// This function should be considered internal to Eremetic, and is used where
+// we need to fetch a task and then re-save it to the database. It should not
+// be returned to the API.
+func (db *TaskDB) ReadUnmaskedTask(id string) (eremetic.Task, error) {
+	var task eremetic.Task
+
+	kv, err := db.conn.Get(db.key("tasks", id))
+	if err != nil {
+		return task, err
+	}
+	if kv == nil {
+		return task, errors.New("unknown task")
+	}
+
+	err = json.Unmarshal(kv.Value, &task)
+	return task, err
+}
+
+// DeleteTask deletes a task matching the given id.
+func (db *TaskDB) DeleteTask(id string) error {
+	return db.conn.Delete(db.key("tasks", id), false)
+}
+
+// ListTasks returns the page of tasks matching the filter.
+func (db *TaskDB) ListTasks(filter *eremetic.TaskFilter) ([]*eremetic.Task, error) {
+	c, err := eremetic.NewTaskCollector(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	kvs, err := db.conn.Range(db.key("tasks", ""), true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kv := range kvs {
+		var task eremetic.Task
+		if err := json.Unmarshal(kv.Value, &task); err != nil {
+			logrus.WithError(err).WithField("key", kv.Key).Debug("Unable to decode task")
+			continue
+		}
+		eremetic.ApplyMask(&task)
+		c.Add(&task)
+	}
+	return c.Tasks(), nil
+}
+
+// ScanTasks calls fn with the stored document of every task.
+func (db *TaskDB) ScanTasks(fn func(id string, data []byte) error) error {
+	prefix := db.key("tasks", "")
+	kvs, err := db.conn.Range(prefix, true)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		if err := fn(strings.TrimPrefix(kv.Key, prefix), kv.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchTasks sends the changes made to tasks, by this or any other Eremetic
+// instance, until stop is closed.
+func (db *TaskDB) WatchTasks(stop <-chan struct{}) (<-chan eremetic.TaskEvent, error) {
+	prefix := db.key("tasks", "")
+	kvs, err := db.conn.Watch(prefix, stop)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan eremetic.TaskEvent)
+	go func() {
+		defer close(events)
+		for kv := range kvs {
+			ev := eremetic.TaskEvent{ID: strings.TrimPrefix(kv.Key, prefix)}
+			if kv.Value == nil {
+				ev.Deleted = true
+			} else {
+				var task eremetic.Task
+				if err := json.Unmarshal(kv.Value, &task); err != nil {
+					logrus.WithError(err).WithField("key", kv.Key).Debug("Unable to decode task")
+					continue
+				}
+				eremetic.ApplyMask(&task)
+				ev.Task = &task
+			}
+			select {
+			case events <- ev:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// PutQuota stores a quota in the database.
+func (db *TaskDB) PutQuota(quota *eremetic.Quota) error {
+	encoded, err := json.Marshal(quota)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to encode quota to byte-array.")
+		return err
+	}
+	return db.conn.Put(db.key("quotas", quota.Name), encoded)
+}
+
+// ReadQuota fetches a quota from the database.
+func (db *TaskDB) ReadQuota(name string) (eremetic.Quota, error) {
+	var quota eremetic.Quota
+
+	kv, err := db.conn.Get(db.key("quotas", name))
+	if err != nil {
+		return quota, err
+	}
+	if kv == nil {
+		return quota, errors.New("unknown quota")
+	}
+
+	err = json.Unmarshal(kv.Value, &quota)
+	return quota, err
+}
+
+// DeleteQuota deletes a quota matching the given name.
+func (db *TaskDB) DeleteQuota(name string) error {
+	return db.conn.Delete(db.key("quotas", name), false)
+}
+
+// ListQuotas returns all quotas.
+func (db *TaskDB) ListQuotas() ([]*eremetic.Quota, error) {
+	quotas := []*eremetic.Quota{}
+
+	kvs, err := db.conn.Range(db.key("quotas", ""), true)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range kvs {
+		var quota eremetic.Quota
+		if err := json.Unmarshal(kv.Value, &quota); err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, &quota)
+	}
+	return quotas, nil
+}
+
+// PutTemplate stores a template in the database.
+func (db *TaskDB) PutTemplate(template *eremetic.Template) error {
+	encoded, err := json.Marshal(template)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to encode template to byte-array.")
+		return err
+	}
+	return db.conn.Put(db.key("templates", template.Name), encoded)
+}
+
+// UpdateTemplate applies fn to a template and writes it if its revision is
+// still the one that was read, retrying otherwise.
+func (db *TaskDB) UpdateTemplate(name string, fn func(*eremetic.Template) error) error {
+	key := db.key("templates", name)
+
+	return eremetic.RetryOnConflict(func() error {
+		template := eremetic.Template{Name: name}
+
+		kv, err := db.conn.Get(key)
+		if err != nil {
+			return err
+		}
+		var revision int64
+		if kv != nil {
+			if err := json.Unmarshal(kv.Value, &template); err != nil {
+				return err
+			}
+			revision = kv.ModRevision
+		}
+
+		if err := fn(&template); err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(template)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to encode template to byte-array.")
+			return err
+		}
+
+		ok, err := db.conn.PutIf(key, encoded, revision)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return eremetic.ErrConflict
+		}
+		return nil
+	})
+}
+
+// ReadTemplate fetches a template from the database.
+func (db *TaskDB) ReadTemplate(name string) (eremetic.Template, error) {
+	var template eremetic.Template
+
+	kv, err := db.conn.Get(db.key("templates", name))
+	if err != nil {
+		return template, err
+	}
+	if kv == nil {
+		return template, errors.New("unknown template")
+	}
+
+	err = json.Unmarshal(kv.Value, &template)
+	return template, err
+}
+
+// DeleteTemplate deletes a template matching the given name.
+func (db *TaskDB) DeleteTemplate(name string) error {
+	return db.conn.Delete(db.key("templates", name), false)
+}
+
+// ListTemplates returns all templates.
+func (db *TaskDB) ListTemplates() ([]*eremetic.Template, error) {
+	templates := []*eremetic.Template{}
+
+	kvs, err := db.conn.Range(db.key("templates", ""), true)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range kvs {
+		var template eremetic.Template
+		if err := json.Unmarshal(kv.Value, &template); err != nil {
+			return nil, err
+		}
+		templates = append(templates, &template)
+	}
+	return templates, nil
+}
+
+// PutFrameworkID stores the framework ID at `<prefix>/framework_id`.
+func (db *TaskDB) PutFrameworkID(id string) error {
+	if id == "" {
+		return db.conn.Delete(db.frameworkIDKey(), false)
+	}
+	return db.conn.Put(db.frameworkIDKey(), []byte(id))
+}
+
+// ReadFrameworkID returns the stored framework ID.
+func (db *TaskDB) ReadFrameworkID() (string, error) {
+	kv, err := db.conn.Get(db.frameworkIDKey())
+	if err != nil || kv == nil {
+		return "", err
+	}
+	return string(kv.Value), nil
+}
+
+func (db *TaskDB) frameworkIDKey() string {
+	return db.prefix + "/framework_id"
+}
@@ -0,0 +1,192 @@
+package etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// requestTimeout bounds every request made to etcd, but watches.
+const requestTimeout = 10 * time.Second
+
+// clientConnection talks to etcd through its v3 gRPC API.
+type clientConnection struct {
+	client *clientv3.Client
+}
+
+type defaultConnector struct{}
+
+func (c defaultConnector) Connect(endpoints []string) (connection, error) {
+	config := clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+		Logger:      zap.NewNop(),
+	}
+	if strings.HasPrefix(endpoints[0], "https://") {
+		config.TLS = &tls.Config{}
+	}
+	client, err := clientv3.New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &clientConnection{client: client}
+	// Check that the cluster is reachable.
+	if _, err := conn.Get("/"); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *clientConnection) Close() {
+	c.client.Close()
+}
+
+func (c *clientConnection) Get(key string) (*keyValue, error) {
+	kvs, err := c.Range(key, false)
+	if err != nil || len(kvs) == 0 {
+		return nil, err
+	}
+	return kvs[0], nil
+}
+
+func (c *clientConnection) Range(key string, prefix bool) ([]*keyValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var opts []clientv3.OpOption
+	if prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	resp, err := c.client.Get(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	kvs := make([]*keyValue, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		kvs[i] = &keyValue{Key: string(kv.Key), Value: kv.Value, ModRevision: kv.ModRevision}
+	}
+	return kvs, nil
+}
+
+func (c *clientConnection) Put(key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	_, err := c.client.Put(ctx, key, string(value))
+	return err
+}
+
+func (c *clientConnection) PutIf(key string, value []byte, revision int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	resp, err := c.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+func (c *clientConnection) Create(key string, value []byte, lease int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	resp, err := c.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(value), clientv3.WithLease(clientv3.LeaseID(lease)))).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+func (c *clientConnection) Delete(key string, prefix bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var opts []clientv3.OpOption
+	if prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	_, err := c.client.Delete(ctx, key, opts...)
+	return err
+}
+
+func (c *clientConnection) Watch(prefix string, stop <-chan struct{}) (<-chan *keyValue, error) {
+	// Without a leader, the watch would wait for one instead of failing.
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(context.Background()))
+	responses := c.client.Watch(ctx, prefix, clientv3.WithPrefix())
+
+	events := make(chan *keyValue)
+	go func() {
+		defer close(events)
+		defer cancel()
+		for {
+			var resp clientv3.WatchResponse
+			var ok bool
+			select {
+			case resp, ok = <-responses:
+			case <-stop:
+				return
+			}
+			if !ok {
+				logrus.Error("etcd watch ended")
+				return
+			}
+			if err := resp.Err(); err != nil {
+				logrus.WithError(err).Error("etcd watch canceled")
+				return
+			}
+			for _, ev := range resp.Events {
+				kv := &keyValue{Key: string(ev.Kv.Key), Value: ev.Kv.Value, ModRevision: ev.Kv.ModRevision}
+				if ev.Type == clientv3.EventTypeDelete {
+					kv.Value = nil
+				}
+				select {
+				case events <- kv:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (c *clientConnection) Grant(ttl int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	resp, err := c.client.Grant(ctx, ttl)
+	if err != nil {
+		return 0, err
+	}
+	return int64(resp.ID), nil
+}
+
+func (c *clientConnection) KeepAlive(lease int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	_, err := c.client.KeepAliveOnce(ctx, clientv3.LeaseID(lease))
+	return err
+}
+
+func (c *clientConnection) Revoke(lease int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	_, err := c.client.Revoke(ctx, clientv3.LeaseID(lease))
+	return err
+}
@@ -0,0 +1,269 @@
+package etcd
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// testCluster returns the connector to the etcd cluster used by the tests,
+// and a function giving locations in it, each with a prefix of its own. The
+// tests run against an in-memory etcd, or against the cluster listed by
+// ETCD_TEST_ENDPOINTS, such as `localhost:2379`, when it is set.
+func testCluster() (connector, func() string) {
+	var c connector = newMemoryConnector()
+	endpoints := os.Getenv("ETCD_TEST_ENDPOINTS")
+	if endpoints != "" {
+		c = defaultConnector{}
+	} else {
+		endpoints = "memory:2379"
+	}
+	return c, func() string {
+		return fmt.Sprintf("etcd://%s/eremetic-test/%d", endpoints, time.Now().UnixNano())
+	}
+}
+
+// storedKeys returns the keys stored under the prefix of the database.
+func storedKeys(db *TaskDB) []string {
+	kvs, err := db.conn.Range(db.prefix+"/", true)
+	So(err, ShouldBeNil)
+	keys := []string{}
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+	return keys
+}
+
+func TestParseLocation(t *testing.T) {
+	Convey("parseLocation", t, func() {
+		endpoints, prefix, err := parseLocation("etcds://etcd1:2379,etcd2:2379/eremetic/")
+		So(err, ShouldBeNil)
+		So(endpoints, ShouldResemble, []string{"https://etcd1:2379", "https://etcd2:2379"})
+		So(prefix, ShouldEqual, "/eremetic")
+
+		_, _, err = parseLocation("zk://zk1:2181/eremetic")
+		So(err, ShouldNotBeNil)
+		_, err = NewTaskDB("")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestEtcdDatabase(t *testing.T) {
+	cluster, newLocation := testCluster()
+
+	var (
+		db *TaskDB
+		// other is another Eremetic instance using the same cluster.
+		other *TaskDB
+	)
+
+	setup := func() func() {
+		location := newLocation()
+		var err error
+		db, err = newCustomTaskDB(cluster, location)
+		So(err, ShouldBeNil)
+		other, err = newCustomTaskDB(cluster, location)
+		So(err, ShouldBeNil)
+		return func() {
+			db.conn.Delete(db.prefix+"/", true)
+			other.Close()
+			db.Close()
+		}
+	}
+
+	task := &eremetic.Task{
+		ID:                "eremetic-task.1",
+		Name:              "etl",
+		MaskedEnvironment: map[string]string{"TOKEN": "secret"},
+		Status:            []eremetic.Status{{Time: 10, Status: eremetic.TaskQueued}},
+	}
+
+	Convey("Tasks", t, func() {
+		defer setup()()
+
+		So(db.PutTask(task), ShouldBeNil)
+		So(storedKeys(db), ShouldResemble, []string{db.prefix + "/tasks/eremetic-task.1"})
+
+		Convey("ReadTask", func() {
+			read, err := db.ReadTask(task.ID)
+			So(err, ShouldBeNil)
+			So(read.Name, ShouldEqual, "etl")
+			So(read.MaskedEnvironment["TOKEN"], ShouldEqual, eremetic.Masking)
+
+			read, err = db.ReadUnmaskedTask(task.ID)
+			So(err, ShouldBeNil)
+			So(read.MaskedEnvironment["TOKEN"], ShouldEqual, "secret")
+
+			_, err = db.ReadTask("unknown")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("PutTask replaces the stored task", func() {
+			So(other.PutTask(&eremetic.Task{ID: task.ID, Name: "other"}), ShouldBeNil)
+			So(db.PutTask(task), ShouldBeNil)
+
+			read, err := other.ReadTask(task.ID)
+			So(err, ShouldBeNil)
+			So(read.Name, ShouldEqual, "etl")
+		})
+
+		Convey("UpdateTask retries when the task changes", func() {
+			calls := 0
+			err := db.UpdateTask(task.ID, func(t *eremetic.Task) error {
+				calls++
+				if calls == 1 {
+					So(other.PutTask(&eremetic.Task{ID: task.ID, Name: "other"}), ShouldBeNil)
+				}
+				t.Hostname = "agent1"
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+
+			read, _ := db.ReadTask(task.ID)
+			So(read.Name, ShouldEqual, "other")
+			So(read.Hostname, ShouldEqual, "agent1")
+			So(read.Revision, ShouldEqual, 1)
+		})
+
+		Convey("UpdateTask of a task created elsewhere", func() {
+			calls := 0
+			err := db.UpdateTask("eremetic-task.2", func(t *eremetic.Task) error {
+				calls++
+				if calls == 1 {
+					So(other.PutTask(&eremetic.Task{ID: "eremetic-task.2", Name: "other"}), ShouldBeNil)
+				}
+				t.Hostname = "agent1"
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+
+			read, _ := db.ReadTask("eremetic-task.2")
+			So(read.Name, ShouldEqual, "other")
+			So(read.Hostname, ShouldEqual, "agent1")
+		})
+
+		Convey("ListTasks", func() {
+			db.PutTask(&eremetic.Task{
+				ID:     "eremetic-task.2",
+				Status: []eremetic.Status{{Time: 20, Status: eremetic.TaskQueued}, {Time: 30, Status: eremetic.TaskFinished}},
+			})
+			db.PutQuota(&eremetic.Quota{Name: "data"})
+
+			tasks, err := db.ListTasks(&eremetic.TaskFilter{State: eremetic.DefaultTaskFilterState})
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 1)
+			So(tasks[0].ID, ShouldEqual, task.ID)
+			So(tasks[0].MaskedEnvironment["TOKEN"], ShouldEqual, eremetic.Masking)
+
+			tasks, err = db.ListTasks(&eremetic.TaskFilter{})
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 2)
+		})
+
+		Convey("DeleteTask", func() {
+			So(db.DeleteTask(task.ID), ShouldBeNil)
+			So(storedKeys(db), ShouldBeEmpty)
+		})
+
+		Convey("Clean keeps quotas and templates", func() {
+			db.PutQuota(&eremetic.Quota{Name: "data"})
+			So(db.Clean(), ShouldBeNil)
+			So(storedKeys(db), ShouldResemble, []string{db.prefix + "/quotas/data"})
+		})
+	})
+
+	Convey("WatchTasks", t, func() {
+		defer setup()()
+
+		stop := make(chan struct{})
+		defer close(stop)
+		events, err := db.WatchTasks(stop)
+		So(err, ShouldBeNil)
+
+		next := func() eremetic.TaskEvent {
+			select {
+			case ev := <-events:
+				return ev
+			case <-time.After(5 * time.Second):
+				return eremetic.TaskEvent{}
+			}
+		}
+
+		So(other.PutTask(task), ShouldBeNil)
+		ev := next()
+		So(ev.ID, ShouldEqual, task.ID)
+		So(ev.Deleted, ShouldBeFalse)
+		So(ev.Task.MaskedEnvironment["TOKEN"], ShouldEqual, eremetic.Masking)
+
+		other.PutQuota(&eremetic.Quota{Name: "data"})
+		So(other.DeleteTask(task.ID), ShouldBeNil)
+		ev = next()
+		So(ev.ID, ShouldEqual, task.ID)
+		So(ev.Deleted, ShouldBeTrue)
+		So(ev.Task, ShouldBeNil)
+	})
+
+	Convey("Quotas and templates", t, func() {
+		defer setup()()
+
+		So(db.PutQuota(&eremetic.Quota{Name: "data", Owner: "alice"}), ShouldBeNil)
+		quota, err := db.ReadQuota("data")
+		So(err, ShouldBeNil)
+		So(quota.Owner, ShouldEqual, "alice")
+		quotas, _ := db.ListQuotas()
+		So(quotas, ShouldHaveLength, 1)
+		So(db.DeleteQuota("data"), ShouldBeNil)
+		_, err = db.ReadQuota("data")
+		So(err, ShouldNotBeNil)
+
+		So(db.PutTemplate(&eremetic.Template{Name: "etl", Version: 2}), ShouldBeNil)
+		template, err := db.ReadTemplate("etl")
+		So(err, ShouldBeNil)
+		So(template.Version, ShouldEqual, 2)
+		templates, _ := db.ListTemplates()
+		So(templates, ShouldHaveLength, 1)
+		So(db.DeleteTemplate("etl"), ShouldBeNil)
+		_, err = db.ReadTemplate("etl")
+		So(err, ShouldNotBeNil)
+
+		calls := 0
+		err = db.UpdateTemplate("etl", func(t *eremetic.Template) error {
+			calls++
+			if calls == 1 {
+				So(other.PutTemplate(&eremetic.Template{Name: "etl", Version: 1}), ShouldBeNil)
+			}
+			t.Revise(eremetic.Request{DockerImage: "etl:2.0"})
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(calls, ShouldEqual, 2)
+		template, _ = db.ReadTemplate("etl")
+		So(template.Version, ShouldEqual, 2)
+		So(template.History, ShouldHaveLength, 1)
+	})
+
+	Convey("Framework ID", t, func() {
+		defer setup()()
+
+		id, err := db.ReadFrameworkID()
+		So(err, ShouldBeNil)
+		So(id, ShouldBeEmpty)
+
+		So(db.PutFrameworkID("1234-0001"), ShouldBeNil)
+		id, err = other.ReadFrameworkID()
+		So(err, ShouldBeNil)
+		So(id, ShouldEqual, "1234-0001")
+
+		So(db.PutFrameworkID(""), ShouldBeNil)
+		id, err = db.ReadFrameworkID()
+		So(err, ShouldBeNil)
+		So(id, ShouldBeEmpty)
+	})
+}
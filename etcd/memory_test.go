@@ -0,0 +1,258 @@
+package etcd
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryConnector hands out connections to the same in-memory store, the
+// way instances connecting to one cluster share its keys.
+type memoryConnector struct {
+	store *memoryConnection
+}
+
+func newMemoryConnector() memoryConnector {
+	return memoryConnector{store: &memoryConnection{
+		keys:   map[string]*memoryKey{},
+		leases: map[int64]*memoryLease{},
+	}}
+}
+
+func (c memoryConnector) Connect(endpoints []string) (connection, error) {
+	return c.store, nil
+}
+
+type memoryKey struct {
+	value          []byte
+	modRevision    int64
+	createRevision int64
+	lease          int64
+}
+
+type memoryLease struct {
+	keys  map[string]struct{}
+	timer *time.Timer
+	ttl   time.Duration
+}
+
+// memoryWatcher queues the changes of the keys starting with prefix, so
+// that writers never wait for the watch to be read.
+type memoryWatcher struct {
+	prefix  string
+	pending []*keyValue
+	notify  chan struct{}
+}
+
+// memoryConnection is an in-memory etcd, with revisions for the
+// compare-and-swap writes and leases expiring when not kept alive.
+type memoryConnection struct {
+	mtx       sync.Mutex
+	revision  int64
+	keys      map[string]*memoryKey
+	leases    map[int64]*memoryLease
+	lastLease int64
+	watchers  map[*memoryWatcher]struct{}
+}
+
+func (c *memoryConnection) Close() {}
+
+func (c *memoryConnection) Get(key string) (*keyValue, error) {
+	kvs, err := c.Range(key, false)
+	if err != nil || len(kvs) == 0 {
+		return nil, err
+	}
+	return kvs[0], nil
+}
+
+func (c *memoryConnection) Range(key string, prefix bool) ([]*keyValue, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	kvs := []*keyValue{}
+	for k, v := range c.keys {
+		if k == key || prefix && strings.HasPrefix(k, key) {
+			kvs = append(kvs, &keyValue{Key: k, Value: append([]byte{}, v.value...), ModRevision: v.modRevision})
+		}
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs, nil
+}
+
+func (c *memoryConnection) Put(key string, value []byte) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.put(key, value, 0)
+	return nil
+}
+
+func (c *memoryConnection) PutIf(key string, value []byte, revision int64) (bool, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var current int64
+	if v, ok := c.keys[key]; ok {
+		current = v.modRevision
+	}
+	if current != revision {
+		return false, nil
+	}
+	c.put(key, value, 0)
+	return true, nil
+}
+
+func (c *memoryConnection) Create(key string, value []byte, lease int64) (bool, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if _, ok := c.keys[key]; ok {
+		return false, nil
+	}
+	if _, ok := c.leases[lease]; lease != 0 && !ok {
+		return false, errors.New("requested lease not found")
+	}
+	c.put(key, value, lease)
+	return true, nil
+}
+
+func (c *memoryConnection) Delete(key string, prefix bool) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for k := range c.keys {
+		if k == key || prefix && strings.HasPrefix(k, key) {
+			c.delete(k)
+		}
+	}
+	return nil
+}
+
+func (c *memoryConnection) Watch(prefix string, stop <-chan struct{}) (<-chan *keyValue, error) {
+	w := &memoryWatcher{prefix: prefix, notify: make(chan struct{}, 1)}
+	c.mtx.Lock()
+	if c.watchers == nil {
+		c.watchers = map[*memoryWatcher]struct{}{}
+	}
+	c.watchers[w] = struct{}{}
+	c.mtx.Unlock()
+
+	events := make(chan *keyValue)
+	go func() {
+		defer close(events)
+		defer func() {
+			c.mtx.Lock()
+			delete(c.watchers, w)
+			c.mtx.Unlock()
+		}()
+		for {
+			select {
+			case <-w.notify:
+			case <-stop:
+				return
+			}
+			c.mtx.Lock()
+			pending := w.pending
+			w.pending = nil
+			c.mtx.Unlock()
+
+			for _, kv := range pending {
+				select {
+				case events <- kv:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (c *memoryConnection) Grant(ttl int64) (int64, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.lastLease++
+	id := c.lastLease
+	l := &memoryLease{keys: map[string]struct{}{}, ttl: time.Duration(ttl) * time.Second}
+	l.timer = time.AfterFunc(l.ttl, func() { c.Revoke(id) })
+	c.leases[id] = l
+	return id, nil
+}
+
+func (c *memoryConnection) KeepAlive(lease int64) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	l, ok := c.leases[lease]
+	if !ok {
+		return errors.New("requested lease not found")
+	}
+	l.timer.Reset(l.ttl)
+	return nil
+}
+
+func (c *memoryConnection) Revoke(lease int64) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	l, ok := c.leases[lease]
+	if !ok {
+		return errors.New("requested lease not found")
+	}
+	l.timer.Stop()
+	delete(c.leases, lease)
+	for k := range l.keys {
+		c.delete(k)
+	}
+	return nil
+}
+
+// put writes the key at the next revision. The caller holds the mutex.
+func (c *memoryConnection) put(key string, value []byte, lease int64) {
+	c.revision++
+	v, ok := c.keys[key]
+	if !ok {
+		v = &memoryKey{createRevision: c.revision}
+		c.keys[key] = v
+	}
+	if l, ok := c.leases[v.lease]; ok && v.lease != lease {
+		delete(l.keys, key)
+	}
+	if l, ok := c.leases[lease]; ok {
+		l.keys[key] = struct{}{}
+	}
+	v.value = append([]byte(nil), value...)
+	v.modRevision = c.revision
+	v.lease = lease
+	c.notify(&keyValue{Key: key, Value: append([]byte{}, value...), ModRevision: c.revision})
+}
+
+// delete removes the key at the next revision. The caller holds the mutex.
+func (c *memoryConnection) delete(key string) {
+	v, ok := c.keys[key]
+	if !ok {
+		return
+	}
+	c.revision++
+	if l, ok := c.leases[v.lease]; ok {
+		delete(l.keys, key)
+	}
+	delete(c.keys, key)
+	c.notify(&keyValue{Key: key, ModRevision: c.revision})
+}
+
+func (c *memoryConnection) notify(kv *keyValue) {
+	for w := range c.watchers {
+		if !strings.HasPrefix(kv.Key, w.prefix) {
+			continue
+		}
+		w.pending = append(w.pending, kv)
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Package fsck checks the task database for tasks that can't make progress
+// on their own, and repairs them.
+package fsck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// Kinds of problems.
+const (
+	// Undecodable tasks hold a document that isn't a valid task.
+	Undecodable = "undecodable"
+	// MissingStatus tasks have no status at all.
+	MissingStatus = "missing_status"
+	// InvalidSequence tasks went through states in an impossible order.
+	InvalidSequence = "invalid_sequence"
+	// MissingAgent tasks were launched but don't know their agent, so
+	// they can be neither reconciled nor killed.
+	MissingAgent = "missing_agent"
+	// Stuck tasks have been staging or terminating for too long.
+	Stuck = "stuck"
+	// OrphanQueued tasks are queued in the database but not in the queue
+	// of the scheduler.
+	OrphanQueued = "orphan_queued"
+)
+
+// Repairs applied to problems.
+const (
+	RepairEnqueue  = "enqueue"
+	RepairMarkLost = "mark_lost"
+	RepairDelete   = "delete"
+)
+
+// DefaultStuckAfter is how long a task may stay staging or terminating
+// before being reported as stuck.
+const DefaultStuckAfter = time.Hour
+
+// pageSize is the number of tasks read from the database at once.
+const pageSize = 100
+
+var errChanged = errors.New("task changed during the check")
+
+// Queue is the queue of the running scheduler.
+type Queue interface {
+	Queued(id string) bool
+	Enqueue(id string) error
+}
+
+// Options controls a check.
+type Options struct {
+	// Repair applies the repair of every problem found.
+	Repair bool
+	// StuckAfter is how long a task may stay staging or terminating,
+	// DefaultStuckAfter if zero.
+	StuckAfter time.Duration
+	// Queue is the queue of the running scheduler. Orphaned queued tasks
+	// are only looked for when it is set.
+	Queue Queue
+}
+
+// Problem is an inconsistency found in a task.
+type Problem struct {
+	TaskID string `json:"task_id"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+	// Repair is the repair fixing the problem, empty if the problem can't
+	// be repaired.
+	Repair   string `json:"repair,omitempty"`
+	Repaired bool   `json:"repaired"`
+	Error    string `json:"error,omitempty"`
+
+	statuses int
+}
+
+// Report is the outcome of a check.
+type Report struct {
+	Scanned  int       `json:"scanned"`
+	Problems []Problem `json:"problems"`
+	Repaired int       `json:"repaired"`
+}
+
+// Check looks for problems in every task of the database, and repairs them
+// if asked to. A task is reported with its first problem only.
+func Check(db eremetic.TaskDB, opts Options) (*Report, error) {
+	return check(db, opts, time.Now())
+}
+
+func check(db eremetic.TaskDB, opts Options, now time.Time) (*Report, error) {
+	if opts.StuckAfter <= 0 {
+		opts.StuckAfter = DefaultStuckAfter
+	}
+
+	report := &Report{Problems: []Problem{}}
+	inspect := func(task *eremetic.Task) {
+		report.Scanned++
+		if p := inspectTask(task, opts, now); p != nil {
+			report.Problems = append(report.Problems, *p)
+		}
+	}
+
+	var err error
+	if scanner, ok := db.(eremetic.TaskScanner); ok {
+		err = scanner.ScanTasks(func(id string, data []byte) error {
+			var task eremetic.Task
+			if err := json.Unmarshal(data, &task); err != nil {
+				report.Scanned++
+				report.Problems = append(report.Problems, Problem{
+					TaskID: id,
+					Kind:   Undecodable,
+					Detail: err.Error(),
+					Repair: RepairDelete,
+				})
+				return nil
+			}
+			if task.ID == "" {
+				task.ID = id
+			}
+			inspect(&task)
+			return nil
+		})
+	} else {
+		err = eachTask(db, inspect)
+	}
+	if err != nil {
+		return report, err
+	}
+
+	if opts.Repair {
+		for i := range report.Problems {
+			p := &report.Problems[i]
+			if p.Repair == "" {
+				continue
+			}
+			if err := repair(db, opts.Queue, p, now); err != nil {
+				logrus.WithError(err).WithField("task_id", p.TaskID).Warn("Unable to repair task")
+				p.Error = err.Error()
+				continue
+			}
+			p.Repaired = true
+			report.Repaired++
+		}
+	}
+	return report, nil
+}
+
+func eachTask(db eremetic.TaskDB, fn func(*eremetic.Task)) error {
+	filter := &eremetic.TaskFilter{Limit: pageSize}
+	for {
+		tasks, err := db.ListTasks(filter)
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			fn(t)
+		}
+		if len(tasks) < pageSize {
+			return nil
+		}
+		filter.Cursor = filter.CursorAfter(tasks[len(tasks)-1])
+	}
+}
+
+// inspectTask returns the first problem of a task, or nil.
+func inspectTask(task *eremetic.Task, opts Options, now time.Time) *Problem {
+	p := &Problem{TaskID: task.ID, statuses: len(task.Status)}
+	state := task.CurrentStatus()
+
+	switch {
+	case len(task.Status) == 0:
+		p.Kind = MissingStatus
+		p.Detail = "the task has no status"
+		p.Repair = RepairDelete
+	case invalidSequence(task.Status) != "":
+		p.Kind = InvalidSequence
+		p.Detail = invalidSequence(task.Status)
+		if eremetic.IsKnownState(state) && !eremetic.IsTerminal(state) {
+			p.Repair = RepairMarkLost
+		}
+	case launched(task) && task.AgentID == "":
+		p.Kind = MissingAgent
+		p.Detail = fmt.Sprintf("the task is %s without agent", state)
+		p.Repair = RepairMarkLost
+	case (state == eremetic.TaskStaging || state == eremetic.TaskTerminating) &&
+		now.Sub(task.LastUpdated()) > opts.StuckAfter:
+		p.Kind = Stuck
+		p.Detail = fmt.Sprintf("the task is %s since %s", state, task.LastUpdated().UTC().Format(time.RFC3339))
+		p.Repair = RepairMarkLost
+	case opts.Queue != nil && task.IsEnqueued() && !opts.Queue.Queued(task.ID):
+		p.Kind = OrphanQueued
+		p.Detail = "the task is not in the queue of the scheduler"
+		p.Repair = RepairEnqueue
+	default:
+		return nil
+	}
+	return p
+}
+
+// launched returns whether a non terminal task was handed to an agent.
+func launched(task *eremetic.Task) bool {
+	switch task.CurrentStatus() {
+	case eremetic.TaskStaging, eremetic.TaskStarting, eremetic.TaskRunning:
+		return true
+	case eremetic.TaskTerminating:
+		for _, s := range task.Status {
+			if s.Status == eremetic.TaskStaging {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// invalidSequence describes why a status history is impossible, or returns
+// an empty string. Times aren't compared, as they come from the clocks of
+// several instances.
+func invalidSequence(statuses []eremetic.Status) string {
+	var prev eremetic.TaskState
+	for _, st := range statuses {
+		switch {
+		case !eremetic.IsKnownState(st.Status):
+			return fmt.Sprintf("unknown state %q", st.Status)
+		case prev == "" && st.Status != eremetic.TaskQueued:
+			return fmt.Sprintf("the task starts as %s", st.Status)
+		case !eremetic.CanTransition(prev, st.Status):
+			return fmt.Sprintf("%s can't follow %s", st.Status, prev)
+		}
+		prev = st.Status
+	}
+	return ""
+}
+
+func repair(db eremetic.TaskDB, queue Queue, p *Problem, now time.Time) error {
+	switch p.Repair {
+	case RepairDelete:
+		return db.DeleteTask(p.TaskID)
+	case RepairEnqueue:
+		if queue == nil {
+			return errors.New("no scheduler to enqueue the task in")
+		}
+		return queue.Enqueue(p.TaskID)
+	case RepairMarkLost:
+		var (
+			task        eremetic.Task
+			transitions []eremetic.Transition
+		)
+		err := db.UpdateTask(p.TaskID, func(t *eremetic.Task) error {
+			if len(t.Status) != p.statuses {
+				return errChanged
+			}
+			if err := t.UpdateStatus(eremetic.Status{
+				Status: eremetic.TaskLost,
+				Time:   now.Unix(),
+			}); err != nil {
+				return err
+			}
+			transitions = t.TransitionsSince(p.statuses)
+			task = *t
+			return nil
+		})
+		if err == nil {
+			eremetic.NotifyTransitions(&task, transitions)
+		}
+		return err
+	}
+	return fmt.Errorf("unknown repair %q", p.Repair)
+}
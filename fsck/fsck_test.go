@@ -0,0 +1,197 @@
+package fsck
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// scanningTaskDB holds raw documents next to the decoded tasks.
+type scanningTaskDB struct {
+	*eremetic.DefaultTaskDB
+	raw map[string][]byte
+}
+
+func (db *scanningTaskDB) ScanTasks(fn func(id string, data []byte) error) error {
+	for id, data := range db.raw {
+		if err := fn(id, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *scanningTaskDB) DeleteTask(id string) error {
+	delete(db.raw, id)
+	return nil
+}
+
+type fakeQueue map[string]bool
+
+func (q fakeQueue) Queued(id string) bool {
+	return q[id]
+}
+
+func (q fakeQueue) Enqueue(id string) error {
+	q[id] = true
+	return nil
+}
+
+func statuses(now time.Time, states ...eremetic.TaskState) []eremetic.Status {
+	res := []eremetic.Status{}
+	for _, s := range states {
+		res = append(res, eremetic.Status{Status: s, Time: now.Unix()})
+	}
+	return res
+}
+
+func TestFsck(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	old := now.Add(-2 * DefaultStuckAfter)
+
+	Convey("check", t, func() {
+		db := eremetic.NewDefaultTaskDB()
+		put := func(task eremetic.Task) {
+			db.PutTask(&task)
+		}
+
+		put(eremetic.Task{ID: "healthy", AgentID: "agent-1",
+			Status: statuses(now, eremetic.TaskQueued, eremetic.TaskStaging, eremetic.TaskRunning)})
+		put(eremetic.Task{ID: "retried",
+			Status: statuses(now, eremetic.TaskQueued, eremetic.TaskStaging, eremetic.TaskFailed, eremetic.TaskQueued)})
+
+		problem := func(r *Report, id string) *Problem {
+			for i := range r.Problems {
+				if r.Problems[i].TaskID == id {
+					return &r.Problems[i]
+				}
+			}
+			return nil
+		}
+
+		Convey("Healthy tasks have no problem", func() {
+			r, err := check(db, Options{}, now)
+
+			So(err, ShouldBeNil)
+			So(r.Scanned, ShouldEqual, 2)
+			So(r.Problems, ShouldBeEmpty)
+		})
+
+		Convey("Tasks without status", func() {
+			put(eremetic.Task{ID: "empty"})
+
+			r, _ := check(db, Options{}, now)
+
+			So(problem(r, "empty").Kind, ShouldEqual, MissingStatus)
+			So(problem(r, "empty").Repair, ShouldEqual, RepairDelete)
+		})
+
+		Convey("Impossible sequences", func() {
+			put(eremetic.Task{ID: "unqueued", AgentID: "agent-1",
+				Status: statuses(now, eremetic.TaskRunning)})
+			put(eremetic.Task{ID: "revived", AgentID: "agent-1",
+				Status: statuses(now, eremetic.TaskQueued, eremetic.TaskKilled, eremetic.TaskRunning)})
+			put(eremetic.Task{ID: "killed-twice",
+				Status: statuses(now, eremetic.TaskQueued, eremetic.TaskKilled, eremetic.TaskKilled)})
+
+			r, _ := check(db, Options{}, now)
+
+			So(problem(r, "unqueued").Kind, ShouldEqual, InvalidSequence)
+			So(problem(r, "revived").Kind, ShouldEqual, InvalidSequence)
+			So(problem(r, "revived").Repair, ShouldEqual, RepairMarkLost)
+
+			Convey("are only reported when the task is terminated", func() {
+				So(problem(r, "killed-twice").Kind, ShouldEqual, InvalidSequence)
+				So(problem(r, "killed-twice").Repair, ShouldBeEmpty)
+			})
+		})
+
+		Convey("Launched tasks without agent", func() {
+			put(eremetic.Task{ID: "running",
+				Status: statuses(now, eremetic.TaskQueued, eremetic.TaskStaging, eremetic.TaskRunning)})
+			put(eremetic.Task{ID: "killed-queued",
+				Status: statuses(now, eremetic.TaskQueued, eremetic.TaskTerminating)})
+
+			r, _ := check(db, Options{}, now)
+
+			So(problem(r, "running").Kind, ShouldEqual, MissingAgent)
+			So(problem(r, "killed-queued"), ShouldBeNil)
+		})
+
+		Convey("Stuck tasks", func() {
+			put(eremetic.Task{ID: "staging", AgentID: "agent-1",
+				Status: statuses(old, eremetic.TaskQueued, eremetic.TaskStaging)})
+			put(eremetic.Task{ID: "terminating",
+				Status: statuses(old, eremetic.TaskQueued, eremetic.TaskTerminating)})
+			put(eremetic.Task{ID: "recent", AgentID: "agent-1",
+				Status: statuses(now, eremetic.TaskQueued, eremetic.TaskStaging)})
+
+			r, _ := check(db, Options{}, now)
+
+			So(problem(r, "staging").Kind, ShouldEqual, Stuck)
+			So(problem(r, "terminating").Kind, ShouldEqual, Stuck)
+			So(problem(r, "recent"), ShouldBeNil)
+
+			Convey("are marked lost", func() {
+				r, err := check(db, Options{Repair: true}, now)
+
+				So(err, ShouldBeNil)
+				So(r.Repaired, ShouldEqual, 2)
+				task, _ := db.ReadUnmaskedTask("staging")
+				So(task.CurrentStatus(), ShouldEqual, eremetic.TaskLost)
+
+				r, _ = check(db, Options{}, now)
+				So(r.Problems, ShouldBeEmpty)
+			})
+
+			Convey("aren't repaired once they changed", func() {
+				p := problem(r, "staging")
+				db.UpdateTask("staging", func(t *eremetic.Task) error {
+					t.UpdateStatus(eremetic.Status{Status: eremetic.TaskRunning, Time: now.Unix()})
+					return nil
+				})
+
+				So(repair(db, nil, p, now), ShouldEqual, errChanged)
+				task, _ := db.ReadUnmaskedTask("staging")
+				So(task.CurrentStatus(), ShouldEqual, eremetic.TaskRunning)
+			})
+		})
+
+		Convey("Queued tasks missing from the queue of the scheduler", func() {
+			put(eremetic.Task{ID: "queued", Status: statuses(now, eremetic.TaskQueued)})
+			put(eremetic.Task{ID: "orphan", Status: statuses(now, eremetic.TaskQueued)})
+			queue := fakeQueue{"queued": true, "retried": true}
+
+			r, _ := check(db, Options{}, now)
+			So(r.Problems, ShouldBeEmpty)
+
+			r, _ = check(db, Options{Queue: queue, Repair: true}, now)
+			So(r.Problems, ShouldHaveLength, 1)
+			So(problem(r, "orphan").Kind, ShouldEqual, OrphanQueued)
+			So(problem(r, "orphan").Repaired, ShouldBeTrue)
+			So(queue["orphan"], ShouldBeTrue)
+		})
+
+		Convey("Undecodable tasks", func() {
+			sdb := &scanningTaskDB{
+				DefaultTaskDB: db,
+				raw: map[string][]byte{
+					"healthy": []byte(`{"ID": "healthy", "AgentID": "agent-1", "Status": [{"status": "TASK_QUEUED"}]}`),
+					"broken":  []byte(`{"ID": `),
+				},
+			}
+
+			r, err := check(sdb, Options{Repair: true}, now)
+
+			So(err, ShouldBeNil)
+			So(r.Scanned, ShouldEqual, 2)
+			So(r.Problems, ShouldHaveLength, 1)
+			So(problem(r, "broken").Kind, ShouldEqual, Undecodable)
+			So(problem(r, "broken").Repaired, ShouldBeTrue)
+			So(sdb.raw, ShouldNotContainKey, "broken")
+		})
+	})
+}
@@ -0,0 +1,24 @@
+package eremetic
+
+import "time"
+
+// Maintenance is a window during which an agent is unavailable.
+type Maintenance struct {
+	Start time.Time `json:"start"`
+	// End is nil when the end of the window isn't known.
+	End *time.Time `json:"end,omitempty"`
+}
+
+// Equal returns whether both windows are the same.
+func (m *Maintenance) Equal(o *Maintenance) bool {
+	if m == nil || o == nil {
+		return m == o
+	}
+	if !m.Start.Equal(o.Start) {
+		return false
+	}
+	if m.End == nil || o.End == nil {
+		return m.End == o.End
+	}
+	return m.End.Equal(*o.End)
+}
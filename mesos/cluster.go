@@ -0,0 +1,138 @@
+package mesos
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// agentOfferTTL is how long the last offer of an agent is reported on. With
+// suppressed offers, agents offer their resources again every
+// suppressRefuseSeconds.
+var agentOfferTTL = 2 * suppressRefuseSeconds * time.Second
+
+// agentOffer is the last offer received from an agent.
+type agentOffer struct {
+	offer *mesosproto.Offer
+	time  time.Time
+}
+
+// recordOffers keeps the offers as the last ones of their agents.
+func (s *Scheduler) recordOffers(offers []*mesosproto.Offer, now time.Time) {
+	s.agentMtx.Lock()
+	defer s.agentMtx.Unlock()
+	if s.agents == nil {
+		s.agents = make(map[string]agentOffer)
+	}
+	for _, offer := range offers {
+		s.agents[offer.SlaveId.GetValue()] = agentOffer{offer, now}
+	}
+}
+
+// forgetOffer forgets the resources of an agent once its last offer was used
+// or rescinded.
+func (s *Scheduler) forgetOffer(id string) {
+	s.agentMtx.Lock()
+	defer s.agentMtx.Unlock()
+	for agent, last := range s.agents {
+		if last.offer.Id.GetValue() == id {
+			delete(s.agents, agent)
+		}
+	}
+}
+
+// forgetAgent forgets the resources of an agent that was removed.
+func (s *Scheduler) forgetAgent(agent string) {
+	s.agentMtx.Lock()
+	defer s.agentMtx.Unlock()
+	delete(s.agents, agent)
+}
+
+// recentOffers returns the last offers of the agents, by agent id, dropping
+// those too old to be reported on.
+func (s *Scheduler) recentOffers(now time.Time) []agentOffer {
+	s.agentMtx.Lock()
+	defer s.agentMtx.Unlock()
+	var recent []agentOffer
+	for agent, last := range s.agents {
+		if now.Sub(last.time) > agentOfferTTL {
+			delete(s.agents, agent)
+			continue
+		}
+		recent = append(recent, last)
+	}
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i].offer.SlaveId.GetValue() < recent[j].offer.SlaveId.GetValue()
+	})
+	return recent
+}
+
+// Agents returns the agents offers were recently received from, with the
+// resources of their last offer.
+func (s *Scheduler) Agents() []eremetic.Agent {
+	agents := []eremetic.Agent{}
+	for _, last := range s.recentOffers(time.Now()) {
+		agents = append(agents, agentFromOffer(last.offer, last.time))
+	}
+	return agents
+}
+
+// Fit tells which agents could launch a task for the request with their
+// last offer.
+func (s *Scheduler) Fit(request eremetic.Request) (eremetic.TaskFit, error) {
+	fit := eremetic.TaskFit{
+		Agents:      []string{},
+		Evaluations: []eremetic.OfferEvaluation{},
+	}
+	task, err := eremetic.NewTask(request)
+	if err != nil {
+		return fit, err
+	}
+
+	matchers := createMatchers(task, s.drainLead())
+	for _, last := range s.recentOffers(time.Now()) {
+		evaluation := evaluateOffer(matchers, last.offer)
+		evaluation.Time = last.time
+		if evaluation.Rejection == "" {
+			fit.Agents = append(fit.Agents, evaluation.AgentID)
+		}
+		fit.Evaluations = append(fit.Evaluations, evaluation)
+	}
+	return fit, nil
+}
+
+func agentFromOffer(offer *mesosproto.Offer, offeredAt time.Time) eremetic.Agent {
+	agent := eremetic.Agent{
+		ID:          offer.SlaveId.GetValue(),
+		Hostname:    offer.GetHostname(),
+		Maintenance: maintenanceWindow(offer.Unavailability),
+		OfferedAt:   offeredAt,
+	}
+	for _, res := range offer.Resources {
+		switch res.GetName() {
+		case "cpus":
+			agent.CPUs += res.GetScalar().GetValue()
+		case "mem":
+			agent.Mem += res.GetScalar().GetValue()
+		}
+	}
+	for _, r := range offeredPorts(offer) {
+		agent.Ports = append(agent.Ports, fmt.Sprintf("%d-%d", r.GetBegin(), r.GetEnd()))
+	}
+	for _, attr := range offer.Attributes {
+		if agent.Attributes == nil {
+			agent.Attributes = make(map[string]string)
+		}
+		switch attr.GetType() {
+		case mesosproto.Value_TEXT:
+			agent.Attributes[attr.GetName()] = attr.GetText().GetValue()
+		case mesosproto.Value_SCALAR:
+			agent.Attributes[attr.GetName()] = fmt.Sprintf("%g", attr.GetScalar().GetValue())
+		}
+	}
+	return agent
+}
@@ -0,0 +1,99 @@
+package mesos
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/mock"
+)
+
+func agentOfferFrom(agent string, id string, cpu float64, mem float64, extra ...interface{}) *mesosproto.Offer {
+	o := offer(id, cpu, mem, nil, extra...)
+	o.SlaveId = &mesosproto.SlaveID{Value: proto.String(agent)}
+	o.Hostname = proto.String(agent + ".internal")
+	return o
+}
+
+func TestCluster(t *testing.T) {
+	logrus.SetOutput(ioutil.Discard)
+
+	Convey("Given a scheduler with offers of several agents", t, func() {
+		driver := mock.NewMesosScheduler()
+		driver.DeclineOfferFn = func(_ *mesosproto.OfferID, _ *mesosproto.Filters) (mesosproto.Status, error) {
+			return mesosproto.Status_DRIVER_RUNNING, nil
+		}
+		driver.LaunchTasksFn = func(_ []*mesosproto.OfferID, _ []*mesosproto.TaskInfo, _ *mesosproto.Filters) (mesosproto.Status, error) {
+			return mesosproto.Status_DRIVER_RUNNING, nil
+		}
+
+		s := &Scheduler{
+			tasks:    make(chan string, 10),
+			database: eremetic.NewDefaultTaskDB(),
+		}
+		s.ResourceOffers(driver, []*mesosproto.Offer{
+			agentOfferFrom("agent-2", "offer-2", 1.0, 2048.0),
+			agentOfferFrom("agent-1", "offer-1", 4.0, 16384.0,
+				textAttribute("rack", "r1"),
+				mesosutil.NewRangesResource("ports", []*mesosproto.Value_Range{
+					mesosutil.NewValueRange(31000, 31009),
+				}),
+			),
+		})
+
+		Convey("The agents report the resources of their last offer", func() {
+			agents := s.Agents()
+
+			So(agents, ShouldHaveLength, 2)
+			So(agents[0].ID, ShouldEqual, "agent-1")
+			So(agents[0].Hostname, ShouldEqual, "agent-1.internal")
+			So(agents[0].CPUs, ShouldEqual, 4.0)
+			So(agents[0].Mem, ShouldEqual, 16384.0)
+			So(agents[0].Ports, ShouldResemble, []string{"31000-31009"})
+			So(agents[0].Attributes, ShouldResemble, map[string]string{"rack": "r1"})
+			So(agents[1].ID, ShouldEqual, "agent-2")
+		})
+
+		Convey("Fit tells which agents a task fits on", func() {
+			fit, err := s.Fit(eremetic.Request{TaskCPUs: 2.0, TaskMem: 8192.0, DockerImage: "busybox"})
+
+			So(err, ShouldBeNil)
+			So(fit.Agents, ShouldResemble, []string{"agent-1"})
+			So(fit.Evaluations, ShouldHaveLength, 2)
+			So(fit.Evaluations[1].AgentID, ShouldEqual, "agent-2")
+			So(fit.Evaluations[1].Rejection, ShouldEqual, eremetic.RejectedCPU)
+
+			fit, _ = s.Fit(eremetic.Request{TaskCPUs: 2.0, TaskMem: 32768.0, DockerImage: "busybox"})
+			So(fit.Agents, ShouldBeEmpty)
+		})
+
+		Convey("Agents whose offer was used are forgotten", func() {
+			_, err := s.ScheduleTask(eremetic.Request{TaskCPUs: 2.0, TaskMem: 8192.0, DockerImage: "busybox"})
+			So(err, ShouldBeNil)
+			s.ResourceOffers(driver, nil)
+			s.ResourceOffers(driver, []*mesosproto.Offer{agentOfferFrom("agent-1", "offer-1b", 4.0, 16384.0)})
+
+			agents := s.Agents()
+			So(agents, ShouldHaveLength, 1)
+			So(agents[0].ID, ShouldEqual, "agent-2")
+		})
+
+		Convey("Agents whose offer was rescinded are forgotten", func() {
+			s.OfferRescinded(driver, &mesosproto.OfferID{Value: proto.String("offer-2")})
+
+			So(s.Agents(), ShouldHaveLength, 1)
+		})
+
+		Convey("Old offers are dropped", func() {
+			So(s.recentOffers(time.Now().Add(agentOfferTTL+time.Second)), ShouldBeEmpty)
+			So(s.Agents(), ShouldBeEmpty)
+		})
+	})
+}
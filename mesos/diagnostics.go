@@ -0,0 +1,49 @@
+package mesos
+
+import "github.com/eremetic-framework/eremetic"
+
+// maxEvaluations is the number of offer evaluations kept for each queued
+// task.
+var maxEvaluations = 20
+
+// recordEvaluations keeps the last evaluations of offers for a queued task.
+func (s *Scheduler) recordEvaluations(id string, evaluations []eremetic.OfferEvaluation) {
+	if len(evaluations) == 0 {
+		return
+	}
+
+	s.queueMtx.Lock()
+	defer s.queueMtx.Unlock()
+	if !s.queued[id] {
+		return
+	}
+	if s.evaluations == nil {
+		s.evaluations = make(map[string][]eremetic.OfferEvaluation)
+	}
+	kept := append(s.evaluations[id], evaluations...)
+	if len(kept) > maxEvaluations {
+		kept = append([]eremetic.OfferEvaluation{}, kept[len(kept)-maxEvaluations:]...)
+	}
+	s.evaluations[id] = kept
+}
+
+// Diagnostics explains why a task hasn't been launched yet from the last
+// offers evaluated for it. They are forgotten once the task leaves the
+// queue.
+func (s *Scheduler) Diagnostics(id string) eremetic.TaskDiagnostics {
+	s.queueMtx.Lock()
+	defer s.queueMtx.Unlock()
+
+	diagnostics := eremetic.TaskDiagnostics{
+		TaskID:     id,
+		Queued:     s.queued[id],
+		Offers:     append([]eremetic.OfferEvaluation{}, s.evaluations[id]...),
+		Rejections: make(map[string]int),
+	}
+	for _, e := range diagnostics.Offers {
+		if e.Rejection != "" {
+			diagnostics.Rejections[e.Rejection]++
+		}
+	}
+	return diagnostics
+}
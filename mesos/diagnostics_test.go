@@ -0,0 +1,94 @@
+package mesos
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/mock"
+)
+
+func TestDiagnostics(t *testing.T) {
+	logrus.SetOutput(ioutil.Discard)
+
+	Convey("Given a scheduler with a queued task", t, func() {
+		driver := mock.NewMesosScheduler()
+		driver.DeclineOfferFn = func(_ *mesosproto.OfferID, _ *mesosproto.Filters) (mesosproto.Status, error) {
+			return mesosproto.Status_DRIVER_RUNNING, nil
+		}
+		driver.LaunchTasksFn = func(_ []*mesosproto.OfferID, _ []*mesosproto.TaskInfo, _ *mesosproto.Filters) (mesosproto.Status, error) {
+			return mesosproto.Status_DRIVER_RUNNING, nil
+		}
+
+		s := &Scheduler{
+			tasks:    make(chan string, 10),
+			database: eremetic.NewDefaultTaskDB(),
+		}
+		id, err := s.ScheduleTask(eremetic.Request{
+			TaskCPUs:    1.0,
+			TaskMem:     128.0,
+			DockerImage: "busybox",
+		})
+		So(err, ShouldBeNil)
+
+		Convey("Nothing is reported before offers are evaluated", func() {
+			d := s.Diagnostics(id)
+
+			So(d.Queued, ShouldBeTrue)
+			So(d.Offers, ShouldBeEmpty)
+		})
+
+		Convey("When offers are rejected", func() {
+			s.ResourceOffers(driver, []*mesosproto.Offer{
+				offer("offer-cpu", 0.5, 512.0, unavailability()),
+				offer("offer-mem", 2.0, 64.0, unavailability()),
+			})
+			// The task is put back in the queue asynchronously.
+			s.tasks <- <-s.tasks
+			s.ResourceOffers(driver, []*mesosproto.Offer{
+				offer("offer-cpu-again", 0.5, 512.0, unavailability()),
+			})
+
+			Convey("The last offers are reported with their rejection", func() {
+				d := s.Diagnostics(id)
+
+				So(d.Offers, ShouldHaveLength, 3)
+				So(d.Offers[0].OfferID, ShouldEqual, "offer-cpu")
+				So(d.Offers[0].Rejection, ShouldEqual, eremetic.RejectedCPU)
+				So(d.Offers[2].OfferID, ShouldEqual, "offer-cpu-again")
+				So(d.Rejections, ShouldResemble, map[string]int{
+					eremetic.RejectedCPU: 2,
+					eremetic.RejectedMem: 1,
+				})
+			})
+
+			Convey("They are forgotten once the task is launched", func() {
+				s.tasks <- <-s.tasks
+				s.ResourceOffers(driver, []*mesosproto.Offer{
+					offer("offer-fit", 2.0, 512.0, unavailability()),
+				})
+
+				d := s.Diagnostics(id)
+				So(d.Queued, ShouldBeFalse)
+				So(d.Offers, ShouldBeEmpty)
+			})
+		})
+
+		Convey("Only the last evaluations are kept", func() {
+			var offers []*mesosproto.Offer
+			for i := 0; i < maxEvaluations+5; i++ {
+				offers = append(offers, offer(fmt.Sprintf("offer-%d", i), 0.5, 512.0, unavailability()))
+			}
+			s.ResourceOffers(driver, offers)
+
+			d := s.Diagnostics(id)
+			So(d.Offers, ShouldHaveLength, maxEvaluations)
+			So(d.Offers[0].OfferID, ShouldEqual, "offer-5")
+		})
+	})
+}
@@ -15,9 +15,9 @@ import (
 )
 
 func getFrameworkID(scheduler *Scheduler) *mesosproto.FrameworkID {
-	if scheduler.frameworkID != "" {
+	if id := scheduler.currentFrameworkID(); id != "" {
 		return &mesosproto.FrameworkID{
-			Value: proto.String(scheduler.frameworkID),
+			Value: proto.String(id),
 		}
 	}
 	return nil
@@ -63,7 +63,7 @@ func getAuthContext(ctx context.Context) context.Context {
 	return auth.WithLoginProvider(ctx, "SASL")
 }
 
-func createDriver(scheduler *Scheduler, settings *Settings) (*mesossched.MesosSchedulerDriver, error) {
+func createDriver(scheduler *Scheduler, settings *Settings) (mesossched.SchedulerDriver, error) {
 	publishedAddr := net.ParseIP(settings.MessengerAddress)
 	bindingPort := settings.MessengerPort
 	credential, err := getCredential(settings)
@@ -72,16 +72,24 @@ func createDriver(scheduler *Scheduler, settings *Settings) (*mesossched.MesosSc
 		return nil, err
 	}
 
-	return mesossched.NewMesosSchedulerDriver(mesossched.DriverConfig{
-		Master: settings.Master,
-		Framework: &mesosproto.FrameworkInfo{
-			Id:              getFrameworkID(scheduler),
-			Name:            proto.String(settings.Name),
-			User:            proto.String(settings.User),
-			Checkpoint:      proto.Bool(settings.Checkpoint),
-			FailoverTimeout: proto.Float64(settings.FailoverTimeout),
-			Principal:       getPrincipalID(credential),
+	framework := &mesosproto.FrameworkInfo{
+		Id:              getFrameworkID(scheduler),
+		Name:            proto.String(settings.Name),
+		User:            proto.String(settings.User),
+		Checkpoint:      proto.Bool(settings.Checkpoint),
+		FailoverTimeout: proto.Float64(settings.FailoverTimeout),
+		Principal:       getPrincipalID(credential),
+		Capabilities: []*mesosproto.FrameworkInfo_Capability{
+			{Type: capabilityPartitionAware.Enum()},
 		},
+	}
+	if settings.NewDriver != nil {
+		return settings.NewDriver(scheduler, framework)
+	}
+
+	driver, err := mesossched.NewMesosSchedulerDriver(mesossched.DriverConfig{
+		Master:           settings.Master,
+		Framework:        framework,
 		Scheduler:        scheduler,
 		BindingAddress:   net.ParseIP("0.0.0.0"),
 		PublishedAddress: publishedAddr,
@@ -89,4 +97,8 @@ func createDriver(scheduler *Scheduler, settings *Settings) (*mesossched.MesosSc
 		Credential:       credential,
 		WithAuthContext:  getAuthContext,
 	})
+	if err != nil {
+		return nil, err
+	}
+	return driver, nil
 }
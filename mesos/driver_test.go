@@ -3,7 +3,13 @@ package mesos
 import (
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	mesossched "github.com/mesos/mesos-go/api/v0/scheduler"
 	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/mock"
 )
 
 func TestDriver(t *testing.T) {
@@ -16,6 +22,25 @@ func TestDriver(t *testing.T) {
 			So(err.Error(), ShouldEqual, "Missing master location URL.")
 			So(driver, ShouldBeNil)
 		})
+
+		Convey("NewDriver creates the driver when set", func() {
+			expected := mock.NewMesosScheduler()
+			var framework *mesosproto.FrameworkInfo
+			scheduler := Scheduler{frameworkID: "zoidberg"}
+
+			driver, err := createDriver(&scheduler, &Settings{
+				Name: "eremetic",
+				NewDriver: func(_ mesossched.Scheduler, f *mesosproto.FrameworkInfo) (mesossched.SchedulerDriver, error) {
+					framework = f
+					return expected, nil
+				},
+			})
+
+			So(err, ShouldBeNil)
+			So(driver, ShouldEqual, expected)
+			So(framework.GetId().GetValue(), ShouldEqual, "zoidberg")
+			So(framework.GetName(), ShouldEqual, "eremetic")
+		})
 	})
 
 	Convey("getFrameworkID", t, func() {
@@ -30,5 +55,23 @@ func TestDriver(t *testing.T) {
 			})
 			So(*fid.Value, ShouldEqual, "zoidberg")
 		})
+
+		Convey("Reads the ID set by the driver goroutine", func() {
+			scheduler := &Scheduler{initialised: true, database: eremetic.NewDefaultTaskDB()}
+			driver := mock.NewMesosScheduler()
+			driver.ReconcileTasksFn = func(_ []*mesosproto.TaskStatus) (mesosproto.Status, error) {
+				return mesosproto.Status_DRIVER_RUNNING, nil
+			}
+
+			registered := make(chan struct{})
+			go func() {
+				defer close(registered)
+				scheduler.Registered(driver, &mesosproto.FrameworkID{Value: proto.String("zoidberg")}, &mesosproto.MasterInfo{})
+			}()
+			getFrameworkID(scheduler)
+			<-registered
+
+			So(getFrameworkID(scheduler).GetValue(), ShouldEqual, "zoidberg")
+		})
 	})
 }
@@ -0,0 +1,183 @@
+package mesos
+
+import (
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	mesossched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// Policies for the tasks of agents going into maintenance. The v0 driver
+// drops inverse offers, maintenance windows are learned from the
+// unavailability of regular offers instead.
+const (
+	// MaintenanceDecline keeps the tasks running until the maintenance.
+	MaintenanceDecline = "decline"
+	// MaintenanceAccept kills the restartable tasks of an agent ahead of its
+	// maintenance and launches them again elsewhere.
+	MaintenanceAccept = "accept"
+)
+
+// maintenanceInterval is how often agents going into maintenance are
+// drained.
+var maintenanceInterval = time.Minute
+
+// maintenanceWindow converts the unavailability of an offer.
+func maintenanceWindow(u *mesosproto.Unavailability) *eremetic.Maintenance {
+	if u.GetStart() == nil {
+		return nil
+	}
+	start := time.Unix(0, u.GetStart().GetNanoseconds())
+	window := &eremetic.Maintenance{Start: start}
+	if d := u.GetDuration(); d != nil {
+		end := start.Add(time.Duration(d.GetNanoseconds()))
+		window.End = &end
+	}
+	return window
+}
+
+// drainLead returns how long before the maintenance of their agent
+// restartable tasks are drained, 0 if they aren't.
+func (s *Scheduler) drainLead() time.Duration {
+	if s.settings == nil || s.settings.MaintenancePolicy != MaintenanceAccept {
+		return 0
+	}
+	return s.settings.MaintenanceLead
+}
+
+// observeMaintenance records the maintenance windows of the agents of
+// offers, and reports them on the tasks of the agents whose window changed.
+func (s *Scheduler) observeMaintenance(offers []*mesosproto.Offer) {
+	changed := make(map[string]*eremetic.Maintenance)
+
+	s.maintenanceMtx.Lock()
+	if s.maintenance == nil {
+		s.maintenance = make(map[string]*eremetic.Maintenance)
+	}
+	for _, offer := range offers {
+		agent := offer.SlaveId.GetValue()
+		window := maintenanceWindow(offer.Unavailability)
+		if window.Equal(s.maintenance[agent]) {
+			continue
+		}
+		changed[agent] = window
+		if window == nil {
+			delete(s.maintenance, agent)
+		} else {
+			s.maintenance[agent] = window
+		}
+	}
+	s.maintenanceMtx.Unlock()
+
+	if len(changed) == 0 {
+		return
+	}
+
+	tasks, err := s.database.ListTasks(&eremetic.TaskFilter{State: eremetic.ActiveState})
+	if err != nil {
+		logrus.WithError(err).Error("Unable to list the tasks of agents going into maintenance")
+		return
+	}
+	for _, t := range tasks {
+		window, ok := changed[t.AgentID]
+		if !ok {
+			continue
+		}
+		agent := t.AgentID
+		err := s.database.UpdateTask(t.ID, func(t *eremetic.Task) error {
+			if t.AgentID != agent {
+				return errNotOnAgent
+			}
+			t.Maintenance = window
+			return nil
+		})
+		if err != nil && err != errNotOnAgent {
+			logrus.WithError(err).WithField("task_id", t.ID).Error("Unable to report the maintenance of the agent of a task")
+		}
+	}
+}
+
+// watchMaintenance drains the agents going into maintenance until the
+// scheduler shuts down.
+func (s *Scheduler) watchMaintenance() {
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			if driver := s.currentDriver(); driver != nil {
+				s.drainAgents(driver, time.Now())
+			}
+		}
+	}
+}
+
+// drainAgents kills the restartable tasks of the agents whose maintenance
+// starts within the lead. They are launched again elsewhere once the master
+// reports them killed, as it rejects launches reusing the ID of a task that
+// is still active.
+func (s *Scheduler) drainAgents(driver mesossched.SchedulerDriver, now time.Time) {
+	lead := s.drainLead()
+	if lead <= 0 {
+		return
+	}
+
+	draining := make(map[string]bool)
+	s.maintenanceMtx.Lock()
+	for agent, window := range s.maintenance {
+		if now.Add(lead).Before(window.Start) || (window.End != nil && now.After(*window.End)) {
+			continue
+		}
+		draining[agent] = true
+	}
+	s.maintenanceMtx.Unlock()
+
+	if len(draining) == 0 {
+		return
+	}
+
+	tasks, err := s.database.ListTasks(&eremetic.TaskFilter{State: eremetic.ActiveState})
+	if err != nil {
+		logrus.WithError(err).Error("Unable to list the tasks of agents going into maintenance")
+		return
+	}
+	for _, t := range tasks {
+		if !t.Restartable || t.Draining || !draining[t.AgentID] || t.IsTerminating() || t.CurrentStatus() == eremetic.TaskKilling {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"task_id":  t.ID,
+			"agent_id": t.AgentID,
+		}).Info("Draining task ahead of the maintenance of its agent")
+		if err := s.setDraining(t.ID, t.AgentID, true); err != nil {
+			if err != errNotOnAgent {
+				logrus.WithError(err).WithField("task_id", t.ID).Error("Unable to mark task as draining")
+			}
+			continue
+		}
+		if _, err := driver.KillTask(&mesosproto.TaskID{Value: proto.String(t.ID)}); err != nil {
+			logrus.WithError(err).WithField("task_id", t.ID).Warn("Unable to kill task of agent going into maintenance")
+			// The task is drained again at the next interval.
+			if err := s.setDraining(t.ID, t.AgentID, false); err != nil && err != errNotOnAgent {
+				logrus.WithError(err).WithField("task_id", t.ID).Error("Unable to mark task as not draining")
+			}
+		}
+	}
+}
+
+// setDraining records whether a task of an agent is being drained.
+func (s *Scheduler) setDraining(id, agent string, draining bool) error {
+	return s.database.UpdateTask(id, func(t *eremetic.Task) error {
+		if !t.IsActive() || t.AgentID != agent {
+			return errNotOnAgent
+		}
+		t.Draining = draining
+		return nil
+	})
+}
@@ -0,0 +1,221 @@
+package mesos
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/mesostest"
+	"github.com/eremetic-framework/eremetic/mock"
+)
+
+func TestMaintenance(t *testing.T) {
+	logrus.SetOutput(ioutil.Discard)
+
+	Convey("ValidateSettings", t, func() {
+		So(ValidateSettings(&Settings{MaintenancePolicy: MaintenanceAccept}), ShouldBeNil)
+		So(ValidateSettings(&Settings{MaintenancePolicy: "ignore"}), ShouldNotBeNil)
+	})
+
+	Convey("maintenanceWindow", t, func() {
+		So(maintenanceWindow(nil), ShouldBeNil)
+		So(maintenanceWindow(unavailability()), ShouldBeNil)
+
+		window := maintenanceWindow(unavailability(int64(time.Hour), int64(time.Minute)))
+		So(window.Start, ShouldResemble, time.Unix(3600, 0))
+		So(*window.End, ShouldResemble, time.Unix(3660, 0))
+		So(maintenanceWindow(unavailability(int64(time.Hour))).End, ShouldBeNil)
+	})
+
+	Convey("Given a scheduler with tasks on an agent going into maintenance", t, func() {
+		db := eremetic.NewDefaultTaskDB()
+		s := &Scheduler{
+			settings: &Settings{MaintenancePolicy: MaintenanceAccept, MaintenanceLead: 10 * time.Minute},
+			tasks:    make(chan string, 10),
+			database: db,
+		}
+
+		restartable := launchedTask("eremetic-task.restartable", "agent-id", eremetic.TaskRunning)
+		restartable.Restartable = true
+		db.PutTask(restartable)
+		db.PutTask(launchedTask("eremetic-task.pinned", "agent-id", eremetic.TaskRunning))
+		db.PutTask(launchedTask("eremetic-task.elsewhere", "agent-2", eremetic.TaskRunning))
+
+		driver := mock.NewMesosScheduler()
+		var killed []string
+		driver.KillTaskFn = func(id *mesosproto.TaskID) (mesosproto.Status, error) {
+			killed = append(killed, id.GetValue())
+			return mesosproto.Status_DRIVER_RUNNING, nil
+		}
+
+		now := time.Now()
+		start := now.Add(5 * time.Minute)
+		s.observeMaintenance([]*mesosproto.Offer{
+			offer("offer-1", 1.0, 128.0, unavailability(start.UnixNano(), int64(time.Hour))),
+		})
+
+		maintenance := func(id string) *eremetic.Maintenance {
+			task, _ := db.ReadTask(id)
+			return task.Maintenance
+		}
+		current := func(id string) eremetic.TaskState {
+			task, _ := db.ReadTask(id)
+			return task.CurrentStatus()
+		}
+
+		Convey("The maintenance is reported on the tasks of the agent", func() {
+			So(maintenance("eremetic-task.restartable").Start.UnixNano(), ShouldEqual, start.UnixNano())
+			So(maintenance("eremetic-task.pinned"), ShouldNotBeNil)
+			So(maintenance("eremetic-task.elsewhere"), ShouldBeNil)
+
+			Convey("And forgotten once it is cancelled", func() {
+				s.observeMaintenance([]*mesosproto.Offer{offer("offer-2", 1.0, 128.0, nil)})
+
+				So(maintenance("eremetic-task.restartable"), ShouldBeNil)
+			})
+		})
+
+		Convey("Restartable tasks are drained within the lead", func() {
+			s.drainAgents(driver, now)
+
+			So(killed, ShouldResemble, []string{"eremetic-task.restartable"})
+			So(current("eremetic-task.pinned"), ShouldEqual, eremetic.TaskRunning)
+
+			Convey("They wait for the kill to be reported", func() {
+				task, _ := db.ReadTask("eremetic-task.restartable")
+				So(task.CurrentStatus(), ShouldEqual, eremetic.TaskRunning)
+				So(task.Draining, ShouldBeTrue)
+
+				s.drainAgents(driver, now)
+				So(killed, ShouldHaveLength, 1)
+			})
+
+			Convey("They are queued again once killed", func() {
+				s.StatusUpdate(driver, &mesosproto.TaskStatus{
+					TaskId:  &mesosproto.TaskID{Value: proto.String("eremetic-task.restartable")},
+					SlaveId: &mesosproto.SlaveID{Value: proto.String("agent-id")},
+					State:   mesosproto.TaskState_TASK_KILLED.Enum(),
+				})
+
+				task, _ := db.ReadTask("eremetic-task.restartable")
+				So(task.CurrentStatus(), ShouldEqual, eremetic.TaskQueued)
+				So(task.Draining, ShouldBeFalse)
+				So(<-s.tasks, ShouldEqual, "eremetic-task.restartable")
+			})
+		})
+
+		Convey("Tasks are drained again when they can't be killed", func() {
+			driver.KillTaskFn = func(id *mesosproto.TaskID) (mesosproto.Status, error) {
+				return mesosproto.Status_DRIVER_NOT_STARTED, errors.New("not started")
+			}
+			s.drainAgents(driver, now)
+
+			task, _ := db.ReadTask("eremetic-task.restartable")
+			So(task.CurrentStatus(), ShouldEqual, eremetic.TaskRunning)
+			So(task.Draining, ShouldBeFalse)
+		})
+
+		Convey("Nothing is drained before the lead", func() {
+			s.drainAgents(driver, now.Add(-time.Hour))
+
+			So(killed, ShouldBeEmpty)
+		})
+
+		Convey("Nothing is drained when maintenance is declined", func() {
+			s.settings.MaintenancePolicy = MaintenanceDecline
+			s.drainAgents(driver, now)
+
+			So(killed, ShouldBeEmpty)
+		})
+
+		Convey("Restartable tasks aren't launched on the agent anymore", func() {
+			task := eremetic.Task{TaskCPUs: 0.5, TaskMem: 64.0, Restartable: true}
+			o := offer("offer-3", 1.0, 128.0, unavailability(start.UnixNano(), int64(time.Hour)))
+
+			match, _, evaluations := evaluateOffers(task, []*mesosproto.Offer{o}, s.drainLead())
+			So(match, ShouldBeNil)
+			So(evaluations[0].Rejection, ShouldEqual, eremetic.RejectedMaintenance)
+
+			task.Restartable = false
+			match, _, _ = evaluateOffers(task, []*mesosproto.Offer{o}, s.drainLead())
+			So(match, ShouldEqual, o)
+		})
+	})
+
+	Convey("Given a scheduler running against a simulated cluster", t, func() {
+		defer func(interval time.Duration) { maintenanceInterval = interval }(maintenanceInterval)
+		maintenanceInterval = 10 * time.Millisecond
+
+		// The maintenance of the first agent comes within the lead shortly
+		// after the task is launched.
+		lead := time.Hour
+		start := time.Now().Add(lead + time.Second)
+		cluster, err := mesostest.NewCluster(
+			mesostest.Agent{CPUs: 2.0, Mem: 2048.0, Maintenance: &eremetic.Maintenance{Start: start}},
+			mesostest.Agent{CPUs: 2.0, Mem: 2048.0},
+		)
+		So(err, ShouldBeNil)
+		defer cluster.Close()
+		cluster.OfferInterval = 10 * time.Millisecond
+
+		db := eremetic.NewDefaultTaskDB()
+		s := NewScheduler(&Settings{
+			MaxQueueSize:      10,
+			Name:              "eremetic",
+			NewDriver:         cluster.NewDriver,
+			MaintenancePolicy: MaintenanceAccept,
+			MaintenanceLead:   lead,
+		}, db)
+		go s.Run()
+		defer s.Stop()
+
+		eventually := func(condition func(eremetic.Task) bool, id string) bool {
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				task, err := db.ReadTask(id)
+				if err == nil && condition(task) {
+					return true
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return false
+		}
+		runningOn := func(agent string) func(eremetic.Task) bool {
+			return func(task eremetic.Task) bool {
+				return task.AgentID == agent && task.CurrentStatus() == eremetic.TaskRunning
+			}
+		}
+
+		Convey("Restartable tasks move to another agent", func() {
+			id, err := s.ScheduleTask(eremetic.Request{
+				TaskCPUs:    0.5,
+				TaskMem:     128.0,
+				DockerImage: "busybox",
+				Command:     "sleep 60",
+				Restartable: true,
+			})
+			So(err, ShouldBeNil)
+			So(eventually(runningOn("agent-1"), id), ShouldBeTrue)
+
+			So(eventually(runningOn("agent-2"), id), ShouldBeTrue)
+			state, _ := cluster.TaskState(id)
+			So(state, ShouldEqual, mesosproto.TaskState_TASK_RUNNING)
+
+			task, _ := db.ReadTask(id)
+			var states []eremetic.TaskState
+			for _, status := range task.Status {
+				states = append(states, status.Status)
+			}
+			So(states, ShouldContain, eremetic.TaskLost)
+			So(states, ShouldNotContain, eremetic.TaskError)
+			So(task.Draining, ShouldBeFalse)
+		})
+	})
+}
@@ -24,26 +24,37 @@ type attributeMatcher struct {
 
 type availabilityMatcher struct {
 	time.Time
+	// lead rejects agents whose maintenance starts within it.
+	lead time.Duration
+}
+
+type portsMatcher struct {
+	ports []eremetic.Port
+}
+
+// offerMatcher rejects offers for a reason.
+type offerMatcher struct {
+	reason string
+	ogle.Matcher
 }
 
 func (m *resourceMatcher) Matches(o interface{}) error {
 	offer := o.(*mesosproto.Offer)
-	err := errors.New("")
 
 	for _, res := range offer.Resources {
 		if res.GetName() == m.name {
 			if res.GetType() != mesosproto.Value_SCALAR {
-				return err
+				return fmt.Errorf("%s is not a scalar resource", m.name)
 			}
 
 			if res.Scalar.GetValue() >= m.value {
 				return nil
 			}
 
-			return err
+			return fmt.Errorf("%g %s offered, %g needed", res.Scalar.GetValue(), m.name, m.value)
 		}
 	}
-	return err
+	return fmt.Errorf("no %s offered", m.name)
 }
 
 func (m *resourceMatcher) Description() string {
@@ -59,7 +70,13 @@ func memoryAvailable(v float64) ogle.Matcher {
 }
 
 func availabilityMatch(matchTime time.Time) ogle.Matcher {
-	return &availabilityMatcher{matchTime}
+	return &availabilityMatcher{matchTime, 0}
+}
+
+// availabilityMatchAhead also rejects the agents whose maintenance starts
+// within lead.
+func availabilityMatchAhead(matchTime time.Time, lead time.Duration) ogle.Matcher {
+	return &availabilityMatcher{matchTime, lead}
 }
 
 func (m *attributeMatcher) Matches(o interface{}) error {
@@ -69,13 +86,17 @@ func (m *attributeMatcher) Matches(o interface{}) error {
 		if attr.GetName() == m.constraint.AttributeName {
 			if attr.GetType() != mesosproto.Value_TEXT ||
 				attr.Text.GetValue() != m.constraint.AttributeValue {
-				return errors.New("")
+				return fmt.Errorf("agent has %s=%s, %s needed",
+					m.constraint.AttributeName,
+					attr.Text.GetValue(),
+					m.constraint.AttributeValue,
+				)
 			}
 			return nil
 		}
 	}
 
-	return errors.New("")
+	return fmt.Errorf("agent has no %s attribute", m.constraint.AttributeName)
 }
 
 func (m *availabilityMatcher) Matches(o interface{}) error {
@@ -85,7 +106,10 @@ func (m *availabilityMatcher) Matches(o interface{}) error {
 		return nil
 	}
 
-	if start := offer.Unavailability.GetStart(); start != nil && m.UnixNano() >= *start.Nanoseconds {
+	if start := offer.Unavailability.GetStart(); start != nil && m.Add(m.lead).UnixNano() >= *start.Nanoseconds {
+		if m.UnixNano() < *start.Nanoseconds {
+			return fmt.Errorf("node goes into maintenance in %s", time.Duration(*start.Nanoseconds-m.UnixNano()).Round(time.Second))
+		}
 		if duration := offer.Unavailability.GetDuration(); duration == nil {
 			return errors.New("node is on indefinite period of maintenance")
 		} else if m.UnixNano() <= *start.Nanoseconds+*duration.Nanoseconds {
@@ -106,6 +130,27 @@ func (m *attributeMatcher) Description() string {
 	)
 }
 
+func (m *portsMatcher) Matches(o interface{}) error {
+	if len(m.ports) == 0 {
+		return nil
+	}
+	_, err := assignPorts(m.ports, offeredPorts(o.(*mesosproto.Offer)))
+	return err
+}
+
+func (m *portsMatcher) Description() string {
+	return fmt.Sprintf("%d ports", len(m.ports))
+}
+
+// portsAvailable matches offers with the ports needed by the port mappings
+// of a task. Tasks on the host network use the ports of the host directly.
+func portsAvailable(task eremetic.Task) ogle.Matcher {
+	if *buildNetwork(task) == mesosproto.ContainerInfo_DockerInfo_HOST {
+		return &portsMatcher{}
+	}
+	return &portsMatcher{task.Ports}
+}
+
 func attributeMatch(agentConstraints []eremetic.AgentConstraint) ogle.Matcher {
 	var submatchers []ogle.Matcher
 	for _, constraint := range agentConstraints {
@@ -114,33 +159,68 @@ func attributeMatch(agentConstraints []eremetic.AgentConstraint) ogle.Matcher {
 	return ogle.AllOf(submatchers...)
 }
 
-func createMatcher(task eremetic.Task) ogle.Matcher {
-	return ogle.AllOf(
-		cpuAvailable(task.TaskCPUs),
-		memoryAvailable(task.TaskMem),
-		attributeMatch(task.AgentConstraints),
-		availabilityMatch(time.Now()),
-	)
+// createMatchers returns the matchers of a task. Restartable tasks aren't
+// launched on agents whose maintenance starts within drainLead, as they
+// would be drained right away.
+func createMatchers(task eremetic.Task, drainLead time.Duration) []offerMatcher {
+	availability := availabilityMatch(time.Now())
+	if task.Restartable && drainLead > 0 {
+		availability = availabilityMatchAhead(time.Now(), drainLead)
+	}
+	return []offerMatcher{
+		{eremetic.RejectedCPU, cpuAvailable(task.TaskCPUs)},
+		{eremetic.RejectedMem, memoryAvailable(task.TaskMem)},
+		{eremetic.RejectedAttribute, attributeMatch(task.AgentConstraints)},
+		{eremetic.RejectedMaintenance, availability},
+		{eremetic.RejectedPorts, portsAvailable(task)},
+	}
 }
 
-func matches(matcher ogle.Matcher, o interface{}) bool {
-	err := matcher.Matches(o)
-	return err == nil
+// evaluateOffer matches an offer against the matchers of a task, and
+// returns the first one rejecting it, if any.
+func evaluateOffer(matchers []offerMatcher, offer *mesosproto.Offer) eremetic.OfferEvaluation {
+	evaluation := eremetic.OfferEvaluation{
+		OfferID:  offer.Id.GetValue(),
+		AgentID:  offer.SlaveId.GetValue(),
+		Hostname: offer.GetHostname(),
+		Time:     time.Now(),
+	}
+	for _, m := range matchers {
+		if err := m.Matches(offer); err != nil {
+			evaluation.Rejection = m.reason
+			evaluation.Detail = err.Error()
+			break
+		}
+	}
+	return evaluation
 }
 
 func matchOffer(task eremetic.Task, offers []*mesosproto.Offer) (*mesosproto.Offer, []*mesosproto.Offer) {
-	var matcher = createMatcher(task)
+	offer, offers, _ := evaluateOffers(task, offers, 0)
+	return offer, offers
+}
+
+// evaluateOffers returns the first offer the task can be launched with, the
+// other offers, and the evaluations of the offers up to the matching one.
+func evaluateOffers(task eremetic.Task, offers []*mesosproto.Offer, drainLead time.Duration) (*mesosproto.Offer, []*mesosproto.Offer, []eremetic.OfferEvaluation) {
+	var (
+		matchers    = createMatchers(task, drainLead)
+		evaluations []eremetic.OfferEvaluation
+	)
 	for i, off := range offers {
-		if matches(matcher, off) {
+		evaluation := evaluateOffer(matchers, off)
+		evaluations = append(evaluations, evaluation)
+		if evaluation.Rejection == "" {
 			offers[i] = offers[len(offers)-1]
 			offers = offers[:len(offers)-1]
-			return off, offers
+			return off, offers, evaluations
 		}
 		logrus.WithFields(logrus.Fields{
-			"offer_id": off.Id.GetValue(),
-			"matcher":  matcher.Description(),
-			"task_id":  task.ID,
+			"offer_id":  off.Id.GetValue(),
+			"rejection": evaluation.Rejection,
+			"detail":    evaluation.Detail,
+			"task_id":   task.ID,
 		}).Debug("Unable to match offer")
 	}
-	return nil, offers
+	return nil, offers, evaluations
 }
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"github.com/eremetic-framework/eremetic"
@@ -235,4 +236,85 @@ func TestMatch(t *testing.T) {
 			})
 		})
 	})
+
+	Convey("evaluateOffers", t, func() {
+		rejection := func(task eremetic.Task, o *mesosproto.Offer) (string, string) {
+			_, _, evaluations := evaluateOffers(task, []*mesosproto.Offer{o}, 0)
+			So(evaluations, ShouldHaveLength, 1)
+			return evaluations[0].Rejection, evaluations[0].Detail
+		}
+
+		Convey("Tells why offers are rejected", func() {
+			reason, detail := rejection(eremetic.Task{TaskCPUs: 1.0, TaskMem: 128.0}, offerA)
+			So(reason, ShouldEqual, eremetic.RejectedCPU)
+			So(detail, ShouldEqual, "0.6 cpus offered, 1 needed")
+
+			reason, _ = rejection(eremetic.Task{TaskCPUs: 0.5, TaskMem: 256.0}, offerA)
+			So(reason, ShouldEqual, eremetic.RejectedMem)
+
+			reason, detail = rejection(eremetic.Task{
+				TaskCPUs:         0.5,
+				TaskMem:          128.0,
+				AgentConstraints: []eremetic.AgentConstraint{{AttributeName: "node_name", AttributeValue: "node2"}},
+			}, offerA)
+			So(reason, ShouldEqual, eremetic.RejectedAttribute)
+			So(detail, ShouldEqual, "agent has node_name=node1, node2 needed")
+
+			reason, _ = rejection(eremetic.Task{TaskCPUs: 0.5, TaskMem: 128.0}, offerD)
+			So(reason, ShouldEqual, eremetic.RejectedMaintenance)
+
+			reason, detail = rejection(eremetic.Task{
+				TaskCPUs: 0.5,
+				TaskMem:  128.0,
+				Ports:    []eremetic.Port{{ContainerPort: 80}},
+			}, offerA)
+			So(reason, ShouldEqual, eremetic.RejectedPorts)
+			So(detail, ShouldEqual, "0 ports offered, 1 needed")
+		})
+
+		Convey("Matches ports across fragmented ranges", func() {
+			fragmented := offer("offer-ports", 1.0, 512.0,
+				&mesosproto.Unavailability{},
+				mesosutil.NewRangesResource("ports", []*mesosproto.Value_Range{
+					mesosutil.NewValueRange(31000, 31000),
+					mesosutil.NewValueRange(31005, 31006),
+				}),
+			)
+			task := eremetic.Task{TaskCPUs: 0.5, TaskMem: 128.0}
+
+			task.Ports = []eremetic.Port{{}, {}, {HostPort: 31006}}
+			reason, _ := rejection(task, fragmented)
+			So(reason, ShouldBeEmpty)
+
+			task.Ports = []eremetic.Port{{}, {}, {}, {}}
+			reason, detail := rejection(task, fragmented)
+			So(reason, ShouldEqual, eremetic.RejectedPorts)
+			So(detail, ShouldEqual, "3 ports offered, 4 needed")
+
+			task.Ports = []eremetic.Port{{HostPort: 31003}}
+			reason, detail = rejection(task, fragmented)
+			So(reason, ShouldEqual, eremetic.RejectedPorts)
+			So(detail, ShouldEqual, "port 31003 not offered")
+		})
+
+		Convey("Stops at the matching offer", func() {
+			offer, others, evaluations := evaluateOffers(eremetic.Task{TaskCPUs: 1.0, TaskMem: 128.0}, []*mesosproto.Offer{offerA, offerB, offerE}, 0)
+
+			So(offer, ShouldEqual, offerB)
+			So(others, ShouldHaveLength, 2)
+			So(evaluations, ShouldHaveLength, 2)
+			So(evaluations[0].OfferID, ShouldEqual, "offer-a")
+			So(evaluations[1].Rejection, ShouldBeEmpty)
+		})
+
+		Convey("Host networking needs no ports", func() {
+			reason, _ := rejection(eremetic.Task{
+				TaskCPUs: 0.5,
+				TaskMem:  128.0,
+				Network:  "HOST",
+				Ports:    []eremetic.Port{{ContainerPort: 80}},
+			}, offerA)
+			So(reason, ShouldBeEmpty)
+		})
+	})
 }
@@ -0,0 +1,129 @@
+package mesos
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	mesossched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic/metrics"
+)
+
+// How long the master holds back the resources of declined offers, in
+// seconds.
+const (
+	// suppressRefuseSeconds applies while no task is queued. The v0 driver
+	// can't suppress offers, declining them for long has the same effect
+	// until they are revived.
+	suppressRefuseSeconds = 300
+	// defaultRefuseSeconds is divided by the number of queued tasks, down
+	// to minRefuseSeconds, for the resources to come back sooner the more
+	// tasks are waiting.
+	defaultRefuseSeconds = 10
+	minRefuseSeconds     = 1
+)
+
+// refuseSeconds returns how long the master should hold back the resources
+// of a declined offer while the given number of tasks is queued.
+func refuseSeconds(queued int) float64 {
+	if queued == 0 {
+		return suppressRefuseSeconds
+	}
+	if r := defaultRefuseSeconds / queued; r > minRefuseSeconds {
+		return float64(r)
+	}
+	return minRefuseSeconds
+}
+
+// declineOffer declines an offer for as long as the queue allows. Offers
+// are suppressed once the queue is drained, until a task is queued again.
+func (s *Scheduler) declineOffer(driver mesossched.SchedulerDriver, offer *mesosproto.Offer) {
+	s.queueMtx.Lock()
+	queued := len(s.queued)
+	if queued == 0 && !s.suppressed {
+		logrus.Debug("Queue drained, suppressing offers")
+		s.suppressed = true
+		metrics.OffersSuppressed.Set(1)
+	}
+	s.queueMtx.Unlock()
+
+	driver.DeclineOffer(offer.Id, &mesosproto.Filters{RefuseSeconds: proto.Float64(refuseSeconds(queued))})
+	metrics.OffersDeclined.Inc()
+}
+
+// wake is called once a task was queued. It revives offers if they were
+// suppressed, and matches the task against the held offers, if any.
+func (s *Scheduler) wake() {
+	s.queueMtx.Lock()
+	revive := s.suppressed
+	s.suppressed = false
+	s.queueMtx.Unlock()
+
+	if revive {
+		metrics.OffersSuppressed.Set(0)
+		if driver := s.currentDriver(); driver != nil {
+			logrus.Debug("Task queued, reviving offers")
+			if _, err := driver.ReviveOffers(); err != nil {
+				logrus.WithError(err).Error("Unable to revive offers")
+			}
+		}
+	}
+
+	s.offerMtx.Lock()
+	driver, held := s.heldDriver, len(s.held)
+	s.offerMtx.Unlock()
+	if held > 0 {
+		go s.ResourceOffers(driver, nil)
+	}
+}
+
+// offerHold returns how long offers no task could use are held.
+func (s *Scheduler) offerHold() time.Duration {
+	if s.settings == nil {
+		return 0
+	}
+	return s.settings.OfferHold
+}
+
+// holdOffers keeps offers no task could use for a while, for tasks queued
+// in the meantime to use them, and declines them afterwards.
+func (s *Scheduler) holdOffers(driver mesossched.SchedulerDriver, offers []*mesosproto.Offer, hold time.Duration) {
+	s.offerMtx.Lock()
+	defer s.offerMtx.Unlock()
+	if s.held == nil {
+		s.held = make(map[string]*mesosproto.Offer)
+	}
+	s.heldDriver = driver
+	for _, offer := range offers {
+		id := offer.Id.GetValue()
+		s.held[id] = offer
+		time.AfterFunc(hold, func() {
+			if offer := s.releaseOffer(id); offer != nil {
+				s.declineOffer(driver, offer)
+			}
+		})
+	}
+}
+
+// takeOffers returns the held offers, which are no longer held.
+func (s *Scheduler) takeOffers() []*mesosproto.Offer {
+	s.offerMtx.Lock()
+	defer s.offerMtx.Unlock()
+	var offers []*mesosproto.Offer
+	for id, offer := range s.held {
+		offers = append(offers, offer)
+		delete(s.held, id)
+	}
+	return offers
+}
+
+// releaseOffer stops holding an offer, and returns it if it was held.
+func (s *Scheduler) releaseOffer(id string) *mesosproto.Offer {
+	s.offerMtx.Lock()
+	defer s.offerMtx.Unlock()
+	offer := s.held[id]
+	delete(s.held, id)
+	return offer
+}
@@ -0,0 +1,125 @@
+package mesos
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/mock"
+)
+
+func TestOffers(t *testing.T) {
+	logrus.SetOutput(ioutil.Discard)
+
+	request := eremetic.Request{
+		TaskCPUs:    0.5,
+		TaskMem:     22.0,
+		DockerImage: "busybox",
+		Command:     "echo hello",
+	}
+
+	Convey("refuseSeconds", t, func() {
+		So(refuseSeconds(0), ShouldEqual, suppressRefuseSeconds)
+		So(refuseSeconds(1), ShouldEqual, 10)
+		So(refuseSeconds(4), ShouldEqual, 2)
+		So(refuseSeconds(50), ShouldEqual, 1)
+	})
+
+	Convey("Given a scheduler with an empty queue", t, func() {
+		driver := mock.NewMesosScheduler()
+		var refused []float64
+		driver.DeclineOfferFn = func(_ *mesosproto.OfferID, f *mesosproto.Filters) (mesosproto.Status, error) {
+			refused = append(refused, f.GetRefuseSeconds())
+			return mesosproto.Status_DRIVER_RUNNING, nil
+		}
+		driver.ReviveOffersFn = func() (mesosproto.Status, error) {
+			return mesosproto.Status_DRIVER_RUNNING, nil
+		}
+		launched := make(chan string, 1)
+		driver.LaunchTasksFn = func(ids []*mesosproto.OfferID, _ []*mesosproto.TaskInfo, _ *mesosproto.Filters) (mesosproto.Status, error) {
+			launched <- ids[0].GetValue()
+			return mesosproto.Status_DRIVER_RUNNING, nil
+		}
+
+		s := &Scheduler{
+			settings: &Settings{},
+			tasks:    make(chan string, 10),
+			database: eremetic.NewDefaultTaskDB(),
+			driver:   driver,
+		}
+
+		Convey("When offers are received", func() {
+			s.ResourceOffers(driver, []*mesosproto.Offer{offer("1234", 1.0, 128, &mesosproto.Unavailability{})})
+
+			Convey("They are declined until offers are revived", func() {
+				So(refused, ShouldResemble, []float64{suppressRefuseSeconds})
+				So(s.suppressed, ShouldBeTrue)
+			})
+
+			Convey("Offers are revived when a task is queued", func() {
+				_, err := s.ScheduleTask(request)
+
+				So(err, ShouldBeNil)
+				So(driver.ReviveOffersFnInvoked, ShouldBeTrue)
+				So(s.suppressed, ShouldBeFalse)
+			})
+		})
+
+		Convey("When tasks are queued that offers don't fit", func() {
+			s.setQueued("eremetic-task.1", true)
+			s.setQueued("eremetic-task.2", true)
+			s.ResourceOffers(driver, []*mesosproto.Offer{offer("1234", 1.0, 128, &mesosproto.Unavailability{})})
+
+			Convey("Offers are declined for a short while", func() {
+				So(refused, ShouldResemble, []float64{5})
+				So(s.suppressed, ShouldBeFalse)
+			})
+		})
+
+		Convey("When offers are held", func() {
+			s.settings.OfferHold = time.Hour
+			s.ResourceOffers(driver, []*mesosproto.Offer{
+				offer("1234", 1.0, 128, &mesosproto.Unavailability{}),
+				offer("5678", 1.0, 128, &mesosproto.Unavailability{}),
+			})
+
+			So(refused, ShouldBeEmpty)
+			So(s.held, ShouldHaveLength, 2)
+
+			Convey("Rescinded offers are no longer held", func() {
+				s.OfferRescinded(driver, &mesosproto.OfferID{Value: proto.String("5678")})
+
+				So(s.held, ShouldHaveLength, 1)
+			})
+
+			Convey("Tasks queued in the meantime are launched with them", func() {
+				s.OfferRescinded(driver, &mesosproto.OfferID{Value: proto.String("5678")})
+				_, err := s.ScheduleTask(request)
+
+				So(err, ShouldBeNil)
+				So(<-launched, ShouldEqual, "1234")
+			})
+		})
+
+		Convey("When the hold expires", func() {
+			s.settings.OfferHold = time.Millisecond
+			declined := make(chan struct{})
+			driver.DeclineOfferFn = func(_ *mesosproto.OfferID, _ *mesosproto.Filters) (mesosproto.Status, error) {
+				close(declined)
+				return mesosproto.Status_DRIVER_RUNNING, nil
+			}
+			s.ResourceOffers(driver, []*mesosproto.Offer{offer("1234", 1.0, 128, &mesosproto.Unavailability{})})
+
+			Convey("Offers are declined", func() {
+				<-declined
+				So(s.takeOffers(), ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,182 @@
+package mesos
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	mesossched "github.com/mesos/mesos-go/api/v0/scheduler"
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/metrics"
+)
+
+// Policies for the tasks of unreachable agents.
+const (
+	// UnreachableWait waits for the agent to come back.
+	UnreachableWait = "wait"
+	// UnreachableRelaunch kills the task and launches it again once it has
+	// been unreachable for the timeout.
+	UnreachableRelaunch = "relaunch"
+)
+
+// capabilityPartitionAware declares that the framework handles the task
+// states of partitioned agents. The protobufs of the v0 driver predate it.
+const capabilityPartitionAware = mesosproto.FrameworkInfo_Capability_Type(5)
+
+// partitionAwareStates are the task states the protobufs of the v0 driver
+// predate.
+var partitionAwareStates = map[mesosproto.TaskState]eremetic.TaskState{
+	8:  eremetic.TaskKilling,
+	9:  eremetic.TaskDropped,
+	10: eremetic.TaskUnreachable,
+	11: eremetic.TaskGone,
+	12: eremetic.TaskGoneByOperator,
+	13: eremetic.TaskUnknown,
+}
+
+// unreachableInterval is how often tasks are checked for having been
+// unreachable for too long.
+var unreachableInterval = time.Minute
+
+var errNotOnAgent = errors.New("task is not running on the agent")
+
+// ValidateSettings checks the settings of the scheduler.
+func ValidateSettings(settings *Settings) error {
+	switch settings.UnreachablePolicy {
+	case "", UnreachableWait:
+	case UnreachableRelaunch:
+		if settings.UnreachableTimeout <= 0 {
+			return errors.New("the relaunch policy needs an unreachable timeout")
+		}
+	default:
+		return fmt.Errorf("unknown unreachable policy %q", settings.UnreachablePolicy)
+	}
+	switch settings.MaintenancePolicy {
+	case "", MaintenanceDecline, MaintenanceAccept:
+	default:
+		return fmt.Errorf("unknown maintenance policy %q", settings.MaintenancePolicy)
+	}
+	return nil
+}
+
+// taskState converts the state of a status update.
+func taskState(state mesosproto.TaskState) eremetic.TaskState {
+	if s, ok := partitionAwareStates[state]; ok {
+		return s
+	}
+	return eremetic.TaskState(state.String())
+}
+
+// SlaveLost is invoked when an agent has been removed. Its tasks are marked
+// lost and launched again elsewhere.
+func (s *Scheduler) SlaveLost(_ mesossched.SchedulerDriver, slaveID *mesosproto.SlaveID) {
+	agent := slaveID.GetValue()
+	logrus.WithField("agent_id", agent).Warn("Agent lost, rescheduling its tasks")
+	s.forgetAgent(agent)
+
+	tasks, err := s.database.ListTasks(&eremetic.TaskFilter{State: eremetic.ActiveState})
+	if err != nil {
+		logrus.WithError(err).WithField("agent_id", agent).Error("Unable to list the tasks of the lost agent")
+		return
+	}
+	for _, t := range tasks {
+		if t.AgentID == agent {
+			s.reschedule(t.ID, agent)
+		}
+	}
+}
+
+// watchUnreachable relaunches the tasks that have been unreachable for too
+// long until the scheduler shuts down.
+func (s *Scheduler) watchUnreachable() {
+	ticker := time.NewTicker(unreachableInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			if driver := s.currentDriver(); driver != nil {
+				s.relaunchUnreachable(driver, time.Now())
+			}
+		}
+	}
+}
+
+// relaunchUnreachable kills the tasks that have been unreachable, or unknown
+// to the master, for longer than the timeout and launches them again. The
+// agent kills the earlier launch if it ever comes back.
+func (s *Scheduler) relaunchUnreachable(driver mesossched.SchedulerDriver, now time.Time) {
+	tasks, err := s.database.ListTasks(&eremetic.TaskFilter{
+		State: fmt.Sprintf("%s,%s", eremetic.TaskUnreachable, eremetic.TaskUnknown),
+	})
+	if err != nil {
+		logrus.WithError(err).Error("Unable to list unreachable tasks")
+		return
+	}
+	for _, t := range tasks {
+		if now.Sub(t.LastUpdated()) < s.settings.UnreachableTimeout {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"task_id":  t.ID,
+			"agent_id": t.AgentID,
+		}).Warn("Relaunching unreachable task")
+		if _, err := driver.KillTask(&mesosproto.TaskID{Value: proto.String(t.ID)}); err != nil {
+			logrus.WithError(err).WithField("task_id", t.ID).Warn("Unable to kill unreachable task")
+		}
+		s.reschedule(t.ID, t.AgentID)
+	}
+}
+
+// reschedule marks a task of an agent as lost, and queues it again unless
+// it was being killed.
+func (s *Scheduler) reschedule(id string, agent string) {
+	var (
+		task        eremetic.Task
+		transitions []eremetic.Transition
+		requeue     bool
+	)
+	err := s.database.UpdateTask(id, func(t *eremetic.Task) error {
+		if !t.IsActive() || t.AgentID != agent {
+			return errNotOnAgent
+		}
+		n := len(t.Status)
+		// Drained tasks are killed by Eremetic, not by their owner.
+		requeue = t.Draining || (!t.IsTerminating() && t.CurrentStatus() != eremetic.TaskKilling)
+		t.Draining = false
+		now := time.Now().Unix()
+		if err := t.UpdateStatus(eremetic.Status{Status: eremetic.TaskLost, Time: now}); err != nil {
+			return err
+		}
+		if requeue {
+			if err := t.UpdateStatus(eremetic.Status{Status: eremetic.TaskQueued, Time: now}); err != nil {
+				return err
+			}
+		}
+		transitions = t.TransitionsSince(n)
+		task = *t
+		return nil
+	})
+	if err == errNotOnAgent {
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("task_id", id).Error("Unable to reschedule task")
+		return
+	}
+
+	eremetic.NotifyTransitions(&task, transitions)
+	if requeue {
+		s.setQueued(id, true)
+		go func() {
+			metrics.QueueSize.Inc()
+			s.tasks <- id
+			s.wake()
+		}()
+	}
+}
@@ -0,0 +1,163 @@
+package mesos
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/mock"
+)
+
+func launchedTask(id, agent string, states ...eremetic.TaskState) *eremetic.Task {
+	task := stagedTask(id)
+	task.AgentID = agent
+	for _, state := range states {
+		task.Status = append(task.Status, eremetic.Status{Status: state, Time: 1})
+	}
+	return task
+}
+
+func TestPartition(t *testing.T) {
+	logrus.SetOutput(ioutil.Discard)
+
+	Convey("taskState", t, func() {
+		So(taskState(mesosproto.TaskState_TASK_RUNNING), ShouldEqual, eremetic.TaskRunning)
+		So(taskState(mesosproto.TaskState(10)), ShouldEqual, eremetic.TaskUnreachable)
+		So(taskState(mesosproto.TaskState(12)), ShouldEqual, eremetic.TaskGoneByOperator)
+	})
+
+	Convey("ValidateSettings", t, func() {
+		So(ValidateSettings(&Settings{}), ShouldBeNil)
+		So(ValidateSettings(&Settings{UnreachablePolicy: UnreachableRelaunch, UnreachableTimeout: time.Minute}), ShouldBeNil)
+		So(ValidateSettings(&Settings{UnreachablePolicy: UnreachableRelaunch}), ShouldNotBeNil)
+		So(ValidateSettings(&Settings{UnreachablePolicy: "pray"}), ShouldNotBeNil)
+	})
+
+	Convey("Given a scheduler with tasks on an agent", t, func() {
+		db := eremetic.NewDefaultTaskDB()
+		s := &Scheduler{
+			settings: &Settings{UnreachablePolicy: UnreachableRelaunch, UnreachableTimeout: time.Minute},
+			tasks:    make(chan string, 10),
+			database: db,
+		}
+
+		db.PutTask(launchedTask("eremetic-task.running", "agent-1", eremetic.TaskRunning))
+		db.PutTask(launchedTask("eremetic-task.killed", "agent-1", eremetic.TaskRunning, eremetic.TaskTerminating))
+		db.PutTask(launchedTask("eremetic-task.elsewhere", "agent-2", eremetic.TaskRunning))
+
+		update := func(id, agent string, state mesosproto.TaskState) {
+			s.StatusUpdate(nil, &mesosproto.TaskStatus{
+				TaskId:  &mesosproto.TaskID{Value: proto.String(id)},
+				SlaveId: &mesosproto.SlaveID{Value: proto.String(agent)},
+				State:   state.Enum(),
+			})
+		}
+		current := func(id string) eremetic.TaskState {
+			task, _ := db.ReadTask(id)
+			return task.CurrentStatus()
+		}
+
+		Convey("When a task becomes unreachable", func() {
+			update("eremetic-task.running", "agent-1", mesosproto.TaskState(10))
+
+			So(current("eremetic-task.running"), ShouldEqual, eremetic.TaskUnreachable)
+
+			Convey("It may come back", func() {
+				update("eremetic-task.running", "agent-1", mesosproto.TaskState_TASK_RUNNING)
+
+				So(current("eremetic-task.running"), ShouldEqual, eremetic.TaskRunning)
+			})
+		})
+
+		Convey("When the agent is lost", func() {
+			s.SlaveLost(nil, &mesosproto.SlaveID{Value: proto.String("agent-1")})
+
+			Convey("Its tasks are marked lost and queued again", func() {
+				task, _ := db.ReadTask("eremetic-task.running")
+				n := len(task.Status)
+				So(task.Status[n-2].Status, ShouldEqual, eremetic.TaskLost)
+				So(task.Status[n-1].Status, ShouldEqual, eremetic.TaskQueued)
+				So(<-s.tasks, ShouldEqual, "eremetic-task.running")
+				So(s.Queued("eremetic-task.running"), ShouldBeTrue)
+			})
+
+			Convey("Tasks being killed are only marked lost", func() {
+				So(current("eremetic-task.killed"), ShouldEqual, eremetic.TaskLost)
+			})
+
+			Convey("Tasks of other agents are left alone", func() {
+				So(current("eremetic-task.elsewhere"), ShouldEqual, eremetic.TaskRunning)
+			})
+
+			Convey("Late updates of the lost launch are ignored", func() {
+				update("eremetic-task.running", "agent-1", mesosproto.TaskState(11))
+
+				So(current("eremetic-task.running"), ShouldEqual, eremetic.TaskQueued)
+
+				db.UpdateTask("eremetic-task.running", func(t *eremetic.Task) error {
+					t.AgentID = "agent-3"
+					return t.UpdateStatus(eremetic.Status{Status: eremetic.TaskStaging})
+				})
+				update("eremetic-task.running", "agent-1", mesosproto.TaskState_TASK_KILLED)
+
+				So(current("eremetic-task.running"), ShouldEqual, eremetic.TaskStaging)
+			})
+		})
+
+		Convey("When tasks are unreachable", func() {
+			now := time.Now()
+			db.PutTask(launchedTask("eremetic-task.unreachable", "agent-1", eremetic.TaskRunning, eremetic.TaskUnreachable))
+			recent := launchedTask("eremetic-task.recent", "agent-2", eremetic.TaskUnreachable)
+			recent.Status[len(recent.Status)-1].Time = now.Unix()
+			db.PutTask(recent)
+
+			driver := mock.NewMesosScheduler()
+			var killed []string
+			driver.KillTaskFn = func(id *mesosproto.TaskID) (mesosproto.Status, error) {
+				killed = append(killed, id.GetValue())
+				return mesosproto.Status_DRIVER_RUNNING, nil
+			}
+
+			s.relaunchUnreachable(driver, now)
+
+			Convey("Those unreachable for too long are killed and queued again", func() {
+				So(killed, ShouldResemble, []string{"eremetic-task.unreachable"})
+				So(current("eremetic-task.unreachable"), ShouldEqual, eremetic.TaskQueued)
+				So(<-s.tasks, ShouldEqual, "eremetic-task.unreachable")
+			})
+
+			Convey("The others are waited for", func() {
+				So(current("eremetic-task.recent"), ShouldEqual, eremetic.TaskUnreachable)
+			})
+		})
+
+		Convey("Unreachable tasks are watched with the current driver", func() {
+			defer func(interval time.Duration) { unreachableInterval = interval }(unreachableInterval)
+			unreachableInterval = time.Millisecond
+			db.PutTask(launchedTask("eremetic-task.unreachable", "agent-1", eremetic.TaskRunning, eremetic.TaskUnreachable))
+
+			killed := make(chan string, 1)
+			driver := mock.NewMesosScheduler()
+			driver.KillTaskFn = func(id *mesosproto.TaskID) (mesosproto.Status, error) {
+				killed <- id.GetValue()
+				return mesosproto.Status_DRIVER_RUNNING, nil
+			}
+
+			s.shutdown = make(chan struct{})
+			defer close(s.shutdown)
+			go s.watchUnreachable()
+			// The driver is replaced while the watch runs, as when the
+			// scheduler is disconnected.
+			time.Sleep(5 * time.Millisecond)
+			s.setDriver(driver)
+
+			So(<-killed, ShouldEqual, "eremetic-task.unreachable")
+		})
+	})
+}
@@ -0,0 +1,110 @@
+package mesos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// offeredPorts returns the port ranges of an offer.
+func offeredPorts(offer *mesosproto.Offer) []*mesosproto.Value_Range {
+	var ranges []*mesosproto.Value_Range
+	for _, res := range offer.Resources {
+		if res.GetName() != "ports" || res.GetType() != mesosproto.Value_RANGES {
+			continue
+		}
+		ranges = append(ranges, res.Ranges.GetRange()...)
+	}
+	return ranges
+}
+
+func inRanges(ranges []*mesosproto.Value_Range, port uint64) bool {
+	for _, r := range ranges {
+		if port >= r.GetBegin() && port <= r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+// assignPorts picks the host ports of the port mappings of a task from the
+// offered ranges. Requested host ports have to be offered, the other ones
+// are the first offered ports left.
+func assignPorts(ports []eremetic.Port, ranges []*mesosproto.Value_Range) ([]uint32, error) {
+	assigned := make([]uint32, len(ports))
+	taken := make(map[uint64]bool)
+
+	for i, p := range ports {
+		if p.HostPort == 0 || p.Dynamic {
+			continue
+		}
+		port := uint64(p.HostPort)
+		if !inRanges(ranges, port) {
+			return nil, fmt.Errorf("port %d not offered", port)
+		}
+		if taken[port] {
+			return nil, fmt.Errorf("port %d needed twice", port)
+		}
+		taken[port] = true
+		assigned[i] = p.HostPort
+	}
+
+	r, next := 0, uint64(0)
+	if len(ranges) > 0 {
+		next = ranges[0].GetBegin()
+	}
+	for i := range ports {
+		if assigned[i] != 0 {
+			continue
+		}
+		for r < len(ranges) {
+			for next <= ranges[r].GetEnd() && taken[next] {
+				next++
+			}
+			if next <= ranges[r].GetEnd() {
+				break
+			}
+			if r++; r < len(ranges) {
+				next = ranges[r].GetBegin()
+			}
+		}
+		if r == len(ranges) {
+			offered := 0
+			for _, rng := range ranges {
+				offered += int(rng.GetEnd()-rng.GetBegin()) + 1
+			}
+			return nil, fmt.Errorf("%d ports offered, %d needed", offered, len(ports))
+		}
+		taken[next] = true
+		assigned[i] = uint32(next)
+	}
+
+	return assigned, nil
+}
+
+// portRanges returns the ranges covering the given ports.
+func portRanges(ports []uint32) []*mesosproto.Value_Range {
+	sorted := append([]uint32(nil), ports...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var ranges []*mesosproto.Value_Range
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		ranges = append(ranges, mesosutil.NewValueRange(uint64(sorted[i]), uint64(sorted[j])))
+		i = j + 1
+	}
+	return ranges
+}
+
+// portVariable returns the environment variable a named port is exposed as.
+func portVariable(name string) string {
+	return "PORT_" + strings.ToUpper(name)
+}
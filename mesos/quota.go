@@ -0,0 +1,41 @@
+package mesos
+
+import (
+	"github.com/eremetic-framework/eremetic"
+)
+
+// checkQuotas verifies a task against every quota that applies to it. When
+// launching is set the running limits are checked, otherwise the limits for
+// adding the task to the queue.
+func checkQuotas(db eremetic.TaskDB, task *eremetic.Task, launching bool) error {
+	quotas, err := db.ListQuotas()
+	if err != nil {
+		return err
+	}
+
+	var tasks []*eremetic.Task
+	for _, q := range quotas {
+		if !q.Matches(task) {
+			continue
+		}
+		if tasks == nil {
+			tasks, err = db.ListTasks(&eremetic.TaskFilter{
+				State: eremetic.DefaultTaskFilterState,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		usage := q.Usage(tasks)
+		if launching {
+			err = q.CheckLaunch(usage)
+		} else {
+			err = q.CheckEnqueue(usage, task)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
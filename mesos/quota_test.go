@@ -0,0 +1,117 @@
+package mesos
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/mock"
+)
+
+// slowListing is a database taking some time to return the tasks it lists.
+type slowListing struct {
+	eremetic.TaskDB
+}
+
+func (db slowListing) ListTasks(filter *eremetic.TaskFilter) ([]*eremetic.Task, error) {
+	tasks, err := db.TaskDB.ListTasks(filter)
+	time.Sleep(10 * time.Millisecond)
+	return tasks, err
+}
+
+func TestQuotas(t *testing.T) {
+	Convey("Quotas", t, func() {
+		db := eremetic.NewDefaultTaskDB()
+		s := &Scheduler{
+			tasks:    make(chan string, 10),
+			database: db,
+		}
+		request := eremetic.Request{
+			TaskCPUs:    0.5,
+			TaskMem:     22.0,
+			DockerImage: "busybox",
+			Command:     "echo hello",
+			Owner:       "alice",
+		}
+
+		Convey("Given a quota on queued tasks", func() {
+			db.PutQuota(&eremetic.Quota{Name: "alice", Owner: "alice", MaxQueued: 1})
+
+			_, err := s.ScheduleTask(request)
+			So(err, ShouldBeNil)
+
+			Convey("Scheduling beyond the quota is rejected", func() {
+				_, err := s.ScheduleTask(request)
+				So(err, ShouldHaveSameTypeAs, &eremetic.QuotaExceededError{})
+				So(s.tasks, ShouldHaveLength, 1)
+			})
+
+			Convey("Concurrent requests don't exceed the quota together", func() {
+				db.PutQuota(&eremetic.Quota{Name: "alice", Owner: "alice", MaxQueued: 3})
+				// Slow listings leave the requests time to check the
+				// quota together.
+				s.database = slowListing{db}
+
+				var (
+					wg        sync.WaitGroup
+					mtx       sync.Mutex
+					scheduled int
+				)
+				for i := 0; i < 8; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := s.ScheduleTask(request); err == nil {
+							mtx.Lock()
+							scheduled++
+							mtx.Unlock()
+						}
+					}()
+				}
+				wg.Wait()
+				So(scheduled, ShouldEqual, 2)
+				So(s.tasks, ShouldHaveLength, 3)
+			})
+
+			Convey("Tasks of other owners are not affected", func() {
+				request.Owner = "bob"
+				_, err := s.ScheduleTask(request)
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("Given a quota on running tasks", func() {
+			db.PutQuota(&eremetic.Quota{Name: "alice", Owner: "alice", MaxRunning: 1})
+			db.PutTask(&eremetic.Task{
+				ID:     "eremetic-task.running",
+				Owner:  "alice",
+				Status: []eremetic.Status{{Status: eremetic.TaskRunning}},
+			})
+
+			id, err := s.ScheduleTask(request)
+			So(err, ShouldBeNil)
+
+			driver := mock.NewMesosScheduler()
+			driver.DeclineOfferFn = func(_ *mesosproto.OfferID, _ *mesosproto.Filters) (mesosproto.Status, error) {
+				return mesosproto.Status_DRIVER_RUNNING, nil
+			}
+
+			s.ResourceOffers(driver, []*mesosproto.Offer{
+				offer("1234", 1.0, 128, &mesosproto.Unavailability{}),
+			})
+
+			Convey("The task is held back in the queue", func() {
+				So(driver.LaunchTasksFnInvoked, ShouldBeFalse)
+				So(driver.DeclineOfferFnInvoked, ShouldBeTrue)
+
+				task, _ := db.ReadTask(id)
+				So(task.CurrentStatus(), ShouldEqual, eremetic.TaskQueued)
+				So(<-s.tasks, ShouldEqual, id)
+			})
+		})
+	})
+}
@@ -1,6 +1,8 @@
 package mesos
 
 import (
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -9,95 +11,264 @@ import (
 	mesossched "github.com/mesos/mesos-go/api/v0/scheduler"
 
 	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/metrics"
 )
 
 var (
 	maxReconciliationDelay = 120
+
+	// redeliveryDelay is how long to wait before asking the master again
+	// for the state of a task whose status update couldn't be stored.
+	redeliveryDelay = 5 * time.Second
 )
 
 type reconciler struct {
 	cancel chan struct{}
 	done   chan struct{}
+
+	mtx     sync.Mutex
+	report  eremetic.Reconciliation
+	pending map[string]bool
 }
 
 func (r *reconciler) Cancel() {
 	close(r.cancel)
 }
 
-func reconcileTasks(driver mesossched.SchedulerDriver, database eremetic.TaskDB) *reconciler {
-	cancel := make(chan struct{})
-	done := make(chan struct{})
+// Report returns the report of the reconciliation so far.
+func (r *reconciler) Report() *eremetic.Reconciliation {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 
-	go func() {
-		var (
-			c     uint
-			delay = 1
-		)
+	report := r.report
+	report.Pending = []string{}
+	for id := range r.pending {
+		report.Pending = append(report.Pending, id)
+	}
+	sort.Strings(report.Pending)
+	report.Discrepancies = append([]eremetic.Discrepancy{}, r.report.Discrepancies...)
+	report.Lost = append([]string{}, r.report.Lost...)
+	return &report
+}
 
-		tasks, err := database.ListTasks(&eremetic.TaskFilter{
-			State: eremetic.DefaultTaskFilterState,
-		})
-		if err != nil {
-			logrus.WithError(err).Error("Failed to list non-terminal tasks")
-			close(done)
+// Answer records a status update of a task, stored being the state of the
+// task before the update. The answers to reconciliation requests that
+// differ from the stored state are reported as discrepancies.
+func (r *reconciler) Answer(status *mesosproto.TaskStatus, stored eremetic.TaskState) {
+	id := status.TaskId.GetValue()
+	reported := taskState(status.GetState())
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.pending, id)
+	if status.GetReason() != mesosproto.TaskStatus_REASON_RECONCILIATION || reported == stored {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"task_id":  id,
+		"stored":   stored,
+		"reported": reported,
+	}).Warn("Master reported a different task state")
+	r.report.Discrepancies = append(r.report.Discrepancies, eremetic.Discrepancy{
+		TaskID:   id,
+		Stored:   stored,
+		Reported: reported,
+		Time:     time.Now(),
+	})
+	metrics.ReconciliationDiscrepancies.Inc()
+}
+
+func (r *reconciler) isPending(id string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.pending[id]
+}
+
+func (r *reconciler) resolve(id string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.pending, id)
+}
+
+func (r *reconciler) finish(outcome string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	now := time.Now()
+	r.report.Finished = &now
+	r.report.Outcome = outcome
+
+	metrics.ReconciliationDuration.Observe(now.Sub(r.report.Started).Seconds())
+	metrics.Reconciliations.WithLabelValues(outcome).Inc()
+}
+
+// reconcileTasks explicitly reconciles the active tasks, asking the master
+// for their state with an exponential backoff until it reported on all of
+// them. Tasks the master hasn't reported on after the timeout, if any, are
+// marked lost. An implicit reconciliation follows if asked for, for the
+// master to report on the tasks it knows of.
+func reconcileTasks(driver mesossched.SchedulerDriver, database eremetic.TaskDB, timeout time.Duration, implicit bool) *reconciler {
+	r := &reconciler{
+		cancel:  make(chan struct{}),
+		done:    make(chan struct{}),
+		pending: make(map[string]bool),
+		report: eremetic.Reconciliation{
+			Started: time.Now(),
+			Outcome: eremetic.ReconciliationRunning,
+		},
+	}
+
+	tasks, err := database.ListTasks(&eremetic.TaskFilter{
+		State: eremetic.ActiveState,
+	})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list non-terminal tasks")
+		r.finish(eremetic.ReconciliationFailed)
+		close(r.done)
+		return r
+	}
+	for _, t := range tasks {
+		r.pending[t.ID] = true
+	}
+
+	go r.run(driver, database, tasks, timeout, implicit)
+	return r
+}
+
+func (r *reconciler) run(driver mesossched.SchedulerDriver, database eremetic.TaskDB, tasks []*eremetic.Task, timeout time.Duration, implicit bool) {
+	defer close(r.done)
+
+	var (
+		c     uint
+		delay = 1
+	)
+
+	logrus.Infof("Trying to reconcile with %d task(s)", len(tasks))
+	start := r.report.Started
+	outcome := eremetic.ReconciliationDone
+
+loop:
+	for len(tasks) > 0 {
+		select {
+		case <-r.cancel:
+			logrus.Info("Cancelling reconciliation job")
+			r.finish(eremetic.ReconciliationCancelled)
 			return
-		}
+		case <-time.After(time.Duration(delay) * time.Second):
+			// Filter tasks that has received a status update
+			ntasks := []*eremetic.Task{}
+			for _, t := range tasks {
+				if !r.isPending(t.ID) {
+					continue
+				}
+				nt, err := database.ReadTask(t.ID)
+				if err != nil {
+					logrus.WithField("task_id", t.ID).Warn("Task not found in database")
+					r.resolve(t.ID)
+					continue
+				}
+				if nt.LastUpdated().Before(start) {
+					ntasks = append(ntasks, &nt)
+				} else {
+					r.resolve(t.ID)
+				}
+			}
+			tasks = ntasks
 
-		logrus.Infof("Trying to reconcile with %d task(s)", len(tasks))
-		start := time.Now()
-
-		for len(tasks) > 0 {
-			select {
-			case <-cancel:
-				logrus.Info("Cancelling reconciliation job")
-				close(done)
-				return
-			case <-time.After(time.Duration(delay) * time.Second):
-				// Filter tasks that has received a status update
-				ntasks := []*eremetic.Task{}
+			if len(tasks) > 0 && timeout > 0 && time.Since(start) >= timeout {
+				r.escalate(database, tasks)
+				outcome = eremetic.ReconciliationEscalated
+				break loop
+			}
+
+			// Send reconciliation request
+			if len(tasks) > 0 {
+				var statuses []*mesosproto.TaskStatus
 				for _, t := range tasks {
-					nt, err := database.ReadTask(t.ID)
-					if err != nil {
-						logrus.WithField("task_id", t.ID).Warn("Task not found in database")
-						continue
-					}
-					if nt.LastUpdated().Before(start) {
-						ntasks = append(ntasks, &nt)
-					}
-				}
-				tasks = ntasks
-
-				// Send reconciliation request
-				if len(tasks) > 0 {
-					var statuses []*mesosproto.TaskStatus
-					for _, t := range tasks {
-						statuses = append(statuses, &mesosproto.TaskStatus{
-							State:   mesosproto.TaskState_TASK_STAGING.Enum(),
-							TaskId:  &mesosproto.TaskID{Value: proto.String(t.ID)},
-							SlaveId: &mesosproto.SlaveID{Value: proto.String(t.AgentID)},
-						})
-					}
-					logrus.WithField("reconciliation_request_count", c).Debug("Sending reconciliation request")
-					driver.ReconcileTasks(statuses)
+					statuses = append(statuses, &mesosproto.TaskStatus{
+						State:   mesosproto.TaskState_TASK_STAGING.Enum(),
+						TaskId:  &mesosproto.TaskID{Value: proto.String(t.ID)},
+						SlaveId: &mesosproto.SlaveID{Value: proto.String(t.AgentID)},
+					})
 				}
+				logrus.WithField("reconciliation_request_count", c).Debug("Sending reconciliation request")
+				driver.ReconcileTasks(statuses)
+				r.mtx.Lock()
+				r.report.Requests++
+				r.mtx.Unlock()
+			}
 
-				if delay < maxReconciliationDelay {
-					delay = 10 << c
-					if delay >= maxReconciliationDelay {
-						delay = maxReconciliationDelay
-					}
+			if delay < maxReconciliationDelay {
+				delay = 10 << c
+				if delay >= maxReconciliationDelay {
+					delay = maxReconciliationDelay
 				}
+			}
 
-				c++
+			c++
+		}
+	}
+
+	if implicit {
+		logrus.Debug("Sending implicit reconciliation request")
+		driver.ReconcileTasks([]*mesosproto.TaskStatus{})
+	}
+
+	logrus.Info("Reconciliation done")
+	r.finish(outcome)
+}
+
+// escalate marks the tasks the master never reported on as lost.
+func (r *reconciler) escalate(database eremetic.TaskDB, tasks []*eremetic.Task) {
+	for _, t := range tasks {
+		var (
+			task        eremetic.Task
+			transitions []eremetic.Transition
+		)
+		err := database.UpdateTask(t.ID, func(t *eremetic.Task) error {
+			n := len(t.Status)
+			if err := t.UpdateStatus(eremetic.Status{
+				Status: eremetic.TaskLost,
+				Time:   time.Now().Unix(),
+			}); err != nil {
+				return err
 			}
+			transitions = t.TransitionsSince(n)
+			task = *t
+			return nil
+		})
+		r.resolve(t.ID)
+		if err != nil {
+			logrus.WithError(err).WithField("task_id", t.ID).Warn("Unable to mark unreconciled task as lost")
+			continue
 		}
 
-		logrus.Info("Reconciliation done")
-		close(done)
-	}()
+		logrus.WithField("task_id", t.ID).Warn("Master never reported on task, marking it as lost")
+		eremetic.NotifyTransitions(&task, transitions)
+		r.mtx.Lock()
+		r.report.Lost = append(r.report.Lost, t.ID)
+		r.mtx.Unlock()
+		metrics.ReconciliationLost.Inc()
+	}
+}
 
-	return &reconciler{
-		cancel: cancel,
-		done:   done,
+// requestRedelivery asks the master to send the latest state of a task
+// again. The v0 driver acknowledges status updates before handing them to
+// the scheduler, so an update that couldn't be stored would otherwise be
+// lost until the next reconciliation.
+func requestRedelivery(driver mesossched.SchedulerDriver, status *mesosproto.TaskStatus) {
+	if driver == nil {
+		return
 	}
+	time.AfterFunc(redeliveryDelay, func() {
+		logrus.WithField("task_id", status.TaskId.GetValue()).Info("Asking for the task status again")
+		_, err := driver.ReconcileTasks([]*mesosproto.TaskStatus{{
+			State:   status.State,
+			TaskId:  status.TaskId,
+			SlaveId: status.SlaveId,
+		}})
+		if err != nil {
+			logrus.WithError(err).WithField("task_id", status.TaskId.GetValue()).Error("Unable to ask for the task status again")
+		}
+	})
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/mesos/mesos-go/api/v0/mesosproto"
 	. "github.com/smartystreets/goconvey/convey"
 
@@ -19,7 +20,7 @@ func TestReconcile(t *testing.T) {
 	Convey("ReconcileTasks", t, func() {
 		Convey("Finishes when there are no tasks", func() {
 			driver := mock.NewMesosScheduler()
-			r := reconcileTasks(driver, db)
+			r := reconcileTasks(driver, db, 0, false)
 
 			select {
 			case <-r.done:
@@ -54,7 +55,7 @@ func TestReconcile(t *testing.T) {
 				},
 			})
 
-			r := reconcileTasks(driver, db)
+			r := reconcileTasks(driver, db, 0, false)
 
 			select {
 			case <-r.done:
@@ -76,7 +77,7 @@ func TestReconcile(t *testing.T) {
 				},
 			})
 
-			r := reconcileTasks(driver, db)
+			r := reconcileTasks(driver, db, 0, false)
 			r.Cancel()
 
 			select {
@@ -85,5 +86,103 @@ func TestReconcile(t *testing.T) {
 
 			So(driver.ReconcileTasksFnInvoked, ShouldBeFalse)
 		})
+
+		Convey("Reports what the master answered", func() {
+			db := eremetic.NewDefaultTaskDB()
+			db.PutTask(&eremetic.Task{
+				ID:     "1234",
+				Status: []eremetic.Status{{Status: eremetic.TaskStaging, Time: time.Now().Unix()}},
+			})
+			driver := mock.NewMesosScheduler()
+
+			r := reconcileTasks(driver, db, 0, false)
+			So(r.Report().Pending, ShouldResemble, []string{"1234"})
+
+			r.Answer(&mesosproto.TaskStatus{
+				TaskId: &mesosproto.TaskID{Value: proto.String("1234")},
+				State:  mesosproto.TaskState_TASK_RUNNING.Enum(),
+				Reason: mesosproto.TaskStatus_REASON_RECONCILIATION.Enum(),
+			}, eremetic.TaskStaging)
+			<-r.done
+
+			report := r.Report()
+			So(driver.ReconcileTasksFnInvoked, ShouldBeFalse)
+			So(report.Outcome, ShouldEqual, eremetic.ReconciliationDone)
+			So(report.Finished, ShouldNotBeNil)
+			So(report.Pending, ShouldBeEmpty)
+			So(report.Discrepancies, ShouldHaveLength, 1)
+			So(report.Discrepancies[0].TaskID, ShouldEqual, "1234")
+			So(report.Discrepancies[0].Stored, ShouldEqual, eremetic.TaskStaging)
+			So(report.Discrepancies[0].Reported, ShouldEqual, eremetic.TaskRunning)
+		})
+
+		Convey("Marks tasks the master never reports on as lost", func() {
+			db := eremetic.NewDefaultTaskDB()
+			db.PutTask(&eremetic.Task{
+				ID: "1234",
+				Status: []eremetic.Status{
+					{Status: eremetic.TaskQueued, Time: time.Now().Unix()},
+					{Status: eremetic.TaskStaging, Time: time.Now().Unix()},
+				},
+			})
+			driver := mock.NewMesosScheduler()
+
+			r := reconcileTasks(driver, db, time.Nanosecond, false)
+			<-r.done
+
+			task, _ := db.ReadTask("1234")
+			So(task.CurrentStatus(), ShouldEqual, eremetic.TaskLost)
+			So(r.Report().Outcome, ShouldEqual, eremetic.ReconciliationEscalated)
+			So(r.Report().Lost, ShouldResemble, []string{"1234"})
+		})
+
+		Convey("Follows with an implicit reconciliation", func() {
+			db := eremetic.NewDefaultTaskDB()
+			driver := mock.NewMesosScheduler()
+			var statuses []*mesosproto.TaskStatus
+			driver.ReconcileTasksFn = func(ts []*mesosproto.TaskStatus) (mesosproto.Status, error) {
+				statuses = ts
+				return mesosproto.Status_DRIVER_RUNNING, nil
+			}
+
+			r := reconcileTasks(driver, db, 0, true)
+			<-r.done
+
+			So(driver.ReconcileTasksFnInvoked, ShouldBeTrue)
+			So(statuses, ShouldBeEmpty)
+		})
+	})
+
+	Convey("reconcilePeriodically", t, func() {
+		s := &Scheduler{
+			settings: &Settings{ReconcileInterval: time.Millisecond},
+			shutdown: make(chan struct{}),
+			database: eremetic.NewDefaultTaskDB(),
+		}
+		defer close(s.shutdown)
+
+		reconciled := make(chan struct{}, 1)
+		driver := mock.NewMesosScheduler()
+		driver.ReconcileTasksFn = func(ts []*mesosproto.TaskStatus) (mesosproto.Status, error) {
+			select {
+			case reconciled <- struct{}{}:
+			default:
+			}
+			return mesosproto.Status_DRIVER_RUNNING, nil
+		}
+
+		go s.reconcilePeriodically()
+		// The driver is replaced while the loop runs, as when the scheduler
+		// is disconnected.
+		time.Sleep(5 * time.Millisecond)
+		s.setDriver(driver)
+
+		var ok bool
+		select {
+		case <-reconciled:
+			ok = true
+		case <-time.After(5 * time.Second):
+		}
+		So(ok, ShouldBeTrue)
 	})
 }
@@ -5,12 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/mesos/mesos-go/api/v0/mesosproto"
 	mesossched "github.com/mesos/mesos-go/api/v0/scheduler"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 
 	"github.com/eremetic-framework/eremetic"
@@ -20,8 +20,21 @@ import (
 var (
 	defaultFilter = &mesosproto.Filters{RefuseSeconds: proto.Float64(10)}
 	maxRetries    = 5
+
+	// errTaskTerminating aborts the launch of a task killed while an offer
+	// was being matched for it.
+	errTaskTerminating = errors.New("task is terminating")
+	errMissingTaskID   = errors.New("task has no id")
+	// errStaleUpdate rejects status updates about an earlier launch of a
+	// task.
+	errStaleUpdate = errors.New("status update is about an earlier launch of the task")
 )
 
+// frameworkRemoved is the error sent by the master to a framework trying to
+// register with the ID of a framework that was torn down or failed over for
+// too long.
+const frameworkRemoved = "Framework has been removed"
+
 // Settings holds configuration values for the scheduler
 type Settings struct {
 	MaxQueueSize     int
@@ -34,25 +47,83 @@ type Settings struct {
 	MessengerPort    uint16
 	Checkpoint       bool
 	FailoverTimeout  float64
+
+	// UnreachablePolicy is either UnreachableWait or UnreachableRelaunch.
+	UnreachablePolicy  string
+	UnreachableTimeout time.Duration
+
+	// ReconcileInterval is how often the tasks are reconciled with the
+	// master, ReconcileTimeout how long the master has to report on a task
+	// before it is marked lost. Zero values disable both.
+	ReconcileInterval time.Duration
+	ReconcileTimeout  time.Duration
+
+	// OfferHold is how long offers no task could use are held for tasks
+	// queued in the meantime, instead of being declined right away.
+	OfferHold time.Duration
+
+	// MaintenancePolicy is either MaintenanceDecline or MaintenanceAccept,
+	// which drains the restartable tasks of an agent MaintenanceLead
+	// before its maintenance.
+	MaintenancePolicy string
+	MaintenanceLead   time.Duration
+
+	// NewDriver, when set, creates the scheduler drivers instead of
+	// connecting to the master, e.g. to run against a simulated cluster.
+	NewDriver func(mesossched.Scheduler, *mesosproto.FrameworkInfo) (mesossched.SchedulerDriver, error)
 }
 
 // Scheduler holds the structure of the Eremetic Scheduler
 type Scheduler struct {
 	settings *Settings
 
-	frameworkID string
 	initialised bool
+
+	// the running driver, replaced when disconnected, and the ID of the
+	// framework, set when it registers. They are read by the background
+	// loops, hence the mutex.
+	driverMtx   sync.Mutex
 	driver      mesossched.SchedulerDriver
+	frameworkID string
 
 	// task to start
 	tasks chan string
 
+	// ids of the tasks in the queue, including those being matched against
+	// offers.
+	queueMtx sync.Mutex
+	queued   map[string]bool
+	// whether offers are suppressed, until a task is queued again.
+	suppressed bool
+	// the last offers evaluated for the queued tasks.
+	evaluations map[string][]eremetic.OfferEvaluation
+	// held from the quota check of a new task until it is stored, so that
+	// concurrent requests can't exceed a quota together.
+	enqueueMtx sync.Mutex
+
+	// offers held for tasks to come, by offer id.
+	offerMtx   sync.Mutex
+	held       map[string]*mesosproto.Offer
+	heldDriver mesossched.SchedulerDriver
+
 	// This channel is closed when the program receives an interrupt,
 	// signalling that the program should shut down.
 	shutdown chan struct{}
 
+	// Receives a value when the framework should be torn down.
+	teardown chan struct{}
+
+	// the last offer of each agent, by agent id.
+	agentMtx sync.Mutex
+	agents   map[string]agentOffer
+
+	// maintenance windows of the agents, by agent id.
+	maintenanceMtx sync.Mutex
+	maintenance    map[string]*eremetic.Maintenance
+
 	// Handle for current reconciliation job
-	reconcile *reconciler
+	reconcileMtx sync.Mutex
+	reconcile    *reconciler
 
 	// Handler for storing tasks
 	database eremetic.TaskDB
@@ -63,6 +134,7 @@ func NewScheduler(settings *Settings, db eremetic.TaskDB) *Scheduler {
 	return &Scheduler{
 		settings:    settings,
 		shutdown:    make(chan struct{}),
+		teardown:    make(chan struct{}, 1),
 		tasks:       make(chan string, settings.MaxQueueSize),
 		database:    db,
 		frameworkID: settings.FrameworkID,
@@ -71,31 +143,187 @@ func NewScheduler(settings *Settings, db eremetic.TaskDB) *Scheduler {
 
 // Run the eremetic scheduler
 func (s *Scheduler) Run() {
+	if s.settings.UnreachablePolicy == UnreachableRelaunch {
+		go s.watchUnreachable()
+	}
+	if s.settings.ReconcileInterval > 0 {
+		go s.reconcilePeriodically()
+	}
+	if s.settings.MaintenancePolicy == MaintenanceAccept {
+		go s.watchMaintenance()
+	}
+	for s.runDriver() {
+		logrus.Info("Framework torn down, registering a new one")
+	}
+
+	logrus.Info("Exiting...")
+}
+
+// runDriver runs a scheduler driver until it stops, and returns whether it
+// was stopped to tear down the framework.
+func (s *Scheduler) runDriver() bool {
+	s.loadFrameworkID()
 	driver, err := createDriver(s, s.settings)
-	s.driver = driver
+	s.setDriver(driver)
 
 	if err != nil {
 		logrus.WithError(err).Error("Unable to create scheduler driver")
+		return false
 	}
 
+	stopped := make(chan struct{})
+	torndown := make(chan bool, 1)
 	go func() {
-		<-s.shutdown
-		driver.Stop(false)
+		select {
+		case <-s.shutdown:
+			// Failing over keeps the tasks running until the framework
+			// registers again with the same ID.
+			driver.Stop(true)
+			torndown <- false
+		case <-s.teardown:
+			driver.Stop(false)
+			torndown <- true
+		case <-stopped:
+			torndown <- false
+		}
 	}()
 
 	if status, err := driver.Run(); err != nil {
 		logrus.WithError(err).WithField("status", status.String()).Error("Framework stopped")
 	}
+	close(stopped)
 
-	logrus.Info("Exiting...")
+	if !<-torndown {
+		return false
+	}
+	// The scheduler stays initialised, so that the new framework explicitly
+	// reconciles the tasks of the old one, which the master reports as lost.
+	s.setFrameworkID("")
+	return true
+}
+
+// setDriver replaces the running driver.
+func (s *Scheduler) setDriver(driver mesossched.SchedulerDriver) {
+	s.driverMtx.Lock()
+	defer s.driverMtx.Unlock()
+	s.driver = driver
+}
+
+// currentDriver returns the running driver, nil if there is none.
+func (s *Scheduler) currentDriver() mesossched.SchedulerDriver {
+	s.driverMtx.Lock()
+	defer s.driverMtx.Unlock()
+	return s.driver
+}
+
+// setFrameworkID replaces the ID of the framework.
+func (s *Scheduler) setFrameworkID(id string) {
+	s.driverMtx.Lock()
+	defer s.driverMtx.Unlock()
+	s.frameworkID = id
+}
+
+// currentFrameworkID returns the ID of the framework, empty until it first
+// registers.
+func (s *Scheduler) currentFrameworkID() string {
+	s.driverMtx.Lock()
+	defer s.driverMtx.Unlock()
+	return s.frameworkID
+}
+
+// loadFrameworkID reads the framework ID stored by a previous run, unless
+// one has been configured.
+func (s *Scheduler) loadFrameworkID() {
+	if s.currentFrameworkID() != "" {
+		return
+	}
+	id, err := s.database.ReadFrameworkID()
+	if err != nil {
+		logrus.WithError(err).Error("Unable to read the framework ID")
+		return
+	}
+	if id != "" {
+		logrus.WithField("framework_id", id).Info("Reusing the stored framework ID")
+	}
+	s.setFrameworkID(id)
 }
 
 // Reconcile reconciles the currently scheduled tasks.
 func (s *Scheduler) Reconcile(driver mesossched.SchedulerDriver) {
+	s.startReconciliation(driver, false)
+}
+
+// Reconciliation reports on the last reconciliation of the tasks with the
+// master.
+func (s *Scheduler) Reconciliation() *eremetic.Reconciliation {
+	s.reconcileMtx.Lock()
+	defer s.reconcileMtx.Unlock()
+	if s.reconcile == nil {
+		return nil
+	}
+	return s.reconcile.Report()
+}
+
+// startReconciliation cancels the current reconciliation, if any, and
+// starts a new one.
+func (s *Scheduler) startReconciliation(driver mesossched.SchedulerDriver, implicit bool) {
+	var timeout time.Duration
+	if s.settings != nil {
+		timeout = s.settings.ReconcileTimeout
+	}
+
+	s.reconcileMtx.Lock()
+	defer s.reconcileMtx.Unlock()
 	if s.reconcile != nil {
 		s.reconcile.Cancel()
 	}
-	s.reconcile = reconcileTasks(driver, s.database)
+	s.reconcile = reconcileTasks(driver, s.database, timeout, implicit)
+}
+
+// reconcilePeriodically reconciles the tasks explicitly and implicitly at
+// every interval, unless the previous reconciliation is still running,
+// until the scheduler shuts down.
+func (s *Scheduler) reconcilePeriodically() {
+	ticker := time.NewTicker(s.settings.ReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			driver := s.currentDriver()
+			if driver == nil || s.reconciling() {
+				continue
+			}
+			s.startReconciliation(driver, true)
+		}
+	}
+}
+
+// reconciling returns whether a reconciliation is running.
+func (s *Scheduler) reconciling() bool {
+	s.reconcileMtx.Lock()
+	defer s.reconcileMtx.Unlock()
+	if s.reconcile == nil {
+		return false
+	}
+	select {
+	case <-s.reconcile.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// answerReconciliation records a status update in the current
+// reconciliation.
+func (s *Scheduler) answerReconciliation(status *mesosproto.TaskStatus, stored eremetic.TaskState) {
+	s.reconcileMtx.Lock()
+	r := s.reconcile
+	s.reconcileMtx.Unlock()
+	if r != nil {
+		r.Answer(status, stored)
+	}
 }
 
 // Registered is called when the Scheduler is Registered
@@ -106,7 +334,10 @@ func (s *Scheduler) Registered(driver mesossched.SchedulerDriver, frameworkID *m
 		"master":       masterInfo.GetHostname(),
 	}).Debug("Framework registered with master.")
 
-	s.frameworkID = frameworkID.GetValue()
+	s.setFrameworkID(frameworkID.GetValue())
+	if err := s.database.PutFrameworkID(frameworkID.GetValue()); err != nil {
+		logrus.WithError(err).Error("Unable to store the framework ID")
+	}
 	if !s.initialised {
 		driver.ReconcileTasks([]*mesosproto.TaskStatus{})
 		s.initialised = true
@@ -133,7 +364,7 @@ func (s *Scheduler) Reregistered(driver mesossched.SchedulerDriver, masterInfo *
 func (s *Scheduler) Disconnected(mesossched.SchedulerDriver) {
 	logrus.Debugf("Framework disconnected with master, attempting to connect a new driver")
 	driver, err := createDriver(s, s.settings)
-	s.driver = driver
+	s.setDriver(driver)
 
 	if err != nil {
 		logrus.WithError(err).Error("Unable to create scheduler driver")
@@ -141,13 +372,17 @@ func (s *Scheduler) Disconnected(mesossched.SchedulerDriver) {
 
 	go func() {
 		<-s.shutdown
-		driver.Stop(false)
+		driver.Stop(true)
 	}()
 }
 
 // ResourceOffers handles the Resource Offers
 func (s *Scheduler) ResourceOffers(driver mesossched.SchedulerDriver, offers []*mesosproto.Offer) {
 	logrus.WithField("offers", len(offers)).Debug("Received offers")
+	metrics.OffersReceived.Add(float64(len(offers)))
+	s.observeMaintenance(offers)
+	s.recordOffers(offers, time.Now())
+	offers = append(s.takeOffers(), offers...)
 	var offer *mesosproto.Offer
 	var offers_updated []*mesosproto.Offer
 
@@ -175,15 +410,21 @@ loop:
 
 			if t.IsTerminating() {
 				logrus.Debug("Dropping terminating task.")
-				t.UpdateStatus(eremetic.Status{
-					Status: eremetic.TaskKilled,
-					Time:   time.Now().Unix(),
-				})
-				s.database.PutTask(&t)
+				s.dropTerminating(tid)
 
 				continue
 			}
-			offer, offers_updated = matchOffer(t, offers)
+
+			if err := checkQuotas(s.database, &t, true); err != nil {
+				logrus.WithError(err).WithField("task_id", tid).Info("Holding back task over quota")
+				metrics.TasksDelayed.Inc()
+				go func() { s.tasks <- tid }()
+				break loop
+			}
+
+			var evaluations []eremetic.OfferEvaluation
+			offer, offers_updated, evaluations = evaluateOffers(t, offers, s.drainLead())
+			s.recordEvaluations(tid, evaluations)
 
 			if offer == nil {
 				logrus.WithField("task_id", tid).Warn("Unable to find a matching offer")
@@ -192,12 +433,26 @@ loop:
 				break loop
 			}
 
-			t, task := createTaskInfo(t, offer)
-			logrus.WithFields(logrus.Fields{
-				"task_id":  task.TaskId.GetValue(),
-				"offer_id": offer.Id.GetValue(),
-			}).Debug("Preparing to launch task")
-			if task.TaskId.GetValue() == "" {
+			var task *mesosproto.TaskInfo
+			err = s.database.UpdateTask(tid, func(t *eremetic.Task) error {
+				if t.IsTerminating() {
+					return errTaskTerminating
+				}
+				*t, task = createTaskInfo(*t, offer)
+				if task.TaskId.GetValue() == "" {
+					return errMissingTaskID
+				}
+				return t.UpdateStatus(eremetic.Status{
+					Status: eremetic.TaskStaging,
+					Time:   time.Now().Unix(),
+				})
+			})
+			if err == errTaskTerminating {
+				logrus.Debug("Dropping terminating task.")
+				s.dropTerminating(tid)
+				continue
+			}
+			if err == errMissingTaskID {
 				logrus.WithFields(logrus.Fields{
 					"task_id_after_createTaskInfo": task.TaskId.GetValue(),
 					"task_id_original":             tid,
@@ -206,24 +461,29 @@ loop:
 				go func() { s.tasks <- tid }()
 				break loop
 			}
-			t.UpdateStatus(eremetic.Status{
-				Status: eremetic.TaskStaging,
-				Time:   time.Now().Unix(),
-			})
-			s.database.PutTask(&t)
+			if err != nil {
+				logrus.WithError(err).WithField("task_id", tid).Error("Unable to update task before launch")
+				metrics.TasksDelayed.Inc()
+				go func() { s.tasks <- tid }()
+				break loop
+			}
+			logrus.WithFields(logrus.Fields{
+				"task_id":  task.TaskId.GetValue(),
+				"offer_id": offer.Id.GetValue(),
+			}).Debug("Preparing to launch task")
 			_, err = driver.LaunchTasks([]*mesosproto.OfferID{offer.Id}, []*mesosproto.TaskInfo{task}, defaultFilter)
 			if err != nil {
 				logrus.WithFields(logrus.Fields{
 					"task_id":  task.TaskId.GetValue(),
 					"offer_id": offer.Id.GetValue(),
 				}).WithError(err).Warn("Failed to launch task")
-				t.UpdateStatus(eremetic.Status{
-					Status: eremetic.TaskError,
-					Time:   time.Now().Unix(),
-				})
+				s.updateStatus(tid, eremetic.TaskError)
 			} else {
 				metrics.TasksLaunched.Inc()
+				metrics.OffersUsed.Inc()
+				s.forgetOffer(offer.Id.GetValue())
 			}
+			s.setQueued(tid, false)
 			metrics.QueueSize.Dec()
 			offers = offers_updated
 
@@ -233,96 +493,208 @@ loop:
 		}
 	}
 
+	if hold := s.offerHold(); hold > 0 && len(offers) > 0 {
+		logrus.Debug("No tasks to launch. Holding offers.")
+		s.holdOffers(driver, offers, hold)
+		return
+	}
+
 	logrus.Debug("No tasks to launch. Declining offers.")
 	for _, offer := range offers {
-		driver.DeclineOffer(offer.Id, defaultFilter)
+		s.declineOffer(driver, offer)
 	}
 }
 
 // StatusUpdate takes care of updating the status
 func (s *Scheduler) StatusUpdate(driver mesossched.SchedulerDriver, status *mesosproto.TaskStatus) {
 	id := status.TaskId.GetValue()
-	newState := eremetic.TaskState(status.State.String())
+	newState := taskState(status.GetState())
 
 	logrus.WithFields(logrus.Fields{
 		"task_id": id,
 		"status":  status.State.String(),
 	}).Debug("Received task status update")
 
-	task, err := s.database.ReadUnmaskedTask(id)
-	if err != nil {
-		logrus.WithError(err).WithField("task_id", id).Debug("Unable to read task from database")
-	}
-
-	if task.ID == "" {
-		task = eremetic.Task{
-			ID:      id,
-			AgentID: status.SlaveId.GetValue(),
-		}
-	}
-
 	sandboxPath, err := extractSandboxPath(status.Data)
 	if err != nil {
 		logrus.WithError(err).Debug("Unable to extract sandbox path")
 	}
 
-	if sandboxPath != "" {
-		task.SandboxPath = sandboxPath
-	}
-
-	if newState == eremetic.TaskRunning && !task.IsRunning() {
-		metrics.TasksRunning.Inc()
-	}
-
-	var shouldRetry bool
-	if newState == eremetic.TaskFailed && !task.WasRunning() {
-		if task.Retry >= maxRetries {
-			logrus.WithFields(logrus.Fields{
-				"task_id": id,
-				"retries": task.Retry,
-			}).Warn("Giving up on launching task")
-		} else {
-			shouldRetry = true
+	var (
+		task        eremetic.Task
+		transitions []eremetic.Transition
+		wasRunning  bool
+		shouldRetry bool
+		drained     bool
+		stored      eremetic.TaskState
+	)
+	err = s.database.UpdateTask(id, func(t *eremetic.Task) error {
+		stored = t.CurrentStatus()
+		// Tasks waiting to be launched again, or launched on another agent,
+		// may still get the updates of their earlier launch.
+		agent := status.SlaveId.GetValue()
+		if t.IsEnqueued() || (t.AgentID != "" && agent != "" && agent != t.AgentID) {
+			return errStaleUpdate
+		}
+		if t.AgentID == "" {
+			t.AgentID = agent
+		}
+		if sandboxPath != "" {
+			t.SandboxPath = sandboxPath
 		}
-	}
 
-	if eremetic.IsTerminal(newState) {
-		var seq string
-		if shouldRetry {
-			seq = "retry"
-		} else {
-			seq = "final"
+		n := len(t.Status)
+		wasRunning = t.WasRunning()
+		// A task killed to drain its agent is lost to the agent and queued
+		// again, like those of lost agents.
+		drained = t.Draining && newState == eremetic.TaskKilled
+		if eremetic.IsTerminal(newState) {
+			t.Draining = false
+		}
+		state := newState
+		if drained {
+			state = eremetic.TaskLost
 		}
-		metrics.TasksTerminated.With(prometheus.Labels{
-			"status":   string(newState),
-			"sequence": seq,
-		}).Inc()
-		if task.WasRunning() {
-			metrics.TasksRunning.Dec()
+		if err := t.UpdateStatus(eremetic.Status{
+			Status: state,
+			Time:   time.Now().Unix(),
+		}); err != nil {
+			return err
 		}
+		shouldRetry = newState == eremetic.TaskFailed && len(t.Status) > n && !wasRunning && t.Retry < maxRetries
+		if shouldRetry || drained {
+			if err := t.UpdateStatus(eremetic.Status{
+				Status: eremetic.TaskQueued,
+				Time:   time.Now().Unix(),
+			}); err != nil {
+				return err
+			}
+		}
+		if shouldRetry {
+			t.Retry++
+		}
+		transitions = t.TransitionsSince(n)
+		task = *t
+		return nil
+	})
+	if _, ok := err.(*eremetic.InvalidTransitionError); ok || err == nil {
+		s.answerReconciliation(status, stored)
+	}
+	if _, ok := err.(*eremetic.InvalidTransitionError); ok || err == errStaleUpdate {
+		logrus.WithError(err).WithField("task_id", id).Warn("Ignoring task status update")
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("task_id", id).Error("Unable to update task in database")
+		requestRedelivery(driver, status)
+		return
 	}
 
-	task.UpdateStatus(eremetic.Status{
-		Status: newState,
-		Time:   time.Now().Unix(),
-	})
+	if newState == eremetic.TaskFailed && len(transitions) > 0 && !wasRunning && !shouldRetry {
+		logrus.WithFields(logrus.Fields{
+			"task_id": id,
+			"retries": task.Retry,
+		}).Warn("Giving up on launching task")
+	}
+
+	eremetic.NotifyTransitions(&task, transitions)
 
 	if shouldRetry {
 		logrus.WithField("task_id", id).Info("Re-scheduling task that never ran.")
-		task.UpdateStatus(eremetic.Status{
-			Status: eremetic.TaskQueued,
-			Time:   time.Now().Unix(),
-		})
-		task.Retry++
+	}
+	if drained {
+		logrus.WithField("task_id", id).Info("Re-scheduling task drained ahead of the maintenance of its agent")
+		metrics.TasksDrained.Inc()
+	}
+	if shouldRetry || drained {
+		s.setQueued(id, true)
 		go func() {
 			metrics.QueueSize.Inc()
 			s.tasks <- id
+			s.wake()
 		}()
-	} else if eremetic.IsTerminal(newState) {
-		eremetic.NotifyCallback(&task)
 	}
+}
+
+// updateStatus moves a task to a state.
+func (s *Scheduler) updateStatus(id string, state eremetic.TaskState) {
+	var (
+		task        eremetic.Task
+		transitions []eremetic.Transition
+	)
+	err := s.database.UpdateTask(id, func(t *eremetic.Task) error {
+		n := len(t.Status)
+		if err := t.UpdateStatus(eremetic.Status{
+			Status: state,
+			Time:   time.Now().Unix(),
+		}); err != nil {
+			return err
+		}
+		transitions = t.TransitionsSince(n)
+		task = *t
+		return nil
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"task_id": id,
+			"status":  state,
+		}).Error("Unable to update task in database")
+		return
+	}
+	eremetic.NotifyTransitions(&task, transitions)
+}
 
-	s.database.PutTask(&task)
+// dropTerminating marks a task that was killed before being launched as
+// killed.
+func (s *Scheduler) dropTerminating(id string) {
+	s.setQueued(id, false)
+	s.updateStatus(id, eremetic.TaskKilled)
+}
+
+func (s *Scheduler) setQueued(id string, queued bool) {
+	s.queueMtx.Lock()
+	defer s.queueMtx.Unlock()
+	if s.queued == nil {
+		s.queued = make(map[string]bool)
+	}
+	if queued {
+		s.queued[id] = true
+	} else {
+		delete(s.queued, id)
+		delete(s.evaluations, id)
+	}
+}
+
+// Queued returns whether a task is in the queue of the scheduler.
+func (s *Scheduler) Queued(id string) bool {
+	s.queueMtx.Lock()
+	defer s.queueMtx.Unlock()
+	return s.queued[id]
+}
+
+// Enqueue adds a task of the database to the queue, unless it is already in
+// it. It is used to recover queued tasks the scheduler lost track of.
+func (s *Scheduler) Enqueue(id string) error {
+	s.queueMtx.Lock()
+	if s.queued[id] {
+		s.queueMtx.Unlock()
+		return nil
+	}
+	if s.queued == nil {
+		s.queued = make(map[string]bool)
+	}
+	s.queued[id] = true
+	s.queueMtx.Unlock()
+
+	select {
+	case s.tasks <- id:
+		metrics.QueueSize.Inc()
+		s.wake()
+		return nil
+	case <-time.After(time.Duration(1) * time.Second):
+		s.setQueued(id, false)
+		return eremetic.ErrQueueFull
+	}
 }
 
 // FrameworkMessage is invoked when an executor sends a message.
@@ -351,11 +723,8 @@ func (s *Scheduler) FrameworkMessage(
 // OfferRescinded is invoked when an offer is no longer valid.
 func (s *Scheduler) OfferRescinded(_ mesossched.SchedulerDriver, offerID *mesosproto.OfferID) {
 	logrus.WithField("offer_id", offerID).Debug("Offer Rescinded")
-}
-
-// SlaveLost is invoked when a slave has been determined unreachable.
-func (s *Scheduler) SlaveLost(_ mesossched.SchedulerDriver, slaveID *mesosproto.SlaveID) {
-	logrus.WithField("slave_id", slaveID).Debug("Slave lost")
+	s.releaseOffer(offerID.GetValue())
+	s.forgetOffer(offerID.GetValue())
 }
 
 // ExecutorLost is invoked when an executor has exited/terminated.
@@ -369,6 +738,15 @@ func (s *Scheduler) ExecutorLost(_ mesossched.SchedulerDriver, executorID *mesos
 // Error is invoked when there is an unrecoverable error in the scheduler or scheduler driver.
 func (s *Scheduler) Error(_ mesossched.SchedulerDriver, err string) {
 	logrus.WithError(errors.New(err)).Debug("Received an error")
+
+	// The stored framework ID can't be used anymore once the master removed
+	// the framework, forget it so that the next run registers a new one.
+	if err == frameworkRemoved {
+		logrus.WithField("framework_id", s.currentFrameworkID()).Warn("Framework was removed by the master")
+		if err := s.database.PutFrameworkID(""); err != nil {
+			logrus.WithError(err).Error("Unable to reset the framework ID")
+		}
+	}
 }
 
 // ScheduleTask tries to register a new task in the database to be scheduled.
@@ -390,45 +768,90 @@ func (s *Scheduler) ScheduleTask(request eremetic.Request) (string, error) {
 		return "", err
 	}
 
+	s.enqueueMtx.Lock()
+	defer s.enqueueMtx.Unlock()
+
+	if err := checkQuotas(s.database, &task, false); err != nil {
+		return "", err
+	}
+
+	s.setQueued(task.ID, true)
 	select {
 	case s.tasks <- task.ID:
 		s.database.PutTask(&task)
 		metrics.TasksCreated.Inc()
 		metrics.QueueSize.Inc()
+		s.wake()
 		return task.ID, nil
 	case <-time.After(time.Duration(1) * time.Second):
+		s.setQueued(task.ID, false)
 		return "", eremetic.ErrQueueFull
 	}
 }
 
 // Kill will signal mesos that a task should be killed as soon as possible.
 func (s *Scheduler) Kill(tastID string) error {
-	task, err := s.database.ReadTask(tastID)
-	if err != nil {
-		return err
-	}
-
-	if task.IsTerminated() {
-		return fmt.Errorf("you can not kill that which is already dead")
-	}
+	var (
+		waiting     bool
+		killed      eremetic.Task
+		transitions []eremetic.Transition
+	)
+	err := s.database.UpdateTask(tastID, func(task *eremetic.Task) error {
+		if task.IsTerminated() {
+			return fmt.Errorf("you can not kill that which is already dead")
+		}
 
-	waiting := task.IsEnqueued()
+		waiting = task.IsEnqueued()
 
-	logrus.Debugf("Marking task for killing.")
-	task.UpdateStatus(eremetic.Status{
-		Status: eremetic.TaskTerminating,
-		Time:   time.Now().Unix(),
+		logrus.Debugf("Marking task for killing.")
+		n := len(task.Status)
+		if err := task.UpdateStatus(eremetic.Status{
+			Status: eremetic.TaskTerminating,
+			Time:   time.Now().Unix(),
+		}); err != nil {
+			return err
+		}
+		transitions = task.TransitionsSince(n)
+		killed = *task
+		return nil
 	})
-	s.database.PutTask(&task)
+	if err != nil {
+		return err
+	}
+	eremetic.NotifyTransitions(&killed, transitions)
 
 	if waiting {
 		return nil
 	}
 
-	_, err = s.driver.KillTask(&mesosproto.TaskID{Value: proto.String(tastID)})
+	driver := s.currentDriver()
+	if driver == nil {
+		return errors.New("scheduler is not running")
+	}
+	_, err = driver.KillTask(&mesosproto.TaskID{Value: proto.String(tastID)})
 	return err
 }
 
+// Teardown unregisters the framework, which makes the master kill all of its
+// tasks, and forgets its ID so that a new framework gets registered. The
+// tasks left in the database are reconciled against the new framework,
+// which reports them as lost.
+func (s *Scheduler) Teardown() error {
+	if s.currentDriver() == nil {
+		return errors.New("scheduler is not running")
+	}
+	if err := s.database.PutFrameworkID(""); err != nil {
+		return err
+	}
+
+	logrus.WithField("framework_id", s.currentFrameworkID()).Warn("Tearing down the framework")
+	select {
+	case s.teardown <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
 // Stop triggers a shutdown of the scheduler.
 func (s *Scheduler) Stop() {
 	close(s.shutdown)
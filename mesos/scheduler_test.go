@@ -40,6 +40,50 @@ func callbackReceiver() (chan eremetic.CallbackData, *httptest.Server) {
 	return cb, ts
 }
 
+// killingTaskDB kills a task right before its first update, as if Kill
+// had been called concurrently.
+type killingTaskDB struct {
+	*eremetic.DefaultTaskDB
+	killed bool
+}
+
+func (db *killingTaskDB) UpdateTask(id string, fn func(*eremetic.Task) error) error {
+	if !db.killed {
+		db.killed = true
+		db.DefaultTaskDB.UpdateTask(id, func(t *eremetic.Task) error {
+			t.UpdateStatus(eremetic.Status{Status: eremetic.TaskTerminating, Time: time.Now().Unix()})
+			return nil
+		})
+	}
+	return db.DefaultTaskDB.UpdateTask(id, fn)
+}
+
+// stagedTask returns a task launched by the scheduler, waiting for its first
+// status update.
+func stagedTask(id string) *eremetic.Task {
+	return &eremetic.Task{
+		ID: id,
+		Status: []eremetic.Status{
+			{Status: eremetic.TaskQueued},
+			{Status: eremetic.TaskStaging},
+		},
+	}
+}
+
+// failingTaskDB fails to store task updates, like a scheduler crashing
+// between receiving a status update and writing it.
+type failingTaskDB struct {
+	eremetic.TaskDB
+	fail bool
+}
+
+func (db *failingTaskDB) UpdateTask(id string, fn func(*eremetic.Task) error) error {
+	if db.fail {
+		return errors.New("write failed")
+	}
+	return db.TaskDB.UpdateTask(id, fn)
+}
+
 func TestScheduler(t *testing.T) {
 	logrus.SetOutput(ioutil.Discard)
 
@@ -82,6 +126,53 @@ func TestScheduler(t *testing.T) {
 
 				Convey("The framework ID is stored", func() {
 					So(s.frameworkID, ShouldEqual, "1234")
+
+					stored, err := db.ReadFrameworkID()
+					So(err, ShouldBeNil)
+					So(stored, ShouldEqual, "1234")
+				})
+			})
+
+			Convey("When a framework ID was stored", func() {
+				db.PutFrameworkID("5678")
+
+				Convey("It is reused", func() {
+					s.loadFrameworkID()
+					So(s.frameworkID, ShouldEqual, "5678")
+				})
+
+				Convey("A configured framework ID takes precedence", func() {
+					s.frameworkID = "1234"
+					s.loadFrameworkID()
+					So(s.frameworkID, ShouldEqual, "1234")
+				})
+
+				Convey("It is forgotten when the master removed the framework", func() {
+					s.Error(nil, frameworkRemoved)
+
+					stored, err := db.ReadFrameworkID()
+					So(err, ShouldBeNil)
+					So(stored, ShouldBeEmpty)
+				})
+			})
+
+			Convey("When the framework is torn down", func() {
+				db.PutFrameworkID("1234")
+				s.teardown = make(chan struct{}, 1)
+
+				Convey("It fails while the scheduler isn't running", func() {
+					So(s.Teardown(), ShouldNotBeNil)
+				})
+
+				Convey("The driver is asked to stop and the framework ID is forgotten", func() {
+					s.driver = mock.NewMesosScheduler()
+
+					So(s.Teardown(), ShouldBeNil)
+					So(s.teardown, ShouldHaveLength, 1)
+
+					stored, err := db.ReadFrameworkID()
+					So(err, ShouldBeNil)
+					So(stored, ShouldBeEmpty)
 				})
 			})
 
@@ -239,6 +330,42 @@ func TestScheduler(t *testing.T) {
 				Convey("The tasks should be launched", func() {
 					So(driver.LaunchTasksFnInvoked, ShouldBeTrue)
 				})
+				Convey("The task should no longer be queued", func() {
+					So(s.Queued(taskID), ShouldBeFalse)
+				})
+			})
+
+			Convey("When a task is killed while it is being launched", func() {
+				kdb := &killingTaskDB{DefaultTaskDB: eremetic.NewDefaultTaskDB()}
+				s.database = kdb
+				offers := []*mesosproto.Offer{
+					offer("1234", 1.0, 128, &mesosproto.Unavailability{}),
+				}
+				driver.DeclineOfferFn = func(_ *mesosproto.OfferID, _ *mesosproto.Filters) (mesosproto.Status, error) {
+					return mesosproto.Status_DRIVER_RUNNING, nil
+				}
+
+				taskID, err := s.ScheduleTask(eremetic.Request{
+					TaskCPUs:    0.5,
+					TaskMem:     22.0,
+					DockerImage: "busybox",
+					Command:     "echo hello",
+				})
+				So(err, ShouldBeNil)
+
+				s.ResourceOffers(driver, offers)
+
+				task, err := kdb.ReadTask(taskID)
+				So(err, ShouldBeNil)
+
+				Convey("The task should be killed", func() {
+					So(task.Status, ShouldHaveLength, 3)
+					So(task.Status[1].Status, ShouldEqual, eremetic.TaskTerminating)
+					So(task.Status[2].Status, ShouldEqual, eremetic.TaskKilled)
+				})
+				Convey("The tasks should not be launched", func() {
+					So(driver.LaunchTasksFnInvoked, ShouldBeFalse)
+				})
 			})
 
 			Convey("When a task can be launched but fails", func() {
@@ -339,7 +466,7 @@ func TestScheduler(t *testing.T) {
 
 			id := "eremetic-task.9999"
 
-			db.PutTask(&eremetic.Task{ID: id})
+			db.PutTask(stagedTask(id))
 
 			Convey("When a running task fails", func() {
 				s.StatusUpdate(nil, &mesosproto.TaskStatus{
@@ -352,8 +479,8 @@ func TestScheduler(t *testing.T) {
 				task, err := db.ReadTask(id)
 				So(err, ShouldBeNil)
 
-				So(len(task.Status), ShouldEqual, 1)
-				So(task.Status[0].Status, ShouldEqual, eremetic.TaskRunning)
+				So(len(task.Status), ShouldEqual, 3)
+				So(task.Status[2].Status, ShouldEqual, eremetic.TaskRunning)
 
 				s.StatusUpdate(nil, &mesosproto.TaskStatus{
 					TaskId: &mesosproto.TaskID{
@@ -366,9 +493,9 @@ func TestScheduler(t *testing.T) {
 				So(err, ShouldBeNil)
 
 				Convey("The task status history should contain the failed status", func() {
-					So(len(task.Status), ShouldEqual, 2)
-					So(task.Status[0].Status, ShouldEqual, eremetic.TaskRunning)
-					So(task.Status[1].Status, ShouldEqual, eremetic.TaskFailed)
+					So(len(task.Status), ShouldEqual, 4)
+					So(task.Status[2].Status, ShouldEqual, eremetic.TaskRunning)
+					So(task.Status[3].Status, ShouldEqual, eremetic.TaskFailed)
 				})
 			})
 
@@ -386,9 +513,9 @@ func TestScheduler(t *testing.T) {
 				So(err, ShouldBeNil)
 
 				Convey("The task should have a queued status", func() {
-					So(len(task.Status), ShouldEqual, 2)
-					So(task.Status[0].Status, ShouldEqual, eremetic.TaskFailed)
-					So(task.Status[1].Status, ShouldEqual, eremetic.TaskQueued)
+					So(len(task.Status), ShouldEqual, 4)
+					So(task.Status[2].Status, ShouldEqual, eremetic.TaskFailed)
+					So(task.Status[3].Status, ShouldEqual, eremetic.TaskQueued)
 				})
 
 				Convey("The task should be published on channel", func() {
@@ -404,10 +531,9 @@ func TestScheduler(t *testing.T) {
 
 				id := "eremetic-task.1000"
 
-				db.PutTask(&eremetic.Task{
-					ID:          id,
-					CallbackURI: ts.URL,
-				})
+				task := stagedTask(id)
+				task.CallbackURI = ts.URL
+				db.PutTask(task)
 
 				s.StatusUpdate(nil, &mesosproto.TaskStatus{
 					TaskId: &mesosproto.TaskID{
@@ -430,10 +556,9 @@ func TestScheduler(t *testing.T) {
 
 				id := "eremetic-task.1001"
 
-				db.PutTask(&eremetic.Task{
-					ID:          id,
-					CallbackURI: ts.URL,
-				})
+				task := stagedTask(id)
+				task.CallbackURI = ts.URL
+				db.PutTask(task)
 
 				s.StatusUpdate(nil, &mesosproto.TaskStatus{
 					TaskId: &mesosproto.TaskID{
@@ -463,10 +588,9 @@ func TestScheduler(t *testing.T) {
 
 				id := "eremetic-task.1002"
 
-				db.PutTask(&eremetic.Task{
-					ID:          id,
-					CallbackURI: ts.URL,
-				})
+				task := stagedTask(id)
+				task.CallbackURI = ts.URL
+				db.PutTask(task)
 
 				s.StatusUpdate(nil, &mesosproto.TaskStatus{
 					TaskId: &mesosproto.TaskID{
@@ -475,6 +599,11 @@ func TestScheduler(t *testing.T) {
 					State: mesosproto.TaskState_TASK_FAILED.Enum(),
 				})
 
+				// The task is launched again.
+				db.UpdateTask(id, func(t *eremetic.Task) error {
+					return t.UpdateStatus(eremetic.Status{Status: eremetic.TaskStaging})
+				})
+
 				s.StatusUpdate(nil, &mesosproto.TaskStatus{
 					TaskId: &mesosproto.TaskID{
 						Value: proto.String(id),
@@ -499,6 +628,7 @@ func TestScheduler(t *testing.T) {
 
 			Convey("When the sandbox is updated", func() {
 				id := "eremetic-task.1003"
+				db.PutTask(stagedTask(id))
 
 				s.StatusUpdate(nil, &mesosproto.TaskStatus{
 					TaskId: &mesosproto.TaskID{
@@ -515,6 +645,104 @@ func TestScheduler(t *testing.T) {
 					So(task.SandboxPath, ShouldNotBeEmpty)
 				})
 			})
+
+			Convey("When an update can't follow the state of the task", func() {
+				for _, state := range []mesosproto.TaskState{
+					mesosproto.TaskState_TASK_FINISHED,
+					mesosproto.TaskState_TASK_FINISHED,
+					mesosproto.TaskState_TASK_RUNNING,
+				} {
+					s.StatusUpdate(nil, &mesosproto.TaskStatus{
+						TaskId: &mesosproto.TaskID{
+							Value: proto.String(id),
+						},
+						State: state.Enum(),
+					})
+				}
+
+				task, err := db.ReadTask(id)
+				So(err, ShouldBeNil)
+
+				Convey("The update should be ignored", func() {
+					So(len(task.Status), ShouldEqual, 3)
+					So(task.CurrentStatus(), ShouldEqual, eremetic.TaskFinished)
+				})
+			})
+
+			Convey("When an update can't be stored", func() {
+				defer func(delay time.Duration) { redeliveryDelay = delay }(redeliveryDelay)
+				redeliveryDelay = 0
+				failing := &failingTaskDB{TaskDB: db, fail: true}
+				s.database = failing
+
+				driver := mock.NewMesosScheduler()
+				reconciled := make(chan []*mesosproto.TaskStatus, 1)
+				driver.ReconcileTasksFn = func(ts []*mesosproto.TaskStatus) (mesosproto.Status, error) {
+					reconciled <- ts
+					return mesosproto.Status_DRIVER_RUNNING, nil
+				}
+
+				update := &mesosproto.TaskStatus{
+					TaskId:  &mesosproto.TaskID{Value: proto.String(id)},
+					SlaveId: &mesosproto.SlaveID{Value: proto.String("agent-1")},
+					State:   mesosproto.TaskState_TASK_RUNNING.Enum(),
+				}
+				s.StatusUpdate(driver, update)
+
+				Convey("The task should be left as it was", func() {
+					task, _ := db.ReadTask(id)
+					So(task.CurrentStatus(), ShouldEqual, eremetic.TaskStaging)
+				})
+
+				Convey("The master should be asked for the status again", func() {
+					ts := <-reconciled
+					So(ts, ShouldHaveLength, 1)
+					So(ts[0].TaskId.GetValue(), ShouldEqual, id)
+					So(ts[0].SlaveId.GetValue(), ShouldEqual, "agent-1")
+				})
+
+				Convey("The status sent again should be applied once", func() {
+					<-reconciled
+					failing.fail = false
+					s.StatusUpdate(driver, update)
+					s.StatusUpdate(driver, update)
+
+					task, _ := db.ReadTask(id)
+					So(len(task.Status), ShouldEqual, 3)
+					So(task.CurrentStatus(), ShouldEqual, eremetic.TaskRunning)
+					So(task.AgentID, ShouldEqual, "agent-1")
+				})
+
+				Convey("A restarted scheduler should get the status from the implicit reconciliation", func() {
+					// The scheduler crashed before storing the update, which
+					// the driver had already acknowledged.
+					<-reconciled
+					restarted := &Scheduler{
+						tasks:    make(chan string, 1),
+						database: db,
+					}
+
+					driver := mock.NewMesosScheduler()
+					var implicit bool
+					driver.ReconcileTasksFn = func(ts []*mesosproto.TaskStatus) (mesosproto.Status, error) {
+						implicit = len(ts) == 0
+						restarted.StatusUpdate(driver, &mesosproto.TaskStatus{
+							TaskId:  update.TaskId,
+							SlaveId: update.SlaveId,
+							State:   mesosproto.TaskState_TASK_RUNNING.Enum(),
+							Reason:  mesosproto.TaskStatus_REASON_RECONCILIATION.Enum(),
+						})
+						return mesosproto.Status_DRIVER_RUNNING, nil
+					}
+					restarted.Registered(driver, &mesosproto.FrameworkID{Value: proto.String("framework-1")}, &mesosproto.MasterInfo{})
+
+					So(implicit, ShouldBeTrue)
+					task, _ := db.ReadTask(id)
+					So(len(task.Status), ShouldEqual, 3)
+					So(task.CurrentStatus(), ShouldEqual, eremetic.TaskRunning)
+					So(task.AgentID, ShouldEqual, "agent-1")
+				})
+			})
 		})
 	})
 	Convey("FrameworkMessage", t, func() {
@@ -578,6 +806,10 @@ func TestScheduler(t *testing.T) {
 					So(c, ShouldEqual, taskID)
 				})
 
+				Convey("The task should be queued", func() {
+					So(scheduler.Queued(taskID), ShouldBeTrue)
+				})
+
 				Convey("The task should be present in the database", func() {
 					task, err := db.ReadTask(taskID)
 					So(err, ShouldBeNil)
@@ -608,6 +840,32 @@ func TestScheduler(t *testing.T) {
 					So(err, ShouldNotBeNil)
 				})
 			})
+
+			Convey("When enqueuing a task", func() {
+				err := scheduler.Enqueue("eremetic-task.1")
+				So(err, ShouldBeNil)
+
+				Convey("It should put the task id on the channel", func() {
+					So(<-scheduler.tasks, ShouldEqual, "eremetic-task.1")
+					So(scheduler.Queued("eremetic-task.1"), ShouldBeTrue)
+				})
+
+				Convey("It should not enqueue the task twice", func() {
+					So(scheduler.Enqueue("eremetic-task.1"), ShouldBeNil)
+					So(scheduler.tasks, ShouldHaveLength, 1)
+				})
+			})
+
+			Convey("When enqueuing a task and the queue is full", func() {
+				scheduler.tasks <- "dummy"
+
+				err := scheduler.Enqueue("eremetic-task.1")
+
+				Convey("It should return an error", func() {
+					So(err, ShouldEqual, eremetic.ErrQueueFull)
+					So(scheduler.Queued("eremetic-task.1"), ShouldBeFalse)
+				})
+			})
 		})
 	})
 	Convey("nextID", t, func() {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/mesos/mesos-go/api/v0/mesosproto"
 	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	"github.com/sirupsen/logrus"
 
 	"github.com/eremetic-framework/eremetic"
 )
@@ -17,6 +18,7 @@ func createTaskInfo(task eremetic.Task, offer *mesosproto.Offer) (eremetic.Task,
 	task.Hostname = *offer.Hostname
 	task.AgentIP = offer.GetUrl().GetAddress().GetIp()
 	task.AgentPort = offer.GetUrl().GetAddress().GetPort()
+	task.Maintenance = maintenanceWindow(offer.Unavailability)
 
 	network := buildNetwork(task)
 	dockerCliParameters := buildDockerCliParameters(task)
@@ -104,6 +106,15 @@ func buildEnvironment(task eremetic.Task, portMappings []*mesosproto.ContainerIn
 			Value: proto.String(fmt.Sprintf("%d", *m.HostPort)),
 		})
 	}
+	for i, p := range task.Ports {
+		if p.Name == "" || i >= len(portMappings) {
+			continue
+		}
+		environment = append(environment, &mesosproto.Environment_Variable{
+			Name:  proto.String(portVariable(p.Name)),
+			Value: proto.String(fmt.Sprintf("%d", *portMappings[i].HostPort)),
+		})
+	}
 	if len(portMappings) > 0 {
 		environment = append(environment, &mesosproto.Environment_Variable{
 			Name:  proto.String("PORT"),
@@ -151,6 +162,9 @@ func buildVolumes(task eremetic.Task) []*mesosproto.Volume {
 	return volumes
 }
 
+// buildPorts assigns the host ports of the port mappings of a task, and
+// returns the mappings and the port resources they use. The ports of the
+// offer were matched against the task before.
 func buildPorts(task eremetic.Task, network *mesosproto.ContainerInfo_DockerInfo_Network, offer *mesosproto.Offer) ([]*mesosproto.ContainerInfo_DockerInfo_PortMapping, []*mesosproto.Value_Range) {
 	var resources []*mesosproto.Value_Range
 	var mappings []*mesosproto.ContainerInfo_DockerInfo_PortMapping
@@ -159,50 +173,36 @@ func buildPorts(task eremetic.Task, network *mesosproto.ContainerInfo_DockerInfo
 		return mappings, resources
 	}
 
-	leftToAssign := len(task.Ports)
-
-	for _, rsrc := range offer.Resources {
-		if *rsrc.Name != "ports" {
-			continue
-		}
-
-		for _, rng := range rsrc.Ranges.Range {
-			if leftToAssign == 0 {
-				break
-			}
-
-			start, end := *rng.Begin, *rng.Begin
-
-			for hport := int(*rng.Begin); hport <= int(*rng.End); hport++ {
-				if leftToAssign == 0 {
-					break
-				}
-
-				leftToAssign--
-
-				tport := &task.Ports[leftToAssign]
-				tport.HostPort = uint32(hport)
-
-				if tport.ContainerPort == 0 {
-					tport.ContainerPort = tport.HostPort
-				}
+	hostPorts, err := assignPorts(task.Ports, offeredPorts(offer))
+	if err != nil {
+		logrus.WithError(err).WithField("task_id", task.ID).Error("Unable to assign ports")
+		return mappings, resources
+	}
 
-				end = uint64(hport + 1)
+	for i, hport := range hostPorts {
+		tport := &task.Ports[i]
 
-				mappings = append(mappings, &mesosproto.ContainerInfo_DockerInfo_PortMapping{
-					ContainerPort: proto.Uint32(tport.ContainerPort),
-					HostPort:      proto.Uint32(tport.HostPort),
-					Protocol:      proto.String(tport.Protocol),
-				})
+		if tport.HostPort == 0 || tport.Dynamic {
+			// The container port defaulted to the previous host port.
+			if tport.Dynamic && tport.ContainerPort == tport.HostPort {
+				tport.ContainerPort = 0
 			}
+			tport.Dynamic = true
+		}
+		tport.HostPort = hport
 
-			if start != end {
-				resources = append(resources, mesosutil.NewValueRange(start, end))
-			}
+		if tport.ContainerPort == 0 {
+			tport.ContainerPort = tport.HostPort
 		}
+
+		mappings = append(mappings, &mesosproto.ContainerInfo_DockerInfo_PortMapping{
+			ContainerPort: proto.Uint32(tport.ContainerPort),
+			HostPort:      proto.Uint32(tport.HostPort),
+			Protocol:      proto.String(tport.Protocol),
+		})
 	}
 
-	return mappings, resources
+	return mappings, portRanges(hostPorts)
 }
 
 func buildURIs(task eremetic.Task) []*mesosproto.CommandInfo_URI {
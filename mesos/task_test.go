@@ -30,6 +30,17 @@ func TestTask(t *testing.T) {
 			Name:     "Eremetic task 17",
 		}
 
+		fragmented := offer("offer-2", 1.0, 500.0,
+			&mesosproto.Unavailability{},
+			mesosutil.NewRangesResource(
+				"ports",
+				[]*mesosproto.Value_Range{
+					mesosutil.NewValueRange(31000, 31001),
+					mesosutil.NewValueRange(31005, 31010),
+				},
+			),
+		)
+
 		offer := offer("offer-1", 1.0, 500.0,
 			&mesosproto.Unavailability{},
 			mesosutil.NewRangesResource(
@@ -129,7 +140,7 @@ func TestTask(t *testing.T) {
 			So(taskInfo.Container.Docker.GetPortMappings()[0].GetContainerPort(), ShouldEqual, ports[0].ContainerPort)
 			So(taskInfo.GetResources()[2].GetName(), ShouldEqual, "ports")
 
-			expectedRange := mesosutil.NewValueRange(31000, 31001)
+			expectedRange := mesosutil.NewValueRange(31000, 31000)
 			So(taskInfo.GetResources()[2].GetRanges().GetRange()[0].GetBegin(), ShouldEqual, expectedRange.GetBegin())
 			So(taskInfo.GetResources()[2].GetRanges().GetRange()[0].GetEnd(), ShouldEqual, expectedRange.GetEnd())
 
@@ -168,7 +179,7 @@ func TestTask(t *testing.T) {
 			So(taskInfo.Container.Docker.GetPortMappings()[0].GetContainerPort(), ShouldEqual, 31000)
 			So(taskInfo.GetResources()[2].GetName(), ShouldEqual, "ports")
 
-			expectedRange := mesosutil.NewValueRange(31000, 31001)
+			expectedRange := mesosutil.NewValueRange(31000, 31000)
 			So(taskInfo.GetResources()[2].GetRanges().GetRange()[0].GetBegin(), ShouldEqual, expectedRange.GetBegin())
 			So(taskInfo.GetResources()[2].GetRanges().GetRange()[0].GetEnd(), ShouldEqual, expectedRange.GetEnd())
 
@@ -189,6 +200,55 @@ func TestTask(t *testing.T) {
 			So(foundPort0Var, ShouldBeTrue)
 		})
 
+		Convey("Given named and specific ports across fragmented ranges", func() {
+			eremeticTask.Ports = []eremetic.Port{
+				{Name: "http", ContainerPort: 80},
+				{Name: "admin", HostPort: 31000},
+				{ContainerPort: 9000},
+				{ContainerPort: 9001},
+			}
+
+			task, taskInfo := createTaskInfo(eremeticTask, fragmented)
+
+			mappings := taskInfo.Container.Docker.GetPortMappings()
+			So(mappings, ShouldHaveLength, 4)
+			So(mappings[0].GetHostPort(), ShouldEqual, 31001)
+			So(mappings[1].GetHostPort(), ShouldEqual, 31000)
+			So(mappings[1].GetContainerPort(), ShouldEqual, 31000)
+			So(mappings[2].GetHostPort(), ShouldEqual, 31005)
+			So(mappings[3].GetHostPort(), ShouldEqual, 31006)
+
+			ranges := taskInfo.GetResources()[2].GetRanges().GetRange()
+			So(ranges, ShouldHaveLength, 2)
+			So(ranges[0].GetBegin(), ShouldEqual, 31000)
+			So(ranges[0].GetEnd(), ShouldEqual, 31001)
+			So(ranges[1].GetBegin(), ShouldEqual, 31005)
+			So(ranges[1].GetEnd(), ShouldEqual, 31006)
+
+			env := make(map[string]string)
+			for _, v := range taskInfo.GetCommand().GetEnvironment().GetVariables() {
+				env[v.GetName()] = v.GetValue()
+			}
+			So(env["PORT_HTTP"], ShouldEqual, "31001")
+			So(env["PORT_ADMIN"], ShouldEqual, "31000")
+			So(env["PORT0"], ShouldEqual, "31001")
+			So(env["PORT3"], ShouldEqual, "31006")
+			So(env["PORT"], ShouldEqual, "31001")
+
+			Convey("Picked ports are picked again on relaunch", func() {
+				So(task.Ports[0].Dynamic, ShouldBeTrue)
+				So(task.Ports[1].Dynamic, ShouldBeFalse)
+
+				_, taskInfo := createTaskInfo(task, offer)
+
+				mappings := taskInfo.Container.Docker.GetPortMappings()
+				So(mappings[0].GetHostPort(), ShouldEqual, 31001)
+				So(mappings[0].GetContainerPort(), ShouldEqual, 80)
+				So(mappings[1].GetHostPort(), ShouldEqual, 31000)
+				So(mappings[2].GetHostPort(), ShouldEqual, 31002)
+			})
+		})
+
 		Convey("Given archive to fetch", func() {
 			URI := []eremetic.URI{{
 				URI:     "http://foobar.local/cats.zip",
@@ -0,0 +1,674 @@
+// Package mesostest simulates a Mesos master and its agents in-process, for
+// end-to-end tests and local development without a cluster.
+package mesostest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	mesossched "github.com/mesos/mesos-go/api/v0/scheduler"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// defaultRefuseSeconds is how long declined resources aren't offered again
+// when no filter is given, as with Mesos.
+const defaultRefuseSeconds = 5.0
+
+// capabilityPartitionAware is the framework capability the protobufs of the
+// v0 driver predate.
+const capabilityPartitionAware = mesosproto.FrameworkInfo_Capability_Type(5)
+
+// States the protobufs of the v0 driver predate.
+const (
+	taskDropped = mesosproto.TaskState(9)
+	taskUnknown = mesosproto.TaskState(13)
+)
+
+// Agent describes a simulated agent.
+type Agent struct {
+	ID       string
+	Hostname string
+	CPUs     float64
+	Mem      float64
+	// Ports are the ranges of ports of the agent, e.g. "31000-32000".
+	Ports      []string
+	Attributes map[string]string
+	// Maintenance, when set, is reported as the unavailability of the
+	// offers of the agent.
+	Maintenance *eremetic.Maintenance
+}
+
+type agent struct {
+	Agent
+	ranges [][2]uint64
+
+	// resources used by tasks.
+	cpus  float64
+	mem   float64
+	ports map[uint64]bool
+
+	// whether the resources of the agent are offered.
+	offered bool
+	// when the resources of the agent may be offered again, by framework.
+	refused map[string]time.Time
+}
+
+type offer struct {
+	id        string
+	agent     *agent
+	framework string
+	cpus      float64
+	mem       float64
+	ports     map[uint64]bool
+}
+
+type task struct {
+	info      *mesosproto.TaskInfo
+	agent     *agent
+	framework string
+	state     mesosproto.TaskState
+	message   string
+	sandbox   string
+	timer     *time.Timer
+
+	cpus  float64
+	mem   float64
+	ports []uint64
+}
+
+type sandbox struct {
+	files map[string]*bytes.Buffer
+}
+
+// Cluster is a simulated Mesos master and its agents. Frameworks connect to
+// it with the drivers it creates.
+type Cluster struct {
+	// Script returns the status updates of the launched tasks. It defaults
+	// to DefaultScript.
+	Script Script
+	// OfferInterval is how often free resources are offered.
+	OfferInterval time.Duration
+
+	mtx        sync.Mutex
+	agents     []*agent
+	offers     map[string]*offer
+	tasks      map[string]*task
+	frameworks map[string]*driver
+	sandboxes  map[string]*sandbox
+	lastID     int
+
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewCluster creates a cluster of the agents, and starts serving the files of
+// the sandboxes of its tasks on a local port.
+func NewCluster(agents ...Agent) (*Cluster, error) {
+	c := &Cluster{
+		Script:        DefaultScript,
+		OfferInterval: time.Second,
+		offers:        make(map[string]*offer),
+		tasks:         make(map[string]*task),
+		frameworks:    make(map[string]*driver),
+		sandboxes:     make(map[string]*sandbox),
+	}
+	for i, a := range agents {
+		if a.ID == "" {
+			a.ID = fmt.Sprintf("agent-%d", i+1)
+		}
+		if a.Hostname == "" {
+			a.Hostname = a.ID
+		}
+		ranges, err := parseRanges(a.Ports)
+		if err != nil {
+			return nil, err
+		}
+		c.agents = append(c.agents, &agent{
+			Agent:   a,
+			ranges:  ranges,
+			ports:   make(map[uint64]bool),
+			refused: make(map[string]time.Time),
+		})
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files/download", c.download)
+	c.listener = listener
+	c.server = &http.Server{Handler: mux}
+	go c.server.Serve(listener)
+
+	return c, nil
+}
+
+// Close stops the tasks and the server of the sandboxes.
+func (c *Cluster) Close() error {
+	c.mtx.Lock()
+	for _, t := range c.tasks {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+	}
+	c.mtx.Unlock()
+	return c.server.Close()
+}
+
+// NewDriver creates a driver connecting the scheduler to the cluster as the
+// framework. It has the signature of mesos.Settings.NewDriver.
+func (c *Cluster) NewDriver(scheduler mesossched.Scheduler, framework *mesosproto.FrameworkInfo) (mesossched.SchedulerDriver, error) {
+	return newDriver(c, scheduler, framework), nil
+}
+
+// TaskState returns the state of a task launched on the cluster.
+func (c *Cluster) TaskState(id string) (mesosproto.TaskState, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t, ok := c.tasks[id]
+	if !ok {
+		return 0, false
+	}
+	return t.state, true
+}
+
+func (c *Cluster) nextID(kind string) string {
+	c.lastID++
+	return fmt.Sprintf("mesostest-%s-%d", kind, c.lastID)
+}
+
+// register connects the driver as its framework, a new one unless the driver
+// has an id.
+func (c *Cluster) register(d *driver) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if d.framework.GetId().GetValue() == "" {
+		d.framework.Id = &mesosproto.FrameworkID{Value: proto.String(c.nextID("framework"))}
+	}
+	id := d.framework.GetId().GetValue()
+	c.frameworks[id] = d
+
+	master := &mesosproto.MasterInfo{
+		Id:       proto.String("mesostest-master"),
+		Ip:       proto.Uint32(0x7f000001),
+		Port:     proto.Uint32(5050),
+		Hostname: proto.String("localhost"),
+	}
+	d.enqueue(func() { d.scheduler.Registered(d, d.framework.Id, master) })
+}
+
+// unregister disconnects the driver. The tasks of the framework are killed
+// unless it fails over.
+func (c *Cluster) unregister(d *driver, failover bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	id := d.framework.GetId().GetValue()
+	if c.frameworks[id] != d {
+		return
+	}
+	delete(c.frameworks, id)
+	for oid, o := range c.offers {
+		if o.framework == id {
+			o.agent.offered = false
+			delete(c.offers, oid)
+		}
+	}
+	if failover {
+		return
+	}
+	for _, t := range c.tasks {
+		if t.framework == id && !isTerminal(t.state) {
+			c.finish(t, mesosproto.TaskState_TASK_KILLED, "Framework was removed")
+		}
+	}
+}
+
+// makeOffers offers the free resources of the agents to the framework of the
+// driver.
+func (c *Cluster) makeOffers(d *driver, now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	fw := d.framework.GetId().GetValue()
+	if c.frameworks[fw] != d {
+		return
+	}
+
+	var offers []*mesosproto.Offer
+	for _, a := range c.agents {
+		if a.offered || now.Before(a.refused[fw]) {
+			continue
+		}
+		cpus, mem := a.CPUs-a.cpus, a.Mem-a.mem
+		if cpus <= 0 || mem <= 0 {
+			continue
+		}
+		o := &offer{
+			id:        c.nextID("offer"),
+			agent:     a,
+			framework: fw,
+			cpus:      cpus,
+			mem:       mem,
+			ports:     make(map[uint64]bool),
+		}
+		resources := []*mesosproto.Resource{
+			mesosutil.NewScalarResource("cpus", cpus),
+			mesosutil.NewScalarResource("mem", mem),
+		}
+		if free := a.freePorts(); len(free) > 0 {
+			for _, r := range free {
+				for p := r.GetBegin(); p <= r.GetEnd(); p++ {
+					o.ports[p] = true
+				}
+			}
+			resources = append(resources, mesosutil.NewRangesResource("ports", free))
+		}
+		a.offered = true
+		c.offers[o.id] = o
+		offers = append(offers, c.offerInfo(o, resources))
+	}
+	if len(offers) > 0 {
+		d.enqueue(func() { d.scheduler.ResourceOffers(d, offers) })
+	}
+}
+
+func (c *Cluster) offerInfo(o *offer, resources []*mesosproto.Resource) *mesosproto.Offer {
+	a := o.agent
+	port := c.listener.Addr().(*net.TCPAddr).Port
+	info := &mesosproto.Offer{
+		Id:          &mesosproto.OfferID{Value: proto.String(o.id)},
+		FrameworkId: &mesosproto.FrameworkID{Value: proto.String(o.framework)},
+		SlaveId:     &mesosproto.SlaveID{Value: proto.String(a.ID)},
+		Hostname:    proto.String(a.Hostname),
+		Url: &mesosproto.URL{
+			Scheme: proto.String("http"),
+			Address: &mesosproto.Address{
+				Hostname: proto.String(a.Hostname),
+				Ip:       proto.String("127.0.0.1"),
+				Port:     proto.Int32(int32(port)),
+			},
+			Path: proto.String("/slave(1)"),
+		},
+		Resources: resources,
+	}
+	names := make([]string, 0, len(a.Attributes))
+	for name := range a.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info.Attributes = append(info.Attributes, &mesosproto.Attribute{
+			Name: proto.String(name),
+			Type: mesosproto.Value_TEXT.Enum(),
+			Text: &mesosproto.Value_Text{Value: proto.String(a.Attributes[name])},
+		})
+	}
+	if m := a.Maintenance; m != nil {
+		info.Unavailability = &mesosproto.Unavailability{
+			Start: &mesosproto.TimeInfo{Nanoseconds: proto.Int64(m.Start.UnixNano())},
+		}
+		if m.End != nil {
+			info.Unavailability.Duration = &mesosproto.DurationInfo{
+				Nanoseconds: proto.Int64(m.End.Sub(m.Start).Nanoseconds()),
+			}
+		}
+	}
+	return info
+}
+
+// decline makes the resources of an offer available again once the filter
+// expires.
+func (c *Cluster) decline(d *driver, id string, filters *mesosproto.Filters, now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	o, ok := c.offers[id]
+	if !ok || o.framework != d.framework.GetId().GetValue() {
+		return
+	}
+	c.release(o, filters, now)
+}
+
+func (c *Cluster) release(o *offer, filters *mesosproto.Filters, now time.Time) {
+	refuse := defaultRefuseSeconds
+	if filters != nil && filters.RefuseSeconds != nil {
+		refuse = filters.GetRefuseSeconds()
+	}
+	delete(c.offers, o.id)
+	o.agent.offered = false
+	o.agent.refused[o.framework] = now.Add(time.Duration(refuse * float64(time.Second)))
+}
+
+// revive clears the filters of the framework of the driver.
+func (c *Cluster) revive(d *driver) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, a := range c.agents {
+		delete(a.refused, d.framework.GetId().GetValue())
+	}
+}
+
+// launch starts the tasks with the resources of the offers, which must all be
+// outstanding offers of the agent to the framework.
+func (c *Cluster) launch(d *driver, offerIDs []*mesosproto.OfferID, tasks []*mesosproto.TaskInfo, filters *mesosproto.Filters, now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	fw := d.framework.GetId().GetValue()
+
+	var (
+		offers []*offer
+		valid  = len(offerIDs) > 0
+	)
+	for _, id := range offerIDs {
+		o, ok := c.offers[id.GetValue()]
+		if !ok || o.framework != fw || (len(offers) > 0 && o.agent != offers[0].agent) {
+			valid = false
+			continue
+		}
+		offers = append(offers, o)
+	}
+	for _, o := range offers {
+		c.release(o, filters, now)
+	}
+	if !valid {
+		for _, info := range tasks {
+			state := mesosproto.TaskState_TASK_LOST
+			if d.partitionAware() {
+				state = taskDropped
+			}
+			c.send(d, &task{info: info, framework: fw, state: state, message: "Task launched with invalid offers"},
+				mesosproto.TaskStatus_SOURCE_MASTER, mesosproto.TaskStatus_REASON_INVALID_OFFERS.Enum())
+		}
+		return
+	}
+
+	var (
+		a     = offers[0].agent
+		cpus  float64
+		mem   float64
+		ports = make(map[uint64]bool)
+	)
+	for _, o := range offers {
+		cpus += o.cpus
+		mem += o.mem
+		for p := range o.ports {
+			ports[p] = true
+		}
+	}
+	for _, info := range tasks {
+		// Like Mesos, the ID of an active task can't be reused.
+		if old, ok := c.tasks[info.TaskId.GetValue()]; ok && !isTerminal(old.state) {
+			c.send(d, &task{info: info, framework: fw, state: mesosproto.TaskState_TASK_ERROR, message: "Task has duplicate ID"},
+				mesosproto.TaskStatus_SOURCE_MASTER, mesosproto.TaskStatus_REASON_TASK_INVALID.Enum())
+			continue
+		}
+
+		t := &task{info: info, agent: a, framework: fw}
+		for _, res := range info.Resources {
+			switch res.GetName() {
+			case "cpus":
+				t.cpus += res.GetScalar().GetValue()
+			case "mem":
+				t.mem += res.GetScalar().GetValue()
+			case "ports":
+				for _, r := range res.GetRanges().GetRange() {
+					for p := r.GetBegin(); p <= r.GetEnd(); p++ {
+						t.ports = append(t.ports, p)
+					}
+				}
+			}
+		}
+		if !t.takeFrom(&cpus, &mem, ports) {
+			t.state = mesosproto.TaskState_TASK_ERROR
+			t.message = "Task uses more resources than offered"
+			c.send(d, t, mesosproto.TaskStatus_SOURCE_MASTER, mesosproto.TaskStatus_REASON_TASK_INVALID.Enum())
+			continue
+		}
+
+		a.cpus += t.cpus
+		a.mem += t.mem
+		for _, p := range t.ports {
+			a.ports[p] = true
+		}
+		t.state = mesosproto.TaskState_TASK_STAGING
+		t.sandbox = fmt.Sprintf("/var/lib/mesos/slaves/%s/frameworks/%s/executors/%s/runs/latest", a.ID, fw, info.TaskId.GetValue())
+		c.sandboxes[t.sandbox] = &sandbox{files: map[string]*bytes.Buffer{
+			"stdout": new(bytes.Buffer),
+			"stderr": new(bytes.Buffer),
+		}}
+		c.tasks[info.TaskId.GetValue()] = t
+		c.schedule(t, c.Script(info))
+	}
+}
+
+// takeFrom takes the resources of the task from those left, if there are
+// enough.
+func (t *task) takeFrom(cpus *float64, mem *float64, ports map[uint64]bool) bool {
+	if t.cpus > *cpus || t.mem > *mem {
+		return false
+	}
+	for _, p := range t.ports {
+		if !ports[p] {
+			return false
+		}
+	}
+	*cpus -= t.cpus
+	*mem -= t.mem
+	for _, p := range t.ports {
+		delete(ports, p)
+	}
+	return true
+}
+
+// schedule sends the updates of the script one after the other.
+func (c *Cluster) schedule(t *task, updates []Update) {
+	if len(updates) == 0 {
+		return
+	}
+	u := updates[0]
+	t.timer = time.AfterFunc(u.After, func() {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		if isTerminal(t.state) {
+			return
+		}
+		if sb, ok := c.sandboxes[t.sandbox]; ok {
+			sb.files["stdout"].WriteString(u.Stdout)
+			sb.files["stderr"].WriteString(u.Stderr)
+		}
+		if isTerminal(u.State) {
+			c.finish(t, u.State, u.Message)
+		} else {
+			t.state, t.message = u.State, u.Message
+		}
+		if d, ok := c.frameworks[t.framework]; ok {
+			c.send(d, t, mesosproto.TaskStatus_SOURCE_EXECUTOR, nil)
+		}
+		c.schedule(t, updates[1:])
+	})
+}
+
+// finish moves the task to a terminal state and frees its resources.
+func (c *Cluster) finish(t *task, state mesosproto.TaskState, message string) {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.state, t.message = state, message
+	t.agent.cpus -= t.cpus
+	t.agent.mem -= t.mem
+	for _, p := range t.ports {
+		delete(t.agent.ports, p)
+	}
+}
+
+// kill kills a task of the framework of the driver.
+func (c *Cluster) kill(d *driver, id string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	fw := d.framework.GetId().GetValue()
+	t, ok := c.tasks[id]
+	if !ok || t.framework != fw {
+		c.send(d, c.unknownTask(d, id), mesosproto.TaskStatus_SOURCE_MASTER, nil)
+		return
+	}
+	if !isTerminal(t.state) {
+		c.finish(t, mesosproto.TaskState_TASK_KILLED, "Command terminated with signal Terminated")
+	}
+	c.send(d, t, mesosproto.TaskStatus_SOURCE_EXECUTOR, nil)
+}
+
+// reconcile sends the latest state of the tasks, or of all the active tasks
+// of the framework of the driver when none is given.
+func (c *Cluster) reconcile(d *driver, statuses []*mesosproto.TaskStatus) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	fw := d.framework.GetId().GetValue()
+	reason := mesosproto.TaskStatus_REASON_RECONCILIATION.Enum()
+	if len(statuses) == 0 {
+		for _, t := range c.tasks {
+			if t.framework == fw && !isTerminal(t.state) {
+				c.send(d, t, mesosproto.TaskStatus_SOURCE_MASTER, reason)
+			}
+		}
+		return
+	}
+	for _, status := range statuses {
+		id := status.TaskId.GetValue()
+		t, ok := c.tasks[id]
+		if !ok || t.framework != fw {
+			t = c.unknownTask(d, id)
+		}
+		c.send(d, t, mesosproto.TaskStatus_SOURCE_MASTER, reason)
+	}
+}
+
+func (c *Cluster) unknownTask(d *driver, id string) *task {
+	state := mesosproto.TaskState_TASK_LOST
+	if d.partitionAware() {
+		state = taskUnknown
+	}
+	return &task{
+		info:      &mesosproto.TaskInfo{TaskId: &mesosproto.TaskID{Value: proto.String(id)}},
+		framework: d.framework.GetId().GetValue(),
+		state:     state,
+		message:   "Task is unknown to the master",
+	}
+}
+
+// send sends the status of the task to the framework of the driver.
+func (c *Cluster) send(d *driver, t *task, source mesosproto.TaskStatus_Source, reason *mesosproto.TaskStatus_Reason) {
+	status := &mesosproto.TaskStatus{
+		TaskId:    t.info.TaskId,
+		State:     t.state.Enum(),
+		Message:   proto.String(t.message),
+		Source:    source.Enum(),
+		Reason:    reason,
+		Timestamp: proto.Float64(float64(time.Now().UnixNano()) / float64(time.Second)),
+	}
+	if t.agent != nil {
+		status.SlaveId = &mesosproto.SlaveID{Value: proto.String(t.agent.ID)}
+	}
+	if t.sandbox != "" {
+		status.Data = sandboxData(t.sandbox)
+	}
+	d.enqueue(func() { d.scheduler.StatusUpdate(d, status) })
+}
+
+// sandboxData is the data of the status updates of Docker containers, which
+// mounts the sandbox.
+func sandboxData(sandbox string) []byte {
+	data, _ := json.Marshal([]map[string]interface{}{{
+		"Mounts": []map[string]interface{}{{
+			"Source":      sandbox,
+			"Destination": "/mnt/mesos/sandbox",
+			"Mode":        "",
+			"RW":          true,
+		}},
+	}})
+	return data
+}
+
+// download serves the files of the sandboxes as the agents do.
+func (c *Cluster) download(w http.ResponseWriter, r *http.Request) {
+	file := r.URL.Query().Get("path")
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	sb, ok := c.sandboxes[path.Dir(file)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	content, ok := sb.files[path.Base(file)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(content.Bytes())
+}
+
+// freePorts returns the ranges of ports no task uses.
+func (a *agent) freePorts() []*mesosproto.Value_Range {
+	var free []*mesosproto.Value_Range
+	for _, r := range a.ranges {
+		begin := r[0]
+		for p := r[0]; p <= r[1]; p++ {
+			if !a.ports[p] {
+				continue
+			}
+			if p > begin {
+				free = append(free, mesosutil.NewValueRange(begin, p-1))
+			}
+			begin = p + 1
+		}
+		if begin <= r[1] {
+			free = append(free, mesosutil.NewValueRange(begin, r[1]))
+		}
+	}
+	return free
+}
+
+func parseRanges(ports []string) ([][2]uint64, error) {
+	var ranges [][2]uint64
+	for _, r := range ports {
+		bounds := strings.SplitN(r, "-", 2)
+		begin, err := strconv.ParseUint(bounds[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port range %q", r)
+		}
+		end := begin
+		if len(bounds) == 2 {
+			if end, err = strconv.ParseUint(bounds[1], 10, 16); err != nil || end < begin {
+				return nil, fmt.Errorf("invalid port range %q", r)
+			}
+		}
+		ranges = append(ranges, [2]uint64{begin, end})
+	}
+	return ranges, nil
+}
+
+func isTerminal(state mesosproto.TaskState) bool {
+	switch state {
+	case mesosproto.TaskState_TASK_FINISHED,
+		mesosproto.TaskState_TASK_FAILED,
+		mesosproto.TaskState_TASK_KILLED,
+		mesosproto.TaskState_TASK_LOST,
+		mesosproto.TaskState_TASK_ERROR,
+		taskDropped,
+		taskUnknown:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package mesostest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	"github.com/mesos/mesos-go/api/v0/mesosutil"
+	"github.com/mesos/mesos-go/api/v0/scheduler"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/mock"
+)
+
+func taskInfo(id string, offer *mesosproto.Offer, cpus float64, mem float64, command string) *mesosproto.TaskInfo {
+	return &mesosproto.TaskInfo{
+		Name:    proto.String(id),
+		TaskId:  &mesosproto.TaskID{Value: proto.String(id)},
+		SlaveId: offer.SlaveId,
+		Command: &mesosproto.CommandInfo{Value: proto.String(command)},
+		Resources: []*mesosproto.Resource{
+			mesosutil.NewScalarResource("cpus", cpus),
+			mesosutil.NewScalarResource("mem", mem),
+		},
+	}
+}
+
+func nextOffers(offers chan []*mesosproto.Offer) []*mesosproto.Offer {
+	select {
+	case o := <-offers:
+		return o
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+func nextUpdate(updates chan *mesosproto.TaskStatus) *mesosproto.TaskStatus {
+	select {
+	case u := <-updates:
+		return u
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+func TestCluster(t *testing.T) {
+	Convey("Given a cluster of two agents", t, func() {
+		start := time.Now().Add(time.Hour)
+		cluster, err := NewCluster(
+			Agent{
+				CPUs:       4.0,
+				Mem:        4096.0,
+				Ports:      []string{"31000-31009"},
+				Attributes: map[string]string{"rack": "r1"},
+			},
+			Agent{
+				ID:          "small",
+				CPUs:        1.0,
+				Mem:         512.0,
+				Maintenance: &eremetic.Maintenance{Start: start},
+			},
+		)
+		So(err, ShouldBeNil)
+		defer cluster.Close()
+		cluster.OfferInterval = 10 * time.Millisecond
+		cluster.Script = func(task *mesosproto.TaskInfo) []Update {
+			return []Update{
+				{State: mesosproto.TaskState_TASK_RUNNING, Stdout: "hello\n"},
+				{State: mesosproto.TaskState_TASK_FINISHED, After: 50 * time.Millisecond},
+			}
+		}
+
+		registered := make(chan string, 1)
+		offers := make(chan []*mesosproto.Offer, 10)
+		updates := make(chan *mesosproto.TaskStatus, 10)
+		sched := mock.NewMesosScheduler()
+		sched.RegisteredFn = func(_ scheduler.SchedulerDriver, id *mesosproto.FrameworkID, _ *mesosproto.MasterInfo) {
+			registered <- id.GetValue()
+		}
+		sched.ResourceOffersFn = func(_ scheduler.SchedulerDriver, o []*mesosproto.Offer) {
+			offers <- o
+		}
+		sched.StatusUpdateFn = func(_ scheduler.SchedulerDriver, status *mesosproto.TaskStatus) {
+			updates <- status
+		}
+
+		driver, err := cluster.NewDriver(sched, &mesosproto.FrameworkInfo{
+			Name: proto.String("test"),
+			Capabilities: []*mesosproto.FrameworkInfo_Capability{
+				{Type: capabilityPartitionAware.Enum()},
+			},
+		})
+		So(err, ShouldBeNil)
+		status, err := driver.Start()
+		So(err, ShouldBeNil)
+		So(status, ShouldEqual, mesosproto.Status_DRIVER_RUNNING)
+		defer driver.Stop(false)
+
+		So(<-registered, ShouldEqual, "mesostest-framework-1")
+		received := nextOffers(offers)
+		So(received, ShouldHaveLength, 2)
+		large, small := received[0], received[1]
+
+		Convey("The agents offer their resources", func() {
+			So(large.SlaveId.GetValue(), ShouldEqual, "agent-1")
+			So(large.GetHostname(), ShouldEqual, "agent-1")
+			So(large.Resources, ShouldHaveLength, 3)
+			So(large.Resources[0].GetScalar().GetValue(), ShouldEqual, 4.0)
+			So(large.Resources[2].GetRanges().GetRange()[0].GetEnd(), ShouldEqual, 31009)
+			So(large.Attributes[0].GetText().GetValue(), ShouldEqual, "r1")
+			So(large.Unavailability, ShouldBeNil)
+
+			So(small.SlaveId.GetValue(), ShouldEqual, "small")
+			So(small.Unavailability.Start.GetNanoseconds(), ShouldEqual, start.UnixNano())
+		})
+
+		Convey("Declined resources are offered again once revived", func() {
+			driver.DeclineOffer(large.Id, &mesosproto.Filters{RefuseSeconds: proto.Float64(60)})
+			driver.DeclineOffer(small.Id, &mesosproto.Filters{RefuseSeconds: proto.Float64(60)})
+			So(nextOffers(offers), ShouldBeNil)
+
+			driver.ReviveOffers()
+			So(nextOffers(offers), ShouldHaveLength, 2)
+		})
+
+		Convey("Launched tasks get the updates of the script", func() {
+			_, err := driver.LaunchTasks([]*mesosproto.OfferID{large.Id}, []*mesosproto.TaskInfo{
+				taskInfo("task-1", large, 1.0, 1024.0, "echo hello"),
+			}, &mesosproto.Filters{})
+			So(err, ShouldBeNil)
+
+			running := nextUpdate(updates)
+			So(running.GetState(), ShouldEqual, mesosproto.TaskState_TASK_RUNNING)
+			So(running.SlaveId.GetValue(), ShouldEqual, "agent-1")
+			So(nextUpdate(updates).GetState(), ShouldEqual, mesosproto.TaskState_TASK_FINISHED)
+
+			state, ok := cluster.TaskState("task-1")
+			So(ok, ShouldBeTrue)
+			So(state, ShouldEqual, mesosproto.TaskState_TASK_FINISHED)
+
+			Convey("The sandbox of the task is served", func() {
+				address := large.Url.Address
+				sandbox := "/var/lib/mesos/slaves/agent-1/frameworks/mesostest-framework-1/executors/task-1/runs/latest"
+				So(string(running.Data), ShouldContainSubstring, sandbox)
+
+				response, err := http.Get(fmt.Sprintf("http://%s:%d/files/download?path=%s/stdout", address.GetIp(), address.GetPort(), sandbox))
+				So(err, ShouldBeNil)
+				defer response.Body.Close()
+				body, _ := ioutil.ReadAll(response.Body)
+				So(string(body), ShouldEqual, "hello\n")
+			})
+		})
+
+		Convey("Tasks using more than offered are rejected", func() {
+			driver.LaunchTasks([]*mesosproto.OfferID{small.Id}, []*mesosproto.TaskInfo{
+				taskInfo("task-1", small, 2.0, 128.0, "true"),
+			}, &mesosproto.Filters{})
+
+			update := nextUpdate(updates)
+			So(update.GetState(), ShouldEqual, mesosproto.TaskState_TASK_ERROR)
+			So(update.GetReason(), ShouldEqual, mesosproto.TaskStatus_REASON_TASK_INVALID)
+		})
+
+		Convey("Tasks reusing the ID of an active task are rejected", func() {
+			cluster.Script = func(*mesosproto.TaskInfo) []Update {
+				return []Update{{State: mesosproto.TaskState_TASK_RUNNING}}
+			}
+			driver.LaunchTasks([]*mesosproto.OfferID{large.Id}, []*mesosproto.TaskInfo{
+				taskInfo("task-1", large, 1.0, 1024.0, "sleep 60"),
+			}, &mesosproto.Filters{})
+			So(nextUpdate(updates).GetState(), ShouldEqual, mesosproto.TaskState_TASK_RUNNING)
+
+			driver.LaunchTasks([]*mesosproto.OfferID{small.Id}, []*mesosproto.TaskInfo{
+				taskInfo("task-1", small, 0.5, 128.0, "sleep 60"),
+			}, &mesosproto.Filters{})
+
+			update := nextUpdate(updates)
+			So(update.GetState(), ShouldEqual, mesosproto.TaskState_TASK_ERROR)
+			So(update.GetReason(), ShouldEqual, mesosproto.TaskStatus_REASON_TASK_INVALID)
+			So(update.SlaveId, ShouldBeNil)
+
+			state, _ := cluster.TaskState("task-1")
+			So(state, ShouldEqual, mesosproto.TaskState_TASK_RUNNING)
+
+			Convey("And accepted once it is terminal", func() {
+				driver.KillTask(&mesosproto.TaskID{Value: proto.String("task-1")})
+				So(nextUpdate(updates).GetState(), ShouldEqual, mesosproto.TaskState_TASK_KILLED)
+
+				// The resources of the killed task and of the rejected launch
+				// are offered again.
+				driver.ReviveOffers()
+				var offered []*mesosproto.Offer
+				for len(offered) < 2 {
+					received := nextOffers(offers)
+					if received == nil {
+						break
+					}
+					offered = append(offered, received...)
+				}
+				So(offered, ShouldHaveLength, 2)
+				for _, o := range offered {
+					if o.SlaveId.GetValue() == "agent-1" {
+						So(o.Resources[0].GetScalar().GetValue(), ShouldEqual, 4.0)
+					} else {
+						So(o.Resources[0].GetScalar().GetValue(), ShouldEqual, 1.0)
+					}
+				}
+
+				driver.LaunchTasks([]*mesosproto.OfferID{offered[0].Id}, []*mesosproto.TaskInfo{
+					taskInfo("task-1", offered[0], 0.5, 128.0, "sleep 60"),
+				}, &mesosproto.Filters{})
+				So(nextUpdate(updates).GetState(), ShouldEqual, mesosproto.TaskState_TASK_RUNNING)
+			})
+		})
+
+		Convey("Tasks launched with an unknown offer are dropped", func() {
+			driver.LaunchTasks([]*mesosproto.OfferID{{Value: proto.String("unknown")}}, []*mesosproto.TaskInfo{
+				taskInfo("task-1", small, 0.5, 128.0, "true"),
+			}, &mesosproto.Filters{})
+
+			So(nextUpdate(updates).GetState(), ShouldEqual, taskDropped)
+		})
+
+		Convey("Tasks can be killed and reconciled", func() {
+			cluster.Script = func(*mesosproto.TaskInfo) []Update {
+				return []Update{{State: mesosproto.TaskState_TASK_RUNNING}}
+			}
+			driver.LaunchTasks([]*mesosproto.OfferID{large.Id}, []*mesosproto.TaskInfo{
+				taskInfo("task-1", large, 1.0, 1024.0, "sleep 60"),
+				taskInfo("task-2", large, 1.0, 1024.0, "sleep 60"),
+			}, &mesosproto.Filters{})
+			nextUpdate(updates)
+			nextUpdate(updates)
+
+			driver.KillTask(&mesosproto.TaskID{Value: proto.String("task-1")})
+			So(nextUpdate(updates).GetState(), ShouldEqual, mesosproto.TaskState_TASK_KILLED)
+
+			driver.ReconcileTasks(nil)
+			update := nextUpdate(updates)
+			So(update.TaskId.GetValue(), ShouldEqual, "task-2")
+			So(update.GetState(), ShouldEqual, mesosproto.TaskState_TASK_RUNNING)
+			So(update.GetReason(), ShouldEqual, mesosproto.TaskStatus_REASON_RECONCILIATION)
+
+			driver.ReconcileTasks([]*mesosproto.TaskStatus{
+				{TaskId: &mesosproto.TaskID{Value: proto.String("task-1")}},
+				{TaskId: &mesosproto.TaskID{Value: proto.String("task-3")}},
+			})
+			So(nextUpdate(updates).GetState(), ShouldEqual, mesosproto.TaskState_TASK_KILLED)
+			So(nextUpdate(updates).GetState(), ShouldEqual, taskUnknown)
+
+			Convey("And are killed when the framework is torn down", func() {
+				driver.Stop(false)
+
+				state, _ := cluster.TaskState("task-2")
+				So(state, ShouldEqual, mesosproto.TaskState_TASK_KILLED)
+			})
+		})
+	})
+
+	Convey("NewCluster", t, func() {
+		_, err := NewCluster(Agent{Ports: []string{"32000-31000"}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("DefaultScript", t, func() {
+		script := func(command string) []Update {
+			return DefaultScript(&mesosproto.TaskInfo{Command: &mesosproto.CommandInfo{Value: proto.String(command)}})
+		}
+
+		So(script("echo hello")[2].State, ShouldEqual, mesosproto.TaskState_TASK_FINISHED)
+		So(script("echo hello")[2].After, ShouldEqual, DefaultRunTime)
+		So(script("sleep 5; echo")[2].After, ShouldEqual, 5*time.Second)
+		So(script("false")[2].State, ShouldEqual, mesosproto.TaskState_TASK_FAILED)
+		So(script("exit 3")[2].Message, ShouldEqual, "Command exited with status 3")
+		So(script("exit 0")[2].State, ShouldEqual, mesosproto.TaskState_TASK_FINISHED)
+	})
+}
@@ -0,0 +1,222 @@
+package mesostest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+	mesossched "github.com/mesos/mesos-go/api/v0/scheduler"
+)
+
+var errNotRunning = errors.New("driver is not running")
+
+// driver connects a scheduler to the cluster. Like the driver of mesos-go,
+// it invokes the callbacks of the scheduler one at a time.
+type driver struct {
+	cluster   *Cluster
+	scheduler mesossched.Scheduler
+	framework *mesosproto.FrameworkInfo
+
+	mtx    sync.Mutex
+	status mesosproto.Status
+	events []func()
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+func newDriver(c *Cluster, scheduler mesossched.Scheduler, framework *mesosproto.FrameworkInfo) *driver {
+	return &driver{
+		cluster:   c,
+		scheduler: scheduler,
+		framework: proto.Clone(framework).(*mesosproto.FrameworkInfo),
+		status:    mesosproto.Status_DRIVER_NOT_STARTED,
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// enqueue queues a callback of the scheduler. It doesn't block, for the
+// callbacks to use the driver.
+func (d *driver) enqueue(event func()) {
+	d.mtx.Lock()
+	d.events = append(d.events, event)
+	d.mtx.Unlock()
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *driver) running() (mesosproto.Status, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if d.status != mesosproto.Status_DRIVER_RUNNING {
+		return d.status, errNotRunning
+	}
+	return d.status, nil
+}
+
+func (d *driver) partitionAware() bool {
+	for _, c := range d.framework.Capabilities {
+		if c.GetType() == capabilityPartitionAware {
+			return true
+		}
+	}
+	return false
+}
+
+// loop invokes the callbacks, and offers resources periodically, until the
+// driver stops.
+func (d *driver) loop() {
+	d.cluster.makeOffers(d, time.Now())
+	ticker := time.NewTicker(d.cluster.OfferInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.wake:
+		case now := <-ticker.C:
+			d.cluster.makeOffers(d, now)
+		}
+
+		d.mtx.Lock()
+		events := d.events
+		d.events = nil
+		d.mtx.Unlock()
+		for _, event := range events {
+			if _, err := d.running(); err != nil {
+				return
+			}
+			event()
+		}
+	}
+}
+
+// Start registers the framework and starts invoking the callbacks.
+func (d *driver) Start() (mesosproto.Status, error) {
+	d.mtx.Lock()
+	if d.status != mesosproto.Status_DRIVER_NOT_STARTED {
+		defer d.mtx.Unlock()
+		return d.status, errors.New("driver was already started")
+	}
+	d.status = mesosproto.Status_DRIVER_RUNNING
+	d.mtx.Unlock()
+
+	d.cluster.register(d)
+	go d.loop()
+	return mesosproto.Status_DRIVER_RUNNING, nil
+}
+
+// Stop disconnects the framework, and removes it unless it fails over.
+func (d *driver) Stop(failover bool) (mesosproto.Status, error) {
+	return d.stop(mesosproto.Status_DRIVER_STOPPED, failover)
+}
+
+// Abort disconnects the framework, which fails over.
+func (d *driver) Abort() (mesosproto.Status, error) {
+	return d.stop(mesosproto.Status_DRIVER_ABORTED, true)
+}
+
+func (d *driver) stop(status mesosproto.Status, failover bool) (mesosproto.Status, error) {
+	d.mtx.Lock()
+	if d.status != mesosproto.Status_DRIVER_RUNNING {
+		defer d.mtx.Unlock()
+		return d.status, errNotRunning
+	}
+	d.status = status
+	close(d.done)
+	d.mtx.Unlock()
+
+	d.cluster.unregister(d, failover)
+	return status, nil
+}
+
+// Join waits for the driver to stop.
+func (d *driver) Join() (mesosproto.Status, error) {
+	if status, err := d.running(); err != nil {
+		return status, err
+	}
+	<-d.done
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.status, nil
+}
+
+// Run starts the driver and waits for it to stop.
+func (d *driver) Run() (mesosproto.Status, error) {
+	if status, err := d.Start(); err != nil {
+		return status, err
+	}
+	return d.Join()
+}
+
+// RequestResources is ignored, as by the allocator of Mesos.
+func (d *driver) RequestResources([]*mesosproto.Request) (mesosproto.Status, error) {
+	return d.running()
+}
+
+// AcceptOffers launches the tasks of the LAUNCH operations. Other operations
+// aren't supported.
+func (d *driver) AcceptOffers(offerIDs []*mesosproto.OfferID, operations []*mesosproto.Offer_Operation, filters *mesosproto.Filters) (mesosproto.Status, error) {
+	var tasks []*mesosproto.TaskInfo
+	for _, op := range operations {
+		if op.GetType() == mesosproto.Offer_Operation_LAUNCH {
+			tasks = append(tasks, op.GetLaunch().GetTaskInfos()...)
+		}
+	}
+	return d.LaunchTasks(offerIDs, tasks, filters)
+}
+
+// LaunchTasks launches the tasks with the resources of the offers.
+func (d *driver) LaunchTasks(offerIDs []*mesosproto.OfferID, tasks []*mesosproto.TaskInfo, filters *mesosproto.Filters) (mesosproto.Status, error) {
+	if status, err := d.running(); err != nil {
+		return status, err
+	}
+	d.cluster.launch(d, offerIDs, tasks, filters, time.Now())
+	return mesosproto.Status_DRIVER_RUNNING, nil
+}
+
+// KillTask kills a task.
+func (d *driver) KillTask(taskID *mesosproto.TaskID) (mesosproto.Status, error) {
+	if status, err := d.running(); err != nil {
+		return status, err
+	}
+	d.cluster.kill(d, taskID.GetValue())
+	return mesosproto.Status_DRIVER_RUNNING, nil
+}
+
+// DeclineOffer declines an offer.
+func (d *driver) DeclineOffer(offerID *mesosproto.OfferID, filters *mesosproto.Filters) (mesosproto.Status, error) {
+	if status, err := d.running(); err != nil {
+		return status, err
+	}
+	d.cluster.decline(d, offerID.GetValue(), filters, time.Now())
+	return mesosproto.Status_DRIVER_RUNNING, nil
+}
+
+// ReviveOffers clears the filters of the framework.
+func (d *driver) ReviveOffers() (mesosproto.Status, error) {
+	if status, err := d.running(); err != nil {
+		return status, err
+	}
+	d.cluster.revive(d)
+	return mesosproto.Status_DRIVER_RUNNING, nil
+}
+
+// SendFrameworkMessage drops the message, as tasks have no executor to
+// receive it.
+func (d *driver) SendFrameworkMessage(*mesosproto.ExecutorID, *mesosproto.SlaveID, string) (mesosproto.Status, error) {
+	return d.running()
+}
+
+// ReconcileTasks sends the latest state of the tasks.
+func (d *driver) ReconcileTasks(statuses []*mesosproto.TaskStatus) (mesosproto.Status, error) {
+	if status, err := d.running(); err != nil {
+		return status, err
+	}
+	d.cluster.reconcile(d, statuses)
+	return mesosproto.Status_DRIVER_RUNNING, nil
+}
@@ -0,0 +1,129 @@
+package mesostest_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/api"
+	"github.com/eremetic-framework/eremetic/client"
+	"github.com/eremetic-framework/eremetic/config"
+	"github.com/eremetic-framework/eremetic/mesos"
+	"github.com/eremetic-framework/eremetic/mesostest"
+	"github.com/eremetic-framework/eremetic/server"
+)
+
+// eventually polls the condition until it holds or a few seconds passed.
+func eventually(condition func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestEndToEnd(t *testing.T) {
+	logrus.SetOutput(ioutil.Discard)
+
+	Convey("Given eremetic running against a simulated cluster", t, func() {
+		cluster, err := mesostest.NewCluster(mesostest.Agent{
+			CPUs:  2.0,
+			Mem:   2048.0,
+			Ports: []string{"31000-31099"},
+		})
+		So(err, ShouldBeNil)
+		defer cluster.Close()
+		cluster.OfferInterval = 10 * time.Millisecond
+
+		db := eremetic.NewDefaultTaskDB()
+		sched := mesos.NewScheduler(&mesos.Settings{
+			MaxQueueSize: 10,
+			Name:         "eremetic",
+			NewDriver:    cluster.NewDriver,
+		}, db)
+		go sched.Run()
+		defer sched.Stop()
+
+		srv := httptest.NewServer(server.NewRouter(sched, &config.Config{}, db, nil))
+		defer srv.Close()
+		c, err := client.New(srv.URL, http.DefaultClient)
+		So(err, ShouldBeNil)
+
+		callbacks := make(chan eremetic.CallbackData, 10)
+		callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var data eremetic.CallbackData
+			json.NewDecoder(r.Body).Decode(&data)
+			callbacks <- data
+		}))
+		defer callback.Close()
+
+		launch := func(command string) eremetic.Task {
+			err := c.AddTask(api.RequestV1{
+				TaskCPUs:    0.5,
+				TaskMem:     128.0,
+				DockerImage: "busybox",
+				Command:     command,
+				CallbackURI: callback.URL,
+			})
+			So(err, ShouldBeNil)
+			tasks, err := c.Tasks()
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 1)
+			return tasks[0]
+		}
+		state := func(id string) eremetic.TaskState {
+			task, err := c.Task(id)
+			if err != nil {
+				return ""
+			}
+			return task.CurrentStatus()
+		}
+
+		Convey("A task runs to completion", func() {
+			task := launch("sleep 0.2")
+
+			So(eventually(func() bool { return state(task.ID) == eremetic.TaskFinished }), ShouldBeTrue)
+
+			launched, _ := c.Task(task.ID)
+			So(launched.AgentID, ShouldEqual, "agent-1")
+			So(launched.SandboxPath, ShouldNotBeEmpty)
+
+			stdout, err := c.Sandbox(task.ID, "stdout")
+			So(err, ShouldBeNil)
+			So(string(stdout), ShouldContainSubstring, "+ sleep 0.2")
+
+			select {
+			case data := <-callbacks:
+				So(data.TaskID, ShouldEqual, task.ID)
+				So(data.Status, ShouldEqual, string(eremetic.TaskFinished))
+			case <-time.After(5 * time.Second):
+				So("no callback", ShouldBeEmpty)
+			}
+		})
+
+		Convey("A failing task is reported as failed", func() {
+			task := launch("false")
+
+			So(eventually(func() bool { return state(task.ID) == eremetic.TaskFailed }), ShouldBeTrue)
+		})
+
+		Convey("A running task can be killed", func() {
+			task := launch("sleep 60")
+			So(eventually(func() bool { return state(task.ID) == eremetic.TaskRunning }), ShouldBeTrue)
+
+			So(c.Kill(task.ID), ShouldBeNil)
+
+			So(eventually(func() bool { return state(task.ID) == eremetic.TaskKilled }), ShouldBeTrue)
+		})
+	})
+}
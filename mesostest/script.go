@@ -0,0 +1,68 @@
+package mesostest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mesos/mesos-go/api/v0/mesosproto"
+)
+
+// Update is a status update sent for a launched task.
+type Update struct {
+	State mesosproto.TaskState
+	// After is how long after the previous update this one is sent.
+	After   time.Duration
+	Message string
+	// Stdout and Stderr are appended to the files of the sandbox of the
+	// task when the update is sent.
+	Stdout string
+	Stderr string
+}
+
+// Script returns the status updates to send for a launched task.
+type Script func(task *mesosproto.TaskInfo) []Update
+
+// DefaultRunTime is how long tasks run for with the DefaultScript.
+var DefaultRunTime = 2 * time.Second
+
+// DefaultScript starts the task, and finishes it after DefaultRunTime. A
+// command starting with `sleep N` runs for N seconds instead, and the
+// commands `false` and `exit N`, with N other than 0, fail.
+func DefaultScript(task *mesosproto.TaskInfo) []Update {
+	command := strings.TrimSpace(task.GetCommand().GetValue())
+	runTime := DefaultRunTime
+	final := Update{State: mesosproto.TaskState_TASK_FINISHED, Message: "Command exited with status 0"}
+
+	fields := strings.Fields(command)
+	switch {
+	case len(fields) >= 2 && fields[0] == "sleep":
+		if seconds, err := strconv.ParseFloat(strings.TrimRight(fields[1], ";"), 64); err == nil {
+			runTime = time.Duration(seconds * float64(time.Second))
+		}
+	case command == "false":
+		final = failed(1)
+	case len(fields) == 2 && fields[0] == "exit" && fields[1] != "0":
+		code, err := strconv.Atoi(fields[1])
+		if err != nil {
+			code = 1
+		}
+		final = failed(code)
+	}
+	final.After = runTime
+	final.Stdout = "Command exited\n"
+
+	return []Update{
+		{State: mesosproto.TaskState_TASK_STARTING, After: 100 * time.Millisecond},
+		{State: mesosproto.TaskState_TASK_RUNNING, After: 100 * time.Millisecond, Stdout: "+ " + command + "\n"},
+		final,
+	}
+}
+
+func failed(code int) Update {
+	return Update{
+		State:   mesosproto.TaskState_TASK_FAILED,
+		Message: "Command exited with status " + strconv.Itoa(code),
+		Stderr:  "exit status " + strconv.Itoa(code) + "\n",
+	}
+}
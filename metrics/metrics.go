@@ -4,6 +4,8 @@ import (
 	"errors"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/eremetic-framework/eremetic"
 )
 
 var (
@@ -37,14 +39,111 @@ var (
 		Name:      "tasks_running",
 		Help:      "Number of tasks currently running",
 	})
+	// TasksDrained increments with each task killed and queued again ahead of
+	// the maintenance of its agent
+	TasksDrained = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: "scheduler",
+		Name:      "tasks_drained",
+		Help:      "Number of tasks launched again elsewhere ahead of the maintenance of their agent",
+	})
 	// QueueSize provides the number of tasks waiting to be launched
 	QueueSize = prometheus.NewGauge(prometheus.GaugeOpts{
 		Subsystem: "scheduler",
 		Name:      "queue_size",
 		Help:      "Number of tasks in the queue",
 	})
+	// OffersReceived increments with each offer received from the master
+	OffersReceived = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: "scheduler",
+		Name:      "offers_received",
+		Help:      "Number of offers received from the master",
+	})
+	// OffersDeclined increments with each declined offer
+	OffersDeclined = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: "scheduler",
+		Name:      "offers_declined",
+		Help:      "Number of offers declined",
+	})
+	// OffersUsed increments with each offer a task was launched with
+	OffersUsed = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: "scheduler",
+		Name:      "offers_used",
+		Help:      "Number of offers tasks were launched with",
+	})
+	// OffersSuppressed is 1 while offers are suppressed
+	OffersSuppressed = prometheus.NewGauge(prometheus.GaugeOpts{
+		Subsystem: "scheduler",
+		Name:      "offers_suppressed",
+		Help:      "Whether offers are suppressed while no task is queued",
+	})
+	// TasksRemoved increments with each terminated task removed by the
+	// retention janitor
+	TasksRemoved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "retention",
+		Name:      "tasks_removed",
+		Help:      "Number of terminated tasks removed by retention limit",
+	}, []string{"limit"})
+	// TasksArchived increments with each task written to the archive
+	TasksArchived = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: "retention",
+		Name:      "tasks_archived",
+		Help:      "Number of tasks archived before their removal",
+	})
+	// ReconciliationDuration observes how long reconciliations with the
+	// master take
+	ReconciliationDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Subsystem: "reconciliation",
+		Name:      "duration_seconds",
+		Help:      "Duration of the reconciliations of tasks with the master",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 11),
+	})
+	// Reconciliations increments with each finished reconciliation
+	Reconciliations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "reconciliation",
+		Name:      "runs",
+		Help:      "Number of reconciliations by outcome",
+	}, []string{"outcome"})
+	// ReconciliationDiscrepancies increments with each task state reported
+	// by the master that differs from the stored one
+	ReconciliationDiscrepancies = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: "reconciliation",
+		Name:      "discrepancies",
+		Help:      "Number of task states reported by the master that differed from the stored ones",
+	})
+	// ReconciliationLost increments with each task marked lost because the
+	// master never reported on it
+	ReconciliationLost = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: "reconciliation",
+		Name:      "tasks_lost",
+		Help:      "Number of tasks marked lost because the master never reported on them",
+	})
 )
 
+func init() {
+	eremetic.OnTransition(countTransition)
+}
+
+// countTransition keeps the task metrics up to date with the transitions of
+// tasks.
+func countTransition(task *eremetic.Task, t eremetic.Transition) {
+	if t.To == eremetic.TaskRunning {
+		TasksRunning.Inc()
+	}
+	if t.From == eremetic.TaskRunning {
+		TasksRunning.Dec()
+	}
+	if eremetic.IsTerminal(t.To) {
+		sequence := "final"
+		if task.CurrentStatus() != t.To {
+			sequence = "retry"
+		}
+		TasksTerminated.With(prometheus.Labels{
+			"status":   string(t.To),
+			"sequence": sequence,
+		}).Inc()
+	}
+}
+
 // RegisterMetrics registers mesos metrics to a prometheus Registerer.
 func RegisterMetrics(r prometheus.Registerer) error {
 	errs := []error{
@@ -53,7 +152,18 @@ func RegisterMetrics(r prometheus.Registerer) error {
 		r.Register(TasksTerminated),
 		r.Register(TasksDelayed),
 		r.Register(TasksRunning),
+		r.Register(TasksDrained),
 		r.Register(QueueSize),
+		r.Register(OffersReceived),
+		r.Register(OffersDeclined),
+		r.Register(OffersUsed),
+		r.Register(OffersSuppressed),
+		r.Register(TasksRemoved),
+		r.Register(TasksArchived),
+		r.Register(ReconciliationDuration),
+		r.Register(Reconciliations),
+		r.Register(ReconciliationDiscrepancies),
+		r.Register(ReconciliationLost),
 	}
 	if len(errs) > 0 {
 		return errors.New("unable to register metrics")
@@ -12,6 +12,15 @@ type Scheduler struct {
 	ScheduleTaskInvoked bool
 	KillFn              func(id string) error
 	KillInvoked         bool
+	TeardownFn          func() error
+	TeardownInvoked     bool
+	QueuedFn            func(id string) bool
+	EnqueueFn           func(id string) error
+	EnqueueInvoked      bool
+	ReconciliationFn    func() *eremetic.Reconciliation
+	DiagnosticsFn       func(id string) eremetic.TaskDiagnostics
+	AgentsFn            func() []eremetic.Agent
+	FitFn               func(req eremetic.Request) (eremetic.TaskFit, error)
 }
 
 // ScheduleTask invokes the ScheduleTaskFn function.
@@ -26,16 +35,81 @@ func (s *Scheduler) Kill(id string) error {
 	return s.KillFn(id)
 }
 
+// Teardown simulates the Teardown functionality
+func (s *Scheduler) Teardown() error {
+	s.TeardownInvoked = true
+	return s.TeardownFn()
+}
+
+// Queued simulates the Queued functionality
+func (s *Scheduler) Queued(id string) bool {
+	return s.QueuedFn(id)
+}
+
+// Enqueue simulates the Enqueue functionality
+func (s *Scheduler) Enqueue(id string) error {
+	s.EnqueueInvoked = true
+	return s.EnqueueFn(id)
+}
+
+// Reconciliation invokes the ReconciliationFn function.
+func (s *Scheduler) Reconciliation() *eremetic.Reconciliation {
+	return s.ReconciliationFn()
+}
+
+// Diagnostics invokes the DiagnosticsFn function.
+func (s *Scheduler) Diagnostics(id string) eremetic.TaskDiagnostics {
+	return s.DiagnosticsFn(id)
+}
+
+// Agents invokes the AgentsFn function.
+func (s *Scheduler) Agents() []eremetic.Agent {
+	return s.AgentsFn()
+}
+
+// Fit invokes the FitFn function.
+func (s *Scheduler) Fit(req eremetic.Request) (eremetic.TaskFit, error) {
+	return s.FitFn(req)
+}
+
+// Leadership mocks the leadership of Eremetic instances.
+type Leadership struct {
+	IsLeaderFn func() bool
+	LeaderFn   func() (string, error)
+}
+
+// IsLeader invokes the IsLeaderFn function.
+func (l *Leadership) IsLeader() bool {
+	return l.IsLeaderFn()
+}
+
+// Leader invokes the LeaderFn function.
+func (l *Leadership) Leader() (string, error) {
+	return l.LeaderFn()
+}
+
 // TaskDB mocks the eremetic task database.
 type TaskDB struct {
 	CleanFn                func() error
 	CloseFn                func()
 	PutTaskFn              func(*eremetic.Task) error
+	UpdateTaskFn           func(string, func(*eremetic.Task) error) error
 	ReadTaskFn             func(string) (eremetic.Task, error)
 	ReadUnmaskedTaskFn     func(string) (eremetic.Task, error)
 	DeleteTaskFn           func(string) error
 	ListNonTerminalTasksFn func() ([]*eremetic.Task, error)
 	ListTasksFn            func(*eremetic.TaskFilter) ([]*eremetic.Task, error)
+	PutQuotaFn             func(*eremetic.Quota) error
+	ReadQuotaFn            func(string) (eremetic.Quota, error)
+	DeleteQuotaFn          func(string) error
+	ListQuotasFn           func() ([]*eremetic.Quota, error)
+	PutTemplateFn          func(*eremetic.Template) error
+	UpdateTemplateFn       func(string, func(*eremetic.Template) error) error
+	ReadTemplateFn         func(string) (eremetic.Template, error)
+	DeleteTemplateFn       func(string) error
+	ListTemplatesFn        func() ([]*eremetic.Template, error)
+	PutFrameworkIDFn       func(string) error
+	ReadFrameworkIDFn      func() (string, error)
 }
 
 // Clean invokes the CleanFn function.
@@ -53,6 +127,11 @@ func (db *TaskDB) PutTask(task *eremetic.Task) error {
 	return db.PutTaskFn(task)
 }
 
+// UpdateTask invokes the UpdateTaskFn function.
+func (db *TaskDB) UpdateTask(id string, fn func(*eremetic.Task) error) error {
+	return db.UpdateTaskFn(id, fn)
+}
+
 // ReadTask invokes the ReadTaskFn function.
 func (db *TaskDB) ReadTask(id string) (eremetic.Task, error) {
 	return db.ReadTaskFn(id)
@@ -78,6 +157,61 @@ func (db *TaskDB) ListTasks(filter *eremetic.TaskFilter) ([]*eremetic.Task, erro
 	return db.ListTasksFn(filter)
 }
 
+// PutQuota invokes the PutQuotaFn function.
+func (db *TaskDB) PutQuota(quota *eremetic.Quota) error {
+	return db.PutQuotaFn(quota)
+}
+
+// ReadQuota invokes the ReadQuotaFn function.
+func (db *TaskDB) ReadQuota(name string) (eremetic.Quota, error) {
+	return db.ReadQuotaFn(name)
+}
+
+// DeleteQuota invokes the DeleteQuotaFn function.
+func (db *TaskDB) DeleteQuota(name string) error {
+	return db.DeleteQuotaFn(name)
+}
+
+// ListQuotas invokes the ListQuotasFn function.
+func (db *TaskDB) ListQuotas() ([]*eremetic.Quota, error) {
+	return db.ListQuotasFn()
+}
+
+// PutTemplate invokes the PutTemplateFn function.
+func (db *TaskDB) PutTemplate(template *eremetic.Template) error {
+	return db.PutTemplateFn(template)
+}
+
+// UpdateTemplate invokes the UpdateTemplateFn function.
+func (db *TaskDB) UpdateTemplate(name string, fn func(*eremetic.Template) error) error {
+	return db.UpdateTemplateFn(name, fn)
+}
+
+// ReadTemplate invokes the ReadTemplateFn function.
+func (db *TaskDB) ReadTemplate(name string) (eremetic.Template, error) {
+	return db.ReadTemplateFn(name)
+}
+
+// DeleteTemplate invokes the DeleteTemplateFn function.
+func (db *TaskDB) DeleteTemplate(name string) error {
+	return db.DeleteTemplateFn(name)
+}
+
+// ListTemplates invokes the ListTemplatesFn function.
+func (db *TaskDB) ListTemplates() ([]*eremetic.Template, error) {
+	return db.ListTemplatesFn()
+}
+
+// PutFrameworkID invokes the PutFrameworkIDFn function.
+func (db *TaskDB) PutFrameworkID(id string) error {
+	return db.PutFrameworkIDFn(id)
+}
+
+// ReadFrameworkID invokes the ReadFrameworkIDFn function.
+func (db *TaskDB) ReadFrameworkID() (string, error) {
+	return db.ReadFrameworkIDFn()
+}
+
 // ErrScheduler mocks the eremetic scheduler.
 type ErrScheduler struct {
 	NextError *error
@@ -98,6 +232,41 @@ func (s *ErrScheduler) Kill(_id string) error {
 	return nil
 }
 
+// Teardown does nothing.
+func (s *ErrScheduler) Teardown() error {
+	return nil
+}
+
+// Queued reports every task as queued.
+func (s *ErrScheduler) Queued(_id string) bool {
+	return true
+}
+
+// Enqueue does nothing.
+func (s *ErrScheduler) Enqueue(_id string) error {
+	return nil
+}
+
+// Reconciliation reports no reconciliation.
+func (s *ErrScheduler) Reconciliation() *eremetic.Reconciliation {
+	return nil
+}
+
+// Diagnostics reports no offers.
+func (s *ErrScheduler) Diagnostics(id string) eremetic.TaskDiagnostics {
+	return eremetic.TaskDiagnostics{TaskID: id}
+}
+
+// Agents reports no agents.
+func (s *ErrScheduler) Agents() []eremetic.Agent {
+	return nil
+}
+
+// Fit reports no agents.
+func (s *ErrScheduler) Fit(_ eremetic.Request) (eremetic.TaskFit, error) {
+	return eremetic.TaskFit{}, nil
+}
+
 // ErrorReader simulates a failure to read stream.
 type ErrorReader struct{}
 
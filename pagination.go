@@ -0,0 +1,191 @@
+package eremetic
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sort fields and orders of a TaskFilter.
+const (
+	SortCreated = "created"
+	SortUpdated = "updated"
+	OrderAsc    = "asc"
+	OrderDesc   = "desc"
+)
+
+// ErrInvalidCursor is returned when a cursor can't be decoded or doesn't
+// belong to the sort order of the filter.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Validate checks that the sorting and paging parameters of the filter are
+// valid.
+func (filter *TaskFilter) Validate() error {
+	switch filter.Sort {
+	case "", SortCreated, SortUpdated:
+	default:
+		return fmt.Errorf("invalid sort %q", filter.Sort)
+	}
+	switch filter.Order {
+	case "", OrderAsc, OrderDesc:
+	default:
+		return fmt.Errorf("invalid order %q", filter.Order)
+	}
+	if filter.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	if filter.Cursor != "" {
+		if _, err := filter.decodeCursor(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SortField returns the field tasks are sorted by.
+func (filter *TaskFilter) SortField() string {
+	if filter.Sort == "" {
+		return SortCreated
+	}
+	return filter.Sort
+}
+
+// SortKey returns the time a task is sorted by.
+func (filter *TaskFilter) SortKey(task *Task) int64 {
+	if filter.SortField() == SortUpdated {
+		return task.LastUpdated().Unix()
+	}
+	return task.CreatedAt().Unix()
+}
+
+// Less reports whether task a comes before task b in the order of the filter.
+func (filter *TaskFilter) Less(a, b *Task) bool {
+	return filter.less(position{filter.SortKey(a), a.ID}, position{filter.SortKey(b), b.ID})
+}
+
+type position struct {
+	key int64
+	id  string
+}
+
+func (filter *TaskFilter) less(a, b position) bool {
+	if filter.Order == OrderDesc {
+		a, b = b, a
+	}
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.id < b.id
+}
+
+// CursorAfter returns the cursor that continues a listing after the task.
+func (filter *TaskFilter) CursorAfter(task *Task) string {
+	raw := fmt.Sprintf("%s:%d:%s", filter.SortField(), filter.SortKey(task), task.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// CursorPosition returns the sort key and the id of the task the cursor of
+// the filter points to. Databases able to seek use it to skip the previous
+// pages.
+func (filter *TaskFilter) CursorPosition() (int64, string, error) {
+	pos, err := filter.decodeCursor()
+	return pos.key, pos.id, err
+}
+
+func (filter *TaskFilter) decodeCursor() (position, error) {
+	b, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+	if err != nil {
+		return position{}, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(b), ":", 3)
+	if len(parts) != 3 || parts[0] != filter.SortField() {
+		return position{}, ErrInvalidCursor
+	}
+	key, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return position{}, ErrInvalidCursor
+	}
+	return position{key, parts[2]}, nil
+}
+
+// TaskCollector gathers the tasks matching a filter while a database is
+// scanned. Only the page requested by the filter is kept in memory.
+type TaskCollector struct {
+	filter *TaskFilter
+	after  *position
+	tasks  taskHeap
+}
+
+// NewTaskCollector returns a collector for the filter.
+func NewTaskCollector(filter *TaskFilter) (*TaskCollector, error) {
+	c := &TaskCollector{
+		filter: filter,
+		tasks:  taskHeap{filter: filter},
+	}
+	if filter.Cursor != "" {
+		pos, err := filter.decodeCursor()
+		if err != nil {
+			return nil, err
+		}
+		c.after = &pos
+	}
+	return c, nil
+}
+
+// Add offers a task to the collector and reports whether it was kept.
+func (c *TaskCollector) Add(task *Task) bool {
+	if !c.filter.Match(task) {
+		return false
+	}
+	if c.after != nil && !c.filter.less(*c.after, position{c.filter.SortKey(task), task.ID}) {
+		return false
+	}
+	if c.filter.Limit <= 0 {
+		c.tasks.tasks = append(c.tasks.tasks, task)
+		return true
+	}
+
+	heap.Push(&c.tasks, task)
+	if c.tasks.Len() > c.filter.Limit {
+		return heap.Pop(&c.tasks).(*Task) != task
+	}
+	return true
+}
+
+// Full reports whether the collector holds a complete page, so that tasks
+// coming after the last one can be skipped.
+func (c *TaskCollector) Full() bool {
+	return c.filter.Limit > 0 && c.tasks.Len() >= c.filter.Limit
+}
+
+// Tasks returns the collected tasks in the order of the filter.
+func (c *TaskCollector) Tasks() []*Task {
+	res := make([]*Task, c.tasks.Len())
+	copy(res, c.tasks.tasks)
+	sort.Slice(res, func(i, j int) bool {
+		return c.filter.Less(res[i], res[j])
+	})
+	return res
+}
+
+// taskHeap is a max-heap in the order of the filter, so that the last task
+// of a page is dropped first.
+type taskHeap struct {
+	filter *TaskFilter
+	tasks  []*Task
+}
+
+func (h taskHeap) Len() int            { return len(h.tasks) }
+func (h taskHeap) Less(i, j int) bool  { return h.filter.Less(h.tasks[j], h.tasks[i]) }
+func (h taskHeap) Swap(i, j int)       { h.tasks[i], h.tasks[j] = h.tasks[j], h.tasks[i] }
+func (h *taskHeap) Push(x interface{}) { h.tasks = append(h.tasks, x.(*Task)) }
+
+func (h *taskHeap) Pop() interface{} {
+	t := h.tasks[len(h.tasks)-1]
+	h.tasks = h.tasks[:len(h.tasks)-1]
+	return t
+}
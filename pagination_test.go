@@ -0,0 +1,75 @@
+package eremetic
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPagination(t *testing.T) {
+	tasks := []*Task{}
+	for i := 0; i < 5; i++ {
+		tasks = append(tasks, &Task{
+			ID: fmt.Sprintf("eremetic-task.%d", i),
+			Status: []Status{
+				Status{int64(10 + i), TaskQueued},
+				Status{int64(30 - i), TaskRunning},
+			},
+		})
+	}
+	collect := func(filter *TaskFilter) []string {
+		c, err := NewTaskCollector(filter)
+		So(err, ShouldBeNil)
+		for _, t := range tasks {
+			c.Add(t)
+		}
+		ids := []string{}
+		for _, t := range c.Tasks() {
+			ids = append(ids, t.ID)
+		}
+		return ids
+	}
+
+	Convey("Validate", t, func() {
+		So((&TaskFilter{}).Validate(), ShouldBeNil)
+		So((&TaskFilter{Sort: "name"}).Validate(), ShouldNotBeNil)
+		So((&TaskFilter{Order: "random"}).Validate(), ShouldNotBeNil)
+		So((&TaskFilter{Limit: -1}).Validate(), ShouldNotBeNil)
+		So((&TaskFilter{Cursor: "garbage!"}).Validate(), ShouldEqual, ErrInvalidCursor)
+	})
+
+	Convey("TaskCollector", t, func() {
+		Convey("Sorts by creation time by default", func() {
+			So(collect(&TaskFilter{State: ActiveState}), ShouldResemble, []string{
+				"eremetic-task.0", "eremetic-task.1", "eremetic-task.2", "eremetic-task.3", "eremetic-task.4",
+			})
+		})
+
+		Convey("Sorts by update time in descending order", func() {
+			So(collect(&TaskFilter{State: ActiveState, Sort: SortUpdated, Order: OrderDesc}), ShouldResemble, []string{
+				"eremetic-task.0", "eremetic-task.1", "eremetic-task.2", "eremetic-task.3", "eremetic-task.4",
+			})
+			So(collect(&TaskFilter{State: ActiveState, Order: OrderDesc, Limit: 2}), ShouldResemble, []string{
+				"eremetic-task.4", "eremetic-task.3",
+			})
+		})
+
+		Convey("Pages through tasks with a cursor", func() {
+			filter := &TaskFilter{State: ActiveState, Limit: 2}
+			So(collect(filter), ShouldResemble, []string{"eremetic-task.0", "eremetic-task.1"})
+
+			filter.Cursor = filter.CursorAfter(tasks[1])
+			So(collect(filter), ShouldResemble, []string{"eremetic-task.2", "eremetic-task.3"})
+
+			filter.Cursor = filter.CursorAfter(tasks[3])
+			So(collect(filter), ShouldResemble, []string{"eremetic-task.4"})
+		})
+
+		Convey("Rejects cursors of another sort order", func() {
+			cursor := (&TaskFilter{Sort: SortUpdated}).CursorAfter(tasks[0])
+			_, err := NewTaskCollector(&TaskFilter{Cursor: cursor})
+			So(err, ShouldEqual, ErrInvalidCursor)
+		})
+	})
+}
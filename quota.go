@@ -0,0 +1,101 @@
+package eremetic
+
+import "fmt"
+
+// Quota limits the number of tasks and the amount of resources that can be
+// used by tasks belonging to an owner or carrying a set of labels.
+// A zero limit means that the limit is not enforced.
+type Quota struct {
+	Name       string
+	Owner      string
+	Labels     map[string]string
+	MaxRunning int
+	MaxQueued  int
+	MaxCPUs    float64
+	MaxMem     float64
+}
+
+// QuotaUsage holds the current consumption of a quota.
+type QuotaUsage struct {
+	Running int     `json:"running"`
+	Queued  int     `json:"queued"`
+	CPUs    float64 `json:"cpu"`
+	Mem     float64 `json:"mem"`
+}
+
+// QuotaExceededError is returned when a task would exceed one of its quotas.
+type QuotaExceededError struct {
+	Quota  string
+	Reason string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota %s exceeded: %s", e.Quota, e.Reason)
+}
+
+// Matches returns whether the quota applies to the given task. A quota with
+// neither owner nor labels applies to every task.
+func (q *Quota) Matches(task *Task) bool {
+	if q.Owner != "" && q.Owner != task.Owner {
+		return false
+	}
+	for k, v := range q.Labels {
+		if task.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Usage computes the current usage of the quota from a list of tasks.
+// Resources are accounted for both active and queued tasks.
+func (q *Quota) Usage(tasks []*Task) QuotaUsage {
+	var usage QuotaUsage
+	for _, t := range tasks {
+		if !q.Matches(t) {
+			continue
+		}
+		switch {
+		case t.IsActive():
+			usage.Running++
+		case t.IsEnqueued():
+			usage.Queued++
+		default:
+			continue
+		}
+		usage.CPUs += t.TaskCPUs
+		usage.Mem += t.TaskMem
+	}
+	return usage
+}
+
+// CheckEnqueue returns an error if adding the task to the queue would exceed
+// the quota.
+func (q *Quota) CheckEnqueue(usage QuotaUsage, task *Task) error {
+	if q.MaxQueued > 0 && usage.Queued+1 > q.MaxQueued {
+		return q.exceeded("max %d queued tasks", q.MaxQueued)
+	}
+	if q.MaxCPUs > 0 && usage.CPUs+task.TaskCPUs > q.MaxCPUs {
+		return q.exceeded("max %.2f cpus", q.MaxCPUs)
+	}
+	if q.MaxMem > 0 && usage.Mem+task.TaskMem > q.MaxMem {
+		return q.exceeded("max %.2f mem", q.MaxMem)
+	}
+	return nil
+}
+
+// CheckLaunch returns an error if launching another task would exceed the
+// quota.
+func (q *Quota) CheckLaunch(usage QuotaUsage) error {
+	if q.MaxRunning > 0 && usage.Running+1 > q.MaxRunning {
+		return q.exceeded("max %d running tasks", q.MaxRunning)
+	}
+	return nil
+}
+
+func (q *Quota) exceeded(format string, args ...interface{}) error {
+	return &QuotaExceededError{
+		Quota:  q.Name,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
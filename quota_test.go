@@ -0,0 +1,91 @@
+package eremetic
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQuota(t *testing.T) {
+	running := func(owner string, labels map[string]string) *Task {
+		return &Task{
+			Owner:    owner,
+			Labels:   labels,
+			TaskCPUs: 1,
+			TaskMem:  128,
+			Status:   []Status{Status{0, TaskQueued}, Status{1, TaskRunning}},
+		}
+	}
+	queued := func(owner string, labels map[string]string) *Task {
+		return &Task{
+			Owner:    owner,
+			Labels:   labels,
+			TaskCPUs: 0.5,
+			TaskMem:  64,
+			Status:   []Status{Status{0, TaskQueued}},
+		}
+	}
+
+	Convey("Matches", t, func() {
+		Convey("A quota without selectors matches every task", func() {
+			q := Quota{Name: "all"}
+			So(q.Matches(&Task{}), ShouldBeTrue)
+			So(q.Matches(&Task{Owner: "alice"}), ShouldBeTrue)
+		})
+
+		Convey("A quota with an owner only matches that owner", func() {
+			q := Quota{Name: "alice", Owner: "alice"}
+			So(q.Matches(&Task{Owner: "alice"}), ShouldBeTrue)
+			So(q.Matches(&Task{Owner: "bob"}), ShouldBeFalse)
+		})
+
+		Convey("A quota with labels requires all labels", func() {
+			q := Quota{Name: "batch", Labels: map[string]string{"team": "data", "tier": "batch"}}
+			So(q.Matches(&Task{Labels: map[string]string{"team": "data", "tier": "batch", "x": "y"}}), ShouldBeTrue)
+			So(q.Matches(&Task{Labels: map[string]string{"team": "data"}}), ShouldBeFalse)
+		})
+	})
+
+	Convey("Usage", t, func() {
+		q := Quota{Name: "alice", Owner: "alice"}
+		tasks := []*Task{
+			running("alice", nil),
+			queued("alice", nil),
+			queued("bob", nil),
+			&Task{Owner: "alice", TaskCPUs: 8, Status: []Status{Status{0, TaskFinished}}},
+		}
+
+		usage := q.Usage(tasks)
+		So(usage.Running, ShouldEqual, 1)
+		So(usage.Queued, ShouldEqual, 1)
+		So(usage.CPUs, ShouldEqual, 1.5)
+		So(usage.Mem, ShouldEqual, 192)
+	})
+
+	Convey("CheckEnqueue", t, func() {
+		Convey("Unlimited quotas never fail", func() {
+			q := Quota{Name: "all"}
+			So(q.CheckEnqueue(QuotaUsage{Queued: 100, CPUs: 100}, &Task{TaskCPUs: 1}), ShouldBeNil)
+		})
+
+		Convey("Fails when the queue limit is reached", func() {
+			q := Quota{Name: "all", MaxQueued: 1}
+			err := q.CheckEnqueue(QuotaUsage{Queued: 1}, &Task{})
+			So(err, ShouldHaveSameTypeAs, &QuotaExceededError{})
+			So(err.Error(), ShouldEqual, "quota all exceeded: max 1 queued tasks")
+		})
+
+		Convey("Fails when resources would be exceeded", func() {
+			q := Quota{Name: "all", MaxCPUs: 2, MaxMem: 256}
+			So(q.CheckEnqueue(QuotaUsage{CPUs: 1.5}, &Task{TaskCPUs: 1}), ShouldNotBeNil)
+			So(q.CheckEnqueue(QuotaUsage{Mem: 200}, &Task{TaskMem: 64}), ShouldNotBeNil)
+			So(q.CheckEnqueue(QuotaUsage{CPUs: 1, Mem: 128}, &Task{TaskCPUs: 1, TaskMem: 128}), ShouldBeNil)
+		})
+	})
+
+	Convey("CheckLaunch", t, func() {
+		q := Quota{Name: "all", MaxRunning: 2}
+		So(q.CheckLaunch(QuotaUsage{Running: 1}), ShouldBeNil)
+		So(q.CheckLaunch(QuotaUsage{Running: 2}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,36 @@
+package eremetic
+
+import "time"
+
+// Outcomes of a reconciliation.
+const (
+	ReconciliationRunning   = "running"
+	ReconciliationDone      = "done"
+	ReconciliationEscalated = "escalated"
+	ReconciliationCancelled = "cancelled"
+	ReconciliationFailed    = "failed"
+)
+
+// Reconciliation reports on a reconciliation of the tasks with the master.
+type Reconciliation struct {
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Outcome  string     `json:"outcome"`
+	// Requests is the number of explicit reconciliation requests sent.
+	Requests int `json:"requests"`
+	// Pending are the tasks the master hasn't reported on yet.
+	Pending       []string      `json:"pending"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+	// Lost are the tasks marked lost because the master never reported on
+	// them.
+	Lost []string `json:"lost"`
+}
+
+// Discrepancy is a task state reported by the master that differs from the
+// stored state of the task.
+type Discrepancy struct {
+	TaskID   string    `json:"task_id"`
+	Stored   TaskState `json:"stored"`
+	Reported TaskState `json:"reported"`
+	Time     time.Time `json:"time"`
+}
@@ -0,0 +1,261 @@
+// Package retention removes terminated tasks from the database once they
+// exceed the configured retention limits.
+package retention
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/config"
+	"github.com/eremetic-framework/eremetic/metrics"
+)
+
+const (
+	defaultInterval  = 10 * time.Minute
+	defaultBatchSize = 100
+)
+
+// Limits that made a task expire, as reported by metrics.
+const (
+	LimitAge   = "age"
+	LimitCount = "count"
+)
+
+// Expired is a terminated task that exceeds a retention limit.
+type Expired struct {
+	Task  *eremetic.Task
+	Limit string
+}
+
+// Janitor periodically removes expired tasks from the database.
+type Janitor struct {
+	db       eremetic.TaskDB
+	config   config.RetentionConfig
+	shutdown chan struct{}
+	now      func() time.Time
+
+	// ids of the archived tasks that couldn't be removed yet, so that they
+	// aren't archived again. Read from the archive on the first collection.
+	archived map[string]bool
+}
+
+// NewJanitor returns a janitor enforcing the retention configuration.
+func NewJanitor(db eremetic.TaskDB, conf *config.RetentionConfig) *Janitor {
+	j := &Janitor{
+		db:       db,
+		config:   *conf,
+		shutdown: make(chan struct{}),
+		now:      time.Now,
+	}
+	if j.config.Interval <= 0 {
+		j.config.Interval = defaultInterval
+	}
+	if j.config.BatchSize <= 0 {
+		j.config.BatchSize = defaultBatchSize
+	}
+	return j
+}
+
+// Run removes expired tasks on every interval until the janitor is stopped.
+func (j *Janitor) Run() {
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Collect(); err != nil {
+			logrus.WithError(err).Error("Unable to remove expired tasks")
+		}
+		select {
+		case <-j.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops the janitor.
+func (j *Janitor) Stop() {
+	close(j.shutdown)
+}
+
+// Collect removes the tasks that are expired now, in batches, and returns
+// the number of removed tasks.
+func (j *Janitor) Collect() (int, error) {
+	tasks, err := j.db.ListTasks(&eremetic.TaskFilter{
+		State: eremetic.TerminatedState,
+		Sort:  eremetic.SortUpdated,
+		Order: eremetic.OrderDesc,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	expired := ExpiredTasks(&j.config, tasks, j.now())
+	if j.config.Archive != "" && j.archived == nil && len(expired) > 0 {
+		if j.archived, err = archivedTasks(j.config.Archive, expired); err != nil {
+			return 0, err
+		}
+	}
+
+	removed := 0
+	for len(expired) > 0 {
+		n := j.config.BatchSize
+		if n > len(expired) {
+			n = len(expired)
+		}
+		if err := j.remove(expired[:n]); err != nil {
+			return removed, err
+		}
+		removed += n
+		expired = expired[n:]
+
+		select {
+		case <-j.shutdown:
+			return removed, nil
+		default:
+		}
+	}
+
+	if removed > 0 {
+		logrus.WithField("count", removed).Info("Removed expired tasks")
+	}
+	return removed, nil
+}
+
+func (j *Janitor) remove(batch []Expired) error {
+	if j.config.Archive != "" {
+		pending := []Expired{}
+		for _, e := range batch {
+			if !j.archived[e.Task.ID] {
+				pending = append(pending, e)
+			}
+		}
+		if len(pending) > 0 {
+			if err := archive(j.config.Archive, pending); err != nil {
+				return err
+			}
+		}
+		for _, e := range pending {
+			j.archived[e.Task.ID] = true
+		}
+		metrics.TasksArchived.Add(float64(len(pending)))
+	}
+	for _, e := range batch {
+		logrus.WithFields(logrus.Fields{
+			"task_id": e.Task.ID,
+			"limit":   e.Limit,
+		}).Debug("Removing expired task")
+		if err := j.db.DeleteTask(e.Task.ID); err != nil {
+			return err
+		}
+		delete(j.archived, e.Task.ID)
+		metrics.TasksRemoved.WithLabelValues(e.Limit).Inc()
+	}
+	return nil
+}
+
+// archive appends the tasks to a JSON lines file. The file is synced before
+// returning so that tasks are only removed once they are archived.
+func archive(path string, batch []Expired) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, e := range batch {
+		encoded, err := eremetic.Encode(e.Task)
+		if err != nil {
+			return err
+		}
+		w.Write(encoded)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// archivedTasks returns the ids of the expired tasks found in the archive,
+// which were archived by a previous collection but not removed.
+func archivedTasks(path string, expired []Expired) (map[string]bool, error) {
+	archived := make(map[string]bool)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return archived, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ids := make(map[string]bool, len(expired))
+	for _, e := range expired {
+		ids[e.Task.ID] = true
+	}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		var task struct{ ID string }
+		if json.Unmarshal(line, &task) == nil && ids[task.ID] {
+			archived[task.ID] = true
+		}
+		if err == io.EOF {
+			return archived, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ExpiredTasks returns the tasks exceeding the retention limits. The tasks
+// must be terminated and sorted by last update, newest first, so that the
+// newest tasks are kept when a count limit is exceeded.
+func ExpiredTasks(conf *config.RetentionConfig, tasks []*eremetic.Task, now time.Time) []Expired {
+	expired := []Expired{}
+	counts := make(map[int]int)
+
+	for _, task := range tasks {
+		if len(task.Status) == 0 {
+			continue
+		}
+		rule := matchingRule(conf, task)
+		maxAge, maxCount := conf.MaxAge, conf.MaxCount
+		if rule >= 0 {
+			maxAge, maxCount = conf.Rules[rule].MaxAge, conf.Rules[rule].MaxCount
+		}
+
+		counts[rule]++
+		switch {
+		case maxAge > 0 && now.Sub(task.LastUpdated()) > maxAge:
+			expired = append(expired, Expired{task, LimitAge})
+		case maxCount > 0 && counts[rule] > maxCount:
+			expired = append(expired, Expired{task, LimitCount})
+		}
+	}
+	return expired
+}
+
+// matchingRule returns the index of the first rule matching the labels of
+// the task, or -1.
+func matchingRule(conf *config.RetentionConfig, task *eremetic.Task) int {
+rules:
+	for i, rule := range conf.Rules {
+		for k, v := range rule.Labels {
+			if task.Labels[k] != v {
+				continue rules
+			}
+		}
+		return i
+	}
+	return -1
+}
@@ -0,0 +1,181 @@
+package retention
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/config"
+	"github.com/eremetic-framework/eremetic/mock"
+)
+
+func finished(id string, at time.Time, labels map[string]string) *eremetic.Task {
+	return &eremetic.Task{
+		ID:     id,
+		Labels: labels,
+		Status: []eremetic.Status{
+			{Time: at.Add(-time.Minute).Unix(), Status: eremetic.TaskQueued},
+			{Time: at.Unix(), Status: eremetic.TaskFinished},
+		},
+	}
+}
+
+func ids(expired []Expired) []string {
+	res := []string{}
+	for _, e := range expired {
+		res = append(res, e.Task.ID)
+	}
+	return res
+}
+
+// archivedIDs returns the ids of the tasks in the archive.
+func archivedIDs(path string) []string {
+	f, err := os.Open(path)
+	So(err, ShouldBeNil)
+	defer f.Close()
+	ids := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var task eremetic.Task
+		So(json.Unmarshal(scanner.Bytes(), &task), ShouldBeNil)
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+func TestExpiredTasks(t *testing.T) {
+	now := time.Now()
+	data := map[string]string{"team": "data"}
+	tasks := []*eremetic.Task{
+		finished("a", now.Add(-1*time.Hour), nil),
+		finished("b", now.Add(-2*time.Hour), data),
+		finished("c", now.Add(-3*time.Hour), nil),
+		finished("d", now.Add(-4*time.Hour), data),
+		finished("e", now.Add(-48*time.Hour), nil),
+	}
+
+	Convey("ExpiredTasks", t, func() {
+		Convey("Keeps everything without limits", func() {
+			So(ExpiredTasks(&config.RetentionConfig{}, tasks, now), ShouldBeEmpty)
+		})
+
+		Convey("Removes tasks older than the max age", func() {
+			expired := ExpiredTasks(&config.RetentionConfig{MaxAge: 24 * time.Hour}, tasks, now)
+			So(ids(expired), ShouldResemble, []string{"e"})
+			So(expired[0].Limit, ShouldEqual, LimitAge)
+		})
+
+		Convey("Keeps the newest tasks up to the max count", func() {
+			expired := ExpiredTasks(&config.RetentionConfig{MaxCount: 3}, tasks, now)
+			So(ids(expired), ShouldResemble, []string{"d", "e"})
+			So(expired[0].Limit, ShouldEqual, LimitCount)
+		})
+
+		Convey("Applies the limits of the first matching rule", func() {
+			conf := &config.RetentionConfig{
+				MaxAge: 150 * time.Minute,
+				Rules: []config.RetentionRule{
+					{Labels: data, MaxCount: 1},
+				},
+			}
+			So(ids(ExpiredTasks(conf, tasks, now)), ShouldResemble, []string{"c", "d", "e"})
+		})
+	})
+}
+
+func TestJanitor(t *testing.T) {
+	Convey("Janitor", t, func() {
+		now := time.Now()
+		deleted := []string{}
+		db := &mock.TaskDB{
+			ListTasksFn: func(filter *eremetic.TaskFilter) ([]*eremetic.Task, error) {
+				So(filter.State, ShouldEqual, eremetic.TerminatedState)
+				return []*eremetic.Task{
+					finished("a", now.Add(-1*time.Hour), nil),
+					finished("b", now.Add(-2*time.Hour), nil),
+					finished("c", now.Add(-3*time.Hour), nil),
+					finished("d", now.Add(-4*time.Hour), nil),
+				}, nil
+			},
+			DeleteTaskFn: func(id string) error {
+				deleted = append(deleted, id)
+				return nil
+			},
+		}
+
+		dir, _ := ioutil.TempDir("", "eremetic")
+		defer os.RemoveAll(dir)
+
+		Convey("Removes expired tasks in batches", func() {
+			j := NewJanitor(db, &config.RetentionConfig{MaxCount: 1, BatchSize: 2})
+			n, err := j.Collect()
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+			So(deleted, ShouldResemble, []string{"b", "c", "d"})
+		})
+
+		Convey("Archives tasks before removing them", func() {
+			path := filepath.Join(dir, "archive.jsonl")
+			j := NewJanitor(db, &config.RetentionConfig{MaxAge: 150 * time.Minute, Archive: path})
+			_, err := j.Collect()
+			So(err, ShouldBeNil)
+			So(deleted, ShouldResemble, []string{"c", "d"})
+
+			So(archivedIDs(path), ShouldResemble, []string{"c", "d"})
+		})
+
+		Convey("Archives tasks once when their removal fails", func() {
+			path := filepath.Join(dir, "archive.jsonl")
+			listTasks := db.ListTasksFn
+			db.ListTasksFn = func(filter *eremetic.TaskFilter) ([]*eremetic.Task, error) {
+				tasks, err := listTasks(filter)
+				stored := []*eremetic.Task{}
+			tasks:
+				for _, t := range tasks {
+					for _, id := range deleted {
+						if t.ID == id {
+							continue tasks
+						}
+					}
+					stored = append(stored, t)
+				}
+				return stored, err
+			}
+			deleteTask := db.DeleteTaskFn
+			db.DeleteTaskFn = func(id string) error {
+				if id == "d" {
+					return errors.New("database unavailable")
+				}
+				return deleteTask(id)
+			}
+			j := NewJanitor(db, &config.RetentionConfig{MaxAge: 150 * time.Minute, Archive: path})
+			_, err := j.Collect()
+			So(err, ShouldNotBeNil)
+			_, err = j.Collect()
+			So(err, ShouldNotBeNil)
+
+			// A restarted janitor finds the task in the archive.
+			db.DeleteTaskFn = deleteTask
+			j = NewJanitor(db, &config.RetentionConfig{MaxAge: 150 * time.Minute, Archive: path})
+			_, err = j.Collect()
+			So(err, ShouldBeNil)
+			So(deleted, ShouldResemble, []string{"c", "d"})
+			So(archivedIDs(path), ShouldResemble, []string{"c", "d"})
+		})
+
+		Convey("Keeps tasks it couldn't archive", func() {
+			j := NewJanitor(db, &config.RetentionConfig{MaxCount: 1, Archive: filepath.Join(dir, "missing", "archive.jsonl")})
+			_, err := j.Collect()
+			So(err, ShouldNotBeNil)
+			So(deleted, ShouldBeEmpty)
+		})
+	})
+}
@@ -10,4 +10,23 @@ var ErrQueueFull = errors.New("task queue is full")
 type Scheduler interface {
 	ScheduleTask(request Request) (string, error)
 	Kill(taskID string) error
+	// Teardown unregisters the framework, which kills all of its tasks,
+	// and registers it again as a new framework.
+	Teardown() error
+	// Queued returns whether a task is in the queue of the scheduler.
+	Queued(taskID string) bool
+	// Enqueue adds a queued task of the database to the queue of the
+	// scheduler, unless it is already in it.
+	Enqueue(taskID string) error
+	// Reconciliation reports on the last reconciliation of the tasks with
+	// the master, or nil if there was none yet.
+	Reconciliation() *Reconciliation
+	// Diagnostics explains why a task hasn't been launched yet from the
+	// last offers evaluated for it while it was queued.
+	Diagnostics(taskID string) TaskDiagnostics
+	// Agents returns the agents offers were recently received from.
+	Agents() []Agent
+	// Fit tells which agents could launch a task for the request with
+	// their last offer.
+	Fit(request Request) (TaskFit, error)
 }
@@ -2,11 +2,9 @@ package server
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"html/template"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"strings"
 
@@ -16,9 +14,11 @@ import (
 	"github.com/gorilla/schema"
 
 	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/admission"
 	"github.com/eremetic-framework/eremetic/api"
 	"github.com/eremetic-framework/eremetic/config"
 	"github.com/eremetic-framework/eremetic/server/assets"
+	"github.com/eremetic-framework/eremetic/validation"
 	"github.com/eremetic-framework/eremetic/version"
 )
 
@@ -27,10 +27,17 @@ type errorDocument struct {
 	Message string `json:"message"`
 }
 
+type validationDocument struct {
+	Error   string            `json:"error,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Errors  validation.Errors `json:"errors"`
+}
+
 // Handler holds the server context.
 type Handler struct {
-	scheduler eremetic.Scheduler
-	database  eremetic.TaskDB
+	scheduler  eremetic.Scheduler
+	database   eremetic.TaskDB
+	leadership eremetic.Leadership
 }
 
 // NewHandler returns a new instance of Handler.
@@ -44,48 +51,43 @@ func NewHandler(scheduler eremetic.Scheduler, database eremetic.TaskDB) Handler
 // AddTask handles adding a task to the queue
 func (h Handler) AddTask(conf *config.Config, apiVersion string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var request eremetic.Request
-
-		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
-		if err != nil {
-			handleError(err, w, "Unable to read payload.")
+		request, ok := h.readRequest(w, r, apiVersion)
+		if !ok {
 			return
 		}
-		format := ""
-		switch apiVersion {
-		case api.V0:
-			deprecated(w)
-			var req api.RequestV0
-			err = json.Unmarshal(body, &req)
-			request = api.RequestFromV0(req)
+		format := "/api/v1/task/%s"
+		if apiVersion == api.V0 {
 			format = "/task/%s"
-			if err != nil {
-				handleError(err, w, "Unable to parse body into a valid request.")
-				return
-			}
-		case api.V1:
-			var req api.RequestV1
-			err = json.Unmarshal(body, &req)
-			request = api.RequestFromV1(req)
-			format = "/api/v1/task/%s"
-			if err != nil {
-				handleError(err, w, "Unable to parse body into a valid request.")
-				return
-			}
-		default:
-			handleError(errors.New("Invalid API version"), w, "Invalid API version.")
+		}
+
+		// The owner can't be chosen by the client, or it could use the
+		// quotas of another owner.
+		request.Owner = principal(r)
+
+		if err := validation.ValidateRequest(request); err != nil {
+			writeValidationErrors(err, w)
 			return
 		}
 
 		taskID, err := h.scheduler.ScheduleTask(request)
 		location := fmt.Sprintf(format, taskID)
 
+		if errs, ok := err.(validation.Errors); ok {
+			writeValidationErrors(errs, w)
+			return
+		}
 		if err != nil {
 			logrus.WithError(err).Error("Unable to create task.")
 			httpStatus := 500
 			if err == eremetic.ErrQueueFull {
 				httpStatus = 503
 			}
+			if _, ok := err.(*eremetic.QuotaExceededError); ok {
+				httpStatus = http.StatusTooManyRequests
+			}
+			if _, ok := err.(*admission.DeniedError); ok {
+				httpStatus = http.StatusForbidden
+			}
 			errorMessage := errorDocument{
 				err.Error(),
 				"Unable to schedule task",
@@ -99,6 +101,21 @@ func (h Handler) AddTask(conf *config.Config, apiVersion string) http.HandlerFun
 	}
 }
 
+// ValidateTask checks a task request without scheduling it.
+func (h Handler) ValidateTask(apiVersion string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, ok := h.readRequest(w, r, apiVersion)
+		if !ok {
+			return
+		}
+		if err := validation.ValidateRequest(request); err != nil {
+			writeValidationErrors(err, w)
+			return
+		}
+		writeJSON(http.StatusOK, validationDocument{Errors: validation.Errors{}}, w)
+	}
+}
+
 // GetFromSandbox fetches a file from the sandbox of the agent that ran the task
 func (h Handler) GetFromSandbox(file string, apiVersion string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -150,12 +167,19 @@ func (h Handler) ListTasks(apiVersion string) http.HandlerFunc {
 			handleError(err, w, "Unable to parse query params")
 			return
 		}
+		if err := filter.Validate(); err != nil {
+			writeJSON(http.StatusBadRequest, errorDocument{err.Error(), "Invalid query params."}, w)
+			return
+		}
 		logrus.Debug("Fetching all tasks")
 		tasks, err := h.database.ListTasks(filter)
 		if err != nil {
 			handleError(err, w, "Unable to fetch running tasks from the database")
 			return
 		}
+		if filter.Limit > 0 && len(tasks) == filter.Limit {
+			w.Header().Set("X-Next-Cursor", filter.CursorAfter(tasks[len(tasks)-1]))
+		}
 		switch apiVersion {
 		case api.V0:
 			deprecated(w)
@@ -243,6 +267,18 @@ func (h Handler) KillTask(conf *config.Config, apiVersion string) http.HandlerFu
 	}
 }
 
+// GetTaskDiagnostics explains why a task hasn't been launched yet.
+func (h Handler) GetTaskDiagnostics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["taskId"]
+		if _, err := h.database.ReadTask(id); err != nil {
+			writeJSON(http.StatusNotFound, errorDocument{err.Error(), "Unable to find task."}, w)
+			return
+		}
+		writeJSON(http.StatusOK, h.scheduler.Diagnostics(id), w)
+	}
+}
+
 // DeleteTask takes care of API calls to remove a task
 func (h Handler) DeleteTask(conf *config.Config, apiVersion string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
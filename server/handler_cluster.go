@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/eremetic-framework/eremetic/api"
+	"github.com/eremetic-framework/eremetic/validation"
+)
+
+// ListAgents reports the agents offers were recently received from, with
+// the resources of their last offer.
+func (h Handler) ListAgents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(http.StatusOK, h.scheduler.Agents(), w)
+	}
+}
+
+// FitTask tells which agents could launch a task for the request with
+// their last offer, without scheduling it.
+func (h Handler) FitTask() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, ok := h.readRequest(w, r, api.V1)
+		if !ok {
+			return
+		}
+		if err := validation.ValidateRequest(request); err != nil {
+			writeValidationErrors(err, w)
+			return
+		}
+		fit, err := h.scheduler.Fit(request)
+		if err != nil {
+			handleError(err, w, "Unable to match the request against the agents.")
+			return
+		}
+		writeJSON(http.StatusOK, fit, w)
+	}
+}
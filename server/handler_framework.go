@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic/api"
+)
+
+// GetFramework returns the framework ID Eremetic is registered with.
+func (h Handler) GetFramework() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := h.database.ReadFrameworkID()
+		if err != nil {
+			handleError(err, w, "Unable to read the framework ID.")
+			return
+		}
+		writeJSON(http.StatusOK, api.FrameworkV1{ID: id}, w)
+	}
+}
+
+// TeardownFramework tears down the framework, killing all of its tasks, and
+// registers Eremetic again as a new framework.
+func (h Handler) TeardownFramework() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logrus.Warn("Tearing down the framework")
+		if err := h.scheduler.Teardown(); err != nil {
+			handleError(err, w, "Unable to tear down the framework.")
+			return
+		}
+		writeJSON(http.StatusAccepted, "", w)
+	}
+}
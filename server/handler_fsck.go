@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic/fsck"
+)
+
+// CheckDatabase reports the tasks of the database that can't make progress
+// on their own.
+func (h Handler) CheckDatabase() http.HandlerFunc {
+	return h.fsck(false)
+}
+
+// RepairDatabase reports the tasks of the database that can't make progress
+// on their own, and repairs them.
+func (h Handler) RepairDatabase() http.HandlerFunc {
+	return h.fsck(true)
+}
+
+func (h Handler) fsck(repair bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts := fsck.Options{Repair: repair, Queue: h.scheduler}
+		if s := r.URL.Query().Get("stuck_after"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				writeJSON(http.StatusBadRequest, errorDocument{err.Error(), "Invalid query params."}, w)
+				return
+			}
+			opts.StuckAfter = d
+		}
+
+		report, err := fsck.Check(h.database, opts)
+		if err != nil {
+			handleError(err, w, "Unable to check the database.")
+			return
+		}
+		if repair {
+			logrus.WithField("repaired", report.Repaired).Info("Repaired the database")
+		}
+		writeJSON(http.StatusOK, report, w)
+	}
+}
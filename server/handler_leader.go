@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic/api"
+)
+
+// leaderRoutes are the routes that need the scheduler, and so are only
+// served by the leader.
+var leaderRoutes = map[string]bool{
+	"AddTask":           true,
+	"Kill":              true,
+	"TeardownFramework": true,
+	"CheckDatabase":     true,
+	"RepairDatabase":    true,
+	"GetReconciliation": true,
+	"TaskDiagnostics":   true,
+	"ListAgents":        true,
+	"FitTask":           true,
+}
+
+// Leader reports the leader of the Eremetic instances.
+func (h Handler) Leader() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.leadership == nil {
+			writeJSON(http.StatusOK, api.LeaderV1{IsLeader: true}, w)
+			return
+		}
+
+		leader, err := h.leadership.Leader()
+		if err != nil {
+			handleError(err, w, "Unable to find the leader.")
+			return
+		}
+		writeJSON(http.StatusOK, api.LeaderV1{
+			Leader:   leader,
+			IsLeader: h.leadership.IsLeader(),
+			Election: true,
+		}, w)
+	}
+}
+
+// leaderOnly redirects requests to the leader while this instance doesn't
+// lead. The redirect keeps the method and body of the request.
+func (h Handler) leaderOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.leadership == nil || h.leadership.IsLeader() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		leader, err := h.leadership.Leader()
+		if err == nil && leader == "" {
+			err = errors.New("no leader")
+		}
+		if err != nil {
+			logrus.WithError(err).Warn("Unable to redirect request to the leader")
+			writeJSON(http.StatusServiceUnavailable, errorDocument{
+				err.Error(),
+				"No Eremetic instance is leading, try again later.",
+			}, w)
+			return
+		}
+
+		http.Redirect(w, r, strings.TrimRight(leader, "/")+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	})
+}
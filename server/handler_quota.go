@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/api"
+)
+
+// ListQuotas returns all configured quotas along with their current usage.
+func (h Handler) ListQuotas() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		quotas, err := h.database.ListQuotas()
+		if err != nil {
+			handleError(err, w, "Unable to list quotas.")
+			return
+		}
+		tasks, err := h.quotaTasks(quotas)
+		if err != nil {
+			handleError(err, w, "Unable to list tasks.")
+			return
+		}
+		res := []api.QuotaV1{}
+		for _, q := range quotas {
+			res = append(res, quotaWithUsage(q, tasks))
+		}
+		writeJSON(http.StatusOK, res, w)
+	}
+}
+
+// GetQuota returns a single quota along with its current usage.
+func (h Handler) GetQuota() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		quota, err := h.database.ReadQuota(name)
+		if err != nil {
+			writeJSON(http.StatusNotFound, errorDocument{err.Error(), "Unable to find quota."}, w)
+			return
+		}
+		tasks, err := h.quotaTasks([]*eremetic.Quota{&quota})
+		if err != nil {
+			handleError(err, w, "Unable to list tasks.")
+			return
+		}
+		writeJSON(http.StatusOK, quotaWithUsage(&quota, tasks), w)
+	}
+}
+
+// PutQuota creates or replaces a quota.
+func (h Handler) PutQuota() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+		if err != nil {
+			handleError(err, w, "Unable to read payload.")
+			return
+		}
+		var req api.QuotaV1
+		if err := json.Unmarshal(body, &req); err != nil {
+			handleError(err, w, "Unable to parse body into a valid quota.")
+			return
+		}
+		req.Name = mux.Vars(r)["name"]
+		quota := api.QuotaFromV1(&req)
+
+		logrus.WithField("quota", quota.Name).Debug("Updating quota")
+		if err := h.database.PutQuota(&quota); err != nil {
+			handleError(err, w, "Unable to store quota.")
+			return
+		}
+		writeJSON(http.StatusOK, api.QuotaV1FromQuota(&quota), w)
+	}
+}
+
+// DeleteQuota removes a quota.
+func (h Handler) DeleteQuota() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		if _, err := h.database.ReadQuota(name); err != nil {
+			writeJSON(http.StatusNotFound, errorDocument{err.Error(), "Unable to find quota."}, w)
+			return
+		}
+		logrus.WithField("quota", name).Debug("Deleting quota")
+		if err := h.database.DeleteQuota(name); err != nil {
+			handleError(err, w, "Unable to delete quota.")
+			return
+		}
+		writeJSON(http.StatusAccepted, "", w)
+	}
+}
+
+func (h Handler) quotaTasks(quotas []*eremetic.Quota) ([]*eremetic.Task, error) {
+	if len(quotas) == 0 {
+		return nil, nil
+	}
+	return h.database.ListTasks(&eremetic.TaskFilter{State: eremetic.DefaultTaskFilterState})
+}
+
+func quotaWithUsage(quota *eremetic.Quota, tasks []*eremetic.Task) api.QuotaV1 {
+	q := api.QuotaV1FromQuota(quota)
+	usage := quota.Usage(tasks)
+	q.Usage = &usage
+	return q
+}
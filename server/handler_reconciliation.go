@@ -0,0 +1,16 @@
+package server
+
+import "net/http"
+
+// GetReconciliation reports on the last reconciliation of the tasks with the
+// master.
+func (h Handler) GetReconciliation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := h.scheduler.Reconciliation()
+		if report == nil {
+			writeJSON(http.StatusNotFound, errorDocument{"no reconciliation", "No reconciliation has run yet."}, w)
+			return
+		}
+		writeJSON(http.StatusOK, report, w)
+	}
+}
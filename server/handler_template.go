@@ -0,0 +1,194 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/api"
+	"github.com/eremetic-framework/eremetic/config"
+)
+
+// errTemplateExists is returned by the update creating a template when
+// there already is one with the name.
+var errTemplateExists = errors.New("template exists")
+
+// templateV1 converts a template into its V1 representation, with its
+// masked environment masked.
+func templateV1(template eremetic.Template) api.TemplateV1 {
+	eremetic.ApplyTemplateMask(&template)
+	return api.TemplateV1FromTemplate(&template)
+}
+
+// ListTemplates returns all task templates.
+func (h Handler) ListTemplates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templates, err := h.database.ListTemplates()
+		if err != nil {
+			handleError(err, w, "Unable to list templates.")
+			return
+		}
+		res := []api.TemplateV1{}
+		for _, t := range templates {
+			res = append(res, templateV1(*t))
+		}
+		writeJSON(http.StatusOK, res, w)
+	}
+}
+
+// GetTemplate returns a single task template.
+func (h Handler) GetTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		template, err := h.database.ReadTemplate(name)
+		if err != nil {
+			writeJSON(http.StatusNotFound, errorDocument{err.Error(), "Unable to find template."}, w)
+			return
+		}
+		writeJSON(http.StatusOK, templateV1(template), w)
+	}
+}
+
+// GetTemplateVersion returns a version of a task template, which may be a
+// prior one.
+func (h Handler) GetTemplateVersion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		template, err := h.database.ReadTemplate(vars["name"])
+		if err != nil {
+			writeJSON(http.StatusNotFound, errorDocument{err.Error(), "Unable to find template."}, w)
+			return
+		}
+		version, err := strconv.Atoi(vars["version"])
+		if err != nil {
+			handleError(err, w, "Unable to parse the template version.")
+			return
+		}
+		request, ok := template.At(version)
+		if !ok {
+			msg := fmt.Sprintf("Template %s has no version %d.", template.Name, version)
+			writeJSON(http.StatusNotFound, errorDocument{"unknown template version", msg}, w)
+			return
+		}
+		writeJSON(http.StatusOK, templateV1(eremetic.Template{
+			Name:    template.Name,
+			Version: version,
+			Request: request,
+		}), w)
+	}
+}
+
+// CreateTemplate stores a new task template.
+func (h Handler) CreateTemplate(conf *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := readTemplate(w, r)
+		if !ok {
+			return
+		}
+
+		var template eremetic.Template
+		logrus.WithField("template", req.Name).Debug("Creating template")
+		err := h.database.UpdateTemplate(req.Name, func(t *eremetic.Template) error {
+			if t.Version > 0 {
+				return errTemplateExists
+			}
+			t.Revise(req.Request)
+			template = *t
+			return nil
+		})
+		if err == errTemplateExists {
+			msg := fmt.Sprintf("Template %s already exists.", req.Name)
+			writeJSON(http.StatusConflict, errorDocument{err.Error(), msg}, w)
+			return
+		}
+		if err != nil {
+			handleError(err, w, "Unable to store template.")
+			return
+		}
+
+		location := fmt.Sprintf("/api/v1/template/%s", template.Name)
+		w.Header().Set("Location", absURL(r, location, conf))
+		writeJSON(http.StatusCreated, templateV1(template), w)
+	}
+}
+
+// UpdateTemplate creates or replaces a task template, increasing its version
+// and keeping the prior one.
+func (h Handler) UpdateTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := readTemplate(w, r)
+		if !ok {
+			return
+		}
+
+		var template eremetic.Template
+		err := h.database.UpdateTemplate(req.Name, func(t *eremetic.Template) error {
+			t.Revise(req.Request)
+			template = *t
+			return nil
+		})
+		if err != nil {
+			handleError(err, w, "Unable to store template.")
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"template": template.Name,
+			"version":  template.Version,
+		}).Debug("Updated template")
+		writeJSON(http.StatusOK, templateV1(template), w)
+	}
+}
+
+// DeleteTemplate removes a task template. Tasks created from it are kept.
+func (h Handler) DeleteTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		if _, err := h.database.ReadTemplate(name); err != nil {
+			writeJSON(http.StatusNotFound, errorDocument{err.Error(), "Unable to find template."}, w)
+			return
+		}
+		logrus.WithField("template", name).Debug("Deleting template")
+		if err := h.database.DeleteTemplate(name); err != nil {
+			handleError(err, w, "Unable to delete template.")
+			return
+		}
+		writeJSON(http.StatusAccepted, "", w)
+	}
+}
+
+// readTemplate parses a template from the request body. The name in the
+// URL, if any, takes precedence over the one in the body.
+func readTemplate(w http.ResponseWriter, r *http.Request) (eremetic.Template, bool) {
+	var template eremetic.Template
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		handleError(err, w, "Unable to read payload.")
+		return template, false
+	}
+
+	var req api.TemplateV1
+	if err := json.Unmarshal(body, &req); err != nil {
+		handleError(err, w, "Unable to parse body into a valid template.")
+		return template, false
+	}
+	if name, ok := mux.Vars(r)["name"]; ok {
+		req.Name = name
+	}
+	if req.Name == "" || strings.ContainsAny(req.Name, "/ ") {
+		handleError(errors.New("invalid template name"), w, "Template names must be non-empty and contain no slashes or spaces.")
+		return template, false
+	}
+
+	return api.TemplateFromV1(&req), true
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -19,6 +20,7 @@ import (
 	"github.com/eremetic-framework/eremetic/api"
 	"github.com/eremetic-framework/eremetic/config"
 	"github.com/eremetic-framework/eremetic/server/assets"
+	"github.com/eremetic-framework/eremetic/validation"
 	"github.com/eremetic-framework/eremetic/version"
 )
 
@@ -63,6 +65,96 @@ func handleError(err error, w http.ResponseWriter, message string) {
 	}
 }
 
+// readRequest parses the body of a task request for the given API version.
+// V1 requests may refer to a template, in which case the overrides of the
+// request are merged into it. It writes an error response and returns false
+// if the body can't be parsed.
+func (h Handler) readRequest(w http.ResponseWriter, r *http.Request, apiVersion string) (eremetic.Request, bool) {
+	var request eremetic.Request
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		handleError(err, w, "Unable to read payload.")
+		return request, false
+	}
+
+	switch apiVersion {
+	case api.V0:
+		deprecated(w)
+		var req api.RequestV0
+		err = json.Unmarshal(body, &req)
+		request = api.RequestFromV0(req)
+	case api.V1:
+		var tr api.TemplateRequestV1
+		if json.Unmarshal(body, &tr) == nil && tr.Template != "" {
+			return h.requestFromTemplate(w, tr)
+		}
+		var req api.RequestV1
+		err = json.Unmarshal(body, &req)
+		request = api.RequestFromV1(req)
+	default:
+		handleError(errors.New("Invalid API version"), w, "Invalid API version.")
+		return request, false
+	}
+	if err != nil {
+		handleError(err, w, "Unable to parse body into a valid request.")
+		return request, false
+	}
+
+	return request, true
+}
+
+func (h Handler) requestFromTemplate(w http.ResponseWriter, tr api.TemplateRequestV1) (eremetic.Request, bool) {
+	var request eremetic.Request
+
+	template, err := h.database.ReadTemplate(tr.Template)
+	if err != nil {
+		writeValidationErrors(validation.Errors{{
+			Field:   "template",
+			Problem: fmt.Sprintf("unknown template %q", tr.Template),
+		}}, w)
+		return request, false
+	}
+
+	version := template.Version
+	if tr.Version != 0 {
+		version = tr.Version
+	}
+	base, ok := template.At(version)
+	if !ok {
+		writeValidationErrors(validation.Errors{{
+			Field:   "version",
+			Problem: fmt.Sprintf("template %q has no version %d", tr.Template, version),
+		}}, w)
+		return request, false
+	}
+
+	req, err := api.MergeRequestV1(api.RequestV1FromRequest(base), tr.Overrides)
+	if err != nil {
+		handleError(err, w, "Unable to merge overrides into template.")
+		return request, false
+	}
+
+	request = api.RequestFromV1(req)
+	request.Template = template.Name
+	request.TemplateVersion = version
+
+	return request, true
+}
+
+func writeValidationErrors(err error, w http.ResponseWriter) {
+	errs, ok := err.(validation.Errors)
+	if !ok {
+		handleError(err, w, "Unable to validate request.")
+		return
+	}
+	writeJSON(http.StatusBadRequest, validationDocument{
+		Error:   err.Error(),
+		Message: "Invalid task request",
+		Errors:  errs,
+	}, w)
+}
+
 func writeJSON(status int, data interface{}, w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(status)
@@ -143,6 +235,7 @@ func makeMap(task eremetic.Task) map[string]interface{} {
 	data["CPU"] = fmt.Sprintf("%.2f", task.TaskCPUs)
 	data["Memory"] = fmt.Sprintf("%.2f", task.TaskMem)
 	data["Terminated"] = task.IsTerminated()
+	data["Queued"] = task.IsEnqueued()
 
 	return data
 }
@@ -221,16 +314,47 @@ func requireAuth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(nil)
 }
 
+// certPrincipal returns the common name of a verified client certificate, or
+// an empty string if the client did not present one.
+func certPrincipal(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+func authenticate(r *http.Request, username, password string) (string, error) {
+	if cn := certPrincipal(r); cn != "" {
+		return cn, nil
+	}
+	if username == "" || password == "" {
+		return "", errors.New("bad authorization")
+	}
+	if err := checkAuth(r, username, password); err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+type principalKey struct{}
+
+// principal returns the authenticated principal of the request, if any.
+func principal(r *http.Request) string {
+	p, _ := r.Context().Value(principalKey{}).(string)
+	return p
+}
+
 func authWrap(fn http.Handler, username, password string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		err := checkAuth(r, username, password)
+		p, err := authenticate(r, username, password)
 		if err != nil {
 			requireAuth(w, r)
 			return
 		}
 
-		fn.ServeHTTP(w, r)
+		ctx := context.WithValue(r.Context(), principalKey{}, p)
+		fn.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
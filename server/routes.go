@@ -2,9 +2,11 @@ package server
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/eremetic-framework/eremetic"
 	"github.com/eremetic-framework/eremetic/config"
@@ -21,17 +23,68 @@ type Route struct {
 // Routes is a collection of route structs
 type Routes []Route
 
-// NewRouter is used to create a new router.
-func NewRouter(scheduler eremetic.Scheduler, conf *config.Config, db eremetic.TaskDB) *mux.Router {
+// The HTTP metrics keep the names and labels of the ones prometheus used to
+// export for instrumented handlers, so that existing dashboards still work.
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests made.",
+	}, []string{"handler", "method", "code"})
+	httpRequestDuration = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "http_request_duration_microseconds",
+		Help:       "The HTTP request latencies in microseconds.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	}, []string{"handler"})
+	httpRequestSize = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "http_request_size_bytes",
+		Help:       "The HTTP request sizes in bytes.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	}, []string{"handler"})
+	httpResponseSize = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "http_response_size_bytes",
+		Help:       "The HTTP response sizes in bytes.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	}, []string{"handler"})
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpRequestDuration, httpRequestSize, httpResponseSize)
+}
+
+// instrument counts the requests of a route, and measures their latency and
+// the size of requests and responses.
+func instrument(name string, handler http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+	handler = promhttp.InstrumentHandlerCounter(httpRequests.MustCurryWith(labels), handler)
+	handler = promhttp.InstrumentHandlerResponseSize(httpResponseSize.MustCurryWith(labels), handler)
+	handler = promhttp.InstrumentHandlerRequestSize(httpRequestSize.MustCurryWith(labels), handler)
+
+	duration := httpRequestDuration.With(labels)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		handler.ServeHTTP(w, r)
+		duration.Observe(float64(time.Since(start)) / float64(time.Microsecond))
+	})
+}
+
+// NewRouter is used to create a new router. With a leadership, requests
+// needing the scheduler are redirected to the leader while this instance
+// doesn't lead; without, this instance is the only one.
+func NewRouter(scheduler eremetic.Scheduler, conf *config.Config, db eremetic.TaskDB, leadership eremetic.Leadership) *mux.Router {
 	h := NewHandler(scheduler, db)
+	h.leadership = leadership
 	router := mux.NewRouter().StrictSlash(true)
 
 	for _, route := range routes(h, conf) {
+		handler := route.Handler
+		if leaderRoutes[route.Name] {
+			handler = h.leaderOnly(handler)
+		}
 		router.
 			Methods(route.Method).
 			Path(route.Pattern).
 			Name(route.Name).
-			Handler(prometheus.InstrumentHandler(route.Name, route.Handler))
+			Handler(instrument(route.Name, handler))
 	}
 
 	router.
@@ -41,7 +94,7 @@ func NewRouter(scheduler eremetic.Scheduler, conf *config.Config, db eremetic.Ta
 	router.NotFoundHandler = http.HandlerFunc(h.NotFound(conf))
 
 	username, password := parseHTTPCredentials(conf.HTTPCredentials)
-	if username != "" && password != "" {
+	if (username != "" && password != "") || conf.ClientCA != "" {
 		router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
 			name := route.GetName()
 			// `/version` can be used as health check, so ignore auth required for it
@@ -70,7 +123,7 @@ func routes(h Handler, conf *config.Config) Routes {
 			Name:    "Metrics",
 			Method:  "GET",
 			Pattern: "/metrics",
-			Handler: prometheus.Handler(),
+			Handler: promhttp.Handler(),
 		},
 	}, apiRoutes...)
 }
@@ -1,6 +1,9 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -16,15 +19,37 @@ func TestRoutes(t *testing.T) {
 
 	Convey("Create", t, func() {
 		Convey("Should build the expected routes", func() {
-			m := NewRouter(nil, &config.Config{}, db)
+			m := NewRouter(nil, &config.Config{}, db, nil)
 			for _, route := range routes {
 				So(m.GetRoute(route.Name), ShouldNotBeNil)
 			}
 		})
 	})
 
+	Convey("Metrics", t, func() {
+		Convey("Keep the names of the instrumented handlers", func() {
+			m := NewRouter(nil, &config.Config{}, db, nil)
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/version", nil))
+
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			So(rec.Code, ShouldEqual, http.StatusOK)
+
+			var names []string
+			for _, line := range strings.Split(rec.Body.String(), "\n") {
+				if strings.HasPrefix(line, "# TYPE http_") {
+					names = append(names, strings.Join(strings.Fields(line)[2:], " "))
+				}
+			}
+			So(names, ShouldContain, "http_request_duration_microseconds summary")
+			So(names, ShouldContain, "http_request_size_bytes summary")
+			So(names, ShouldContain, "http_requests_total counter")
+			So(names, ShouldContain, "http_response_size_bytes summary")
+		})
+	})
+
 	Convey("Expected number of routes", t, func() {
-		ExpectedNumberOfRoutes := 18 // Magic numbers FTW
+		ExpectedNumberOfRoutes := 38 // Magic numbers FTW
 
 		So(len(routes), ShouldEqual, ExpectedNumberOfRoutes)
 	})
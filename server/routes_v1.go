@@ -13,6 +13,24 @@ func apiV1Routes(h Handler, conf *config.Config) Routes {
 			Pattern: "/api/v1/task",
 			Handler: h.AddTask(conf, api.V1),
 		},
+		Route{
+			Name:    "ValidateTask",
+			Method:  "POST",
+			Pattern: "/api/v1/task/validate",
+			Handler: h.ValidateTask(api.V1),
+		},
+		Route{
+			Name:    "FitTask",
+			Method:  "POST",
+			Pattern: "/api/v1/task/fit",
+			Handler: h.FitTask(),
+		},
+		Route{
+			Name:    "ListAgents",
+			Method:  "GET",
+			Pattern: "/api/v1/cluster/agents",
+			Handler: h.ListAgents(),
+		},
 		Route{
 			Name:    "Status",
 			Method:  "GET",
@@ -31,6 +49,12 @@ func apiV1Routes(h Handler, conf *config.Config) Routes {
 			Pattern: "/api/v1/task/{taskId}/stderr",
 			Handler: h.GetFromSandbox("stderr", api.V1),
 		},
+		Route{
+			Name:    "TaskDiagnostics",
+			Method:  "GET",
+			Pattern: "/api/v1/task/{taskId}/diagnostics",
+			Handler: h.GetTaskDiagnostics(),
+		},
 		Route{
 			Name:    "Kill",
 			Method:  "POST",
@@ -55,5 +79,101 @@ func apiV1Routes(h Handler, conf *config.Config) Routes {
 			Pattern: "/api/v1/version",
 			Handler: h.Version(conf, api.V1),
 		},
+		Route{
+			Name:    "Leader",
+			Method:  "GET",
+			Pattern: "/api/v1/leader",
+			Handler: h.Leader(),
+		},
+		Route{
+			Name:    "ListTemplates",
+			Method:  "GET",
+			Pattern: "/api/v1/template",
+			Handler: h.ListTemplates(),
+		},
+		Route{
+			Name:    "CreateTemplate",
+			Method:  "POST",
+			Pattern: "/api/v1/template",
+			Handler: h.CreateTemplate(conf),
+		},
+		Route{
+			Name:    "GetTemplate",
+			Method:  "GET",
+			Pattern: "/api/v1/template/{name}",
+			Handler: h.GetTemplate(),
+		},
+		Route{
+			Name:    "GetTemplateVersion",
+			Method:  "GET",
+			Pattern: "/api/v1/template/{name}/version/{version}",
+			Handler: h.GetTemplateVersion(),
+		},
+		Route{
+			Name:    "UpdateTemplate",
+			Method:  "PUT",
+			Pattern: "/api/v1/template/{name}",
+			Handler: h.UpdateTemplate(),
+		},
+		Route{
+			Name:    "DeleteTemplate",
+			Method:  "DELETE",
+			Pattern: "/api/v1/template/{name}",
+			Handler: h.DeleteTemplate(),
+		},
+		Route{
+			Name:    "ListQuotas",
+			Method:  "GET",
+			Pattern: "/api/v1/admin/quota",
+			Handler: h.ListQuotas(),
+		},
+		Route{
+			Name:    "GetQuota",
+			Method:  "GET",
+			Pattern: "/api/v1/admin/quota/{name}",
+			Handler: h.GetQuota(),
+		},
+		Route{
+			Name:    "PutQuota",
+			Method:  "PUT",
+			Pattern: "/api/v1/admin/quota/{name}",
+			Handler: h.PutQuota(),
+		},
+		Route{
+			Name:    "DeleteQuota",
+			Method:  "DELETE",
+			Pattern: "/api/v1/admin/quota/{name}",
+			Handler: h.DeleteQuota(),
+		},
+		Route{
+			Name:    "GetFramework",
+			Method:  "GET",
+			Pattern: "/api/v1/admin/framework",
+			Handler: h.GetFramework(),
+		},
+		Route{
+			Name:    "TeardownFramework",
+			Method:  "DELETE",
+			Pattern: "/api/v1/admin/framework",
+			Handler: h.TeardownFramework(),
+		},
+		Route{
+			Name:    "GetReconciliation",
+			Method:  "GET",
+			Pattern: "/api/v1/reconciliation",
+			Handler: h.GetReconciliation(),
+		},
+		Route{
+			Name:    "CheckDatabase",
+			Method:  "GET",
+			Pattern: "/api/v1/admin/fsck",
+			Handler: h.CheckDatabase(),
+		},
+		Route{
+			Name:    "RepairDatabase",
+			Method:  "POST",
+			Pattern: "/api/v1/admin/fsck",
+			Handler: h.RepairDatabase(),
+		},
 	}
 }
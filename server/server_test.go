@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -13,8 +14,10 @@ import (
 	. "github.com/smartystreets/goconvey/convey"
 
 	"github.com/eremetic-framework/eremetic"
+	"github.com/eremetic-framework/eremetic/admission"
 	"github.com/eremetic-framework/eremetic/config"
 	"github.com/eremetic-framework/eremetic/mock"
+	"github.com/eremetic-framework/eremetic/validation"
 )
 
 func TestServer(t *testing.T) {
@@ -30,10 +33,10 @@ func TestServer(t *testing.T) {
 				db := mock.TaskDB{}
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				var body bytes.Buffer
-				body.WriteString(`{}`)
+				body.WriteString(`{"task_cpus": 0.5, "task_mem": 22, "docker_image": "busybox"}`)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("POST", "http://example.com/task", &body)
@@ -52,10 +55,10 @@ func TestServer(t *testing.T) {
 				db := mock.TaskDB{}
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				var body bytes.Buffer
-				body.WriteString(`{}`)
+				body.WriteString(`{"task_cpus": 0.5, "task_mem": 22, "docker_image": "busybox"}`)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("POST", "http://example.com/task", &body)
@@ -74,10 +77,10 @@ func TestServer(t *testing.T) {
 				db := mock.TaskDB{}
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				var body bytes.Buffer
-				body.WriteString(`{}`)
+				body.WriteString(`{"task_cpus": 0.5, "task_mem": 22, "docker_image": "busybox"}`)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("POST", "http://example.com/task", &body)
@@ -86,6 +89,494 @@ func TestServer(t *testing.T) {
 
 				So(rec.Code, ShouldEqual, http.StatusInternalServerError)
 			})
+			Convey("QuotaExceeded", func() {
+				sched := mock.Scheduler{
+					ScheduleTaskFn: func(req eremetic.Request) (string, error) {
+						return "", &eremetic.QuotaExceededError{Quota: "team", Reason: "max 1 queued tasks"}
+					},
+				}
+
+				db := mock.TaskDB{}
+				cfg := config.Config{}
+
+				srv := NewRouter(&sched, &cfg, &db, nil)
+
+				var body bytes.Buffer
+				body.WriteString(`{"cpu": 0.5, "mem": 22, "image": "busybox"}`)
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task", &body)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusTooManyRequests)
+				So(rec.Body.String(), ShouldContainSubstring, "quota team exceeded")
+			})
+			Convey("AdmissionDenied", func() {
+				sched := mock.Scheduler{
+					ScheduleTaskFn: func(req eremetic.Request) (string, error) {
+						return "", &admission.DeniedError{Controller: "policy", Reason: "privileged containers are not allowed"}
+					},
+				}
+
+				db := mock.TaskDB{}
+				cfg := config.Config{}
+
+				srv := NewRouter(&sched, &cfg, &db, nil)
+
+				var body bytes.Buffer
+				body.WriteString(`{"cpu": 0.5, "mem": 22, "image": "busybox", "privileged": true}`)
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task", &body)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusForbidden)
+				So(rec.Body.String(), ShouldContainSubstring, "privileged containers are not allowed")
+			})
+			Convey("OwnerFromPrincipal", func() {
+				var owner string
+				sched := mock.Scheduler{
+					ScheduleTaskFn: func(req eremetic.Request) (string, error) {
+						owner = req.Owner
+						return "task_id", nil
+					},
+				}
+
+				db := mock.TaskDB{}
+				cfg := config.Config{
+					HTTPCredentials: "alice:secret",
+				}
+
+				srv := NewRouter(&sched, &cfg, &db, nil)
+
+				var body bytes.Buffer
+				body.WriteString(`{"cpu": 0.5, "mem": 22, "image": "busybox", "owner": "bob"}`)
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task", &body)
+				r.SetBasicAuth("alice", "secret")
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusAccepted)
+				So(owner, ShouldEqual, "alice")
+			})
+			Convey("OwnerOfTheBodyIsIgnored", func() {
+				owner := "unset"
+				sched := mock.Scheduler{
+					ScheduleTaskFn: func(req eremetic.Request) (string, error) {
+						owner = req.Owner
+						return "task_id", nil
+					},
+				}
+
+				db := mock.TaskDB{}
+				cfg := config.Config{}
+
+				srv := NewRouter(&sched, &cfg, &db, nil)
+
+				var body bytes.Buffer
+				body.WriteString(`{"cpu": 0.5, "mem": 22, "image": "busybox", "owner": "bob"}`)
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task", &body)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusAccepted)
+				So(owner, ShouldEqual, "")
+			})
+		})
+		Convey("Validation", func() {
+			sched := mock.Scheduler{
+				ScheduleTaskFn: func(req eremetic.Request) (string, error) {
+					return "task_id", nil
+				},
+			}
+			db := mock.TaskDB{}
+			cfg := config.Config{}
+			srv := NewRouter(&sched, &cfg, &db, nil)
+
+			invalid := `{"cpu": -1, "mem": 22, "network": "OVERLAY", "callback_uri": "ftp://example.com"}`
+
+			Convey("AddTask rejects invalid requests", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task", strings.NewReader(invalid))
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+				So(sched.ScheduleTaskInvoked, ShouldBeFalse)
+
+				var doc validationDocument
+				So(json.Unmarshal(rec.Body.Bytes(), &doc), ShouldBeNil)
+				So(doc.Errors, ShouldHaveLength, 4)
+				So(doc.Errors[0], ShouldResemble, validation.FieldError{Field: "cpu", Problem: "must be greater than 0"})
+				So(doc.Errors[1].Field, ShouldEqual, "image")
+				So(doc.Errors[2].Field, ShouldEqual, "network")
+				So(doc.Errors[3].Field, ShouldEqual, "callback_uri")
+			})
+
+			Convey("Validate reports problems without scheduling", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task/validate", strings.NewReader(invalid))
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+				So(sched.ScheduleTaskInvoked, ShouldBeFalse)
+			})
+
+			Convey("Validate accepts valid requests", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task/validate", strings.NewReader(`{"cpu": 0.5, "mem": 22, "image": "busybox"}`))
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"errors":[]`)
+				So(sched.ScheduleTaskInvoked, ShouldBeFalse)
+			})
+		})
+		Convey("Templates", func() {
+			var scheduled eremetic.Request
+			sched := mock.Scheduler{
+				ScheduleTaskFn: func(req eremetic.Request) (string, error) {
+					scheduled = req
+					return "task_id", nil
+				},
+			}
+			db := eremetic.NewDefaultTaskDB()
+			cfg := config.Config{}
+			srv := NewRouter(&sched, &cfg, db, nil)
+
+			do := func(method, url, body string) *httptest.ResponseRecorder {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest(method, "http://example.com"+url, strings.NewReader(body))
+				srv.ServeHTTP(rec, r)
+				return rec
+			}
+
+			etl := `{"name": "nightly-etl", "request": {"cpu": 1, "mem": 512, "image": "etl:1.0", "env": {"MODE": "nightly"}, "masked_env": {"TOKEN": "secret"}}}`
+
+			Convey("Create", func() {
+				rec := do("POST", "/api/v1/template", etl)
+				So(rec.Code, ShouldEqual, http.StatusCreated)
+				So(rec.Header().Get("Location"), ShouldEndWith, "/api/v1/template/nightly-etl")
+				So(rec.Body.String(), ShouldNotContainSubstring, "secret")
+
+				t, err := db.ReadTemplate("nightly-etl")
+				So(err, ShouldBeNil)
+				So(t.Version, ShouldEqual, 1)
+				So(t.Request.DockerImage, ShouldEqual, "etl:1.0")
+
+				Convey("Twice", func() {
+					So(do("POST", "/api/v1/template", etl).Code, ShouldEqual, http.StatusConflict)
+				})
+
+				Convey("Update increases the version and keeps the prior one", func() {
+					rec := do("PUT", "/api/v1/template/nightly-etl", `{"request": {"cpu": 2, "mem": 512, "image": "etl:1.1", "masked_env": {"TOKEN": "*******"}}}`)
+					So(rec.Code, ShouldEqual, http.StatusOK)
+					So(rec.Body.String(), ShouldContainSubstring, `"version":2`)
+					So(rec.Body.String(), ShouldContainSubstring, `"versions":[1,2]`)
+					So(rec.Body.String(), ShouldNotContainSubstring, "secret")
+
+					t, _ := db.ReadTemplate("nightly-etl")
+					So(t.Request.MaskedEnvironment["TOKEN"], ShouldEqual, "secret")
+					So(t.History, ShouldHaveLength, 1)
+
+					rec = do("GET", "/api/v1/template/nightly-etl/version/1", "")
+					So(rec.Code, ShouldEqual, http.StatusOK)
+					So(rec.Body.String(), ShouldContainSubstring, `"image":"etl:1.0"`)
+					So(rec.Body.String(), ShouldNotContainSubstring, "secret")
+					So(do("GET", "/api/v1/template/nightly-etl/version/3", "").Code, ShouldEqual, http.StatusNotFound)
+
+					Convey("Tasks can be created from the prior version", func() {
+						rec := do("POST", "/api/v1/task", `{"template": "nightly-etl", "version": 1}`)
+						So(rec.Code, ShouldEqual, http.StatusAccepted)
+						So(scheduled.DockerImage, ShouldEqual, "etl:1.0")
+						So(scheduled.TemplateVersion, ShouldEqual, 1)
+
+						rec = do("POST", "/api/v1/task", `{"template": "nightly-etl", "version": 5}`)
+						So(rec.Code, ShouldEqual, http.StatusBadRequest)
+						So(rec.Body.String(), ShouldContainSubstring, `"field":"version"`)
+					})
+				})
+
+				Convey("Get and list", func() {
+					rec := do("GET", "/api/v1/template/nightly-etl", "")
+					So(rec.Body.String(), ShouldContainSubstring, `"image":"etl:1.0"`)
+					So(rec.Body.String(), ShouldNotContainSubstring, "secret")
+					rec = do("GET", "/api/v1/template", "")
+					So(rec.Body.String(), ShouldContainSubstring, `"name":"nightly-etl"`)
+					So(rec.Body.String(), ShouldNotContainSubstring, "secret")
+
+					t, _ := db.ReadTemplate("nightly-etl")
+					So(t.Request.MaskedEnvironment["TOKEN"], ShouldEqual, "secret")
+				})
+
+				Convey("Delete", func() {
+					So(do("DELETE", "/api/v1/template/nightly-etl", "").Code, ShouldEqual, http.StatusAccepted)
+					So(do("GET", "/api/v1/template/nightly-etl", "").Code, ShouldEqual, http.StatusNotFound)
+				})
+
+				Convey("AddTask from a template", func() {
+					rec := do("POST", "/api/v1/task", `{"template": "nightly-etl", "overrides": {"mem": 1024, "env": {"DAY": "monday"}}}`)
+					So(rec.Code, ShouldEqual, http.StatusAccepted)
+					So(scheduled.TaskCPUs, ShouldEqual, 1)
+					So(scheduled.TaskMem, ShouldEqual, 1024)
+					So(scheduled.Environment, ShouldResemble, map[string]string{"MODE": "nightly", "DAY": "monday"})
+					So(scheduled.MaskedEnvironment, ShouldResemble, map[string]string{"TOKEN": "secret"})
+					So(scheduled.Template, ShouldEqual, "nightly-etl")
+					So(scheduled.TemplateVersion, ShouldEqual, 1)
+				})
+			})
+
+			Convey("Invalid names are rejected", func() {
+				So(do("POST", "/api/v1/template", `{"name": "a/b"}`).Code, ShouldEqual, 422)
+			})
+
+			Convey("AddTask from an unknown template", func() {
+				rec := do("POST", "/api/v1/task", `{"template": "nope"}`)
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+				So(rec.Body.String(), ShouldContainSubstring, `"field":"template"`)
+				So(sched.ScheduleTaskInvoked, ShouldBeFalse)
+			})
+		})
+		Convey("Quotas", func() {
+			quotas := map[string]*eremetic.Quota{}
+			db := mock.TaskDB{
+				PutQuotaFn: func(q *eremetic.Quota) error {
+					quotas[q.Name] = q
+					return nil
+				},
+				ReadQuotaFn: func(name string) (eremetic.Quota, error) {
+					if q, ok := quotas[name]; ok {
+						return *q, nil
+					}
+					return eremetic.Quota{}, errors.New("unknown quota")
+				},
+				DeleteQuotaFn: func(name string) error {
+					delete(quotas, name)
+					return nil
+				},
+				ListQuotasFn: func() ([]*eremetic.Quota, error) {
+					var res []*eremetic.Quota
+					for _, q := range quotas {
+						res = append(res, q)
+					}
+					return res, nil
+				},
+				ListTasksFn: func(filter *eremetic.TaskFilter) ([]*eremetic.Task, error) {
+					return []*eremetic.Task{
+						{
+							Owner:    "alice",
+							TaskCPUs: 0.5,
+							TaskMem:  128,
+							Status:   []eremetic.Status{{Status: eremetic.TaskRunning}},
+						},
+						{
+							Owner:    "bob",
+							TaskCPUs: 1,
+							TaskMem:  256,
+							Status:   []eremetic.Status{{Status: eremetic.TaskQueued}},
+						},
+					}, nil
+				},
+			}
+			cfg := config.Config{}
+			srv := NewRouter(&mock.Scheduler{}, &cfg, &db, nil)
+
+			Convey("PutQuota", func() {
+				var body bytes.Buffer
+				body.WriteString(`{"owner": "alice", "max_running": 2, "max_cpu": 4}`)
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("PUT", "http://example.com/api/v1/admin/quota/alice", &body)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(quotas, ShouldContainKey, "alice")
+				So(quotas["alice"].MaxRunning, ShouldEqual, 2)
+				So(quotas["alice"].MaxCPUs, ShouldEqual, 4)
+			})
+			Convey("GetQuota", func() {
+				quotas["alice"] = &eremetic.Quota{Name: "alice", Owner: "alice", MaxRunning: 2}
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/admin/quota/alice", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"running":1`)
+				So(rec.Body.String(), ShouldContainSubstring, `"queued":0`)
+			})
+			Convey("GetUnknownQuota", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/admin/quota/nope", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+			})
+			Convey("ListQuotas", func() {
+				quotas["all"] = &eremetic.Quota{Name: "all"}
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/admin/quota", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"running":1`)
+				So(rec.Body.String(), ShouldContainSubstring, `"queued":1`)
+			})
+			Convey("DeleteQuota", func() {
+				quotas["alice"] = &eremetic.Quota{Name: "alice"}
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("DELETE", "http://example.com/api/v1/admin/quota/alice", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusAccepted)
+				So(quotas, ShouldNotContainKey, "alice")
+			})
+		})
+		Convey("Framework", func() {
+			frameworkID := "1234-0001"
+			sched := mock.Scheduler{
+				TeardownFn: func() error {
+					frameworkID = ""
+					return nil
+				},
+			}
+			db := mock.TaskDB{
+				ReadFrameworkIDFn: func() (string, error) {
+					return frameworkID, nil
+				},
+			}
+			cfg := config.Config{}
+			srv := NewRouter(&sched, &cfg, &db, nil)
+
+			Convey("GetFramework", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/admin/framework", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"id":"1234-0001"`)
+			})
+			Convey("TeardownFramework", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("DELETE", "http://example.com/api/v1/admin/framework", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusAccepted)
+				So(sched.TeardownInvoked, ShouldBeTrue)
+				So(frameworkID, ShouldBeEmpty)
+			})
+			Convey("TeardownFramework fails", func() {
+				sched.TeardownFn = func() error {
+					return errors.New("scheduler is not running")
+				}
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("DELETE", "http://example.com/api/v1/admin/framework", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, 422)
+			})
+		})
+		Convey("Reconciliation", func() {
+			var report *eremetic.Reconciliation
+			sched := mock.Scheduler{
+				ReconciliationFn: func() *eremetic.Reconciliation {
+					return report
+				},
+			}
+			cfg := config.Config{}
+			srv := NewRouter(&sched, &cfg, &mock.TaskDB{}, nil)
+
+			Convey("Reports the last reconciliation", func() {
+				report = &eremetic.Reconciliation{
+					Outcome: eremetic.ReconciliationRunning,
+					Pending: []string{"eremetic-task.1"},
+				}
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/reconciliation", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"outcome":"running"`)
+				So(rec.Body.String(), ShouldContainSubstring, `"pending":["eremetic-task.1"]`)
+			})
+			Convey("Fails before the first reconciliation", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/reconciliation", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+		Convey("Fsck", func() {
+			sched := mock.Scheduler{
+				QueuedFn: func(id string) bool {
+					return false
+				},
+				EnqueueFn: func(id string) error {
+					return nil
+				},
+			}
+			db := mock.TaskDB{
+				ListTasksFn: func(*eremetic.TaskFilter) ([]*eremetic.Task, error) {
+					return []*eremetic.Task{{
+						ID:     "eremetic-task.1",
+						Status: []eremetic.Status{{Status: eremetic.TaskQueued}},
+					}}, nil
+				},
+			}
+			cfg := config.Config{}
+			srv := NewRouter(&sched, &cfg, &db, nil)
+
+			Convey("CheckDatabase", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/admin/fsck", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"kind":"orphan_queued"`)
+				So(rec.Body.String(), ShouldContainSubstring, `"repaired":0`)
+				So(sched.EnqueueInvoked, ShouldBeFalse)
+			})
+			Convey("RepairDatabase", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/admin/fsck?stuck_after=10m", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"repaired":1`)
+				So(sched.EnqueueInvoked, ShouldBeTrue)
+			})
+			Convey("Invalid stuck_after", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/admin/fsck?stuck_after=soon", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+			})
 		})
 		Convey("GetFromSandBox", func() {
 			Convey("Simple", func() {
@@ -113,7 +604,7 @@ func TestServer(t *testing.T) {
 
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("GET", "http://example.com/task/test_id/stdout", nil)
@@ -136,7 +627,7 @@ func TestServer(t *testing.T) {
 
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("GET", "http://example.com/task/test_id/stdout", nil)
@@ -160,7 +651,7 @@ func TestServer(t *testing.T) {
 
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("GET", "http://example.com/task/test_id", nil)
@@ -180,7 +671,7 @@ func TestServer(t *testing.T) {
 
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("GET", "http://example.com/task/unknown_id", nil)
@@ -190,6 +681,90 @@ func TestServer(t *testing.T) {
 				So(rec.Code, ShouldEqual, http.StatusNotFound)
 			})
 		})
+		Convey("TaskDiagnostics", func() {
+			sched := mock.Scheduler{
+				DiagnosticsFn: func(id string) eremetic.TaskDiagnostics {
+					return eremetic.TaskDiagnostics{
+						TaskID:     id,
+						Queued:     true,
+						Rejections: map[string]int{eremetic.RejectedCPU: 2},
+					}
+				},
+			}
+			db := mock.TaskDB{
+				ReadTaskFn: func(id string) (eremetic.Task, error) {
+					if id != "eremetic-task.1" {
+						return eremetic.Task{}, errors.New("not found")
+					}
+					return eremetic.Task{ID: id}, nil
+				},
+			}
+			cfg := config.Config{}
+			srv := NewRouter(&sched, &cfg, &db, nil)
+
+			Convey("Simple", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/task/eremetic-task.1/diagnostics", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"queued":true`)
+				So(rec.Body.String(), ShouldContainSubstring, `"rejections":{"cpu":2}`)
+			})
+			Convey("TaskNotFound", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/task/unknown_id/diagnostics", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+		Convey("Cluster", func() {
+			sched := mock.Scheduler{
+				AgentsFn: func() []eremetic.Agent {
+					return []eremetic.Agent{{ID: "agent-1", CPUs: 4, Mem: 16384}}
+				},
+				FitFn: func(req eremetic.Request) (eremetic.TaskFit, error) {
+					fit := eremetic.TaskFit{Agents: []string{}}
+					if req.TaskMem <= 16384 {
+						fit.Agents = append(fit.Agents, "agent-1")
+					}
+					return fit, nil
+				},
+			}
+			cfg := config.Config{}
+			srv := NewRouter(&sched, &cfg, &mock.TaskDB{}, nil)
+
+			Convey("ListAgents", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/cluster/agents", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"mem":16384`)
+			})
+			Convey("FitTask", func() {
+				body := `{"image":"busybox","command":"true","cpu":1,"mem":16384}`
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task/fit", strings.NewReader(body))
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"agents":["agent-1"]`)
+			})
+			Convey("FitTask rejects invalid requests", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task/fit", strings.NewReader(`{"cpu":1,"mem":64}`))
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
 		Convey("ListRunningTasks", func() {
 			Convey("Simple", func() {
 				sched := mock.Scheduler{}
@@ -202,7 +777,7 @@ func TestServer(t *testing.T) {
 
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("GET", "http://example.com/task", nil)
@@ -211,6 +786,50 @@ func TestServer(t *testing.T) {
 
 				So(rec.Code, ShouldEqual, http.StatusOK)
 			})
+			Convey("Paged", func() {
+				sched := mock.Scheduler{}
+
+				var received *eremetic.TaskFilter
+				db := mock.TaskDB{
+					ListTasksFn: func(filter *eremetic.TaskFilter) ([]*eremetic.Task, error) {
+						received = filter
+						return []*eremetic.Task{
+							&eremetic.Task{ID: "eremetic-task.1", Status: []eremetic.Status{{Time: 1, Status: eremetic.TaskQueued}}},
+							&eremetic.Task{ID: "eremetic-task.2", Status: []eremetic.Status{{Time: 2, Status: eremetic.TaskQueued}}},
+						}, nil
+					},
+				}
+
+				cfg := config.Config{}
+
+				srv := NewRouter(&sched, &cfg, &db, nil)
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/task?limit=2&labels=team%3Ddata&sort=updated", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(received.Labels, ShouldEqual, "team=data")
+				So(rec.Header().Get("X-Next-Cursor"), ShouldEqual, received.CursorAfter(&eremetic.Task{
+					ID:     "eremetic-task.2",
+					Status: []eremetic.Status{{Time: 2, Status: eremetic.TaskQueued}},
+				}))
+			})
+			Convey("Invalid sort", func() {
+				sched := mock.Scheduler{}
+				db := mock.TaskDB{}
+				cfg := config.Config{}
+
+				srv := NewRouter(&sched, &cfg, &db, nil)
+
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/task?sort=name", nil)
+
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+			})
 		})
 		Convey("Index", func() {
 			Convey("Simple", func() {
@@ -224,7 +843,7 @@ func TestServer(t *testing.T) {
 
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("GET", "http://example.com/", nil)
@@ -245,7 +864,7 @@ func TestServer(t *testing.T) {
 
 				cfg := config.Config{}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("GET", "http://example.com/", nil)
@@ -267,7 +886,7 @@ func TestServer(t *testing.T) {
 
 				cfg := config.Config{HTTPCredentials: "admin:admin"}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("GET", "http://example.com/", nil)
@@ -289,7 +908,7 @@ func TestServer(t *testing.T) {
 
 				cfg := config.Config{HTTPCredentials: "admin:admin"}
 
-				srv := NewRouter(&sched, &cfg, &db)
+				srv := NewRouter(&sched, &cfg, &db, nil)
 
 				rec := httptest.NewRecorder()
 				r, _ := http.NewRequest("GET", "http://example.com/", nil)
@@ -301,5 +920,77 @@ func TestServer(t *testing.T) {
 				So(rec.Code, ShouldEqual, http.StatusOK)
 			})
 		})
+		Convey("Leadership", func() {
+			sched := mock.Scheduler{
+				ScheduleTaskFn: func(req eremetic.Request) (string, error) {
+					return "task_id", nil
+				},
+			}
+			db := mock.TaskDB{
+				ReadTaskFn: func(id string) (eremetic.Task, error) {
+					return eremetic.Task{ID: id}, nil
+				},
+			}
+			cfg := config.Config{}
+			leader := "http://eremetic-1:8080/"
+			isLeader := false
+			leadership := mock.Leadership{
+				IsLeaderFn: func() bool { return isLeader },
+				LeaderFn:   func() (string, error) { return leader, nil },
+			}
+			srv := NewRouter(&sched, &cfg, &db, &leadership)
+
+			addTask := func() *httptest.ResponseRecorder {
+				var body bytes.Buffer
+				body.WriteString(`{"cpu": 0.5, "mem": 22, "image": "busybox", "command": "true"}`)
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("POST", "http://example.com/api/v1/task?x=1", &body)
+				srv.ServeHTTP(rec, r)
+				return rec
+			}
+
+			Convey("Followers redirect scheduling requests", func() {
+				rec := addTask()
+
+				So(rec.Code, ShouldEqual, http.StatusTemporaryRedirect)
+				So(rec.Header().Get("Location"), ShouldEqual, "http://eremetic-1:8080/api/v1/task?x=1")
+				So(sched.ScheduleTaskInvoked, ShouldBeFalse)
+			})
+
+			Convey("Followers serve reads", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/task/1234", nil)
+				r.Header.Set("Accept", "application/json")
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("Requests fail without leader", func() {
+				leader = ""
+				rec := addTask()
+
+				So(rec.Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+
+			Convey("The leader schedules tasks", func() {
+				isLeader = true
+				rec := addTask()
+
+				So(rec.Code, ShouldEqual, http.StatusAccepted)
+				So(sched.ScheduleTaskInvoked, ShouldBeTrue)
+			})
+
+			Convey("Reports the leader", func() {
+				rec := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "http://example.com/api/v1/leader", nil)
+				srv.ServeHTTP(rec, r)
+
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Body.String(), ShouldContainSubstring, `"leader":"http://eremetic-1:8080/"`)
+				So(rec.Body.String(), ShouldContainSubstring, `"is_leader":false`)
+				So(rec.Body.String(), ShouldContainSubstring, `"election":true`)
+			})
+		})
 	})
 }
@@ -88,6 +88,27 @@ $(document).ready(function() {
     });
   }
 
+  /**
+   * Summarize the resources recently offered by the agents.
+   */
+  function showCluster() {
+    $.getJSON(EREMETIC_URL_PREFIX + '/api/v1/cluster/agents', function(agents) {
+      var cpu = 0, mem = 0, cpuMax = 0, memMax = 0;
+      $.each(agents, function(_, agent) {
+        cpu += agent.cpu;
+        mem += agent.mem;
+        cpuMax = Math.max(cpuMax, agent.cpu);
+        memMax = Math.max(memMax, agent.mem);
+      });
+      $('#cluster-agents').text(agents.length);
+      $('#cluster-cpu').text(cpu.toFixed(1));
+      $('#cluster-cpu-max').text(cpuMax.toFixed(1));
+      $('#cluster-mem').text(Math.round(mem));
+      $('#cluster-mem-max').text(Math.round(memMax));
+      $('#cluster').show();
+    });
+  }
+
   function createInput(type, number) {
     var input = {};
     if (type === 'env') {
@@ -311,4 +332,6 @@ $(document).ready(function() {
   });
 
   $(document).on('click', '.action.input button', removeInput);
+
+  showCluster();
 })
@@ -58,6 +58,50 @@ $(document).ready(function() {
     });
   }
 
+  function getDiagnostics() {
+    var $el = $('#diagnostics');
+    if ($el.length == 0) {
+      return;
+    }
+    $.ajax({
+      method: 'GET',
+      url: EREMETIC_URL_PREFIX + '/api/v1/task/' + taskId + '/diagnostics',
+      dataType: 'json',
+      success: function(data) {
+        if (!data.offers || data.offers.length == 0) {
+          return;
+        }
+        $el.text('');
+
+        var reasons = $.map(data.rejections, function(count, reason) {
+          return reason + ': ' + count;
+        });
+        $el.append($('<p/>', {
+          text: 'Last ' + data.offers.length + ' offers rejected by ' + reasons.join(', ')
+        }));
+
+        var $table = $('<table/>', { class: 'ui compact table' });
+        $table.append($('<thead/>').append($('<tr/>')
+          .append($('<th/>', { text: 'Agent' }))
+          .append($('<th/>', { text: 'Rejected by' }))
+          .append($('<th/>', { text: 'Detail' }))
+          .append($('<th/>', { text: 'Time' }))));
+        var $body = $('<tbody/>');
+        $.each(data.offers.slice().reverse(), function(i, offer) {
+          $body.append($('<tr/>')
+            .append($('<td/>', { text: offer.hostname }))
+            .append($('<td/>', { text: offer.rejection || 'accepted' }))
+            .append($('<td/>', { text: offer.detail || '' }))
+            .append($('<td/>', { text: new Date(offer.time).toLocaleString() })));
+        });
+        $el.append($table.append($body));
+      },
+      error: function(xhr, e) {
+        $el.text(e)
+      }
+    });
+  }
+
   $('body').on('click', '#kill', function(e) {
     e.preventDefault();
     $.ajax({
@@ -86,4 +130,5 @@ $(document).ready(function() {
 
   getLogs('stdout');
   getLogs('stderr');
+  getDiagnostics();
 })
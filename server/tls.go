@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic/config"
+)
+
+// CertificateReloader keeps the server certificate and client CA pool in
+// memory, allowing them to be replaced without restarting the listener.
+type CertificateReloader struct {
+	mtx      sync.RWMutex
+	certFile string
+	keyFile  string
+	caFile   string
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+}
+
+// NewCertificateReloader returns a CertificateReloader with the certificate,
+// key and optional client CA loaded from disk.
+func NewCertificateReloader(certFile, keyFile, caFile string) (*CertificateReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both tls_cert and tls_key must be set")
+	}
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate, key and client CA from disk. The previous
+// values are kept if any of them fail to load.
+func (r *CertificateReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pool, err = loadCertPool(r.caFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.cert = &cert
+	r.clientCA = pool
+
+	logrus.WithFields(logrus.Fields{
+		"tls_cert":  r.certFile,
+		"client_ca": r.caFile,
+	}).Info("Loaded TLS certificate")
+
+	return nil
+}
+
+// GetCertificate returns the currently loaded server certificate.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.cert, nil
+}
+
+// GetConfigForClient returns a tls.Config using the currently loaded
+// certificate and client CA pool.
+func (r *CertificateReloader) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.config(), nil
+}
+
+func (r *CertificateReloader) config() *tls.Config {
+	conf := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"http/1.1"},
+		GetCertificate: r.GetCertificate,
+	}
+	if r.caFile != "" {
+		conf.ClientCAs = r.clientCA
+		conf.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return conf
+}
+
+// NewTLSConfig creates the TLS configuration for the HTTP server based on the
+// tls_cert, tls_key and client_ca settings.
+func NewTLSConfig(conf *config.Config) (*tls.Config, *CertificateReloader, error) {
+	reloader, err := NewCertificateReloader(conf.TLSCert, conf.TLSKey, conf.ClientCA)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := reloader.config()
+	tlsConfig.GetConfigForClient = reloader.GetConfigForClient
+
+	return tlsConfig, reloader, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
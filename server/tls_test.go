@@ -0,0 +1,173 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic/config"
+	"github.com/eremetic-framework/eremetic/mock"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(cn string, parent *testCert, isCA bool) *testCert {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) write(dir, name string) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	ioutil.WriteFile(certFile, c.certPEM, 0600)
+	ioutil.WriteFile(keyFile, c.keyPEM, 0600)
+	return certFile, keyFile
+}
+
+func TestTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "eremetic-tls")
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert("eremetic-ca", nil, true)
+	caFile, _ := ca.write(dir, "ca")
+	srvCert := newTestCert("eremetic", ca, false)
+	certFile, keyFile := srvCert.write(dir, "server")
+	clientCert := newTestCert("alice", ca, false)
+	clientCertFile, clientKeyFile := clientCert.write(dir, "client")
+
+	Convey("NewCertificateReloader", t, func() {
+		Convey("Requires both certificate and key", func() {
+			_, err := NewCertificateReloader(certFile, "", "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Fails on missing files", func() {
+			_, err := NewCertificateReloader(filepath.Join(dir, "nope.crt"), keyFile, "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Reload replaces the certificate", func() {
+			r, err := NewCertificateReloader(certFile, keyFile, caFile)
+			So(err, ShouldBeNil)
+
+			before, _ := r.GetCertificate(nil)
+
+			newCert := newTestCert("eremetic-2", ca, false)
+			newCertFile, newKeyFile := newCert.write(dir, "server")
+			So(newCertFile, ShouldEqual, certFile)
+			So(newKeyFile, ShouldEqual, keyFile)
+
+			So(r.Reload(), ShouldBeNil)
+			after, _ := r.GetCertificate(nil)
+			So(after, ShouldNotEqual, before)
+
+			leaf, _ := x509.ParseCertificate(after.Certificate[0])
+			So(leaf.Subject.CommonName, ShouldEqual, "eremetic-2")
+		})
+
+		Convey("Reload keeps the old certificate on failure", func() {
+			r, err := NewCertificateReloader(certFile, keyFile, "")
+			So(err, ShouldBeNil)
+			before, _ := r.GetCertificate(nil)
+
+			r.keyFile = filepath.Join(dir, "missing.key")
+			So(r.Reload(), ShouldNotBeNil)
+
+			after, _ := r.GetCertificate(nil)
+			So(after, ShouldEqual, before)
+		})
+	})
+
+	Convey("Mutual TLS", t, func() {
+		cfg := config.Config{
+			TLSCert:  certFile,
+			TLSKey:   keyFile,
+			ClientCA: caFile,
+		}
+		tlsConfig, _, err := NewTLSConfig(&cfg)
+		So(err, ShouldBeNil)
+
+		var seen string
+		router := NewRouter(&mock.Scheduler{}, &cfg, &mock.TaskDB{}, nil)
+		router.Handle("/whoami", authWrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = principal(r)
+		}), "", ""))
+
+		ts := httptest.NewUnstartedServer(router)
+		ts.TLS = tlsConfig
+		ts.StartTLS()
+		defer ts.Close()
+
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.cert)
+
+		Convey("A verified client certificate is mapped to a principal", func() {
+			cert, _ := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs:      pool,
+				Certificates: []tls.Certificate{cert},
+			}}}
+
+			resp, err := c.Get(ts.URL + "/whoami")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(seen, ShouldEqual, "alice")
+		})
+
+		Convey("Requests without a certificate are rejected", func() {
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs: pool,
+			}}}
+
+			resp, err := c.Get(ts.URL + "/whoami")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package sqldb
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// migrations holds the statements bringing the schema from one version to
+// the next. The version of a schema is the number of migrations applied to
+// it. Migrations must only be appended, never changed.
+//
+// Statements have to work with both PostgreSQL and SQLite. `{{binary_text}}`
+// is a text column compared byte by byte, which is the order tasks are paged
+// in. Tasks are stored as a JSON document in tasks.data, the other columns
+// and tables hold the fields tasks are filtered on and reported from.
+var migrations = [][]string{
+	{
+		`CREATE TABLE tasks (
+			id         {{binary_text}} PRIMARY KEY,
+			name       TEXT NOT NULL,
+			state      TEXT NOT NULL,
+			image      TEXT NOT NULL,
+			hostname   TEXT NOT NULL,
+			owner      TEXT NOT NULL,
+			cpus       DOUBLE PRECISION NOT NULL,
+			mem        DOUBLE PRECISION NOT NULL,
+			created_at BIGINT NOT NULL,
+			updated_at BIGINT NOT NULL,
+			data       TEXT NOT NULL
+		)`,
+		`CREATE INDEX tasks_state ON tasks (state)`,
+		`CREATE INDEX tasks_name ON tasks (name)`,
+		`CREATE INDEX tasks_created ON tasks (created_at, id)`,
+		`CREATE INDEX tasks_updated ON tasks (updated_at, id)`,
+		`CREATE TABLE task_status (
+			task_id TEXT NOT NULL,
+			seq     INTEGER NOT NULL,
+			time    BIGINT NOT NULL,
+			status  TEXT NOT NULL,
+			PRIMARY KEY (task_id, seq)
+		)`,
+		`CREATE TABLE task_env (
+			task_id TEXT NOT NULL,
+			name    TEXT NOT NULL,
+			value   TEXT,
+			masked  BOOLEAN NOT NULL,
+			PRIMARY KEY (task_id, masked, name)
+		)`,
+		`CREATE TABLE task_labels (
+			task_id TEXT NOT NULL,
+			name    TEXT NOT NULL,
+			value   TEXT NOT NULL,
+			PRIMARY KEY (task_id, name)
+		)`,
+		`CREATE INDEX task_labels_name_value ON task_labels (name, value)`,
+		`CREATE TABLE task_ports (
+			task_id        TEXT NOT NULL,
+			seq            INTEGER NOT NULL,
+			container_port INTEGER NOT NULL,
+			host_port      INTEGER NOT NULL,
+			protocol       TEXT NOT NULL,
+			PRIMARY KEY (task_id, seq)
+		)`,
+		`CREATE TABLE quotas (
+			name TEXT PRIMARY KEY,
+			data TEXT NOT NULL
+		)`,
+		`CREATE TABLE templates (
+			name    TEXT PRIMARY KEY,
+			version INTEGER NOT NULL,
+			data    TEXT NOT NULL
+		)`,
+	},
+	{
+		`ALTER TABLE tasks ADD COLUMN revision BIGINT NOT NULL DEFAULT 0`,
+	},
+	{
+		`CREATE TABLE framework (
+			name  TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)`,
+	},
+	{
+		`ALTER TABLE task_ports ADD COLUMN name TEXT NOT NULL DEFAULT ''`,
+	},
+}
+
+var dialects = map[string]*strings.Replacer{
+	"postgres": strings.NewReplacer("{{binary_text}}", `TEXT COLLATE "C"`),
+	"sqlite3":  strings.NewReplacer("{{binary_text}}", "TEXT"),
+}
+
+// migrate applies the migrations missing from the database.
+func migrate(conn *sql.DB, driver string) error {
+	_, err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = conn.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for ; version < len(migrations); version++ {
+		logrus.WithField("version", version+1).Info("Migrating database schema")
+		if err := apply(conn, dialects[driver], version+1, migrations[version]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func apply(conn *sql.DB, dialect *strings.Replacer, version int, statements []string) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(dialect.Replace(stmt)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,107 @@
+package sqldb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// query builds a statement with numbered placeholders.
+type query struct {
+	strings.Builder
+	where []string
+	args  []interface{}
+}
+
+// arg adds an argument and returns its placeholder.
+func (q *query) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *query) cond(format string, args ...interface{}) {
+	placeholders := make([]interface{}, len(args))
+	for i, a := range args {
+		placeholders[i] = q.arg(a)
+	}
+	q.where = append(q.where, fmt.Sprintf(format, placeholders...))
+}
+
+// listQuery returns the query selecting the page of tasks described by the
+// filter. states are the states matching the state filter, nil if the
+// filter doesn't restrict states.
+func listQuery(filter *eremetic.TaskFilter, states []string) (*query, error) {
+	q := &query{}
+
+	if states != nil {
+		if len(states) == 0 {
+			q.where = append(q.where, "1 = 0")
+		} else {
+			placeholders := make([]string, len(states))
+			for i, s := range states {
+				placeholders[i] = q.arg(s)
+			}
+			q.where = append(q.where, "state IN ("+strings.Join(placeholders, ", ")+")")
+		}
+	}
+	if filter.Name != "" {
+		q.cond("name = %s", filter.Name)
+	}
+	if filter.Image != "" {
+		q.cond("image = %s", filter.Image)
+	}
+	if filter.Host != "" {
+		q.cond("hostname = %s", filter.Host)
+	}
+	if filter.Labels != "" {
+		for _, sel := range strings.Split(filter.Labels, ",") {
+			kv := strings.SplitN(sel, "=", 2)
+			if len(kv) == 2 {
+				q.cond("EXISTS (SELECT 1 FROM task_labels l WHERE l.task_id = tasks.id AND l.name = %s AND l.value = %s)", kv[0], kv[1])
+			} else {
+				q.cond("EXISTS (SELECT 1 FROM task_labels l WHERE l.task_id = tasks.id AND l.name = %s)", kv[0])
+			}
+		}
+	}
+	if filter.CreatedAfter > 0 {
+		q.cond("created_at >= %s", filter.CreatedAfter)
+	}
+	if filter.CreatedBefore > 0 {
+		q.cond("created_at < %s", filter.CreatedBefore)
+	}
+	if filter.UpdatedAfter > 0 {
+		q.cond("updated_at >= %s", filter.UpdatedAfter)
+	}
+	if filter.UpdatedBefore > 0 {
+		q.cond("updated_at < %s", filter.UpdatedBefore)
+	}
+
+	column := "created_at"
+	if filter.SortField() == eremetic.SortUpdated {
+		column = "updated_at"
+	}
+	op, dir := ">", "ASC"
+	if filter.Order == eremetic.OrderDesc {
+		op, dir = "<", "DESC"
+	}
+
+	if filter.Cursor != "" {
+		key, id, err := filter.CursorPosition()
+		if err != nil {
+			return nil, err
+		}
+		k, i := q.arg(key), q.arg(id)
+		q.where = append(q.where, fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s))", column, op, k, column, k, op, i))
+	}
+
+	q.WriteString("SELECT id, data FROM tasks")
+	if len(q.where) > 0 {
+		q.WriteString(" WHERE " + strings.Join(q.where, " AND "))
+	}
+	fmt.Fprintf(q, " ORDER BY %s %s, id %s", column, dir, dir)
+	if filter.Limit > 0 {
+		fmt.Fprintf(q, " LIMIT %d", filter.Limit)
+	}
+	return q, nil
+}
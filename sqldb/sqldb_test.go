@@ -0,0 +1,332 @@
+package sqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+func TestSQLDatabase(t *testing.T) {
+	var (
+		dir string
+		db  *TaskDB
+	)
+
+	setup := func() {
+		dir, _ = ioutil.TempDir("", "eremetic")
+		adb, err := NewTaskDB("sqlite3://" + filepath.Join(dir, "test.db"))
+		So(err, ShouldBeNil)
+		db = adb
+	}
+
+	teardown := func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+
+	count := func(query string, args ...interface{}) int {
+		var n int
+		So(db.conn.QueryRow(query, args...).Scan(&n), ShouldBeNil)
+		return n
+	}
+
+	task := func(id string, created int64, state eremetic.TaskState) *eremetic.Task {
+		return &eremetic.Task{
+			ID:                id,
+			Name:              "etl",
+			Image:             "busybox",
+			Environment:       map[string]string{"MODE": "nightly"},
+			MaskedEnvironment: map[string]string{"TOKEN": "secret"},
+			Labels:            map[string]string{"team": "data"},
+			Ports:             []eremetic.Port{{Name: "http", ContainerPort: 80, HostPort: 31000, Protocol: "tcp"}},
+			Status: []eremetic.Status{
+				{Time: created, Status: eremetic.TaskQueued},
+				{Time: created + 10, Status: state},
+			},
+		}
+	}
+
+	Convey("NewTaskDB", t, func() {
+		Convey("Rejects unknown locations", func() {
+			_, err := NewTaskDB("mysql://localhost/eremetic")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Migrates the schema once", func() {
+			setup()
+			defer teardown()
+
+			So(count(`SELECT COUNT(*) FROM schema_migrations`), ShouldEqual, len(migrations))
+
+			db.Close()
+			adb, err := NewTaskDB("sqlite3://" + filepath.Join(dir, "test.db"))
+			So(err, ShouldBeNil)
+			db = adb
+			So(count(`SELECT COUNT(*) FROM schema_migrations`), ShouldEqual, len(migrations))
+		})
+	})
+
+	Convey("Tasks", t, func() {
+		setup()
+		defer teardown()
+
+		t1 := task("eremetic-task.1", 100, eremetic.TaskRunning)
+		So(db.PutTask(t1), ShouldBeNil)
+
+		Convey("ReadUnmaskedTask", func() {
+			read, err := db.ReadUnmaskedTask(t1.ID)
+			So(err, ShouldBeNil)
+			So(read, ShouldResemble, *t1)
+		})
+
+		Convey("ReadTask masks the environment", func() {
+			read, err := db.ReadTask(t1.ID)
+			So(err, ShouldBeNil)
+			So(read.MaskedEnvironment["TOKEN"], ShouldEqual, eremetic.Masking)
+		})
+
+		Convey("ReadTask of an unknown task", func() {
+			_, err := db.ReadTask("unknown")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Details are written to their tables", func() {
+			So(count(`SELECT COUNT(*) FROM task_status WHERE task_id = $1`, t1.ID), ShouldEqual, 2)
+			So(count(`SELECT COUNT(*) FROM task_labels WHERE name = 'team' AND value = 'data'`), ShouldEqual, 1)
+			So(count(`SELECT COUNT(*) FROM task_ports WHERE host_port = 31000 AND name = 'http'`), ShouldEqual, 1)
+			So(count(`SELECT COUNT(*) FROM task_env WHERE masked AND value IS NULL`), ShouldEqual, 1)
+
+			t1.UpdateStatus(eremetic.Status{Time: 200, Status: eremetic.TaskFinished})
+			So(db.PutTask(t1), ShouldBeNil)
+			So(count(`SELECT COUNT(*) FROM task_status WHERE task_id = $1`, t1.ID), ShouldEqual, 3)
+			So(count(`SELECT COUNT(*) FROM tasks WHERE state = 'TASK_FINISHED'`), ShouldEqual, 1)
+		})
+
+		Convey("UpdateTask", func() {
+			err := db.UpdateTask(t1.ID, func(t *eremetic.Task) error {
+				t.UpdateStatus(eremetic.Status{Time: 200, Status: eremetic.TaskFinished})
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(count(`SELECT COUNT(*) FROM tasks WHERE state = 'TASK_FINISHED' AND revision = 1`), ShouldEqual, 1)
+			So(count(`SELECT COUNT(*) FROM task_status WHERE task_id = $1`, t1.ID), ShouldEqual, 3)
+
+			read, _ := db.ReadTask(t1.ID)
+			So(read.Revision, ShouldEqual, 1)
+		})
+
+		Convey("UpdateTask retries when the revision changed", func() {
+			calls := 0
+			err := db.UpdateTask(t1.ID, func(t *eremetic.Task) error {
+				calls++
+				if calls == 1 {
+					_, err := db.conn.Exec(`UPDATE tasks SET revision = 5 WHERE id = $1`, t1.ID)
+					So(err, ShouldBeNil)
+				}
+				t.Hostname = "agent1"
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+			So(count(`SELECT COUNT(*) FROM tasks WHERE hostname = 'agent1' AND revision = 6`), ShouldEqual, 1)
+		})
+
+		Convey("UpdateTask creates missing tasks", func() {
+			err := db.UpdateTask("eremetic-task.2", func(t *eremetic.Task) error {
+				t.Name = "new"
+				return nil
+			})
+			So(err, ShouldBeNil)
+
+			read, err := db.ReadTask("eremetic-task.2")
+			So(err, ShouldBeNil)
+			So(read.Name, ShouldEqual, "new")
+		})
+
+		Convey("DeleteTask", func() {
+			So(db.DeleteTask(t1.ID), ShouldBeNil)
+			So(count(`SELECT COUNT(*) FROM tasks`), ShouldEqual, 0)
+			So(count(`SELECT COUNT(*) FROM task_status`), ShouldEqual, 0)
+		})
+
+		Convey("Clean", func() {
+			So(db.Clean(), ShouldBeNil)
+			So(count(`SELECT COUNT(*) FROM tasks`), ShouldEqual, 0)
+			So(count(`SELECT COUNT(*) FROM task_labels`), ShouldEqual, 0)
+		})
+	})
+
+	Convey("ListTasks", t, func() {
+		setup()
+		defer teardown()
+
+		for i := 0; i < 5; i++ {
+			t := task(fmt.Sprintf("eremetic-task.%d", i), int64(100+i), eremetic.TaskRunning)
+			if i%2 == 1 {
+				t.Labels["team"] = "web"
+				t.UpdateStatus(eremetic.Status{Time: int64(200 + i), Status: eremetic.TaskFailed})
+			}
+			db.PutTask(t)
+		}
+
+		ids := func(filter *eremetic.TaskFilter) []string {
+			tasks, err := db.ListTasks(filter)
+			So(err, ShouldBeNil)
+			res := []string{}
+			for _, t := range tasks {
+				res = append(res, t.ID)
+			}
+			return res
+		}
+
+		Convey("Filters by state groups and exact states", func() {
+			So(ids(&eremetic.TaskFilter{State: eremetic.DefaultTaskFilterState}), ShouldResemble, []string{
+				"eremetic-task.0", "eremetic-task.2", "eremetic-task.4",
+			})
+			So(ids(&eremetic.TaskFilter{State: "TASK_FAILED"}), ShouldResemble, []string{
+				"eremetic-task.1", "eremetic-task.3",
+			})
+			So(ids(&eremetic.TaskFilter{State: "TASK_LOST"}), ShouldBeEmpty)
+		})
+
+		Convey("Filters by labels, name and time", func() {
+			So(ids(&eremetic.TaskFilter{Labels: "team=web"}), ShouldResemble, []string{
+				"eremetic-task.1", "eremetic-task.3",
+			})
+			So(ids(&eremetic.TaskFilter{Labels: "team,owner"}), ShouldBeEmpty)
+			So(ids(&eremetic.TaskFilter{Name: "etl", CreatedAfter: 103}), ShouldResemble, []string{
+				"eremetic-task.3", "eremetic-task.4",
+			})
+			So(ids(&eremetic.TaskFilter{UpdatedBefore: 200}), ShouldResemble, []string{
+				"eremetic-task.0", "eremetic-task.2", "eremetic-task.4",
+			})
+		})
+
+		Convey("Skips undecodable tasks", func() {
+			_, err := db.conn.Exec(`UPDATE tasks SET data = '{"ID": ' WHERE id = 'eremetic-task.2'`)
+			So(err, ShouldBeNil)
+			So(ids(&eremetic.TaskFilter{State: eremetic.DefaultTaskFilterState}), ShouldResemble, []string{
+				"eremetic-task.0", "eremetic-task.4",
+			})
+		})
+
+		Convey("Pages in the database", func() {
+			filter := &eremetic.TaskFilter{Sort: eremetic.SortUpdated, Order: eremetic.OrderDesc, Limit: 2}
+			So(ids(filter), ShouldResemble, []string{"eremetic-task.3", "eremetic-task.1"})
+
+			tasks, _ := db.ListTasks(filter)
+			filter.Cursor = filter.CursorAfter(tasks[1])
+			So(ids(filter), ShouldResemble, []string{"eremetic-task.4", "eremetic-task.2"})
+		})
+	})
+
+	Convey("Quotas", t, func() {
+		setup()
+		defer teardown()
+
+		So(db.PutQuota(&eremetic.Quota{Name: "data", Owner: "alice"}), ShouldBeNil)
+		So(db.PutQuota(&eremetic.Quota{Name: "data", Owner: "bob"}), ShouldBeNil)
+
+		quota, err := db.ReadQuota("data")
+		So(err, ShouldBeNil)
+		So(quota.Owner, ShouldEqual, "bob")
+
+		quotas, err := db.ListQuotas()
+		So(err, ShouldBeNil)
+		So(quotas, ShouldHaveLength, 1)
+
+		So(db.DeleteQuota("data"), ShouldBeNil)
+		_, err = db.ReadQuota("data")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Templates", t, func() {
+		setup()
+		defer teardown()
+
+		So(db.PutTemplate(&eremetic.Template{Name: "etl", Version: 1}), ShouldBeNil)
+		So(db.PutTemplate(&eremetic.Template{Name: "etl", Version: 2}), ShouldBeNil)
+
+		template, err := db.ReadTemplate("etl")
+		So(err, ShouldBeNil)
+		So(template.Version, ShouldEqual, 2)
+
+		templates, err := db.ListTemplates()
+		So(err, ShouldBeNil)
+		So(templates, ShouldHaveLength, 1)
+
+		So(db.DeleteTemplate("etl"), ShouldBeNil)
+		_, err = db.ReadTemplate("etl")
+		So(err, ShouldNotBeNil)
+
+		Convey("UpdateTemplate retries when the template changed", func() {
+			So(db.UpdateTemplate("etl", func(t *eremetic.Template) error {
+				t.Revise(eremetic.Request{DockerImage: "etl:1.0"})
+				return nil
+			}), ShouldBeNil)
+
+			calls := 0
+			err := db.UpdateTemplate("etl", func(t *eremetic.Template) error {
+				calls++
+				if calls == 1 {
+					// Another instance updates the template in between.
+					So(db.PutTemplate(&eremetic.Template{Name: "etl", Version: 5}), ShouldBeNil)
+				}
+				t.Revise(eremetic.Request{DockerImage: "etl:2.0"})
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+
+			template, _ := db.ReadTemplate("etl")
+			So(template.Version, ShouldEqual, 6)
+			So(count(`SELECT COUNT(*) FROM templates WHERE version = 6`), ShouldEqual, 1)
+		})
+	})
+
+	Convey("Framework ID", t, func() {
+		setup()
+		defer teardown()
+
+		id, err := db.ReadFrameworkID()
+		So(err, ShouldBeNil)
+		So(id, ShouldBeEmpty)
+
+		So(db.PutFrameworkID("1234-0001"), ShouldBeNil)
+		So(db.PutFrameworkID("1234-0002"), ShouldBeNil)
+		id, err = db.ReadFrameworkID()
+		So(err, ShouldBeNil)
+		So(id, ShouldEqual, "1234-0002")
+
+		So(db.PutFrameworkID(""), ShouldBeNil)
+		id, err = db.ReadFrameworkID()
+		So(err, ShouldBeNil)
+		So(id, ShouldBeEmpty)
+	})
+
+	Convey("ScanTasks", t, func() {
+		setup()
+		defer teardown()
+
+		db.PutTask(&eremetic.Task{ID: "eremetic-task.2"})
+		db.PutTask(&eremetic.Task{ID: "eremetic-task.1"})
+
+		ids := []string{}
+		err := db.ScanTasks(func(id string, data []byte) error {
+			var task eremetic.Task
+			So(json.Unmarshal(data, &task), ShouldBeNil)
+			So(task.ID, ShouldEqual, id)
+			ids = append(ids, id)
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(ids, ShouldResemble, []string{"eremetic-task.1", "eremetic-task.2"})
+	})
+}
@@ -0,0 +1,126 @@
+package eremetic
+
+import (
+	"fmt"
+	"sync"
+)
+
+type stateInfo struct {
+	terminal bool
+	active   bool
+	// next are the states a task may move to, besides the terminal ones.
+	next []TaskState
+}
+
+// states is the state machine of a task. Tasks are queued by Eremetic, and
+// Mesos reports their state once they are launched. Nothing follows a
+// terminal state, but failed and lost tasks may be queued again.
+var states = map[TaskState]stateInfo{
+	TaskQueued: {next: []TaskState{TaskStaging, TaskTerminating, TaskLost}},
+
+	TaskStaging:     {active: true, next: launchedStates(TaskStarting, TaskRunning)},
+	TaskStarting:    {active: true, next: launchedStates(TaskRunning)},
+	TaskRunning:     {active: true, next: launchedStates()},
+	TaskKilling:     {active: true, next: launchedStates()},
+	TaskTerminating: {active: true, next: launchedStates()},
+	TaskUnreachable: {active: true, next: launchedStates(TaskStarting, TaskRunning)},
+	TaskUnknown:     {active: true, next: launchedStates(TaskStarting, TaskRunning)},
+
+	TaskFinished:       {terminal: true},
+	TaskFailed:         {terminal: true, next: []TaskState{TaskQueued}},
+	TaskKilled:         {terminal: true},
+	TaskLost:           {terminal: true, next: []TaskState{TaskQueued}},
+	TaskError:          {terminal: true},
+	TaskDropped:        {terminal: true},
+	TaskGone:           {terminal: true},
+	TaskGoneByOperator: {terminal: true},
+}
+
+// launchedStates returns the states a launched task may move to: it may be
+// killed or lose contact with Eremetic at any time.
+func launchedStates(next ...TaskState) []TaskState {
+	return append(next, TaskKilling, TaskTerminating, TaskUnreachable, TaskUnknown)
+}
+
+// IsKnownState returns whether the state is part of the state machine.
+func IsKnownState(state TaskState) bool {
+	_, ok := states[state]
+	return ok
+}
+
+// CanTransition returns whether a task in the state from may move to the
+// state to. New tasks, with an empty state, start queued. A non terminal
+// state may be repeated.
+func CanTransition(from, to TaskState) bool {
+	if from == "" {
+		return to == TaskQueued
+	}
+	info, ok := states[from]
+	if !ok || !IsKnownState(to) {
+		return false
+	}
+	if from == to {
+		return !info.terminal
+	}
+	if states[to].terminal && info.active {
+		return true
+	}
+	for _, s := range info.next {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidTransitionError is returned when a task can't move to a state.
+type InvalidTransitionError struct {
+	TaskID string
+	From   TaskState
+	To     TaskState
+}
+
+func (e *InvalidTransitionError) Error() string {
+	from := e.From
+	if from == "" {
+		from = "no state"
+	}
+	return fmt.Sprintf("task %s can't go from %s to %s", e.TaskID, from, e.To)
+}
+
+// Transition is a change of the state of a task.
+type Transition struct {
+	From TaskState
+	To   TaskState
+	Time int64
+}
+
+// TransitionHook is called with a task after it changed state, once the task
+// has been stored.
+type TransitionHook func(task *Task, t Transition)
+
+var transitionHooks struct {
+	sync.RWMutex
+	hooks []TransitionHook
+}
+
+// OnTransition registers a hook called on every transition of a task.
+func OnTransition(hook TransitionHook) {
+	transitionHooks.Lock()
+	defer transitionHooks.Unlock()
+	transitionHooks.hooks = append(transitionHooks.hooks, hook)
+}
+
+// NotifyTransitions calls the transition hooks with each transition, in
+// order.
+func NotifyTransitions(task *Task, transitions []Transition) {
+	transitionHooks.RLock()
+	hooks := transitionHooks.hooks
+	transitionHooks.RUnlock()
+
+	for _, t := range transitions {
+		for _, hook := range hooks {
+			hook(task, t)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package eremetic
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestState(t *testing.T) {
+	Convey("CanTransition", t, func() {
+		Convey("Tasks start queued", func() {
+			So(CanTransition("", TaskQueued), ShouldBeTrue)
+			So(CanTransition("", TaskRunning), ShouldBeFalse)
+		})
+
+		Convey("Queued tasks are launched or killed", func() {
+			So(CanTransition(TaskQueued, TaskStaging), ShouldBeTrue)
+			So(CanTransition(TaskQueued, TaskTerminating), ShouldBeTrue)
+			So(CanTransition(TaskQueued, TaskRunning), ShouldBeFalse)
+			So(CanTransition(TaskQueued, TaskFinished), ShouldBeFalse)
+		})
+
+		Convey("Launched tasks may end at any time", func() {
+			for _, from := range []TaskState{TaskStaging, TaskStarting, TaskRunning, TaskKilling, TaskTerminating, TaskUnreachable, TaskUnknown} {
+				for _, to := range []TaskState{TaskFinished, TaskFailed, TaskKilled, TaskLost, TaskError, TaskDropped, TaskGone, TaskGoneByOperator} {
+					So(CanTransition(from, to), ShouldBeTrue)
+				}
+				So(CanTransition(from, TaskUnreachable), ShouldBeTrue)
+				So(CanTransition(from, TaskQueued), ShouldBeFalse)
+			}
+		})
+
+		Convey("Unreachable tasks may come back", func() {
+			So(CanTransition(TaskUnreachable, TaskRunning), ShouldBeTrue)
+			So(CanTransition(TaskTerminating, TaskRunning), ShouldBeFalse)
+		})
+
+		Convey("Nothing follows a terminal state but a retry", func() {
+			So(CanTransition(TaskFailed, TaskQueued), ShouldBeTrue)
+			So(CanTransition(TaskLost, TaskQueued), ShouldBeTrue)
+			So(CanTransition(TaskFinished, TaskQueued), ShouldBeFalse)
+			So(CanTransition(TaskLost, TaskRunning), ShouldBeFalse)
+			So(CanTransition(TaskKilled, TaskKilled), ShouldBeFalse)
+		})
+
+		Convey("Non terminal states may be repeated", func() {
+			So(CanTransition(TaskRunning, TaskRunning), ShouldBeTrue)
+		})
+
+		Convey("Unknown states are rejected", func() {
+			So(CanTransition(TaskRunning, "TASK_SLEEPING"), ShouldBeFalse)
+			So(CanTransition("TASK_SLEEPING", TaskLost), ShouldBeFalse)
+		})
+	})
+
+	Convey("UpdateStatus", t, func() {
+		task := Task{ID: "eremetic-task.1"}
+		So(task.UpdateStatus(Status{Time: 1, Status: TaskQueued}), ShouldBeNil)
+		So(task.UpdateStatus(Status{Time: 2, Status: TaskStaging}), ShouldBeNil)
+
+		Convey("Ignores repeated states", func() {
+			So(task.UpdateStatus(Status{Time: 3, Status: TaskStaging}), ShouldBeNil)
+			So(task.Status, ShouldHaveLength, 2)
+		})
+
+		Convey("Rejects invalid transitions", func() {
+			err := task.UpdateStatus(Status{Time: 3, Status: TaskQueued})
+
+			So(err, ShouldResemble, &InvalidTransitionError{TaskID: "eremetic-task.1", From: TaskStaging, To: TaskQueued})
+			So(err.Error(), ShouldEqual, "task eremetic-task.1 can't go from TASK_STAGING to TASK_QUEUED")
+			So(task.Status, ShouldHaveLength, 2)
+		})
+
+		Convey("Records transitions", func() {
+			task.UpdateStatus(Status{Time: 3, Status: TaskFailed})
+			task.UpdateStatus(Status{Time: 3, Status: TaskQueued})
+
+			So(task.TransitionsSince(2), ShouldResemble, []Transition{
+				{From: TaskStaging, To: TaskFailed, Time: 3},
+				{From: TaskFailed, To: TaskQueued, Time: 3},
+			})
+			So(task.TransitionsSince(0)[0], ShouldResemble, Transition{To: TaskQueued, Time: 1})
+			So(task.TransitionsSince(4), ShouldBeEmpty)
+		})
+	})
+
+	Convey("NotifyTransitions", t, func() {
+		var seen []Transition
+		OnTransition(func(task *Task, t Transition) {
+			if task.ID == "eremetic-task.hooked" {
+				seen = append(seen, t)
+			}
+		})
+
+		task := Task{ID: "eremetic-task.hooked"}
+		transitions := []Transition{
+			{From: TaskStaging, To: TaskFailed},
+			{From: TaskFailed, To: TaskQueued},
+		}
+		NotifyTransitions(&task, transitions)
+
+		So(seen, ShouldResemble, transitions)
+	})
+}
@@ -14,14 +14,20 @@ type TaskState string
 // Valid task states
 const (
 	// Standard mesos states
-	TaskStaging  TaskState = "TASK_STAGING"
-	TaskStarting TaskState = "TASK_STARTING"
-	TaskRunning  TaskState = "TASK_RUNNING"
-	TaskFinished TaskState = "TASK_FINISHED"
-	TaskFailed   TaskState = "TASK_FAILED"
-	TaskKilled   TaskState = "TASK_KILLED"
-	TaskLost     TaskState = "TASK_LOST"
-	TaskError    TaskState = "TASK_ERROR"
+	TaskStaging        TaskState = "TASK_STAGING"
+	TaskStarting       TaskState = "TASK_STARTING"
+	TaskRunning        TaskState = "TASK_RUNNING"
+	TaskKilling        TaskState = "TASK_KILLING"
+	TaskFinished       TaskState = "TASK_FINISHED"
+	TaskFailed         TaskState = "TASK_FAILED"
+	TaskKilled         TaskState = "TASK_KILLED"
+	TaskLost           TaskState = "TASK_LOST"
+	TaskError          TaskState = "TASK_ERROR"
+	TaskDropped        TaskState = "TASK_DROPPED"
+	TaskUnreachable    TaskState = "TASK_UNREACHABLE"
+	TaskGone           TaskState = "TASK_GONE"
+	TaskGoneByOperator TaskState = "TASK_GONE_BY_OPERATOR"
+	TaskUnknown        TaskState = "TASK_UNKNOWN"
 
 	// Custom eremetic states
 	TaskQueued      TaskState = "TASK_QUEUED"
@@ -31,12 +37,7 @@ const (
 // IsTerminal takes a string representation of a state and returns whether it
 // is terminal or not.
 func IsTerminal(state TaskState) bool {
-	switch state {
-	case "TASK_LOST", "TASK_KILLED", "TASK_FAILED", "TASK_FINISHED":
-		return true
-	default:
-		return false
-	}
+	return states[state].terminal
 }
 
 func (s TaskState) String() string {
@@ -46,12 +47,7 @@ func (s TaskState) String() string {
 // IsActive takes a string representation of a state and returns whether it
 // is active or not.
 func IsActive(state TaskState) bool {
-	switch state {
-	case "TASK_STAGING", "TASK_STARTING", "TASK_RUNNING", "TASK_ERROR", "TASK_TERMINATING":
-		return true
-	default:
-		return false
-	}
+	return states[state].active
 }
 
 // IsEnqueued takes a string representation of a state and returns whether it
@@ -78,11 +74,17 @@ type Volume struct {
 	HostPath      string `json:"host_path"`
 }
 
-// Port defines a port mapping.
+// Port defines a port mapping. A host port of 0 is picked from the ports
+// offered by the agent, and set once the task is launched.
 type Port struct {
+	// Name exposes the host port as PORT_<NAME> in the environment.
+	Name          string `json:"name,omitempty"`
 	ContainerPort uint32 `json:"container_port"`
 	HostPort      uint32 `json:"host_port"`
 	Protocol      string `json:"protocol"`
+	// Dynamic is set when the host port was picked from an offer, for
+	// another one to be picked when the task is launched again.
+	Dynamic bool `json:"dynamic,omitempty"`
 }
 
 // AgentConstraint is a constraint that is validated for each agent when
@@ -131,12 +133,48 @@ type Task struct {
 	ForcePullImage    bool
 	Privileged        bool
 	FetchURIs         []URI
+	Owner             string
+	Template          string
+	TemplateVersion   int
+	// Restartable tasks may be killed and launched again elsewhere before
+	// the maintenance of their agent.
+	Restartable bool
+	// Maintenance is the upcoming maintenance of the agent of the task.
+	Maintenance *Maintenance
+	// Draining is set while the task is killed ahead of the maintenance of
+	// its agent, for it to be queued again once the kill is reported.
+	Draining bool
+	// Revision is incremented by every UpdateTask, which compares it to
+	// detect concurrent updates.
+	Revision int64
 }
 
 // TaskFilter represents the query param state
 type TaskFilter struct {
 	Name  string `schema:"name"`
 	State string `schema:"state"`
+
+	// Labels is a comma separated list of label selectors, either `key=value`
+	// or `key` to only require the label to be present.
+	Labels string `schema:"labels"`
+	Image  string `schema:"image"`
+	Host   string `schema:"host"`
+
+	// Time ranges in seconds since epoch. Zero means unbounded.
+	CreatedAfter  int64 `schema:"created_after"`
+	CreatedBefore int64 `schema:"created_before"`
+	UpdatedAfter  int64 `schema:"updated_after"`
+	UpdatedBefore int64 `schema:"updated_before"`
+
+	// Sort is either `created` (default) or `updated`, Order `asc` (default)
+	// or `desc`.
+	Sort  string `schema:"sort"`
+	Order string `schema:"order"`
+
+	// Limit restricts the number of tasks returned, zero means no limit.
+	// Cursor continues a listing after the last task of a previous page.
+	Limit  int    `schema:"limit"`
+	Cursor string `schema:"cursor"`
 }
 
 // Possible states for the TaskFilter. And the default state
@@ -201,6 +239,10 @@ type Request struct {
 	Fetch             []URI
 	ForcePullImage    bool
 	Privileged        bool
+	Owner             string
+	Template          string
+	TemplateVersion   int
+	Restartable       bool
 }
 
 // NewTask returns a new instance of a Task.
@@ -237,6 +279,10 @@ func NewTask(request Request) (Task, error) {
 		ForcePullImage:    request.ForcePullImage,
 		Privileged:        request.Privileged,
 		FetchURIs:         mergeURIs(request),
+		Owner:             request.Owner,
+		Template:          request.Template,
+		TemplateVersion:   request.TemplateVersion,
+		Restartable:       request.Restartable,
 	}
 	return task, nil
 }
@@ -288,6 +334,15 @@ func (task *Task) CurrentStatus() TaskState {
 	return task.Status[len(task.Status)-1].Status
 }
 
+// CreatedAt returns the time of the first status update, which is when the
+// task was queued.
+func (task *Task) CreatedAt() time.Time {
+	if len(task.Status) == 0 {
+		return time.Unix(0, 0)
+	}
+	return time.Unix(task.Status[0].Time, 0)
+}
+
 // LastUpdated returns the time of the latest status update.
 func (task *Task) LastUpdated() time.Time {
 	if len(task.Status) == 0 {
@@ -297,9 +352,33 @@ func (task *Task) LastUpdated() time.Time {
 	return time.Unix(st.Time, 0)
 }
 
-// UpdateStatus updates the current task status.
-func (task *Task) UpdateStatus(status Status) {
+// UpdateStatus moves the task to the state of the status. A status
+// repeating the current state is ignored, and an *InvalidTransitionError is
+// returned when the task can't move to the state.
+func (task *Task) UpdateStatus(status Status) error {
+	current := task.CurrentStatus()
+	if len(task.Status) > 0 && status.Status == current {
+		return nil
+	}
+	if !CanTransition(current, status.Status) {
+		return &InvalidTransitionError{TaskID: task.ID, From: current, To: status.Status}
+	}
 	task.Status = append(task.Status, status)
+	return nil
+}
+
+// TransitionsSince returns the transitions of the task after its first n
+// statuses.
+func (task *Task) TransitionsSince(n int) []Transition {
+	var transitions []Transition
+	for i := n; i < len(task.Status); i++ {
+		t := Transition{To: task.Status[i].Status, Time: task.Status[i].Time}
+		if i > 0 {
+			t.From = task.Status[i-1].Status
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions
 }
 
 // Match the conditions of TaskFilter with the current task
@@ -314,6 +393,42 @@ func (filter TaskFilter) Match(task *Task) bool {
 			return false
 		}
 	}
+	if len(filter.Image) > 0 && filter.Image != task.Image {
+		return false
+	}
+	if len(filter.Host) > 0 && filter.Host != task.Hostname {
+		return false
+	}
+	if len(filter.Labels) > 0 && !taskHasLabels(task, filter.Labels) {
+		return false
+	}
+	if !inRange(task.CreatedAt().Unix(), filter.CreatedAfter, filter.CreatedBefore) {
+		return false
+	}
+	if !inRange(task.LastUpdated().Unix(), filter.UpdatedAfter, filter.UpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+func inRange(t, after, before int64) bool {
+	if after > 0 && t < after {
+		return false
+	}
+	if before > 0 && t >= before {
+		return false
+	}
+	return true
+}
+
+func taskHasLabels(task *Task, selectors string) bool {
+	for _, sel := range strings.Split(selectors, ",") {
+		kv := strings.SplitN(sel, "=", 2)
+		v, ok := task.Labels[kv[0]]
+		if !ok || (len(kv) == 2 && v != kv[1]) {
+			return false
+		}
+	}
 	return true
 }
 func taskHasAnyState(task *Task, states string) bool {
@@ -327,7 +442,7 @@ func taskHasAnyState(task *Task, states string) bool {
 		case "queued":
 			result = result || task.IsEnqueued()
 		default:
-			result = result || false
+			result = result || string(task.CurrentStatus()) == state
 		}
 	}
 	return result
@@ -335,13 +335,19 @@ func TestTask(t *testing.T) {
 			TaskFailed,
 			TaskKilled,
 			TaskLost,
+			TaskError,
+			TaskDropped,
+			TaskGone,
+			TaskGoneByOperator,
 		}
 
 		activeStates := []TaskState{
 			TaskStaging,
 			TaskStarting,
 			TaskRunning,
-			TaskError,
+			TaskKilling,
+			TaskUnreachable,
+			TaskUnknown,
 			TaskTerminating,
 		}
 
@@ -460,9 +466,10 @@ func TestTask(t *testing.T) {
 			So(task.IsTerminated(), ShouldBeTrue)
 		})
 
-		task.UpdateStatus(Status{0, "TASK_FINISHED"})
-		Convey("TASK_FINISHED should be in terminated state", func() {
-			So(task.IsTerminated(), ShouldBeTrue)
+		err := task.UpdateStatus(Status{0, "TASK_FINISHED"})
+		Convey("TASK_FINISHED should not follow a terminal state", func() {
+			So(err, ShouldResemble, &InvalidTransitionError{From: TaskFailed, To: TaskFinished})
+			So(task.CurrentStatus(), ShouldEqual, TaskFailed)
 		})
 	})
 
@@ -520,5 +527,33 @@ func TestTask(t *testing.T) {
 			So(taskFilter.Match(&task), ShouldBeFalse)
 		})
 
+		Convey("Match exact state", func() {
+			So((&TaskFilter{State: "TASK_FINISHED"}).Match(&task), ShouldBeTrue)
+			So((&TaskFilter{State: "TASK_FAILED"}).Match(&task), ShouldBeFalse)
+		})
+
+		Convey("Match Labels", func() {
+			task.Labels = map[string]string{"team": "data", "env": "prod"}
+			So((&TaskFilter{State: TerminatedState, Labels: "team=data"}).Match(&task), ShouldBeTrue)
+			So((&TaskFilter{State: TerminatedState, Labels: "team=data,env"}).Match(&task), ShouldBeTrue)
+			So((&TaskFilter{State: TerminatedState, Labels: "team=web"}).Match(&task), ShouldBeFalse)
+			So((&TaskFilter{State: TerminatedState, Labels: "owner"}).Match(&task), ShouldBeFalse)
+		})
+
+		Convey("Match Image and Host", func() {
+			task.Image = "busybox"
+			task.Hostname = "agent-1"
+			So((&TaskFilter{State: TerminatedState, Image: "busybox", Host: "agent-1"}).Match(&task), ShouldBeTrue)
+			So((&TaskFilter{State: TerminatedState, Image: "alpine"}).Match(&task), ShouldBeFalse)
+			So((&TaskFilter{State: TerminatedState, Host: "agent-2"}).Match(&task), ShouldBeFalse)
+		})
+
+		Convey("Match time ranges", func() {
+			So((&TaskFilter{State: TerminatedState, CreatedAfter: 0, CreatedBefore: 1}).Match(&task), ShouldBeTrue)
+			So((&TaskFilter{State: TerminatedState, CreatedAfter: 1}).Match(&task), ShouldBeFalse)
+			So((&TaskFilter{State: TerminatedState, UpdatedAfter: 2}).Match(&task), ShouldBeTrue)
+			So((&TaskFilter{State: TerminatedState, UpdatedBefore: 2}).Match(&task), ShouldBeFalse)
+		})
+
 	})
 }
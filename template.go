@@ -0,0 +1,76 @@
+package eremetic
+
+// Template is a named task request that new tasks can be created from.
+// The version is increased every time the template is changed, and the
+// prior versions are kept in the history.
+type Template struct {
+	Name    string
+	Version int
+	Request Request
+	// History holds the prior versions of the template, oldest first.
+	History []TemplateVersion
+}
+
+// TemplateVersion is a prior version of a template.
+type TemplateVersion struct {
+	Version int
+	Request Request
+}
+
+// Revise replaces the request of the template with a new version, keeping
+// the current one in the history. Masked environment variables holding the
+// masking string keep their current value, for templates that were read
+// through the API to be written back.
+func (t *Template) Revise(request Request) {
+	for k, v := range request.MaskedEnvironment {
+		if current, ok := t.Request.MaskedEnvironment[k]; ok && v == Masking {
+			request.MaskedEnvironment[k] = current
+		}
+	}
+	if t.Version > 0 {
+		t.History = append(t.History, TemplateVersion{Version: t.Version, Request: t.Request})
+	}
+	t.Version++
+	t.Request = request
+}
+
+// At returns the request of the given version of the template.
+func (t *Template) At(version int) (Request, bool) {
+	if version == t.Version {
+		return t.Request, true
+	}
+	for _, v := range t.History {
+		if v.Version == version {
+			return v.Request, true
+		}
+	}
+	return Request{}, false
+}
+
+// ApplyTemplateMask replaces the masked environment variables of every
+// version of the template with a masking string.
+func ApplyTemplateMask(template *Template) {
+	template.Request.MaskedEnvironment = maskEnvironment(template.Request.MaskedEnvironment)
+	if len(template.History) == 0 {
+		return
+	}
+	history := make([]TemplateVersion, len(template.History))
+	for i, v := range template.History {
+		v.Request.MaskedEnvironment = maskEnvironment(v.Request.MaskedEnvironment)
+		history[i] = v
+	}
+	template.History = history
+}
+
+// maskEnvironment returns a masked copy of the environment, which the
+// stored templates may share.
+func maskEnvironment(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(env))
+	for k := range env {
+		masked[k] = Masking
+	}
+	return masked
+}
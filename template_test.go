@@ -0,0 +1,43 @@
+package eremetic
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTemplate(t *testing.T) {
+	Convey("Revise", t, func() {
+		template := Template{Name: "etl"}
+		template.Revise(Request{DockerImage: "etl:1.0", MaskedEnvironment: map[string]string{"TOKEN": "secret"}})
+		So(template.Version, ShouldEqual, 1)
+		So(template.History, ShouldBeEmpty)
+
+		template.Revise(Request{DockerImage: "etl:1.1", MaskedEnvironment: map[string]string{"TOKEN": Masking, "KEY": Masking}})
+		So(template.Version, ShouldEqual, 2)
+		So(template.History, ShouldHaveLength, 1)
+		So(template.History[0].Version, ShouldEqual, 1)
+		So(template.Request.MaskedEnvironment, ShouldResemble, map[string]string{"TOKEN": "secret", "KEY": Masking})
+
+		Convey("At", func() {
+			request, ok := template.At(1)
+			So(ok, ShouldBeTrue)
+			So(request.DockerImage, ShouldEqual, "etl:1.0")
+			request, ok = template.At(2)
+			So(ok, ShouldBeTrue)
+			So(request.DockerImage, ShouldEqual, "etl:1.1")
+			_, ok = template.At(3)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("ApplyTemplateMask", func() {
+			masked := template
+			ApplyTemplateMask(&masked)
+			So(masked.Request.MaskedEnvironment["TOKEN"], ShouldEqual, Masking)
+			So(masked.History[0].Request.MaskedEnvironment["TOKEN"], ShouldEqual, Masking)
+
+			So(template.Request.MaskedEnvironment["TOKEN"], ShouldEqual, "secret")
+			So(template.History[0].Request.MaskedEnvironment["TOKEN"], ShouldEqual, "secret")
+		})
+	})
+}
@@ -0,0 +1,212 @@
+// Package validation checks task requests before they are scheduled.
+//
+// Field names in errors refer to the json fields of the V1 API.
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// Networks lists the docker network modes accepted in a request.
+var Networks = []string{"BRIDGE", "HOST", "NONE", "USER"}
+
+// Protocols lists the port protocols accepted in a request.
+var Protocols = []string{"tcp", "udp"}
+
+const maxPort = 65535
+
+// FieldError describes a problem with a single field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Problem string `json:"problem"`
+}
+
+// Errors is a list of problems found in a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	problems := make([]string, len(e))
+	for i, f := range e {
+		problems[i] = fmt.Sprintf("%s: %s", f.Field, f.Problem)
+	}
+	return fmt.Sprintf("invalid request: %s", strings.Join(problems, "; "))
+}
+
+func (e *Errors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{
+		Field:   field,
+		Problem: fmt.Sprintf(format, args...),
+	})
+}
+
+// ValidateRequest checks a task request. It returns nil if the request is
+// valid, or Errors listing every problem found.
+func ValidateRequest(req eremetic.Request) error {
+	var errs Errors
+
+	if req.TaskCPUs <= 0 {
+		errs.add("cpu", "must be greater than 0")
+	}
+	if req.TaskMem <= 0 {
+		errs.add("mem", "must be greater than 0")
+	}
+
+	if req.DockerImage == "" {
+		errs.add("image", "is required")
+	} else if strings.ContainsAny(req.DockerImage, " \t\n") {
+		errs.add("image", "must not contain whitespace")
+	}
+
+	if req.Network != "" && !contains(Networks, req.Network) {
+		errs.add("network", "must be one of %s", strings.Join(Networks, ", "))
+	}
+
+	if req.DNS != "" && net.ParseIP(req.DNS) == nil {
+		errs.add("dns", "must be an IP address")
+	}
+
+	for i, v := range req.Volumes {
+		field := fmt.Sprintf("volumes[%d]", i)
+		if !path.IsAbs(v.ContainerPath) {
+			errs.add(field+".container_path", "must be an absolute path")
+		}
+		if !path.IsAbs(v.HostPath) {
+			errs.add(field+".host_path", "must be an absolute path")
+		}
+	}
+
+	for i, name := range req.VolumesFrom {
+		if strings.TrimSpace(name) == "" {
+			errs.add(fmt.Sprintf("volumes_from[%d]", i), "must not be empty")
+		}
+	}
+
+	names := make(map[string]bool)
+	hostPorts := make(map[uint32]bool)
+	for i, p := range req.Ports {
+		field := fmt.Sprintf("ports[%d]", i)
+		if p.Name != "" {
+			if !isPortName(p.Name) {
+				errs.add(field+".name", "must only contain letters, digits and underscores")
+			} else if names[strings.ToUpper(p.Name)] {
+				errs.add(field+".name", "duplicate name %q", p.Name)
+			}
+			names[strings.ToUpper(p.Name)] = true
+		}
+		if p.HostPort != 0 {
+			if hostPorts[p.HostPort] {
+				errs.add(field+".host_port", "duplicate host port %d", p.HostPort)
+			}
+			hostPorts[p.HostPort] = true
+		}
+		if p.ContainerPort > maxPort {
+			errs.add(field+".container_port", "must be at most %d", maxPort)
+		}
+		if p.HostPort > maxPort {
+			errs.add(field+".host_port", "must be at most %d", maxPort)
+		}
+		if p.Protocol != "" && !contains(Protocols, p.Protocol) {
+			errs.add(field+".protocol", "must be one of %s", strings.Join(Protocols, ", "))
+		}
+	}
+
+	for k := range req.Environment {
+		if !isEnvName(k) {
+			errs.add("env", "invalid variable name %q", k)
+		}
+	}
+	for k := range req.MaskedEnvironment {
+		if !isEnvName(k) {
+			errs.add("masked_env", "invalid variable name %q", k)
+		}
+	}
+
+	for k := range req.Labels {
+		if k == "" {
+			errs.add("labels", "label names must not be empty")
+		}
+	}
+
+	for i, c := range req.AgentConstraints {
+		if c.AttributeName == "" {
+			errs.add(fmt.Sprintf("agent_constraints[%d].attribute_name", i), "is required")
+		}
+	}
+
+	if req.CallbackURI != "" {
+		if problem := checkURL(req.CallbackURI, "http", "https"); problem != "" {
+			errs.add("callback_uri", "%s", problem)
+		}
+	}
+
+	for i, u := range req.URIs {
+		if problem := checkURL(u); problem != "" {
+			errs.add(fmt.Sprintf("uris[%d]", i), "%s", problem)
+		}
+	}
+	for i, u := range req.Fetch {
+		if problem := checkURL(u.URI); problem != "" {
+			errs.add(fmt.Sprintf("fetch[%d].uri", i), "%s", problem)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkURL returns a description of what is wrong with the url, or an empty
+// string. When schemes are given the url must use one of them, otherwise
+// absolute paths on the agent are accepted as well.
+func checkURL(raw string, schemes ...string) string {
+	if raw == "" {
+		return "is required"
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "must be a valid URL"
+	}
+	if u.Scheme == "" {
+		if len(schemes) == 0 && path.IsAbs(raw) {
+			return ""
+		}
+		return "must be an absolute URL"
+	}
+	if len(schemes) > 0 && !contains(schemes, u.Scheme) {
+		return fmt.Sprintf("scheme must be one of %s", strings.Join(schemes, ", "))
+	}
+	if u.Host == "" && u.Scheme != "file" {
+		return "must contain a host"
+	}
+	return ""
+}
+
+func isEnvName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "= \t\n")
+}
+
+// isPortName tells whether a port name can be exposed as PORT_<NAME>.
+func isPortName(name string) bool {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
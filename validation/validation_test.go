@@ -0,0 +1,145 @@
+package validation
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+func fields(err error) []string {
+	var res []string
+	for _, e := range err.(Errors) {
+		res = append(res, e.Field)
+	}
+	return res
+}
+
+func TestValidateRequest(t *testing.T) {
+	valid := func() eremetic.Request {
+		return eremetic.Request{
+			TaskCPUs:    0.5,
+			TaskMem:     128,
+			DockerImage: "busybox:latest",
+			Command:     "echo hello",
+		}
+	}
+
+	Convey("ValidateRequest", t, func() {
+		Convey("A minimal request is valid", func() {
+			So(ValidateRequest(valid()), ShouldBeNil)
+		})
+
+		Convey("A complete request is valid", func() {
+			req := valid()
+			req.Network = "HOST"
+			req.DNS = "10.0.0.1"
+			req.Volumes = []eremetic.Volume{{ContainerPath: "/data", HostPath: "/mnt/data"}}
+			req.VolumesFrom = []string{"data-container"}
+			req.Ports = []eremetic.Port{{ContainerPort: 8080, Protocol: "tcp"}, {}}
+			req.Environment = map[string]string{"FOO": "bar"}
+			req.MaskedEnvironment = map[string]string{"SECRET": "baz"}
+			req.AgentConstraints = []eremetic.AgentConstraint{{AttributeName: "rack", AttributeValue: "a"}}
+			req.CallbackURI = "https://example.com/callback"
+			req.URIs = []string{"/opt/file.tgz"}
+			req.Fetch = []eremetic.URI{{URI: "http://example.com/file.tgz"}}
+
+			So(ValidateRequest(req), ShouldBeNil)
+		})
+
+		Convey("Resources must be positive", func() {
+			req := valid()
+			req.TaskCPUs = -1
+			req.TaskMem = 0
+
+			So(fields(ValidateRequest(req)), ShouldResemble, []string{"cpu", "mem"})
+		})
+
+		Convey("An image is required", func() {
+			req := valid()
+			req.DockerImage = ""
+
+			err := ValidateRequest(req)
+			So(err, ShouldResemble, Errors{{Field: "image", Problem: "is required"}})
+			So(err.Error(), ShouldEqual, "invalid request: image: is required")
+		})
+
+		Convey("Unknown networks are rejected", func() {
+			req := valid()
+			req.Network = "bridge"
+
+			So(fields(ValidateRequest(req)), ShouldResemble, []string{"network"})
+		})
+
+		Convey("DNS must be an IP address", func() {
+			req := valid()
+			req.DNS = "dns.example.com"
+
+			So(fields(ValidateRequest(req)), ShouldResemble, []string{"dns"})
+		})
+
+		Convey("Volume paths must be absolute", func() {
+			req := valid()
+			req.Volumes = []eremetic.Volume{
+				{ContainerPath: "/data", HostPath: "/mnt"},
+				{ContainerPath: "data", HostPath: ""},
+			}
+
+			So(fields(ValidateRequest(req)), ShouldResemble, []string{
+				"volumes[1].container_path",
+				"volumes[1].host_path",
+			})
+		})
+
+		Convey("Ports must be in range and use a known protocol", func() {
+			req := valid()
+			req.Ports = []eremetic.Port{{ContainerPort: 70000, Protocol: "sctp"}}
+
+			So(fields(ValidateRequest(req)), ShouldResemble, []string{
+				"ports[0].container_port",
+				"ports[0].protocol",
+			})
+		})
+
+		Convey("Port names and host ports must be unique", func() {
+			req := valid()
+			req.Ports = []eremetic.Port{
+				{Name: "http", HostPort: 31000},
+				{Name: "HTTP", HostPort: 31000},
+				{Name: "admin-port"},
+			}
+
+			So(fields(ValidateRequest(req)), ShouldResemble, []string{
+				"ports[1].name",
+				"ports[1].host_port",
+				"ports[2].name",
+			})
+		})
+
+		Convey("Environment names must be valid", func() {
+			req := valid()
+			req.Environment = map[string]string{"A=B": "c"}
+			req.MaskedEnvironment = map[string]string{"": "c"}
+
+			So(fields(ValidateRequest(req)), ShouldResemble, []string{"env", "masked_env"})
+		})
+
+		Convey("Callback URIs must be http urls", func() {
+			for _, u := range []string{"ftp://example.com", "example.com/callback", "http://"} {
+				req := valid()
+				req.CallbackURI = u
+
+				So(fields(ValidateRequest(req)), ShouldResemble, []string{"callback_uri"})
+			}
+		})
+
+		Convey("Fetch URIs must be absolute", func() {
+			req := valid()
+			req.URIs = []string{"file.tgz"}
+			req.Fetch = []eremetic.URI{{URI: ""}}
+
+			So(fields(ValidateRequest(req)), ShouldResemble, []string{"uris[0]", "fetch[0].uri"})
+		})
+	})
+}
@@ -0,0 +1,199 @@
+package zk
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/samuel/go-zookeeper/zk"
+	"github.com/sirupsen/logrus"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+// electionNode is the node below the database path holding the candidates
+// of the leader election.
+const electionNode = metaPrefix + "election"
+
+// Elector elects the leader of the Eremetic instances through ZooKeeper.
+// Every instance creates an ephemeral sequential node holding its address.
+// The instance with the lowest sequence number leads, the others watch the
+// node preceding theirs.
+type Elector struct {
+	conn    connection
+	path    string
+	address string
+
+	mtx    sync.Mutex
+	node   string
+	leader bool
+}
+
+// NewElector returns a new instance of a Zookeeper Elector. The location has
+// the same form as the one of the database, address is the address other
+// instances reach this one at.
+func NewElector(location, address string) (*Elector, error) {
+	return newCustomElector(defaultConnector{}, location, address)
+}
+
+func newCustomElector(c connector, location, address string) (*Elector, error) {
+	if location == "" {
+		return nil, errors.New("Missing ZK path")
+	}
+
+	servers, path, err := parsePath(location)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := c.Connect(servers)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Elector{
+		conn:    conn,
+		path:    path + "/" + electionNode,
+		address: address,
+	}
+	for _, p := range []string{path, e.path} {
+		exists, _, err := conn.Exists(p)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			continue
+		}
+		_, err = conn.Create(p, nil, int32(0), zk.WorldACL(zk.PermAll))
+		if err != nil && err != zk.ErrNodeExists {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Campaign registers this instance as a candidate and waits until all the
+// candidates registered before it are gone.
+func (e *Elector) Campaign(stop <-chan struct{}) (<-chan struct{}, error) {
+	e.mtx.Lock()
+	node := e.node
+	e.mtx.Unlock()
+
+	if node == "" {
+		created, err := e.conn.Create(e.path+"/candidate-", []byte(e.address), zk.FlagEphemeral|zk.FlagSequence, zk.WorldACL(zk.PermAll))
+		if err != nil {
+			return nil, err
+		}
+		node = strings.TrimPrefix(created, e.path+"/")
+
+		e.mtx.Lock()
+		e.node = node
+		e.mtx.Unlock()
+	}
+
+	for {
+		candidates, err := e.candidates()
+		if err != nil {
+			return nil, err
+		}
+
+		i := sort.SearchStrings(candidates, node)
+		if i == len(candidates) || candidates[i] != node {
+			return nil, errors.New("candidate node is gone")
+		}
+		if i == 0 {
+			return e.elected(node)
+		}
+
+		logrus.WithField("predecessor", candidates[i-1]).Debug("Waiting for the leadership")
+		exists, _, events, err := e.conn.ExistsW(e.path + "/" + candidates[i-1])
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		select {
+		case <-events:
+		case <-stop:
+			return nil, eremetic.ErrNotElected
+		}
+	}
+}
+
+// elected watches the node of the leader, whose removal means the
+// leadership is lost.
+func (e *Elector) elected(node string) (<-chan struct{}, error) {
+	exists, _, events, err := e.conn.ExistsW(e.path + "/" + node)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("candidate node is gone")
+	}
+
+	e.mtx.Lock()
+	e.leader = true
+	e.mtx.Unlock()
+
+	lost := make(chan struct{})
+	go func() {
+		ev := <-events
+		logrus.WithField("event", ev.Type.String()).Warn("Lost the leadership")
+
+		e.mtx.Lock()
+		e.leader = false
+		e.mtx.Unlock()
+		close(lost)
+	}()
+	return lost, nil
+}
+
+func (e *Elector) candidates() ([]string, error) {
+	children, _, err := e.conn.Children(e.path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(children)
+	return children, nil
+}
+
+// IsLeader returns whether this instance is currently the leader.
+func (e *Elector) IsLeader() bool {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.leader
+}
+
+// Leader returns the address of the candidate with the lowest sequence
+// number.
+func (e *Elector) Leader() (string, error) {
+	candidates, err := e.candidates()
+	if err != nil || len(candidates) == 0 {
+		return "", err
+	}
+	address, _, err := e.conn.Get(e.path + "/" + candidates[0])
+	if err == zk.ErrNoNode {
+		return "", nil
+	}
+	return string(address), err
+}
+
+// Close removes the candidate node of this instance and closes the
+// connection.
+func (e *Elector) Close() {
+	e.mtx.Lock()
+	node := e.node
+	e.node = ""
+	e.leader = false
+	e.mtx.Unlock()
+
+	if node != "" {
+		if err := e.conn.Delete(e.path+"/"+node, -1); err != nil {
+			logrus.WithError(err).Warn("Unable to remove the candidate node")
+		}
+	}
+	e.conn.Close()
+}
@@ -0,0 +1,128 @@
+package zk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/samuel/go-zookeeper/zk"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/eremetic-framework/eremetic"
+)
+
+func TestZKElector(t *testing.T) {
+	var (
+		e      *Elector
+		object *mockConnection
+	)
+
+	const path = "/testdb/_election"
+
+	setup := func() {
+		object = new(mockConnection)
+		e = &Elector{
+			conn:    object,
+			path:    path,
+			address: "http://eremetic-1:8080",
+		}
+		object.On("Create", path+"/candidate-", []byte("http://eremetic-1:8080"), int32(zk.FlagEphemeral|zk.FlagSequence), mock.Anything).
+			Return(path+"/candidate-0000000002", nil)
+	}
+
+	Convey("newCustomElector", t, func() {
+		Convey("Creates the election node", func() {
+			conn := new(mockConnection)
+			connector := new(mockConnector)
+			connector.On("Connect", "localhost:1234").Return(conn, nil)
+			conn.On("Exists", "/testdb").Return(true, &zk.Stat{}, nil)
+			conn.On("Exists", path).Return(false, &zk.Stat{}, nil)
+			conn.On("Create", path, []byte(nil), int32(0), mock.Anything).Return(path, nil)
+
+			e, err := newCustomElector(connector, "zk://localhost:1234/testdb", "http://eremetic-1:8080")
+			So(err, ShouldBeNil)
+			So(e.path, ShouldEqual, path)
+			So(conn.AssertCalled(t, "Create", path, []byte(nil), int32(0), mock.Anything), ShouldBeTrue)
+		})
+
+		Convey("Missing path", func() {
+			_, err := newCustomElector(new(mockConnector), "", "")
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Campaign", t, func() {
+		Convey("Is elected without predecessor", func() {
+			setup()
+			watch := make(chan zk.Event, 1)
+			object.On("Children", path).Return([]string{"candidate-0000000002"}, &zk.Stat{}, nil)
+			object.On("ExistsW", path+"/candidate-0000000002").Return(true, &zk.Stat{}, (<-chan zk.Event)(watch), nil)
+
+			lost, err := e.Campaign(make(chan struct{}))
+			So(err, ShouldBeNil)
+			So(e.IsLeader(), ShouldBeTrue)
+
+			Convey("And loses the leadership when its node goes away", func() {
+				watch <- zk.Event{Type: zk.EventNodeDeleted}
+				<-lost
+				So(e.IsLeader(), ShouldBeFalse)
+			})
+		})
+
+		Convey("Waits for its predecessor", func() {
+			setup()
+			predecessor := make(chan zk.Event, 1)
+			object.On("Children", path).Return([]string{"candidate-0000000002", "candidate-0000000001"}, &zk.Stat{}, nil).Once()
+			object.On("Children", path).Return([]string{"candidate-0000000002"}, &zk.Stat{}, nil)
+			object.On("ExistsW", path+"/candidate-0000000001").Return(true, &zk.Stat{}, (<-chan zk.Event)(predecessor), nil)
+			object.On("ExistsW", path+"/candidate-0000000002").Return(true, &zk.Stat{}, (<-chan zk.Event)(make(chan zk.Event)), nil)
+
+			predecessor <- zk.Event{Type: zk.EventNodeDeleted}
+			_, err := e.Campaign(make(chan struct{}))
+			So(err, ShouldBeNil)
+			So(e.IsLeader(), ShouldBeTrue)
+			So(object.AssertNumberOfCalls(t, "Children", 2), ShouldBeTrue)
+		})
+
+		Convey("Stops campaigning", func() {
+			setup()
+			object.On("Children", path).Return([]string{"candidate-0000000001", "candidate-0000000002"}, &zk.Stat{}, nil)
+			object.On("ExistsW", path+"/candidate-0000000001").Return(true, &zk.Stat{}, (<-chan zk.Event)(make(chan zk.Event)), nil)
+
+			stop := make(chan struct{})
+			close(stop)
+			_, err := e.Campaign(stop)
+			So(err, ShouldEqual, eremetic.ErrNotElected)
+			So(e.IsLeader(), ShouldBeFalse)
+		})
+
+		Convey("Reports connection errors", func() {
+			setup()
+			object.On("Children", path).Return(nil, nil, errors.New("Bad Connection"))
+
+			_, err := e.Campaign(make(chan struct{}))
+			So(err.Error(), ShouldEqual, "Bad Connection")
+		})
+	})
+
+	Convey("Leader", t, func() {
+		setup()
+		object.On("Children", path).Return([]string{"candidate-0000000003", "candidate-0000000001"}, &zk.Stat{}, nil)
+		object.On("Get", path+"/candidate-0000000001").Return([]byte("http://eremetic-0:8080"), &zk.Stat{}, nil)
+
+		leader, err := e.Leader()
+		So(err, ShouldBeNil)
+		So(leader, ShouldEqual, "http://eremetic-0:8080")
+	})
+
+	Convey("Close", t, func() {
+		setup()
+		e.node = "candidate-0000000002"
+		object.On("Delete", path+"/candidate-0000000002", int32(-1)).Return(nil)
+		object.On("Close").Return()
+
+		e.Close()
+		So(object.AssertCalled(t, "Delete", path+"/candidate-0000000002", int32(-1)), ShouldBeTrue)
+		So(object.AssertCalled(t, "Close"), ShouldBeTrue)
+	})
+}
@@ -112,6 +112,45 @@ func (_m *mockConnection) Exists(path string) (bool, *zk.Stat, error) {
 	return r0, r1, r2
 }
 
+// ExistsW provides a mock function with given fields: path
+func (_m *mockConnection) ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error) {
+	ret := _m.Called(path)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(path)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 *zk.Stat
+	if rf, ok := ret.Get(1).(func(string) *zk.Stat); ok {
+		r1 = rf(path)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*zk.Stat)
+		}
+	}
+
+	var r2 <-chan zk.Event
+	if rf, ok := ret.Get(2).(func(string) <-chan zk.Event); ok {
+		r2 = rf(path)
+	} else {
+		if ret.Get(2) != nil {
+			r2 = ret.Get(2).(<-chan zk.Event)
+		}
+	}
+
+	var r3 error
+	if rf, ok := ret.Get(3).(func(string) error); ok {
+		r3 = rf(path)
+	} else {
+		r3 = ret.Error(3)
+	}
+
+	return r0, r1, r2, r3
+}
+
 // Get provides a mock function with given fields: path
 func (_m *mockConnection) Get(path string) ([]byte, *zk.Stat, error) {
 	ret := _m.Called(path)
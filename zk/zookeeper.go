@@ -20,6 +20,7 @@ type connection interface {
 	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
 	Delete(path string, n int32) error
 	Exists(path string) (bool, *zk.Stat, error)
+	ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error)
 	Get(path string) ([]byte, *zk.Stat, error)
 	Set(path string, data []byte, version int32) (*zk.Stat, error)
 	Children(path string) ([]string, *zk.Stat, error)
@@ -30,6 +31,9 @@ type connector interface {
 	Connect(path string) (connection, error)
 }
 
+// metaPrefix marks nodes below the database path that do not hold tasks.
+const metaPrefix = "_"
+
 // TaskDB is a Zookeeper implementation of the task database.
 type TaskDB struct {
 	conn connection
@@ -135,6 +139,49 @@ func (z *TaskDB) PutTask(task *eremetic.Task) error {
 	return err
 }
 
+// UpdateTask applies fn to a task and sets it with the version it was read
+// at, retrying when the node has been changed or created in between.
+func (z *TaskDB) UpdateTask(id string, fn func(*eremetic.Task) error) error {
+	path := fmt.Sprintf("%s/%s", z.path, id)
+
+	return eremetic.RetryOnConflict(func() error {
+		task := eremetic.Task{ID: id}
+
+		bytes, stat, err := z.conn.Get(path)
+		exists := err != zk.ErrNoNode
+		if exists {
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(bytes, &task); err != nil {
+				return err
+			}
+		}
+
+		if err := fn(&task); err != nil {
+			return err
+		}
+		task.Revision++
+
+		encode, err := eremetic.Encode(&task)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to encode task to byte-array.")
+			return err
+		}
+
+		if exists {
+			_, err = z.conn.Set(path, encode, stat.Version)
+		} else {
+			_, err = z.conn.Create(path, encode, int32(0), zk.WorldACL(zk.PermAll))
+		}
+		if err == zk.ErrBadVersion || err == zk.ErrNodeExists {
+			logrus.WithField("task_id", id).Debug("Task was modified concurrently, retrying")
+			return eremetic.ErrConflict
+		}
+		return err
+	})
+}
+
 // ReadTask returns a task with a given id, or an error if not found.
 func (z *TaskDB) ReadTask(id string) (eremetic.Task, error) {
 	task, err := z.ReadUnmaskedTask(id)
@@ -174,20 +221,268 @@ func (z *TaskDB) DeleteTask(id string) error {
 	return err
 }
 
-// ListTasks returns all tasks.
+// ListTasks returns the page of tasks matching the filter.
 func (z *TaskDB) ListTasks(filter *eremetic.TaskFilter) ([]*eremetic.Task, error) {
-	tasks := []*eremetic.Task{}
+	c, err := eremetic.NewTaskCollector(filter)
+	if err != nil {
+		return nil, err
+	}
 	paths, _, _ := z.conn.Children(z.path)
 	for _, p := range paths {
+		if strings.HasPrefix(p, metaPrefix) {
+			continue
+		}
 		t, err := z.ReadTask(p)
 		if err != nil {
 			logrus.WithError(err).Error("Unable to read task from database, skipping")
 			continue
 		}
 		eremetic.ApplyMask(&t)
-		if filter.Match(&t) {
-			tasks = append(tasks, &t)
+		c.Add(&t)
+	}
+	return c.Tasks(), nil
+}
+
+// ScanTasks calls fn with the stored document of every task.
+func (z *TaskDB) ScanTasks(fn func(id string, data []byte) error) error {
+	paths, _, err := z.conn.Children(z.path)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if strings.HasPrefix(p, metaPrefix) {
+			continue
+		}
+		data, _, err := z.conn.Get(fmt.Sprintf("%s/%s", z.path, p))
+		if err == zk.ErrNoNode {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(p, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (z *TaskDB) quotasPath() string {
+	return fmt.Sprintf("%s/%squotas", z.path, metaPrefix)
+}
+
+func (z *TaskDB) quotaPath(name string) string {
+	return fmt.Sprintf("%s/%s", z.quotasPath(), name)
+}
+
+func (z *TaskDB) templatesPath() string {
+	return fmt.Sprintf("%s/%stemplates", z.path, metaPrefix)
+}
+
+func (z *TaskDB) templatePath(name string) string {
+	return fmt.Sprintf("%s/%s", z.templatesPath(), name)
+}
+
+// ensureNode creates the node at path unless it already exists.
+func (z *TaskDB) ensureNode(path string) error {
+	exists, _, err := z.conn.Exists(path)
+	if err != nil || exists {
+		return err
+	}
+	_, err = z.conn.Create(path, nil, int32(0), zk.WorldACL(zk.PermAll))
+	if err == zk.ErrNodeExists {
+		return nil
+	}
+	return err
+}
+
+// PutQuota adds or replaces a quota in the database.
+func (z *TaskDB) PutQuota(quota *eremetic.Quota) error {
+	encoded, err := json.Marshal(quota)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to encode quota to byte-array.")
+		return err
+	}
+
+	if err := z.ensureNode(z.quotasPath()); err != nil {
+		return err
+	}
+
+	return z.putNode(z.quotaPath(quota.Name), encoded)
+}
+
+// putNode sets the data of the node at path, creating it if needed.
+func (z *TaskDB) putNode(path string, data []byte) error {
+	exists, stat, err := z.conn.Exists(path)
+	if err != nil {
+		return err
+	}
+	if exists {
+		_, err = z.conn.Set(path, data, stat.Version)
+		return err
+	}
+
+	_, err = z.conn.Create(path, data, int32(0), zk.WorldACL(zk.PermAll))
+	return err
+}
+
+// ReadQuota returns a quota with a given name, or an error if not found.
+func (z *TaskDB) ReadQuota(name string) (eremetic.Quota, error) {
+	var quota eremetic.Quota
+
+	bytes, _, err := z.conn.Get(z.quotaPath(name))
+	if err != nil {
+		return quota, err
+	}
+	err = json.Unmarshal(bytes, &quota)
+	return quota, err
+}
+
+// DeleteQuota deletes a quota with the matching name from zookeeper
+func (z *TaskDB) DeleteQuota(name string) error {
+	return z.conn.Delete(z.quotaPath(name), -1)
+}
+
+// ListQuotas returns all quotas.
+func (z *TaskDB) ListQuotas() ([]*eremetic.Quota, error) {
+	quotas := []*eremetic.Quota{}
+	names, _, err := z.conn.Children(z.quotasPath())
+	if err == zk.ErrNoNode {
+		return quotas, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		q, err := z.ReadQuota(name)
+		if err != nil {
+			logrus.WithError(err).WithField("quota", name).Error("Unable to read quota from database, skipping")
+			continue
+		}
+		quotas = append(quotas, &q)
+	}
+	return quotas, nil
+}
+
+// PutTemplate adds or replaces a template in the database.
+func (z *TaskDB) PutTemplate(template *eremetic.Template) error {
+	encoded, err := json.Marshal(template)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to encode template to byte-array.")
+		return err
+	}
+
+	if err := z.ensureNode(z.templatesPath()); err != nil {
+		return err
+	}
+
+	return z.putNode(z.templatePath(template.Name), encoded)
+}
+
+// UpdateTemplate applies fn to a template and writes it if the version of
+// its node is still the one that was read, retrying otherwise.
+func (z *TaskDB) UpdateTemplate(name string, fn func(*eremetic.Template) error) error {
+	if err := z.ensureNode(z.templatesPath()); err != nil {
+		return err
+	}
+	path := z.templatePath(name)
+
+	return eremetic.RetryOnConflict(func() error {
+		template := eremetic.Template{Name: name}
+
+		bytes, stat, err := z.conn.Get(path)
+		exists := err != zk.ErrNoNode
+		if exists {
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(bytes, &template); err != nil {
+				return err
+			}
+		}
+
+		if err := fn(&template); err != nil {
+			return err
 		}
+
+		encoded, err := json.Marshal(template)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to encode template to byte-array.")
+			return err
+		}
+
+		if exists {
+			_, err = z.conn.Set(path, encoded, stat.Version)
+		} else {
+			_, err = z.conn.Create(path, encoded, int32(0), zk.WorldACL(zk.PermAll))
+		}
+		if err == zk.ErrBadVersion || err == zk.ErrNodeExists {
+			logrus.WithField("template", name).Debug("Template was modified concurrently, retrying")
+			return eremetic.ErrConflict
+		}
+		return err
+	})
+}
+
+// ReadTemplate returns a template with a given name, or an error if not found.
+func (z *TaskDB) ReadTemplate(name string) (eremetic.Template, error) {
+	var template eremetic.Template
+
+	bytes, _, err := z.conn.Get(z.templatePath(name))
+	if err != nil {
+		return template, err
+	}
+	err = json.Unmarshal(bytes, &template)
+	return template, err
+}
+
+// DeleteTemplate deletes a template with the matching name from zookeeper
+func (z *TaskDB) DeleteTemplate(name string) error {
+	return z.conn.Delete(z.templatePath(name), -1)
+}
+
+// ListTemplates returns all templates.
+func (z *TaskDB) ListTemplates() ([]*eremetic.Template, error) {
+	templates := []*eremetic.Template{}
+	names, _, err := z.conn.Children(z.templatesPath())
+	if err == zk.ErrNoNode {
+		return templates, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		t, err := z.ReadTemplate(name)
+		if err != nil {
+			logrus.WithError(err).WithField("template", name).Error("Unable to read template from database, skipping")
+			continue
+		}
+		templates = append(templates, &t)
+	}
+	return templates, nil
+}
+
+func (z *TaskDB) frameworkIDPath() string {
+	return fmt.Sprintf("%s/%sframework_id", z.path, metaPrefix)
+}
+
+// PutFrameworkID stores the framework ID in its own node.
+func (z *TaskDB) PutFrameworkID(id string) error {
+	if id == "" {
+		err := z.conn.Delete(z.frameworkIDPath(), -1)
+		if err == zk.ErrNoNode {
+			return nil
+		}
+		return err
+	}
+	return z.putNode(z.frameworkIDPath(), []byte(id))
+}
+
+// ReadFrameworkID returns the stored framework ID.
+func (z *TaskDB) ReadFrameworkID() (string, error) {
+	id, _, err := z.conn.Get(z.frameworkIDPath())
+	if err == zk.ErrNoNode {
+		return "", nil
 	}
-	return tasks, nil
+	return string(id), err
 }
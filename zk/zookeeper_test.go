@@ -1,6 +1,7 @@
 package zk
 
 import (
+	"encoding/json"
 	"errors"
 	"strings"
 	"testing"
@@ -196,6 +197,73 @@ func TestZKDatabase(t *testing.T) {
 		})
 	})
 
+	Convey("UpdateTask", t, func() {
+		update := func(t *eremetic.Task) error {
+			t.Name = "updated"
+			return nil
+		}
+		updated := func(data []byte) bool {
+			var t eremetic.Task
+			json.Unmarshal(data, &t)
+			return t.ID == "1234" && t.Name == "updated" && t.Revision == 1
+		}
+
+		Convey("Sets the version that was read", func() {
+			setup()
+			defer teardown()
+
+			object.On("Get", "/testdb/1234").Return(taskBytes, &zk.Stat{Version: 3}, nil)
+			object.On("Set", "/testdb/1234", mock.MatchedBy(updated), int32(3)).Return(&zk.Stat{}, nil)
+
+			So(db.UpdateTask(task.ID, update), ShouldBeNil)
+			So(object.AssertNumberOfCalls(t, "Set", 1), ShouldBeTrue)
+		})
+
+		Convey("Creates missing tasks", func() {
+			setup()
+			defer teardown()
+
+			object.On("Get", "/testdb/1234").Return(nil, nil, zk.ErrNoNode)
+			object.On("Create", "/testdb/1234", mock.MatchedBy(updated), int32(0), mock.Anything).Return("", nil)
+
+			So(db.UpdateTask(task.ID, update), ShouldBeNil)
+		})
+
+		Convey("Retries on version conflicts", func() {
+			setup()
+			defer teardown()
+
+			object.On("Get", "/testdb/1234").Return(taskBytes, &zk.Stat{Version: 3}, nil)
+			object.On("Set", "/testdb/1234", mock.Anything, int32(3)).Return(nil, zk.ErrBadVersion).Once()
+			object.On("Set", "/testdb/1234", mock.Anything, int32(3)).Return(&zk.Stat{}, nil).Once()
+
+			So(db.UpdateTask(task.ID, update), ShouldBeNil)
+			So(object.AssertNumberOfCalls(t, "Get", 2), ShouldBeTrue)
+		})
+
+		Convey("Gives up after UpdateAttempts conflicts", func() {
+			setup()
+			defer teardown()
+
+			object.On("Get", "/testdb/1234").Return(taskBytes, &zk.Stat{Version: 3}, nil)
+			object.On("Set", "/testdb/1234", mock.Anything, int32(3)).Return(nil, zk.ErrBadVersion)
+
+			So(db.UpdateTask(task.ID, update), ShouldEqual, eremetic.ErrConflict)
+			So(object.AssertNumberOfCalls(t, "Set", eremetic.UpdateAttempts), ShouldBeTrue)
+		})
+
+		Convey("Doesn't write when fn fails", func() {
+			setup()
+			defer teardown()
+
+			object.On("Get", "/testdb/1234").Return(taskBytes, &zk.Stat{Version: 3}, nil)
+
+			err := db.UpdateTask(task.ID, func(*eremetic.Task) error { return errors.New("nope") })
+			So(err.Error(), ShouldEqual, "nope")
+			So(object.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything), ShouldBeTrue)
+		})
+	})
+
 	Convey("DeleteTask", t, func() {
 		Convey("Success", func() {
 			setup()
@@ -339,6 +407,12 @@ func TestZKDatabase(t *testing.T) {
 				So(list, ShouldHaveLength, 0)
 
 			})
+			Convey("Invalid cursor", func() {
+				_, err := db.ListTasks(&eremetic.TaskFilter{
+					Cursor: "nope",
+				})
+				So(err, ShouldEqual, eremetic.ErrInvalidCursor)
+			})
 		})
 
 		Convey("Error", func() {
@@ -358,6 +432,190 @@ func TestZKDatabase(t *testing.T) {
 		})
 	})
 
+	Convey("Quotas", t, func() {
+		quota := &eremetic.Quota{Name: "team", Owner: "alice", MaxRunning: 2}
+		quotaBytes, _ := json.Marshal(quota)
+
+		Convey("PutQuota creates the quota node", func() {
+			setup()
+			defer teardown()
+
+			object.On("Exists", "/testdb/_quotas").Return(true, &zk.Stat{}, nil)
+			object.On("Exists", "/testdb/_quotas/team").Return(false, &zk.Stat{}, nil)
+			object.On("Create", mock.AnythingOfType("string"), mock.Anything, mock.AnythingOfType("int32"), mock.Anything).Return("", nil)
+
+			err := db.PutQuota(quota)
+
+			So(err, ShouldBeNil)
+			So(object.AssertCalled(t, "Create", "/testdb/_quotas/team", quotaBytes, mock.AnythingOfType("int32"), mock.Anything), ShouldBeTrue)
+		})
+
+		Convey("ListQuotas", func() {
+			setup()
+			defer teardown()
+
+			object.On("Children", "/testdb/_quotas").Return([]string{"team"}, nil, nil)
+			object.On("Get", "/testdb/_quotas/team").Return(quotaBytes, &zk.Stat{}, nil)
+
+			quotas, err := db.ListQuotas()
+
+			So(err, ShouldBeNil)
+			So(quotas, ShouldHaveLength, 1)
+			So(quotas[0], ShouldResemble, quota)
+		})
+
+		Convey("ListQuotas without quotas", func() {
+			setup()
+			defer teardown()
+
+			object.On("Children", "/testdb/_quotas").Return([]string{}, nil, zk.ErrNoNode)
+
+			quotas, err := db.ListQuotas()
+
+			So(err, ShouldBeNil)
+			So(quotas, ShouldBeEmpty)
+		})
+
+		Convey("ListTasks skips the quota node", func() {
+			setup()
+			defer teardown()
+
+			object.On("Children", "/testdb").Return([]string{"1234", "_quotas"}, nil, nil)
+			object.On("Get", "/testdb/1234").Return(taskBytes, &zk.Stat{}, nil)
+
+			list, err := db.ListTasks(&eremetic.TaskFilter{})
+
+			So(err, ShouldBeNil)
+			So(list, ShouldHaveLength, 1)
+			So(object.AssertNotCalled(t, "Get", "/testdb/_quotas"), ShouldBeTrue)
+		})
+	})
+
+	Convey("Templates", t, func() {
+		template := &eremetic.Template{Name: "nightly-etl", Version: 2}
+		templateBytes, _ := json.Marshal(template)
+
+		Convey("PutTemplate creates the parent node", func() {
+			setup()
+			defer teardown()
+
+			object.On("Exists", "/testdb/_templates").Return(false, &zk.Stat{}, nil)
+			object.On("Exists", "/testdb/_templates/nightly-etl").Return(true, &zk.Stat{Version: 1}, nil)
+			object.On("Create", mock.AnythingOfType("string"), mock.Anything, mock.AnythingOfType("int32"), mock.Anything).Return("", nil)
+			object.On("Set", mock.Anything, mock.Anything, mock.Anything).Return(&zk.Stat{}, nil)
+
+			err := db.PutTemplate(template)
+
+			So(err, ShouldBeNil)
+			So(object.AssertCalled(t, "Create", "/testdb/_templates", []byte(nil), mock.AnythingOfType("int32"), mock.Anything), ShouldBeTrue)
+			So(object.AssertCalled(t, "Set", "/testdb/_templates/nightly-etl", templateBytes, int32(1)), ShouldBeTrue)
+		})
+
+		Convey("ReadTemplate", func() {
+			setup()
+			defer teardown()
+
+			object.On("Get", "/testdb/_templates/nightly-etl").Return(templateBytes, &zk.Stat{}, nil)
+
+			tpl, err := db.ReadTemplate("nightly-etl")
+
+			So(err, ShouldBeNil)
+			So(tpl, ShouldResemble, *template)
+		})
+
+		Convey("UpdateTemplate", func() {
+			revise := func(t *eremetic.Template) error {
+				t.Revise(eremetic.Request{DockerImage: "etl:2.0"})
+				return nil
+			}
+			revised := func(data []byte) bool {
+				var t eremetic.Template
+				json.Unmarshal(data, &t)
+				return t.Name == "nightly-etl" && t.Version == 3 && len(t.History) == 1
+			}
+
+			Convey("Sets the version that was read", func() {
+				setup()
+				defer teardown()
+
+				object.On("Exists", "/testdb/_templates").Return(true, &zk.Stat{}, nil)
+				object.On("Get", "/testdb/_templates/nightly-etl").Return(templateBytes, &zk.Stat{Version: 4}, nil)
+				object.On("Set", "/testdb/_templates/nightly-etl", mock.MatchedBy(revised), int32(4)).Return(&zk.Stat{}, nil)
+
+				So(db.UpdateTemplate("nightly-etl", revise), ShouldBeNil)
+				So(object.AssertNumberOfCalls(t, "Set", 1), ShouldBeTrue)
+			})
+
+			Convey("Retries on version conflicts", func() {
+				setup()
+				defer teardown()
+
+				object.On("Exists", "/testdb/_templates").Return(true, &zk.Stat{}, nil)
+				object.On("Get", "/testdb/_templates/nightly-etl").Return(templateBytes, &zk.Stat{Version: 4}, nil)
+				object.On("Set", "/testdb/_templates/nightly-etl", mock.Anything, int32(4)).Return(nil, zk.ErrBadVersion).Once()
+				object.On("Set", "/testdb/_templates/nightly-etl", mock.Anything, int32(4)).Return(&zk.Stat{}, nil).Once()
+
+				So(db.UpdateTemplate("nightly-etl", revise), ShouldBeNil)
+				So(object.AssertNumberOfCalls(t, "Get", 2), ShouldBeTrue)
+			})
+
+			Convey("Creates missing templates", func() {
+				setup()
+				defer teardown()
+
+				object.On("Exists", "/testdb/_templates").Return(true, &zk.Stat{}, nil)
+				object.On("Get", "/testdb/_templates/etl").Return(nil, nil, zk.ErrNoNode)
+				object.On("Create", "/testdb/_templates/etl", mock.Anything, int32(0), mock.Anything).Return("", nil)
+
+				So(db.UpdateTemplate("etl", revise), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Framework ID", t, func() {
+		Convey("PutFrameworkID", func() {
+			setup()
+			defer teardown()
+
+			object.On("Exists", "/testdb/_framework_id").Return(false, &zk.Stat{}, nil)
+			object.On("Create", mock.AnythingOfType("string"), mock.Anything, mock.AnythingOfType("int32"), mock.Anything).Return("", nil)
+
+			So(db.PutFrameworkID("1234-0001"), ShouldBeNil)
+			So(object.AssertCalled(t, "Create", "/testdb/_framework_id", []byte("1234-0001"), mock.AnythingOfType("int32"), mock.Anything), ShouldBeTrue)
+		})
+
+		Convey("PutFrameworkID removes an empty ID", func() {
+			setup()
+			defer teardown()
+
+			object.On("Delete", "/testdb/_framework_id", int32(-1)).Return(zk.ErrNoNode)
+
+			So(db.PutFrameworkID(""), ShouldBeNil)
+		})
+
+		Convey("ReadFrameworkID", func() {
+			setup()
+			defer teardown()
+
+			object.On("Get", "/testdb/_framework_id").Return([]byte("1234-0001"), &zk.Stat{}, nil)
+
+			id, err := db.ReadFrameworkID()
+			So(err, ShouldBeNil)
+			So(id, ShouldEqual, "1234-0001")
+		})
+
+		Convey("ReadFrameworkID without ID", func() {
+			setup()
+			defer teardown()
+
+			object.On("Get", "/testdb/_framework_id").Return(nil, nil, zk.ErrNoNode)
+
+			id, err := db.ReadFrameworkID()
+			So(err, ShouldBeNil)
+			So(id, ShouldBeEmpty)
+		})
+	})
+
 	Convey("parsePath", t, func() {
 		masters := make(map[string]string)
 		masters["master1.local:1111,master2.local:1111,master3.local:1111"] =